@@ -1,14 +1,29 @@
 package main
 
 import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"dragonbytelabs/dz/internal/config"
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/importer"
+	"dragonbytelabs/dz/internal/lint"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/publish"
+	"dragonbytelabs/dz/internal/theme"
+	"dragonbytelabs/dz/internal/vault"
 )
 
 func main() {
@@ -22,6 +37,12 @@ func main() {
 	switch command {
 	case "theme":
 		handleTheme(os.Args[2:])
+	case "publish":
+		handlePublish(os.Args[2:])
+	case "vault":
+		handleVault(os.Args[2:])
+	case "import":
+		handleImport(os.Args[2:])
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -39,9 +60,561 @@ func printUsage() {
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  theme add <source>  Add a theme from a git URL or local path")
+	fmt.Println("  publish [flags]     Export notes marked publish: true as a static site")
+	fmt.Println("  vault check [flags] Check a vault for broken links, bad frontmatter and more")
+	fmt.Println("  vault export|import Back up a vault as a zip, or restore one")
+	fmt.Println("  vault rekey [flags] Encrypt a vault, change its password or decrypt it")
+	fmt.Println("  import <tool> <src> Import an Obsidian vault, Logseq graph or Notion export")
 	fmt.Println("  help                Show this help message")
 }
 
+func handlePublish(args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	vaultPath := fs.String("vault", cfg.Content.VaultPath, "vault to publish")
+	out := fs.String("out", "public", "output directory")
+	title := fs.String("title", cfg.App.Name, "site title")
+	themeName := fs.String("theme", "", "theme to render with (default: the site's active theme)")
+	baseURL := fs.String("base-url", cfg.Site.URL, "public URL of the site, for absolute links in feeds")
+	feedContent := fs.String("feed-content", cfg.Site.FeedContent, "what feed entries carry: full or excerpt")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dz publish [flags]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Writes every note with `publish: true` in its frontmatter as a static HTML site,")
+		fmt.Fprintln(os.Stderr, "with RSS, Atom and JSON feeds for the site, each tag and each folder.")
+		fmt.Fprintln(os.Stderr, "")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *themeName == "" {
+		*themeName = activeTheme(cfg)
+	}
+	th, err := theme.Open(cfg.Content.ThemesPath, *themeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading theme: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := publish.Options{
+		Title:       *title,
+		Theme:       th,
+		BaseURL:     *baseURL,
+		FeedContent: *feedContent,
+		FeedLimit:   cfg.Site.FeedLimit,
+	}
+	if err := publishVault(*vaultPath, *out, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error publishing: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// activeTheme returns the theme picked in the server's site settings, or
+// the built-in theme when there is no database yet
+func activeTheme(cfg *config.Config) string {
+	appDir, err := config.AppDir("deez")
+	if err != nil {
+		return theme.DefaultName
+	}
+	dbPath := config.ResolveInAppDir(appDir, cfg.Database.Path)
+	if _, err := os.Stat(dbPath); err != nil {
+		return theme.DefaultName
+	}
+	db, err := dbx.OpenSQLite(dbPath)
+	if err != nil {
+		return theme.DefaultName
+	}
+	defer db.Close()
+
+	name, err := db.GetSiteSetting(context.Background(), models.SettingActiveTheme)
+	if err != nil || name == "" {
+		return theme.DefaultName
+	}
+	return name
+}
+
+// publishVault renders the published notes of the vault at vaultPath into out
+func publishVault(vaultPath, out string, opts publish.Options) error {
+	if info, err := os.Stat(vaultPath); err != nil || !info.IsDir() {
+		return fmt.Errorf("vault directory does not exist: %s", vaultPath)
+	}
+	v, err := vault.New(vaultPath)
+	if err != nil {
+		return fmt.Errorf("failed to open vault: %w", err)
+	}
+
+	ctx := context.Background()
+	site, err := publish.Build(ctx, v, opts)
+	if err != nil {
+		return err
+	}
+	if err := site.Write(ctx, out); err != nil {
+		return err
+	}
+
+	fmt.Printf("Published %d notes to %s\n", len(site.Pages), out)
+	return nil
+}
+
+func handleVault(args []string) {
+	if len(args) < 1 {
+		printVaultUsage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "check":
+		handleVaultCheck(cfg, args[1:])
+	case "export":
+		handleVaultExport(cfg, args[1:])
+	case "import":
+		handleVaultImport(cfg, args[1:])
+	case "rekey":
+		handleVaultRekey(cfg, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown vault subcommand: %s\n", args[0])
+		printVaultUsage()
+		os.Exit(1)
+	}
+}
+
+func printVaultUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: dz vault <subcommand>")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Subcommands:")
+	fmt.Fprintln(os.Stderr, "  check [flags]         Check a vault for problems, optionally fixing them")
+	fmt.Fprintln(os.Stderr, "  export [flags]        Back up a vault as a zip with its manifest and file hashes")
+	fmt.Fprintln(os.Stderr, "  import [flags] <zip>  Restore or merge a vault zip")
+	fmt.Fprintln(os.Stderr, "  rekey [flags]         Encrypt a vault, change its password or decrypt it")
+}
+
+func handleVaultCheck(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("vault check", flag.ExitOnError)
+	vaultPath := fs.String("vault", cfg.Content.VaultPath, "vault to check")
+	fix := fs.Bool("fix", false, "repair what is safely fixable: missing ids and created dates, duplicate ids and stale temp files")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dz vault check [flags]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Reports missing or invalid frontmatter, duplicate ids, broken and ambiguous links,")
+		fmt.Fprintln(os.Stderr, "orphan notes, stray .tmp files and paths that differ only in case. Exits with")
+		fmt.Fprintln(os.Stderr, "status 1 if errors remain.")
+		fmt.Fprintln(os.Stderr, "")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	ok, err := checkVault(*vaultPath, *fix, *asJSON, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error checking vault: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// checkVault checks the vault at vaultPath, fixing what it can when fix is
+// set, and writes the report to w. It reports whether no errors remain.
+func checkVault(vaultPath string, fix, asJSON bool, w io.Writer) (bool, error) {
+	if info, err := os.Stat(vaultPath); err != nil || !info.IsDir() {
+		return false, fmt.Errorf("vault directory does not exist: %s", vaultPath)
+	}
+	v, err := vault.New(vaultPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open vault: %w", err)
+	}
+
+	ctx := context.Background()
+	report, err := lint.Check(ctx, v)
+	if err != nil {
+		return false, err
+	}
+	if fix {
+		report.Fix(ctx)
+	}
+
+	remaining := 0
+	for _, is := range report.Issues {
+		if is.Severity == lint.SeverityError && !is.Fixed {
+			remaining++
+		}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return remaining == 0, enc.Encode(report)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, is := range report.Issues {
+		status := ""
+		switch {
+		case is.Fixed:
+			status = "fixed"
+		case is.FixError != "":
+			status = "fix failed: " + is.FixError
+		case is.Fixable:
+			status = "fixable"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", is.Severity, is.Kind, is.Path, is.Message, status)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d notes, %d files: %d errors, %d warnings", report.Notes, report.Files, report.Errors, report.Warnings)
+	if fix {
+		fmt.Fprintf(w, ", %d fixed", report.Fixed)
+	}
+	fmt.Fprintln(w)
+	return remaining == 0, nil
+}
+
+func handleVaultExport(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("vault export", flag.ExitOnError)
+	vaultPath := fs.String("vault", cfg.Content.VaultPath, "vault to export")
+	out := fs.String("out", "", "zip to write (default: <vault name>-<date>.zip)")
+	fs.Parse(args)
+
+	if err := exportVault(*vaultPath, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting vault: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// exportVault writes the vault at vaultPath as a zip to out
+func exportVault(vaultPath, out string) error {
+	if info, err := os.Stat(vaultPath); err != nil || !info.IsDir() {
+		return fmt.Errorf("vault directory does not exist: %s", vaultPath)
+	}
+	v, err := vault.New(vaultPath)
+	if err != nil {
+		return fmt.Errorf("failed to open vault: %w", err)
+	}
+	if out == "" {
+		name := strings.NewReplacer("/", "-", `\`, "-").Replace(v.Manifest().Name)
+		out = fmt.Sprintf("%s-%s.zip", name, time.Now().Format("2006-01-02"))
+	}
+
+	// Write next to the destination and rename, so a failed export never
+	// leaves a truncated backup behind
+	f, err := os.CreateTemp(filepath.Dir(out), ".dz-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := v.Export(context.Background(), f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), out); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %s to %s\n", vaultPath, out)
+	return nil
+}
+
+func handleVaultImport(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("vault import", flag.ExitOnError)
+	vaultPath := fs.String("vault", cfg.Content.VaultPath, "vault to import into")
+	policy := fs.String("policy", vault.ImportSkip, "what to do with files that exist with other content: skip, overwrite or merge")
+	dryRun := fs.Bool("dry-run", false, "report what would happen without writing")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dz vault import [flags] <zip>")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Imports a zip made by \"dz vault export\" or the editor. Archives with entries")
+		fmt.Fprintln(os.Stderr, "outside the vault or files that don't match their hashes are refused as a whole.")
+		fmt.Fprintln(os.Stderr, "")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if err := importVault(*vaultPath, fs.Arg(0), vault.ImportOptions{Policy: *policy, DryRun: *dryRun}, *asJSON, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing vault: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleVaultRekey(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("vault rekey", flag.ExitOnError)
+	vaultPath := fs.String("vault", cfg.Content.VaultPath, "vault to rekey")
+	filenames := fs.Bool("filenames", false, "encrypt file and folder names too")
+	decrypt := fs.Bool("decrypt", false, "store the vault as plaintext again")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dz vault rekey [flags]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Re-encrypts every file of the vault with a new password, encrypting a plain")
+		fmt.Fprintln(os.Stderr, "vault. Passwords are read from stdin, one per line. Stop the server first.")
+		fmt.Fprintln(os.Stderr, "")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if info, err := os.Stat(*vaultPath); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "Error: vault directory does not exist: %s\n", *vaultPath)
+		os.Exit(1)
+	}
+	v, err := vault.New(*vaultPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening vault: %v\n", err)
+		os.Exit(1)
+	}
+	in := bufio.NewReader(os.Stdin)
+	var oldPassword, newPassword string
+	if v.Encrypted() {
+		oldPassword = readPassword(in, "Current password: ")
+	}
+	if !*decrypt {
+		newPassword = readPassword(in, "New password: ")
+		if readPassword(in, "Repeat the new password: ") != newPassword {
+			fmt.Fprintln(os.Stderr, "Error: the passwords don't match")
+			os.Exit(1)
+		}
+		if newPassword == "" {
+			fmt.Fprintln(os.Stderr, "Error: the password is empty; use --decrypt to decrypt the vault")
+			os.Exit(1)
+		}
+	}
+	if err := rekeyVault(*vaultPath, oldPassword, newPassword, *filenames, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error rekeying vault: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// readPassword prompts for a line of in. It isn't hidden as it is typed.
+func readPassword(in *bufio.Reader, prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Error: no password given")
+		os.Exit(1)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// rekeyVault re-encrypts the vault at vaultPath, unlocked with oldPassword
+// if it is encrypted, with newPassword, or decrypts it when newPassword is
+// empty
+func rekeyVault(vaultPath, oldPassword, newPassword string, filenames bool, w io.Writer) error {
+	if info, err := os.Stat(vaultPath); err != nil || !info.IsDir() {
+		return fmt.Errorf("vault directory does not exist: %s", vaultPath)
+	}
+	v, err := vault.New(vaultPath)
+	if err != nil {
+		return fmt.Errorf("failed to open vault: %w", err)
+	}
+	ctx := context.Background()
+	wasEncrypted := v.Encrypted()
+	if wasEncrypted {
+		k, err := v.Unlock(oldPassword)
+		if err != nil {
+			return err
+		}
+		ctx = vault.WithKey(ctx, k)
+	} else if newPassword == "" {
+		return fmt.Errorf("vault is not encrypted")
+	}
+	if _, err := v.Rekey(ctx, newPassword, filenames); err != nil {
+		return err
+	}
+	switch {
+	case newPassword == "":
+		fmt.Fprintf(w, "Decrypted %s\n", vaultPath)
+	case wasEncrypted:
+		fmt.Fprintf(w, "Re-encrypted %s with the new password\n", vaultPath)
+	default:
+		fmt.Fprintf(w, "Encrypted %s\n", vaultPath)
+	}
+	return nil
+}
+
+// importVault imports the zip at src into the vault at vaultPath, creating
+// the vault if needed, and writes what happened to w
+func importVault(vaultPath, src string, opts vault.ImportOptions, asJSON bool, w io.Writer) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	v, err := vault.New(vaultPath)
+	if err != nil {
+		return fmt.Errorf("failed to open vault: %w", err)
+	}
+
+	res, err := v.Import(context.Background(), &zr.Reader, opts)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+
+	for _, f := range res.Files {
+		switch {
+		case f.Error != "":
+			fmt.Fprintf(w, "%-11s %s: %s\n", f.Action, f.Path, f.Error)
+		case f.WrittenTo != "":
+			fmt.Fprintf(w, "%-11s %s -> %s\n", f.Action, f.Path, f.WrittenTo)
+		case f.Action != vault.ImportUnchanged:
+			fmt.Fprintf(w, "%-11s %s\n", f.Action, f.Path)
+		}
+	}
+	var counts []string
+	for _, action := range []string{vault.ImportCreated, vault.ImportOverwritten, vault.ImportMerged, vault.ImportSkipped, vault.ImportUnchanged, vault.ImportFailed} {
+		if n := res.Summary[action]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, action))
+		}
+	}
+	if len(counts) == 0 {
+		counts = append(counts, "nothing to import")
+	}
+	verified := "no hashes to verify"
+	if res.Verified {
+		verified = "hashes verified"
+	}
+	prefix := ""
+	if res.DryRun {
+		prefix = "dry run: "
+	}
+	fmt.Fprintf(w, "%s%s (%s)\n", prefix, strings.Join(counts, ", "), verified)
+	if res.Summary[vault.ImportFailed] > 0 {
+		return fmt.Errorf("%d files failed to import", res.Summary[vault.ImportFailed])
+	}
+	return nil
+}
+
+// importers convert the exports of other tools, by the name dz import takes
+var importers = map[string]func(context.Context, fs.FS) (*importer.Result, error){
+	"obsidian": importer.Obsidian,
+	"logseq":   importer.Logseq,
+	"notion":   importer.Notion,
+}
+
+func printImportUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: dz import <tool> [flags] <src>")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Tools:")
+	fmt.Fprintln(os.Stderr, "  obsidian <dir>  An Obsidian vault")
+	fmt.Fprintln(os.Stderr, "  logseq <dir>    A Logseq graph")
+	fmt.Fprintln(os.Stderr, "  notion <zip>    A Notion \"Markdown & CSV\" export, zipped or extracted")
+}
+
+func handleImport(args []string) {
+	if len(args) < 1 || importers[args[0]] == nil {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "Unknown tool: %s\n", args[0])
+		}
+		printImportUsage()
+		os.Exit(1)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	tool := args[0]
+	fs := flag.NewFlagSet("import "+tool, flag.ExitOnError)
+	vaultPath := fs.String("vault", cfg.Content.VaultPath, "vault to import into")
+	dryRun := fs.Bool("dry-run", false, "report what would happen without writing")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dz import %s [flags] <src>\n", tool)
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Converts links, block references, daily notes, tags and attachments to deez")
+		fmt.Fprintln(os.Stderr, "conventions and adds id and created frontmatter where missing. Notes that exist")
+		fmt.Fprintln(os.Stderr, "in the vault are skipped; anything that couldn't be converted is reported.")
+		fmt.Fprintln(os.Stderr, "")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if err := importNotes(tool, fs.Arg(0), *vaultPath, *dryRun, *asJSON, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing %s: %v\n", tool, err)
+		os.Exit(1)
+	}
+}
+
+// importNotes converts the export of tool at src and writes it into the
+// vault at vaultPath, creating the vault if needed, then reports to w
+func importNotes(tool, src, vaultPath string, dryRun, asJSON bool, w io.Writer) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	var fsys fs.FS
+	switch {
+	case info.IsDir():
+		fsys = os.DirFS(src)
+	case tool == "notion":
+		zr, err := zip.OpenReader(src)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		fsys = zr
+	default:
+		return fmt.Errorf("%s is not a directory", src)
+	}
+
+	ctx := context.Background()
+	res, err := importers[tool](ctx, fsys)
+	if err != nil {
+		return err
+	}
+	v, err := vault.New(vaultPath)
+	if err != nil {
+		return fmt.Errorf("failed to open vault: %w", err)
+	}
+	if err := res.Write(ctx, v, dryRun); err != nil {
+		return err
+	}
+
+	report := res.Report
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	for _, p := range report.Skipped {
+		fmt.Fprintf(w, "skipped  %s: exists in the vault\n", p)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(w, "warning  %s: %s\n", warning.Path, warning.Message)
+	}
+	prefix := ""
+	if dryRun {
+		prefix = "dry run: "
+	}
+	fmt.Fprintf(w, "%s%d notes, %d attachments: %d written, %d skipped, %d warnings\n",
+		prefix, report.Notes, report.Attachments, report.Written, len(report.Skipped), len(report.Warnings))
+	return nil
+}
+
 func handleTheme(args []string) {
 	if len(args) < 1 {
 		fmt.Fprintln(os.Stderr, "Usage: dz theme <subcommand>")
@@ -166,14 +739,13 @@ func cloneTheme(url string, themesPath string) error {
 		fmt.Printf("Warning: could not remove .git directory: %v\n", err)
 	}
 
-	// Verify theme has index.html
-	indexPath := filepath.Join(destPath, "index.html")
-	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+	// Verify the theme's templates parse
+	if _, err := theme.Load(themeName, os.DirFS(destPath)); err != nil {
 		// Clean up invalid theme
 		if cleanupErr := os.RemoveAll(destPath); cleanupErr != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to clean up invalid theme directory %s: %v\n", destPath, cleanupErr)
 		}
-		return fmt.Errorf("invalid theme: missing index.html")
+		return fmt.Errorf("invalid theme: %w", err)
 	}
 
 	fmt.Printf("Theme '%s' added successfully to %s\n", themeName, destPath)
@@ -209,14 +781,13 @@ func copyTheme(source string, themesPath string) error {
 		return fmt.Errorf("source must be a directory: %s", source)
 	}
 
-	// Verify theme has index.html
-	indexPath := filepath.Join(source, "index.html")
-	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-		return fmt.Errorf("invalid theme: missing index.html in %s", source)
-	}
-
 	// Extract theme name from source path
 	themeName := filepath.Base(source)
+
+	// Verify the theme's templates parse
+	if _, err := theme.Load(themeName, os.DirFS(source)); err != nil {
+		return fmt.Errorf("invalid theme: %w", err)
+	}
 	destPath := filepath.Join(themesPath, themeName)
 
 	// Check if theme already exists
@@ -1,9 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"dragonbytelabs/dz/internal/lint"
+	"dragonbytelabs/dz/internal/publish"
+	"dragonbytelabs/dz/internal/theme"
+	"dragonbytelabs/dz/internal/vault"
 )
 
 func TestIsGitURL(t *testing.T) {
@@ -319,6 +328,30 @@ func TestCopyTheme(t *testing.T) {
 		}
 	})
 
+	t.Run("rejects theme with broken templates", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		srcDir := filepath.Join(tmpDir, "broken-theme")
+		if err := os.MkdirAll(filepath.Join(srcDir, "layouts"), 0755); err != nil {
+			t.Fatalf("failed to create source dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(srcDir, "index.html"), []byte("<html></html>"), 0644); err != nil {
+			t.Fatalf("failed to create index.html: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(srcDir, "layouts", "base.html"), []byte(`{{define "base"}}{{.Site.Title}`), 0644); err != nil {
+			t.Fatalf("failed to create base.html: %v", err)
+		}
+
+		themesPath := filepath.Join(tmpDir, "themes")
+		err := copyTheme(srcDir, themesPath)
+		if err == nil || !strings.Contains(err.Error(), "base.html") {
+			t.Errorf("copyTheme() error = %v, want a parse error in base.html", err)
+		}
+		if _, err := os.Stat(filepath.Join(themesPath, "broken-theme")); err == nil {
+			t.Error("broken theme was installed")
+		}
+	})
+
 	t.Run("rejects duplicate theme", func(t *testing.T) {
 		tmpDir := t.TempDir()
 
@@ -373,3 +406,173 @@ func TestCopyTheme(t *testing.T) {
 		}
 	})
 }
+
+func TestPublishVault(t *testing.T) {
+	t.Run("writes published notes", func(t *testing.T) {
+		vaultDir := t.TempDir()
+		out := filepath.Join(t.TempDir(), "site")
+		os.WriteFile(filepath.Join(vaultDir, "hello.md"), []byte("---\npublish: true\n---\nHi\n"), 0644)
+		os.WriteFile(filepath.Join(vaultDir, "private.md"), []byte("secret\n"), 0644)
+
+		if err := publishVault(vaultDir, out, publish.Options{Title: "Test"}); err != nil {
+			t.Fatalf("publishVault() returned error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(out, "notes", "hello.html")); err != nil {
+			t.Errorf("published note missing: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(out, "notes", "private.html")); err == nil {
+			t.Error("unpublished note was written")
+		}
+	})
+
+	t.Run("renders with a theme", func(t *testing.T) {
+		vaultDir := t.TempDir()
+		themeDir := t.TempDir()
+		out := filepath.Join(t.TempDir(), "site")
+		os.WriteFile(filepath.Join(vaultDir, "hello.md"), []byte("---\npublish: true\n---\nHi\n"), 0644)
+		os.WriteFile(filepath.Join(themeDir, "index.html"), []byte(`<h1 class="custom">{{.Site.Title}}</h1>`), 0644)
+
+		th, err := theme.Load("custom", os.DirFS(themeDir))
+		if err != nil {
+			t.Fatalf("theme.Load() returned error: %v", err)
+		}
+		if err := publishVault(vaultDir, out, publish.Options{Title: "Test", Theme: th}); err != nil {
+			t.Fatalf("publishVault() returned error: %v", err)
+		}
+		index, _ := os.ReadFile(filepath.Join(out, "index.html"))
+		if string(index) != `<h1 class="custom">Test</h1>` {
+			t.Errorf("index.html = %q", index)
+		}
+		// Pages the theme leaves out come from the built-in theme
+		if _, err := os.Stat(filepath.Join(out, "notes", "hello.html")); err != nil {
+			t.Errorf("published note missing: %v", err)
+		}
+	})
+
+	t.Run("rejects missing vault", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "nope")
+		if err := publishVault(missing, t.TempDir(), publish.Options{Title: "Test"}); err == nil {
+			t.Error("publishVault() succeeded for missing vault")
+		}
+		if _, err := os.Stat(missing); err == nil {
+			t.Error("publishVault() created the missing vault")
+		}
+	})
+}
+
+func TestCheckVault(t *testing.T) {
+	vaultDir := t.TempDir()
+	os.WriteFile(filepath.Join(vaultDir, "a.md"), []byte("---\nid: 20240501100000-aaa\ncreated: 2024-05-01\n---\n[[b]]"), 0644)
+	os.WriteFile(filepath.Join(vaultDir, "b.md"), []byte("[[a]]"), 0644)
+
+	var out bytes.Buffer
+	ok, err := checkVault(vaultDir, false, true, &out)
+	if err != nil || ok {
+		t.Fatalf("checkVault() = %v, %v; want errors reported", ok, err)
+	}
+	var report lint.Report
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil || len(report.Issues) != 1 || report.Issues[0].Path != "b.md" {
+		t.Errorf("report = %s", out.String())
+	}
+
+	out.Reset()
+	if ok, err := checkVault(vaultDir, true, false, &out); err != nil || !ok {
+		t.Errorf("checkVault() with fix = %v, %v: %s", ok, err, out.String())
+	}
+	if !strings.Contains(out.String(), "1 fixed") {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestExportImportVault(t *testing.T) {
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "a.md"), []byte("a"), 0644)
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	os.WriteFile(filepath.Join(src, "sub", "b.md"), []byte("b"), 0644)
+
+	archive := filepath.Join(t.TempDir(), "backup.zip")
+	if err := exportVault(src, archive); err != nil {
+		t.Fatalf("exportVault() returned error: %v", err)
+	}
+
+	dst := t.TempDir()
+	os.WriteFile(filepath.Join(dst, "a.md"), []byte("changed"), 0644)
+	var out bytes.Buffer
+	if err := importVault(dst, archive, vault.ImportOptions{Policy: vault.ImportMerge}, false, &out); err != nil {
+		t.Fatalf("importVault() returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "1 created, 1 merged (hashes verified)") {
+		t.Errorf("output = %q", out.String())
+	}
+	if b, _ := os.ReadFile(filepath.Join(dst, "a (imported).md")); string(b) != "a" {
+		t.Errorf("merged copy = %q", b)
+	}
+
+	if err := exportVault(filepath.Join(t.TempDir(), "nope"), archive); err == nil {
+		t.Error("exportVault() succeeded for missing vault")
+	}
+}
+
+func TestImportNotes(t *testing.T) {
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "pages"), 0755)
+	os.MkdirAll(filepath.Join(src, "journals"), 0755)
+	os.WriteFile(filepath.Join(src, "pages", "Plan.md"), []byte("- TODO ship\n- {{query todo}}"), 0644)
+	os.WriteFile(filepath.Join(src, "journals", "2024_05_01.md"), []byte("- [[Plan]]"), 0644)
+
+	dst := t.TempDir()
+	os.WriteFile(filepath.Join(dst, "Plan.md"), []byte("mine"), 0644)
+	var out bytes.Buffer
+	if err := importNotes("logseq", src, dst, false, false, &out); err != nil {
+		t.Fatalf("importNotes() returned error: %v", err)
+	}
+	for _, want := range []string{"skipped  Plan.md", "warning  pages/Plan.md", "2 notes, 0 attachments: 1 written, 1 skipped, 1 warnings"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output = %q, want %q", out.String(), want)
+		}
+	}
+	if b, _ := os.ReadFile(filepath.Join(dst, "daily", "2024-05-01.md")); !strings.Contains(string(b), "type: daily-note") {
+		t.Errorf("daily note = %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dst, "Plan.md")); string(b) != "mine" {
+		t.Errorf("Plan.md = %q, want it left alone", b)
+	}
+
+	if err := importNotes("obsidian", filepath.Join(dst, "Plan.md"), dst, false, false, &out); err == nil {
+		t.Error("importNotes() accepted a file as an Obsidian vault")
+	}
+}
+
+func TestRekeyVault(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("secret"), 0644)
+
+	if err := rekeyVault(dir, "", "", false, &bytes.Buffer{}); err == nil {
+		t.Error("rekeyVault() decrypted a plain vault")
+	}
+	var out bytes.Buffer
+	if err := rekeyVault(dir, "", "correct horse", false, &out); err != nil {
+		t.Fatalf("rekeyVault() returned error: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Encrypted ") {
+		t.Errorf("output = %q", out.String())
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "a.md")); bytes.Contains(b, []byte("secret")) {
+		t.Error("a.md is stored as plaintext")
+	}
+	if err := rekeyVault(dir, "wrong", "other", false, &out); !errors.Is(err, vault.ErrWrongPassword) {
+		t.Errorf("rekeyVault(wrong password) error = %v", err)
+	}
+	if err := rekeyVault(dir, "correct horse", "battery staple", true, &out); err != nil {
+		t.Fatalf("rekeyVault() with a new password returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.md")); !os.IsNotExist(err) {
+		t.Error("a.md is stored under its name")
+	}
+	if err := rekeyVault(dir, "battery staple", "", false, &out); err != nil {
+		t.Fatalf("rekeyVault() decrypting returned error: %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "a.md")); string(b) != "secret" {
+		t.Errorf("decrypted a.md = %q", b)
+	}
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
 
+	"dragonbytelabs/dz/internal/audit"
 	"dragonbytelabs/dz/internal/config"
 	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/plugin"
 )
 
 func main() {
@@ -42,9 +50,22 @@ func handlePlugin(args []string) {
 		os.Exit(1)
 	}
 
+	var err error
 	switch args[0] {
 	case "add":
-		handlePluginAdd(args[1:])
+		err = handlePluginAdd(args[1:])
+	case "list":
+		err = handlePluginList()
+	case "enable":
+		err = handlePluginStatus(args[1:], true)
+	case "disable":
+		err = handlePluginStatus(args[1:], false)
+	case "grant":
+		err = handlePluginGrants(args[1:], true)
+	case "revoke":
+		err = handlePluginGrants(args[1:], false)
+	case "remove":
+		err = handlePluginRemove(args[1:])
 	case "help", "-h", "--help":
 		printPluginUsage()
 	default:
@@ -52,39 +73,224 @@ func handlePlugin(args []string) {
 		printPluginUsage()
 		os.Exit(1)
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func printPluginUsage() {
 	fmt.Println("Usage: dz plugin <command> [arguments]")
 	fmt.Println()
 	fmt.Println("Commands:")
-	fmt.Println("  add <name>    Add a plugin to the application")
-	fmt.Println("  help          Show this help message")
+	fmt.Println("  add <source>          Install a plugin from a directory or a .zip, .tar.gz or .tgz archive")
+	fmt.Println("  list                  List installed plugins")
+	fmt.Println("  enable <id>           Enable an installed plugin")
+	fmt.Println("  disable <id>          Disable an installed plugin")
+	fmt.Println("  grant <id> <cap>...   Let a plugin's hooks use capabilities it asks for (read_notes, write_notes)")
+	fmt.Println("  revoke <id> <cap>...  Take capabilities back from a plugin's hooks")
+	fmt.Println("  remove <id>           Uninstall a plugin")
+	fmt.Println("  help                  Show this help message")
 }
 
-func handlePluginAdd(args []string) {
+// openDB loads the configuration and opens the server's database, applying
+// any pending migrations
+func openDB() (*config.Config, *dbx.DB, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading config: %w", err)
+	}
+	appDir, err := config.AppDir("deez")
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := dbx.OpenSQLite(config.ResolveInAppDir(appDir, cfg.Database.Path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening database: %w", err)
+	}
+	if err := db.ApplyMigrations(context.Background()); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("migrating database: %w", err)
+	}
+	return cfg, db, nil
+}
+
+func handlePluginAdd(args []string) error {
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Error: plugin name is required")
-		fmt.Println()
-		fmt.Println("Usage: dz plugin add <name>")
-		os.Exit(1)
+		return fmt.Errorf("plugin source is required\n\nUsage: dz plugin add <directory|archive>")
 	}
+	cfg, db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-	pluginName := args[0]
+	m, err := plugin.Install(args[0], cfg.Content.PluginsPath)
+	if err != nil {
+		return err
+	}
+	p, err := db.AddPlugin(context.Background(), m.Plugin())
+	if err != nil {
+		return err
+	}
+	status := "run \"dz plugin enable " + p.Name + "\" to enable it"
+	if p.IsActive {
+		status = "it is enabled"
+	}
+	fmt.Printf("Plugin %q %s installed to %s; %s\n", p.Name, p.Version, filepath.Join(cfg.Content.PluginsPath, p.Name), status)
+	if missing := ungranted(p); len(missing) > 0 {
+		fmt.Printf("Its hooks ask for %s; run \"dz plugin grant %s %s\" to allow them\n",
+			strings.Join(missing, ", "), p.Name, strings.Join(missing, " "))
+	}
+	return nil
+}
 
-	// Load configuration
-	cfg, err := config.Load()
+func handlePluginList() error {
+	_, db, err := openDB()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return err
 	}
+	defer db.Close()
 
-	// Open database
-	db, err := dbx.OpenSQLite(cfg.Database.Path)
+	plugins, err := db.GetPlugins(context.Background())
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+	if len(plugins) == 0 {
+		fmt.Println("No plugins installed")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tVERSION\tSTATUS\tGRANTED")
+	for _, p := range plugins {
+		status := "disabled"
+		if p.IsActive {
+			status = "enabled"
+		}
+		granted := "-"
+		if caps := p.Active(); len(caps) > 0 {
+			granted = strings.Join(caps, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", p.Name, p.DisplayName, p.Version, status, granted)
+	}
+	return tw.Flush()
+}
+
+func handlePluginStatus(args []string, active bool) error {
+	if len(args) < 1 {
+		return fmt.Errorf("plugin id is required")
+	}
+	_, db, err := openDB()
+	if err != nil {
+		return err
 	}
 	defer db.Close()
-	fmt.Printf("Plugin %q added successfully\n", pluginName)
+
+	ctx := context.Background()
+	p, err := db.GetPlugin(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("plugin %q is not installed", args[0])
+	}
+	if err := db.SetPluginActive(ctx, p.Name, active); err != nil {
+		return err
+	}
+	action := models.AuditPluginDisabled
+	if active {
+		action = models.AuditPluginEnabled
+	}
+	audit.Log(ctx, db, models.AuditEvent{Action: action, Target: p.Name, Details: p.Version})
+	if active {
+		fmt.Printf("Plugin %q enabled\n", p.Name)
+	} else {
+		fmt.Printf("Plugin %q disabled\n", p.Name)
+	}
+	return nil
+}
+
+// ungranted returns the capabilities p asks for but wasn't granted
+func ungranted(p *models.Plugin) []string {
+	var missing []string
+	for _, c := range p.Capabilities {
+		if !p.Granted.Has(c) {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+func handlePluginGrants(args []string, grant bool) error {
+	if len(args) < 2 {
+		return fmt.Errorf("plugin id and capabilities are required")
+	}
+	_, db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	p, err := db.GetPlugin(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("plugin %q is not installed", args[0])
+	}
+	granted := models.Capabilities{}
+	for _, c := range p.Granted {
+		if grant || !slices.Contains(args[1:], c) {
+			granted = append(granted, c)
+		}
+	}
+	if grant {
+		for _, c := range args[1:] {
+			if !p.Capabilities.Has(c) {
+				return fmt.Errorf("plugin %q does not ask for %q", p.Name, c)
+			}
+			if !granted.Has(c) {
+				granted = append(granted, c)
+			}
+		}
+	}
+	if err := db.SetPluginGrants(ctx, p.Name, granted); err != nil {
+		return err
+	}
+	audit.Log(ctx, db, models.AuditEvent{Action: models.AuditPluginGrants, Target: p.Name, Details: strings.Join(granted, ",")})
+	if len(granted) == 0 {
+		fmt.Printf("Plugin %q has no capabilities\n", p.Name)
+	} else {
+		fmt.Printf("Plugin %q may use %s\n", p.Name, strings.Join(granted, ", "))
+	}
+	return nil
+}
+
+func handlePluginRemove(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("plugin id is required")
+	}
+	cfg, db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	p, err := db.GetPlugin(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("plugin %q is not installed", args[0])
+	}
+	if err := plugin.Remove(cfg.Content.PluginsPath, p.Name); err != nil {
+		return err
+	}
+	if err := db.DeletePlugin(ctx, p.Name); err != nil {
+		return err
+	}
+	fmt.Printf("Plugin %q removed\n", p.Name)
+	return nil
 }
@@ -1,14 +1,31 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"time"
 
+	"dragonbytelabs/dz/internal/blobstore"
 	"dragonbytelabs/dz/internal/config"
 	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/forms"
+	"dragonbytelabs/dz/internal/hooks"
+	"dragonbytelabs/dz/internal/oidc"
+	"dragonbytelabs/dz/internal/posts"
+	"dragonbytelabs/dz/internal/publish"
+	"dragonbytelabs/dz/internal/ratelimit"
 	"dragonbytelabs/dz/internal/routes"
+	"dragonbytelabs/dz/internal/session"
 	"dragonbytelabs/dz/internal/vault"
+	"dragonbytelabs/dz/internal/webhooks"
+
+	"golang.org/x/crypto/bcrypt"
 
 	webview "github.com/webview/webview_go"
 )
@@ -24,15 +41,55 @@ func main() {
 
 	db := setupDB(*cfg)
 	defer db.Close()
+	ensureAdmin(*cfg, db, appDir)
 
 	mux := http.NewServeMux()
-	vault, err := vault.New(cfg.Content.VaultPath)
+	defaultVault, err := vault.New(cfg.Content.VaultPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-	setupRoutes(mux, vault)
+	sm := session.NewSessionManager(
+		setupSessionStore(*cfg, db),
+		cfg.Session.GCInterval,
+		cfg.Session.IdleExpiration,
+		cfg.Session.AbsoluteExpiration,
+		cfg.Session.CookieName,
+	)
+	sm.SetCookieOptions(session.CookieOptions{
+		Secure:     session.SecureMode(cfg.Session.CookieSecure),
+		SameSite:   session.ParseSameSite(cfg.Session.CookieSameSite),
+		Domain:     cfg.Session.CookieDomain,
+		HostPrefix: cfg.Session.CookieHostPrefix,
+	})
+	limiter := setupLimiter(*cfg, db)
+	posts.NewScheduler(db, cfg.Posts.SchedulerInterval)
+	webhooks.NewDeliverer(db, cfg.Webhooks.DeliveryInterval)
+
+	var fallback *vault.Vault
+	if cfg.Content.AnonymousVault {
+		fallback = defaultVault
+	}
+	vaults := routes.NewVaults(db, vault.NewManager(cfg.Content.VaultsPath), fallback)
+	setupHooks(*cfg, db, vaults)
+
+	setupRoutes(mux, vaults, db, sm, limiter)
+	routes.RegisterSite(mux, db, routes.NewPublicSite(db, defaultVault, cfg.Content.ThemesPath, publish.Options{
+		BaseURL:     cfg.Site.URL,
+		FeedContent: cfg.Site.FeedContent,
+		FeedLimit:   cfg.Site.FeedLimit,
+	}))
+	setupOIDC(*cfg, mux, db, sm)
+	if cfg.Admin.SQLConsole {
+		routes.RegisterSQLConsole(mux, db, cfg.Admin.SQLTimeout, cfg.Admin.SQLMaxRows)
+	}
+	addr := "127.0.0.1:3000"
+	if cfg.Sync.Server {
+		routes.RegisterSync(mux, db, blobstore.NewManager(cfg.Sync.Path))
+		log.Println("sync: serving encrypted blobs from", cfg.Sync.Path)
+		addr = cfg.Sync.Addr
+	}
 
-	ln, err := net.Listen("tcp", "127.0.0.1:3000")
+	ln, err := net.Listen("tcp", addr)
 	// ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		log.Fatal(err)
@@ -43,7 +100,7 @@ func main() {
 	log.Println("serving UI at", url)
 
 	// Start HTTP server in background
-	srv := &http.Server{Handler: mux}
+	srv := &http.Server{Handler: sm.Handle(session.CSRF(mux, routes.SharePrefix, routes.FormPrefix))}
 	log.Fatal(srv.Serve(ln))
 	// go func() {
 	// 	// Serve returns http.ErrServerClosed on normal shutdown
@@ -59,20 +116,147 @@ func main() {
 }
 
 func setupDB(cfg config.Config) *dbx.DB {
-	// ctx := context.Background()
+	ctx := context.Background()
 	db, err := dbx.OpenSQLite(cfg.Database.Path)
 
 	if err != nil {
 		log.Fatal(err)
 	}
-	// if err := db.ApplyMigrations(ctx); err != nil {
-	// 	log.Fatal(err)
-	// }
+	if err := db.ApplyMigrations(ctx); err != nil {
+		log.Fatal(err)
+	}
 	return db
 }
 
-func setupRoutes(mux *http.ServeMux, vault *vault.Vault) {
-	routes.RegisterApi(mux, vault)
+// ensureAdmin makes sure the site has an administrator. On first start it
+// creates the default admin with a random password saved to the credentials
+// file in appDir, or promotes an existing user with the default admin email.
+func ensureAdmin(cfg config.Config, db *dbx.DB, appDir string) {
+	ctx := context.Background()
+	n, err := db.CountAdmins(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if n > 0 {
+		return
+	}
+
+	user, err := db.GetUserByEmail(ctx, cfg.DefaultAdminEmail)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if user == nil {
+		raw := make([]byte, cfg.AdminPasswordLength)
+		if _, err := rand.Read(raw); err != nil {
+			log.Fatal(err)
+		}
+		password := base64.RawURLEncoding.EncodeToString(raw)[:cfg.AdminPasswordLength]
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if user, err = db.CreateUser(ctx, cfg.DefaultAdminEmail, string(hash), cfg.DefaultAdminUsername); err != nil {
+			log.Fatal(err)
+		}
+
+		path := filepath.Join(appDir, cfg.CredentialsFileName)
+		if err := os.WriteFile(path, []byte(cfg.DefaultAdminEmail+"\n"+password+"\n"), 0o600); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("created admin %s; password written to %s", cfg.DefaultAdminEmail, path)
+	}
+
+	if err := db.SetUserAdmin(ctx, user.ID, true); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func setupSessionStore(cfg config.Config, db *dbx.DB) session.SessionStore {
+	switch cfg.Session.Store {
+	case "memory":
+		return session.NewInMemoryStore()
+	case "cookie":
+		store, err := session.NewCookieStore(cfg.Session.Secrets, cfg.Session.AbsoluteExpiration)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return store
+	}
+	return session.NewSQLiteStore(db)
+}
+
+func setupLimiter(cfg config.Config, db *dbx.DB) *ratelimit.Limiter {
+	var store ratelimit.Store = ratelimit.NewSQLiteStore(db)
+	if cfg.Login.LimiterStore == "memory" {
+		store = ratelimit.NewInMemoryStore()
+	}
+
+	policy := ratelimit.Policy{
+		FreeAttempts:     cfg.Login.FreeAttempts,
+		BaseDelay:        cfg.Login.BaseDelay,
+		MaxDelay:         cfg.Login.MaxDelay,
+		LockoutThreshold: cfg.Login.LockoutThreshold,
+		LockoutDuration:  cfg.Login.LockoutDuration,
+		ResetAfter:       cfg.Login.ResetAfter,
+	}
+	return ratelimit.NewLimiter(store, policy, cfg.Login.GCInterval)
+}
+
+// setupOIDC enables single sign-on when an issuer is configured
+func setupOIDC(cfg config.Config, mux *http.ServeMux, db *dbx.DB, sm *session.SessionManager) {
+	if cfg.OIDC.Issuer == "" {
+		return
+	}
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       cfg.OIDC.Issuer,
+		ClientID:     cfg.OIDC.ClientID,
+		ClientSecret: cfg.OIDC.ClientSecret,
+		RedirectURL:  cfg.OIDC.RedirectURL,
+		Scopes:       cfg.OIDC.Scopes,
+	}, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	groupTeams, err := oidc.ParseGroupTeams(cfg.OIDC.GroupTeams)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	routes.RegisterOIDC(mux, db, sm, provider, oidc.ProvisionOptions{
+		LinkByEmail: cfg.OIDC.LinkByEmail,
+		GroupsClaim: cfg.OIDC.GroupsClaim,
+		GroupTeams:  groupTeams,
+	})
+	log.Println("oidc: single sign-on enabled for", cfg.OIDC.Issuer)
+}
+
+// setupHooks runs the server-side hooks of enabled plugins on every vault
+func setupHooks(cfg config.Config, db *dbx.DB, vaults *routes.Vaults) {
+	rt, err := hooks.New(context.Background(), cfg.Content.PluginsPath, db.GetActivePlugins, hooks.Options{
+		MemoryLimitMB: cfg.Plugins.HookMemoryMB,
+		Timeout:       cfg.Plugins.HookTimeout,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	vaults.Hook(rt.Hook)
+}
+
+func setupRoutes(mux *http.ServeMux, vaults *routes.Vaults, db *dbx.DB, sm *session.SessionManager, limiter *ratelimit.Limiter) {
+	routes.RegisterApi(mux, vaults)
+	routes.RegisterVaults(mux, vaults)
+	routes.RegisterAdmin(mux, db)
+	routes.RegisterAuth(mux, db, sm, limiter)
+	routes.RegisterSessions(mux, sm)
+	routes.RegisterTeams(mux, db)
+	routes.RegisterShares(mux, vaults, limiter)
+	routes.RegisterPosts(mux, vaults)
+	routes.RegisterCollections(mux, vaults)
+	routes.RegisterPlugins(mux, db)
+	routes.RegisterWebhooks(mux, vaults)
+	routes.RegisterForms(mux, vaults, ratelimit.NewLimiter(ratelimit.NewInMemoryStore(), forms.SubmitPolicy(), time.Hour))
 	routes.RegisterStatic(mux)
 }
 
@@ -0,0 +1,10 @@
+-- Sessions table
+CREATE TABLE IF NOT EXISTS sessions (
+  id               TEXT PRIMARY KEY,
+  data             TEXT NOT NULL DEFAULT '{}',
+  created_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_activity_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_last_activity_at ON sessions(last_activity_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_created_at ON sessions(created_at);
@@ -0,0 +1,5 @@
+-- User avatars and public user hashes
+ALTER TABLE users ADD COLUMN avatar_url TEXT;
+ALTER TABLE users ADD COLUMN user_hash TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_user_hash ON users(user_hash);
@@ -0,0 +1,22 @@
+-- Login throttling state, keyed by client IP or account
+CREATE TABLE IF NOT EXISTS login_throttles (
+  key             TEXT PRIMARY KEY,
+  failures        INTEGER NOT NULL DEFAULT 0,
+  last_failure_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  locked_until    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every login attempt, successful or not, for the audit trail
+CREATE TABLE IF NOT EXISTS login_attempts (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  email      TEXT NOT NULL,
+  ip         TEXT NOT NULL,
+  user_agent TEXT NOT NULL DEFAULT '',
+  success    INTEGER NOT NULL DEFAULT 0,
+  reason     TEXT NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts(email);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_created_at ON login_attempts(created_at);
@@ -0,0 +1,15 @@
+-- Pending invitations to join a team, addressed by email
+CREATE TABLE IF NOT EXISTS team_invitations (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT,
+  team_id      INTEGER NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+  email        TEXT NOT NULL,
+  role         TEXT NOT NULL DEFAULT 'viewer',
+  invited_by   INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  status       TEXT NOT NULL DEFAULT 'pending', -- pending, accepted, declined
+  created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  responded_at DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_team_invitations_pending
+  ON team_invitations(team_id, email) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_team_invitations_email ON team_invitations(email);
@@ -0,0 +1,2 @@
+-- Site administrators
+ALTER TABLE users ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,30 @@
+-- Append-only log of security and content events
+CREATE TABLE IF NOT EXISTS audit_log (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  actor_id    INTEGER,            -- users.id; kept after the user is deleted
+  actor_email TEXT NOT NULL DEFAULT '',
+  ip          TEXT NOT NULL DEFAULT '',
+  action      TEXT NOT NULL,      -- e.g. auth.login, file.write, team.member_removed
+  target      TEXT NOT NULL DEFAULT '',
+  vault       TEXT NOT NULL DEFAULT '',
+  hash_before TEXT NOT NULL DEFAULT '',
+  hash_after  TEXT NOT NULL DEFAULT '',
+  details     TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update
+BEFORE UPDATE ON audit_log
+BEGIN
+  SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete
+BEFORE DELETE ON audit_log
+BEGIN
+  SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
@@ -0,0 +1,6 @@
+-- Site-wide settings, e.g. the active theme
+CREATE TABLE IF NOT EXISTS site_settings (
+  setting_key   TEXT PRIMARY KEY,
+  setting_value TEXT NOT NULL DEFAULT '',
+  updated_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
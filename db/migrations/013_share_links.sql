@@ -0,0 +1,17 @@
+-- Public read-only links to single notes
+CREATE TABLE IF NOT EXISTS share_links (
+  id             INTEGER PRIMARY KEY AUTOINCREMENT,
+  token          TEXT NOT NULL UNIQUE,
+  vault          TEXT NOT NULL,         -- vault name, e.g. users/3 or teams/7
+  path           TEXT NOT NULL,         -- note path; follows renames
+  created_by     INTEGER REFERENCES users(id) ON DELETE CASCADE,
+  password_hash  TEXT NOT NULL DEFAULT '',
+  expires_at     DATETIME NOT NULL,
+  revoked_at     DATETIME,
+  view_count     INTEGER NOT NULL DEFAULT 0,
+  last_viewed_at DATETIME,
+  created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_vault_path ON share_links(vault, path);
+CREATE INDEX IF NOT EXISTS idx_share_links_created_by ON share_links(created_by);
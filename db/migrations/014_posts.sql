@@ -0,0 +1,20 @@
+-- Posts published on the site, written directly or promoted from a vault note
+CREATE TABLE IF NOT EXISTS posts (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT,
+  author_id    INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  title        TEXT NOT NULL,
+  slug         TEXT NOT NULL UNIQUE,
+  content      TEXT NOT NULL DEFAULT '',
+  status       TEXT NOT NULL DEFAULT 'draft',    -- draft, scheduled, published
+  visibility   TEXT NOT NULL DEFAULT 'public',   -- public, unlisted, private
+  format       TEXT NOT NULL DEFAULT 'markdown', -- markdown, html
+  excerpt      TEXT NOT NULL DEFAULT '',
+  publish_at   DATETIME,                         -- when it goes or went live
+  source_vault TEXT NOT NULL DEFAULT '',         -- set for promoted notes
+  source_path  TEXT NOT NULL DEFAULT '',
+  created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_posts_status_publish_at ON posts(status, publish_at);
+CREATE INDEX IF NOT EXISTS idx_posts_source ON posts(source_vault, source_path);
@@ -0,0 +1,23 @@
+-- Forms with a typed field schema, and what visitors submitted through them
+CREATE TABLE IF NOT EXISTS dz_forms (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  name          TEXT NOT NULL,
+  description   TEXT NOT NULL DEFAULT '',
+  fields        TEXT NOT NULL DEFAULT '[]', -- JSON array of models.FormField
+  capture_vault TEXT NOT NULL DEFAULT '',   -- vault of the note submissions are appended to
+  capture_path  TEXT NOT NULL DEFAULT '',   -- empty: submissions are only stored
+  created_by    INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS dz_form_submissions (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  form_id    INTEGER NOT NULL REFERENCES dz_forms(id) ON DELETE CASCADE,
+  data       TEXT NOT NULL DEFAULT '{}', -- JSON object of field name to value
+  ip         TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_form_submissions_form ON dz_form_submissions(form_id, created_at);
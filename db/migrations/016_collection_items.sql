@@ -0,0 +1,15 @@
+-- Collections hold notes of one vault: an ordered list of paths, or a saved
+-- search query evaluated on read. Team vault collections can be shared.
+ALTER TABLE collections ADD COLUMN vault TEXT NOT NULL DEFAULT '';
+ALTER TABLE collections ADD COLUMN query TEXT NOT NULL DEFAULT '';
+ALTER TABLE collections ADD COLUMN team_id INTEGER REFERENCES teams(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_collections_vault ON collections(vault);
+CREATE INDEX IF NOT EXISTS idx_collections_team_id ON collections(team_id);
+
+CREATE TABLE IF NOT EXISTS collection_items (
+  collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+  position      INTEGER NOT NULL,
+  path          TEXT NOT NULL,         -- note path; follows renames
+  PRIMARY KEY (collection_id, path)
+);
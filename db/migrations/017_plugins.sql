@@ -0,0 +1,15 @@
+-- Installed plugins; files live in CONTENT_PLUGINS_PATH/<name>
+CREATE TABLE IF NOT EXISTS dz_plugins (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  name            TEXT NOT NULL UNIQUE,  -- manifest id, e.g. core.zettelkasten
+  display_name    TEXT NOT NULL,
+  description     TEXT NOT NULL DEFAULT '',
+  version         TEXT NOT NULL,
+  is_active       INTEGER NOT NULL DEFAULT 0,
+  sidebar_icon    TEXT NOT NULL DEFAULT '',
+  sidebar_title   TEXT NOT NULL DEFAULT '',
+  sidebar_link    TEXT NOT NULL DEFAULT '',
+  metadata_schema TEXT NOT NULL DEFAULT '[]', -- JSON array of fields
+  created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+-- Server-side hooks: the plugin's WebAssembly module, the capabilities its
+-- manifest asks for and those an admin granted
+ALTER TABLE dz_plugins ADD COLUMN hook_module TEXT NOT NULL DEFAULT '';
+ALTER TABLE dz_plugins ADD COLUMN capabilities TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE dz_plugins ADD COLUMN granted_capabilities TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,30 @@
+-- Outgoing webhooks and the queue of their deliveries
+CREATE TABLE IF NOT EXISTS webhooks (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  url        TEXT NOT NULL,
+  secret     TEXT NOT NULL,                -- HMAC-SHA256 key signing the payloads
+  events     TEXT NOT NULL DEFAULT '[]',   -- JSON array of event names
+  paths      TEXT NOT NULL DEFAULT '[]',   -- JSON array of path patterns; empty matches all
+  vault      TEXT NOT NULL DEFAULT '',     -- empty matches every vault
+  is_active  INTEGER NOT NULL DEFAULT 1,
+  created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  webhook_id      INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  event           TEXT NOT NULL,
+  payload         TEXT NOT NULL,
+  status          TEXT NOT NULL DEFAULT 'pending', -- pending, delivered or failed
+  attempts        INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at DATETIME,                        -- pending deliveries only
+  response_code   INTEGER,
+  error           TEXT NOT NULL DEFAULT '',
+  created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  delivered_at    DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
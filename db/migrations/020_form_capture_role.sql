@@ -0,0 +1,6 @@
+-- The vault role of whoever set a form's capture note; captures are written
+-- with it, so a form can't reach folders its creator couldn't. Existing
+-- captures were set by editors at least.
+ALTER TABLE dz_forms ADD COLUMN capture_role TEXT NOT NULL DEFAULT '';
+
+UPDATE dz_forms SET capture_role = 'editor' WHERE capture_path != '';
@@ -0,0 +1,2 @@
+INSERT INTO collection_items (collection_id, position, path)
+VALUES (:collection_id, :position, :path);
@@ -1,2 +1,16 @@
-INSERT INTO dz_plugins (name, display_name, version, is_active)
-VALUES (:name, :display_name, :version, 0);
+-- Registers a plugin, or refreshes it on reinstall keeping whether it is
+-- active and the capabilities it was granted
+INSERT INTO dz_plugins (name, display_name, description, version, sidebar_icon, sidebar_title, sidebar_link, metadata_schema, hook_module, capabilities)
+VALUES (:name, :display_name, :description, :version, :sidebar_icon, :sidebar_title, :sidebar_link, :metadata_schema, :hook_module, :capabilities)
+ON CONFLICT (name) DO UPDATE SET
+  display_name = excluded.display_name,
+  description = excluded.description,
+  version = excluded.version,
+  sidebar_icon = excluded.sidebar_icon,
+  sidebar_title = excluded.sidebar_title,
+  sidebar_link = excluded.sidebar_link,
+  metadata_schema = excluded.metadata_schema,
+  hook_module = excluded.hook_module,
+  capabilities = excluded.capabilities,
+  updated_at = CURRENT_TIMESTAMP
+RETURNING id, name, display_name, description, version, is_active, sidebar_icon, sidebar_title, sidebar_link, metadata_schema, hook_module, capabilities, granted_capabilities, created_at, updated_at;
@@ -0,0 +1 @@
+SELECT COUNT(*) FROM %s;
\ No newline at end of file
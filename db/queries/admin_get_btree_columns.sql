@@ -0,0 +1,7 @@
+SELECT s.rootpage, s.tbl_name AS tbl, c.cid AS pos, c.name, 0 AS "key"
+FROM sqlite_schema AS s, pragma_table_xinfo(s.name) AS c
+WHERE s.type = 'table' AND s.rootpage > 0
+UNION ALL
+SELECT s.rootpage, s.tbl_name, c.seqno, coalesce(c.name, ''), c."key"
+FROM sqlite_schema AS s, pragma_index_xinfo(s.name) AS c
+WHERE s.type = 'index' AND s.rootpage > 0;
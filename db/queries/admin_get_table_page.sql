@@ -0,0 +1,3 @@
+SELECT * FROM %s
+ORDER BY %s %s
+LIMIT :limit OFFSET :offset;
\ No newline at end of file
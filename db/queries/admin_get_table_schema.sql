@@ -0,0 +1,3 @@
+SELECT cid, name, type, "notnull", dflt_value, pk
+FROM pragma_table_info(:table_name)
+ORDER BY cid;
\ No newline at end of file
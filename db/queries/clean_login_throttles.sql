@@ -0,0 +1,3 @@
+DELETE FROM login_throttles
+WHERE last_failure_at < :threshold
+  AND locked_until < :threshold;
@@ -0,0 +1 @@
+SELECT COUNT(*) FROM users WHERE is_admin = 1;
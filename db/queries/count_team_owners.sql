@@ -0,0 +1,2 @@
+SELECT COUNT(*) FROM team_members
+WHERE team_id = :team_id AND role = 'owner';
@@ -0,0 +1,2 @@
+INSERT INTO audit_log (actor_id, actor_email, ip, action, target, vault, hash_before, hash_after, details)
+VALUES (:actor_id, :actor_email, :ip, :action, :target, :vault, :hash_before, :hash_after, :details);
@@ -1,3 +1,3 @@
-INSERT INTO collections (user_id, name, description)
-VALUES (:user_id, :name, :description)
-RETURNING id, user_id, name, description, created_at, updated_at;
+INSERT INTO collections (user_id, name, description, vault, query, team_id)
+VALUES (:user_id, :name, :description, :vault, :query, :team_id)
+RETURNING id, user_id, name, description, vault, query, team_id, created_at, updated_at;
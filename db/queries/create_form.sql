@@ -1,2 +1,3 @@
-INSERT INTO dz_forms (name, description, fields)
-VALUES (:name, :description, :fields);
+INSERT INTO dz_forms (name, description, fields, capture_vault, capture_path, capture_role, created_by)
+VALUES (:name, :description, :fields, :capture_vault, :capture_path, :capture_role, :created_by)
+RETURNING id, name, description, fields, capture_vault, capture_path, capture_role, created_by, created_at, updated_at;
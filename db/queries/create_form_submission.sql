@@ -0,0 +1,3 @@
+INSERT INTO dz_form_submissions (form_id, data, ip, user_agent)
+VALUES (:form_id, :data, :ip, :user_agent)
+RETURNING id, form_id, data, ip, user_agent, created_at;
@@ -0,0 +1,2 @@
+INSERT INTO login_attempts (email, ip, user_agent, success, reason)
+VALUES (:email, :ip, :user_agent, :success, :reason);
@@ -1,3 +1,3 @@
-INSERT INTO posts (title, content, status, visibility, format, excerpt, publish_at)
-VALUES (:title, :content, :status, :visibility, :format, :excerpt, :publish_at)
-RETURNING id, title, content, status, visibility, format, excerpt, publish_at, created_at, updated_at;
+INSERT INTO posts (author_id, title, slug, content, status, visibility, format, excerpt, publish_at, source_vault, source_path)
+VALUES (:author_id, :title, :slug, :content, :status, :visibility, :format, :excerpt, :publish_at, :source_vault, :source_path)
+RETURNING id, author_id, title, slug, content, status, visibility, format, excerpt, publish_at, source_vault, source_path, created_at, updated_at;
@@ -1,5 +1,8 @@
-INSERT INTO sessions (id, data, created_at, last_activity_at)
-VALUES (:id, :data, :created_at, :last_activity_at)
+INSERT INTO sessions (id, data, user_id, user_agent, ip, created_at, last_activity_at)
+VALUES (:id, :data, :user_id, :user_agent, :ip, :created_at, :last_activity_at)
 ON CONFLICT(id) DO UPDATE SET
     data = excluded.data,
-    last_activity_at = excluded.last_activity_at
\ No newline at end of file
+    user_id = excluded.user_id,
+    user_agent = excluded.user_agent,
+    ip = excluded.ip,
+    last_activity_at = excluded.last_activity_at
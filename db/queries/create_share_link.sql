@@ -0,0 +1,3 @@
+INSERT INTO share_links (token, vault, path, created_by, password_hash, expires_at)
+VALUES (:token, :vault, :path, :created_by, :password_hash, :expires_at)
+RETURNING id, token, vault, path, created_by, password_hash, expires_at, revoked_at, view_count, last_viewed_at, created_at;
@@ -0,0 +1,3 @@
+INSERT INTO team_invitations (team_id, email, role, invited_by)
+VALUES (:team_id, :email, :role, :invited_by)
+RETURNING id, team_id, email, role, invited_by, status, created_at, responded_at;
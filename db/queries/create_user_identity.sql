@@ -0,0 +1,2 @@
+INSERT INTO user_identities (issuer, subject, user_id, email)
+VALUES (:issuer, :subject, :user_id, :email);
@@ -0,0 +1,3 @@
+INSERT INTO webhooks (url, secret, events, paths, vault, is_active, created_by)
+VALUES (:url, :secret, :events, :paths, :vault, :is_active, :created_by)
+RETURNING id, url, secret, events, paths, vault, is_active, created_by, created_at, updated_at;
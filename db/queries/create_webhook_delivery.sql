@@ -0,0 +1,3 @@
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+VALUES (:webhook_id, :event, :payload, :next_attempt_at)
+RETURNING id, webhook_id, event, payload, status, attempts, next_attempt_at, response_code, error, created_at, delivered_at;
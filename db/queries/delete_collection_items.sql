@@ -0,0 +1,2 @@
+DELETE FROM collection_items
+WHERE collection_id = :collection_id;
@@ -0,0 +1,2 @@
+DELETE FROM dz_forms
+WHERE id = :id;
@@ -0,0 +1 @@
+DELETE FROM login_throttles WHERE key = :key;
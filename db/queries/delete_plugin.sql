@@ -0,0 +1,2 @@
+DELETE FROM dz_plugins
+WHERE name = :name;
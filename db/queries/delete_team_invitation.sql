@@ -0,0 +1 @@
+DELETE FROM team_invitations WHERE id = :id;
@@ -0,0 +1,4 @@
+SELECT id, url, secret, events, paths, vault, is_active, created_by, created_at, updated_at
+FROM webhooks
+WHERE is_active = 1
+ORDER BY id;
@@ -1,3 +1,3 @@
-SELECT id, name, display_name, description, version, is_active, sidebar_icon, sidebar_title, sidebar_link, created_at, updated_at
+SELECT id, name, display_name, description, version, is_active, sidebar_icon, sidebar_title, sidebar_link, metadata_schema, hook_module, capabilities, granted_capabilities, created_at, updated_at
 FROM dz_plugins
 ORDER BY name;
@@ -1,3 +1,4 @@
-SELECT id, title, content, status, visibility, format, excerpt, publish_at, created_at, updated_at
+SELECT id, author_id, title, slug, content, status, visibility, format, excerpt, publish_at, source_vault, source_path, created_at, updated_at
 FROM posts
-ORDER BY created_at DESC;
+WHERE (:status = '' OR status = :status)
+ORDER BY created_at DESC, id DESC;
@@ -0,0 +1,3 @@
+SELECT id, url, secret, events, paths, vault, is_active, created_by, created_at, updated_at
+FROM webhooks
+ORDER BY id;
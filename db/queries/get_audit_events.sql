@@ -0,0 +1,11 @@
+SELECT id, created_at, actor_id, actor_email, ip, action, target, vault, hash_before, hash_after, details
+FROM audit_log
+WHERE (:actor = '' OR actor_email = :actor)
+  AND (:action = '' OR action = :action OR action LIKE :action || '.%')
+  AND (:target = '' OR target = :target OR target LIKE :target || '/%')
+  AND (:vault = '' OR vault = :vault)
+  AND (:since = '' OR created_at >= :since)
+  AND (:until = '' OR created_at < :until)
+  AND (:before_id = 0 OR id < :before_id)
+ORDER BY id DESC
+LIMIT :limit;
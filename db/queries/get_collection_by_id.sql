@@ -1,3 +1,3 @@
-SELECT id, user_id, name, description, created_at, updated_at
+SELECT id, user_id, name, description, vault, query, team_id, created_at, updated_at
 FROM collections
-WHERE id = :id AND user_id = :user_id;
+WHERE id = :id;
@@ -0,0 +1,4 @@
+SELECT path
+FROM collection_items
+WHERE collection_id = :collection_id
+ORDER BY position;
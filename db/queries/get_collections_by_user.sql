@@ -1,4 +1,6 @@
-SELECT id, user_id, name, description, created_at, updated_at
+-- The user's own collections and those shared with their teams
+SELECT id, user_id, name, description, vault, query, team_id, created_at, updated_at
 FROM collections
 WHERE user_id = :user_id
-ORDER BY created_at DESC;
+   OR team_id IN (SELECT team_id FROM team_members WHERE user_id = :user_id)
+ORDER BY created_at DESC, id DESC;
@@ -0,0 +1,5 @@
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, response_code, error, created_at, delivered_at
+FROM webhook_deliveries
+WHERE status = 'pending' AND next_attempt_at <= :now
+ORDER BY next_attempt_at, id
+LIMIT :limit;
@@ -1,3 +1,3 @@
-SELECT id, name, description, fields, created_at, updated_at
+SELECT id, name, description, fields, capture_vault, capture_path, capture_role, created_by, created_at, updated_at
 FROM dz_forms
 WHERE id = :id;
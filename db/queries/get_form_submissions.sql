@@ -0,0 +1,4 @@
+SELECT id, form_id, data, ip, user_agent, created_at
+FROM dz_form_submissions
+WHERE form_id = :form_id
+ORDER BY created_at, id;
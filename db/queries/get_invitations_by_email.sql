@@ -0,0 +1,4 @@
+SELECT id, team_id, email, role, invited_by, status, created_at, responded_at
+FROM team_invitations
+WHERE email = lower(:email) AND status = 'pending'
+ORDER BY created_at DESC;
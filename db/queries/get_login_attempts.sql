@@ -0,0 +1,5 @@
+SELECT id, email, ip, user_agent, success, reason, created_at
+FROM login_attempts
+WHERE (:email = '' OR email = :email)
+ORDER BY id DESC
+LIMIT :limit;
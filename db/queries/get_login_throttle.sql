@@ -0,0 +1,4 @@
+SELECT key, failures, last_failure_at, locked_until
+FROM login_throttles
+WHERE key = :key
+LIMIT 1;
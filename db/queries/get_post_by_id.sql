@@ -1,3 +1,3 @@
-SELECT id, title, content, status, visibility, format, excerpt, publish_at, created_at, updated_at
+SELECT id, author_id, title, slug, content, status, visibility, format, excerpt, publish_at, source_vault, source_path, created_at, updated_at
 FROM posts
 WHERE id = :id;
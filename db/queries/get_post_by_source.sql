@@ -0,0 +1,3 @@
+SELECT id, author_id, title, slug, content, status, visibility, format, excerpt, publish_at, source_vault, source_path, created_at, updated_at
+FROM posts
+WHERE source_vault = :source_vault AND source_path = :source_path;
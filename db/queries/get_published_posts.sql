@@ -0,0 +1,5 @@
+SELECT id, author_id, title, slug, content, status, visibility, format, excerpt, publish_at, source_vault, source_path, created_at, updated_at
+FROM posts
+WHERE status = 'published' AND visibility = 'public'
+ORDER BY publish_at DESC, id DESC
+LIMIT :limit;
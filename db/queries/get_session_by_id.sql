@@ -1,6 +1,6 @@
 
 -- get session by id 
-SELECT id, data, created_at, last_activity_at 
+SELECT id, data, user_id, user_agent, ip, created_at, last_activity_at 
 FROM sessions 
 WHERE id = :id
 LIMIT 1;
\ No newline at end of file
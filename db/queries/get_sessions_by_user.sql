@@ -0,0 +1,4 @@
+SELECT id, data, user_id, user_agent, ip, created_at, last_activity_at
+FROM sessions
+WHERE user_id = :user_id
+ORDER BY last_activity_at DESC;
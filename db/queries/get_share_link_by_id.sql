@@ -0,0 +1,3 @@
+SELECT id, token, vault, path, created_by, password_hash, expires_at, revoked_at, view_count, last_viewed_at, created_at
+FROM share_links
+WHERE id = :id;
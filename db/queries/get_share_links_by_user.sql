@@ -0,0 +1,4 @@
+SELECT id, token, vault, path, created_by, password_hash, expires_at, revoked_at, view_count, last_viewed_at, created_at
+FROM share_links
+WHERE created_by = :created_by
+ORDER BY created_at DESC, id DESC;
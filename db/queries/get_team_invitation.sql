@@ -0,0 +1,3 @@
+SELECT id, team_id, email, role, invited_by, status, created_at, responded_at
+FROM team_invitations
+WHERE id = :id;
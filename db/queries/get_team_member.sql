@@ -0,0 +1,3 @@
+SELECT team_id, user_id, role, joined_at
+FROM team_members
+WHERE team_id = :team_id AND user_id = :user_id;
@@ -0,0 +1,5 @@
+SELECT tm.team_id, tm.user_id, tm.role, tm.joined_at, u.email, u.display_name
+FROM team_members tm
+INNER JOIN users u ON u.id = tm.user_id
+WHERE tm.team_id = :team_id
+ORDER BY tm.joined_at ASC;
@@ -1,5 +1,5 @@
 -- get user by email 
-SELECT id, user_hash, email, password_hash, display_name, avatar_url, created_at, updated_at
+SELECT id, user_hash, email, password_hash, display_name, avatar_url, is_admin, created_at, updated_at
 FROM users
 WHERE email = :email
 LIMIT 1;
\ No newline at end of file
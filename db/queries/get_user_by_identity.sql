@@ -0,0 +1,5 @@
+SELECT u.id, u.user_hash, u.email, u.password_hash, u.display_name, u.avatar_url, u.is_admin, u.created_at, u.updated_at
+FROM users u
+INNER JOIN user_identities ui ON ui.user_id = u.id
+WHERE ui.issuer = :issuer AND ui.subject = :subject
+LIMIT 1;
@@ -0,0 +1,3 @@
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, response_code, error, created_at, delivered_at
+FROM webhook_deliveries
+WHERE id = :id;
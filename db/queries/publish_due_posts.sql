@@ -0,0 +1,4 @@
+UPDATE posts
+SET status = 'published', updated_at = CURRENT_TIMESTAMP
+WHERE status = 'scheduled' AND publish_at <= :now
+RETURNING id, author_id, title, slug, content, status, visibility, format, excerpt, publish_at, source_vault, source_path, created_at, updated_at;
@@ -0,0 +1,3 @@
+UPDATE share_links
+SET view_count = view_count + 1, last_viewed_at = CURRENT_TIMESTAMP
+WHERE id = :id;
@@ -0,0 +1,4 @@
+-- Drops a deleted note, or the notes inside a deleted folder
+DELETE FROM collection_items
+WHERE collection_id IN (SELECT id FROM collections WHERE vault = :vault)
+  AND (path = :path OR substr(path, 1, length(:path) + 1) = :path || '/');
@@ -0,0 +1,5 @@
+-- Moves items to a renamed note, or to notes inside a renamed folder
+UPDATE collection_items
+SET path = :new_path || substr(path, length(:old_path) + 1)
+WHERE collection_id IN (SELECT id FROM collections WHERE vault = :vault)
+  AND (path = :old_path OR substr(path, 1, length(:old_path) + 1) = :old_path || '/');
@@ -0,0 +1,5 @@
+-- Keeps promoted posts attached to their note, or to notes inside a renamed folder
+UPDATE posts
+SET source_path = :new_path || substr(source_path, length(:old_path) + 1)
+WHERE source_vault = :vault
+  AND (source_path = :old_path OR substr(source_path, 1, length(:old_path) + 1) = :old_path || '/');
@@ -0,0 +1,3 @@
+UPDATE share_links
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = :id AND revoked_at IS NULL;
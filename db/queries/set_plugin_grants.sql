@@ -0,0 +1,3 @@
+UPDATE dz_plugins
+SET granted_capabilities = :granted_capabilities, updated_at = CURRENT_TIMESTAMP
+WHERE name = :name;
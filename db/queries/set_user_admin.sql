@@ -0,0 +1,2 @@
+UPDATE users SET is_admin = :is_admin, updated_at = CURRENT_TIMESTAMP
+WHERE id = :id;
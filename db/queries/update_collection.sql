@@ -1,4 +1,5 @@
 UPDATE collections
-SET name = :name, description = :description, updated_at = CURRENT_TIMESTAMP
+SET name = :name, description = :description, query = :query, team_id = :team_id,
+    updated_at = CURRENT_TIMESTAMP
 WHERE id = :id AND user_id = :user_id
-RETURNING id, user_id, name, description, created_at, updated_at;
+RETURNING id, user_id, name, description, vault, query, team_id, created_at, updated_at;
@@ -1,3 +1,4 @@
 UPDATE dz_forms
-SET name = :name, description = :description, fields = :fields, updated_at = CURRENT_TIMESTAMP
-WHERE id = :id;
+SET name = :name, description = :description, fields = :fields, capture_vault = :capture_vault, capture_path = :capture_path, capture_role = :capture_role, updated_at = CURRENT_TIMESTAMP
+WHERE id = :id
+RETURNING id, name, description, fields, capture_vault, capture_path, capture_role, created_by, created_at, updated_at;
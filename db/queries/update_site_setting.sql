@@ -1,3 +1,5 @@
-UPDATE site_settings 
-SET setting_value = :setting_value, updated_at = CURRENT_TIMESTAMP 
-WHERE setting_key = :setting_key;
+INSERT INTO site_settings (setting_key, setting_value, updated_at)
+VALUES (:setting_key, :setting_value, CURRENT_TIMESTAMP)
+ON CONFLICT(setting_key) DO UPDATE SET
+  setting_value = excluded.setting_value,
+  updated_at = CURRENT_TIMESTAMP;
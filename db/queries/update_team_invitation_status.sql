@@ -0,0 +1,4 @@
+UPDATE team_invitations
+SET status = :status,
+    responded_at = CURRENT_TIMESTAMP
+WHERE id = :id AND status = 'pending';
@@ -0,0 +1,3 @@
+UPDATE team_members
+SET role = :role
+WHERE team_id = :team_id AND user_id = :user_id;
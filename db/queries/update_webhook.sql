@@ -0,0 +1,4 @@
+UPDATE webhooks
+SET url = :url, secret = :secret, events = :events, paths = :paths, vault = :vault, is_active = :is_active, updated_at = CURRENT_TIMESTAMP
+WHERE id = :id
+RETURNING id, url, secret, events, paths, vault, is_active, created_by, created_at, updated_at;
@@ -0,0 +1,3 @@
+UPDATE webhook_deliveries
+SET status = :status, attempts = :attempts, next_attempt_at = :next_attempt_at, response_code = :response_code, error = :error, delivered_at = :delivered_at
+WHERE id = :id;
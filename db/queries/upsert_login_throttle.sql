@@ -0,0 +1,6 @@
+INSERT INTO login_throttles (key, failures, last_failure_at, locked_until)
+VALUES (:key, :failures, :last_failure_at, :locked_until)
+ON CONFLICT(key) DO UPDATE SET
+    failures = excluded.failures,
+    last_failure_at = excluded.last_failure_at,
+    locked_until = excluded.locked_until;
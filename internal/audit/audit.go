@@ -0,0 +1,57 @@
+// Package audit records security and content events to the audit log.
+package audit
+
+import (
+	"context"
+	"log"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+)
+
+// Actor identifies who performed an action
+type Actor struct {
+	UserID int64
+	Email  string
+	IP     string
+}
+
+type actorKey struct{}
+
+// WithActor attaches the acting user to ctx so events recorded deeper in the
+// stack (e.g. by vault observers) are attributed to them
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// ActorFrom returns the actor attached to ctx, if any
+func ActorFrom(ctx context.Context) (Actor, bool) {
+	a, ok := ctx.Value(actorKey{}).(Actor)
+	return a, ok
+}
+
+// Log appends an event, filling in the actor from ctx when the event has none.
+// Failures are logged rather than returned so auditing never breaks a request.
+func Log(ctx context.Context, db *dbx.DB, e models.AuditEvent) {
+	if a, ok := ActorFrom(ctx); ok {
+		if e.ActorID == nil && a.UserID != 0 {
+			id := a.UserID
+			e.ActorID = &id
+		}
+		if e.ActorEmail == "" {
+			e.ActorEmail = a.Email
+		}
+		if e.IP == "" {
+			e.IP = a.IP
+		}
+	}
+
+	if err := db.RecordAuditEvent(ctx, &e); err != nil {
+		log.Printf("audit: failed to record %s on %q: %v", e.Action, e.Target, err)
+	}
+}
+
+// ForUser returns the actor for a signed-in user
+func ForUser(u *models.User, ip string) Actor {
+	return Actor{UserID: u.ID, Email: u.Email, IP: ip}
+}
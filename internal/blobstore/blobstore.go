@@ -0,0 +1,294 @@
+// Package blobstore stores the files of the zero-knowledge sync server.
+// Clients encrypt the contents and the paths of their files with the
+// editor's VaultEncryption before uploading them, so a store only ever holds
+// ciphertext: opaque blobs plus the hash, size and time sync needs. It never
+// handles keys, and refuses anything that isn't in the sealed format.
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for paths the store holds no blob for
+	ErrNotFound = errors.New("blob not found")
+	// ErrConflict is returned when the If-Match hash of a change isn't the
+	// hash of the stored blob
+	ErrConflict = errors.New("conflict: blob changed")
+	// ErrNotSealed is returned for paths and contents that aren't in the
+	// format of VaultEncryption.encrypt, e.g. a client uploading plaintext
+	ErrNotSealed = errors.New("not encrypted with the vault key")
+)
+
+// MaxPathLength caps the encrypted paths a store accepts
+const MaxPathLength = 4096
+
+// sealedOverhead is the IV and tag every sealed value carries
+const sealedOverhead = 12 + 16
+
+const indexFile = "index.json"
+
+// Blob is the metadata of a stored blob, in the format of the editor's
+// RemoteFileInfo
+type Blob struct {
+	Path     string    `json:"path"` // encrypted by the client
+	Hash     string    `json:"hash"` // SHA-256 of the ciphertext, hex
+	Size     int64     `json:"size"` // of the ciphertext
+	Modified time.Time `json:"modified"`
+}
+
+// Index lists the blobs of a store, in the format of the editor's
+// RemoteIndex. VaultID is the store's own id; the vault's isn't known.
+type Index struct {
+	VaultID string    `json:"vaultId"`
+	Updated time.Time `json:"updated"`
+	Files   []Blob    `json:"files"`
+}
+
+// Sealed reports whether b looks like the output of VaultEncryption.encrypt:
+// base64 of a 12-byte IV, the ciphertext and a 16-byte tag. It can't tell
+// ciphertext from random base64, but keeps plaintext out.
+func Sealed(b []byte) bool {
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b)))
+	return err == nil && len(raw) >= sealedOverhead
+}
+
+// Store holds the blobs of one sync client, e.g. one user's vault, in a
+// directory: blobs/ keyed by the hash of their path, and index.json
+type Store struct {
+	root string
+
+	mu    sync.RWMutex
+	index Index
+	blobs map[string]*Blob // by path
+}
+
+// Open opens the store at root, creating it if needed
+func Open(root string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(root, "blobs"), 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{root: root, blobs: map[string]*Blob{}}
+	b, err := os.ReadFile(filepath.Join(root, indexFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s.index = Index{VaultID: newStoreID(), Updated: now()}
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(b, &s.index); err != nil {
+			return nil, fmt.Errorf("%s: %w", indexFile, err)
+		}
+	}
+	for i := range s.index.Files {
+		f := s.index.Files[i]
+		s.blobs[f.Path] = &f
+	}
+	return s, nil
+}
+
+func newStoreID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to generate store id")
+	}
+	return hex.EncodeToString(b)
+}
+
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func hashOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// file returns where the blob for path is kept. Encrypted paths are long
+// and may hold slashes, so they aren't used as file names.
+func (s *Store) file(path string) string {
+	return filepath.Join(s.root, "blobs", hashOf([]byte(path)))
+}
+
+func checkPath(path string) error {
+	if len(path) > MaxPathLength {
+		return fmt.Errorf("path is longer than %d bytes", MaxPathLength)
+	}
+	if !Sealed([]byte(path)) {
+		return fmt.Errorf("path: %w", ErrNotSealed)
+	}
+	return nil
+}
+
+// Index returns the metadata of every blob, sorted by path
+func (s *Store) Index() Index {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	index := s.index
+	index.Files = append([]Blob{}, s.index.Files...)
+	return index
+}
+
+// Get returns the ciphertext stored for path and its metadata
+func (s *Store) Get(ctx context.Context, path string) ([]byte, *Blob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	meta, ok := s.blobs[path]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	b, err := os.ReadFile(s.file(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	m := *meta
+	return b, &m, nil
+}
+
+// matches checks ifMatch against the blob at path: "" always matches, "*"
+// matches any blob and a hash the blob with that hash
+func (s *Store) matches(path, ifMatch string) error {
+	if ifMatch == "" {
+		return nil
+	}
+	meta, ok := s.blobs[path]
+	if !ok || (ifMatch != "*" && ifMatch != meta.Hash) {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Put stores sealed as the blob for path. If ifMatch is set the change is
+// refused with ErrConflict unless it is the hash of the stored blob, so
+// clients get optimistic concurrency without the server reading anything.
+func (s *Store) Put(ctx context.Context, path string, sealed []byte, ifMatch string) (*Blob, error) {
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+	if !Sealed(sealed) {
+		return nil, ErrNotSealed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.matches(path, ifMatch); err != nil {
+		return nil, err
+	}
+
+	abs := s.file(path)
+	tmp := abs + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, abs); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	meta := &Blob{Path: path, Hash: hashOf(sealed), Size: int64(len(sealed)), Modified: now()}
+	prev := s.blobs[path]
+	s.blobs[path] = meta
+	if err := s.save(); err != nil {
+		if prev != nil {
+			s.blobs[path] = prev
+		} else {
+			delete(s.blobs, path)
+		}
+		return nil, err
+	}
+	m := *meta
+	return &m, nil
+}
+
+// Delete removes the blob for path, with ifMatch as for Put
+func (s *Store) Delete(ctx context.Context, path, ifMatch string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.blobs[path]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := s.matches(path, ifMatch); err != nil {
+		return err
+	}
+	delete(s.blobs, path)
+	if err := s.save(); err != nil {
+		s.blobs[path] = prev
+		return err
+	}
+	if err := os.Remove(s.file(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// save writes the index, rebuilt from s.blobs, atomically; callers hold s.mu
+func (s *Store) save() error {
+	s.index.Updated = now()
+	s.index.Files = make([]Blob, 0, len(s.blobs))
+	for _, b := range s.blobs {
+		s.index.Files = append(s.index.Files, *b)
+	}
+	sort.Slice(s.index.Files, func(i, j int) bool { return s.index.Files[i].Path < s.index.Files[j].Path })
+	b, err := json.MarshalIndent(s.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	abs := filepath.Join(s.root, indexFile)
+	tmp := abs + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, abs); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Manager opens and caches the stores of the users of a sync server under a
+// base directory
+type Manager struct {
+	base string
+
+	mu     sync.Mutex
+	stores map[string]*Store
+}
+
+// NewManager creates a manager rooted at base
+func NewManager(base string) *Manager {
+	return &Manager{base: base, stores: map[string]*Store{}}
+}
+
+// Personal returns the store of a user
+func (m *Manager) Personal(userID int64) (*Store, error) {
+	rel := filepath.Join("users", strconv.FormatInt(userID, 10))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.stores[rel]; ok {
+		return s, nil
+	}
+	s, err := Open(filepath.Join(m.base, rel))
+	if err != nil {
+		return nil, err
+	}
+	m.stores[rel] = s
+	return s, nil
+}
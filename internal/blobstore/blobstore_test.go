@@ -0,0 +1,85 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// sealed returns random base64 in the format of VaultEncryption.encrypt
+func sealed(n int) string {
+	b := make([]byte, sealedOverhead+n)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	path, content := sealed(10), sealed(100)
+
+	blob, err := s.Put(ctx, path, []byte(content), "")
+	if err != nil {
+		t.Fatalf("Put() returned error: %v", err)
+	}
+	if blob.Hash != hashOf([]byte(content)) || blob.Size != int64(len(content)) {
+		t.Errorf("Put() = %+v", blob)
+	}
+	b, got, err := s.Get(ctx, path)
+	if err != nil || string(b) != content || got.Hash != blob.Hash {
+		t.Fatalf("Get() = %q, %+v, %v", b, got, err)
+	}
+
+	t.Run("if-match on ciphertext hashes", func(t *testing.T) {
+		if _, err := s.Put(ctx, path, []byte(sealed(5)), "stale"); !errors.Is(err, ErrConflict) {
+			t.Errorf("Put(stale) error = %v, want ErrConflict", err)
+		}
+		if _, err := s.Put(ctx, sealed(10), []byte(sealed(5)), "*"); !errors.Is(err, ErrConflict) {
+			t.Errorf("Put(*) of a new path error = %v, want ErrConflict", err)
+		}
+		next, err := s.Put(ctx, path, []byte(sealed(5)), blob.Hash)
+		if err != nil {
+			t.Fatalf("Put(current) returned error: %v", err)
+		}
+		if err := s.Delete(ctx, path, blob.Hash); !errors.Is(err, ErrConflict) {
+			t.Errorf("Delete(stale) error = %v, want ErrConflict", err)
+		}
+		blob = next
+	})
+
+	t.Run("refuses plaintext", func(t *testing.T) {
+		if _, err := s.Put(ctx, "notes/a.md", []byte(content), ""); !errors.Is(err, ErrNotSealed) {
+			t.Errorf("Put(plain path) error = %v, want ErrNotSealed", err)
+		}
+		if _, err := s.Put(ctx, sealed(10), []byte("# A note"), ""); !errors.Is(err, ErrNotSealed) {
+			t.Errorf("Put(plain content) error = %v, want ErrNotSealed", err)
+		}
+	})
+
+	t.Run("reopens", func(t *testing.T) {
+		again, err := Open(root)
+		if err != nil {
+			t.Fatalf("Open() returned error: %v", err)
+		}
+		index := again.Index()
+		if index.VaultID != s.Index().VaultID || len(index.Files) != 1 || index.Files[0] != *blob {
+			t.Errorf("Index() = %+v, want %+v", index, *blob)
+		}
+	})
+
+	if err := s.Delete(ctx, path, blob.Hash); err != nil {
+		t.Fatalf("Delete() returned error: %v", err)
+	}
+	if _, _, err := s.Get(ctx, path); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, path, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrNotFound", err)
+	}
+}
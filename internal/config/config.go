@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,9 +16,17 @@ type Config struct {
 	Server               ServerConfig
 	Database             DatabaseConfig
 	Session              SessionConfig
+	Login                LoginConfig
+	OIDC                 OIDCConfig
 	Queries              QueriesConfig
 	Media                MediaConfig
 	Content              ContentConfig
+	Admin                AdminConfig
+	Posts                PostsConfig
+	Site                 SiteConfig
+	Plugins              PluginsConfig
+	Webhooks             WebhooksConfig
+	Sync                 SyncConfig
 	DefaultAdminEmail    string
 	DefaultAdminUsername string
 	CredentialsFileName  string
@@ -29,6 +39,54 @@ type ContentConfig struct {
 	PluginsPath string // Path to plugins folder
 	UploadsPath string // Path to uploads folder
 	VaultPath   string // Path to vault folder
+	VaultsPath  string // Path to per-user and per-team vaults
+	// AnonymousVault serves VaultPath to requests without a signed-in user,
+	// for the single-user desktop app
+	AnonymousVault bool
+}
+
+// AdminConfig configures the admin database browser
+type AdminConfig struct {
+	// SQLConsole enables the read-only SQL console. Queries can alias
+	// columns past redaction, so it is off by default.
+	SQLConsole bool
+	SQLTimeout time.Duration
+	SQLMaxRows int
+}
+
+// PostsConfig configures the posts scheduler
+type PostsConfig struct {
+	// SchedulerInterval is how often scheduled posts are checked; 0 disables
+	SchedulerInterval time.Duration
+}
+
+// SiteConfig configures the published site and its feeds
+type SiteConfig struct {
+	// URL is the public URL of the site root, e.g. https://example.com/site/.
+	// Feeds use it for absolute links.
+	URL         string
+	FeedContent string // "full" or "excerpt"
+	FeedLimit   int    // entries per feed
+}
+
+// PluginsConfig limits the server-side hooks of plugins
+type PluginsConfig struct {
+	HookTimeout  time.Duration // per hook call
+	HookMemoryMB int           // memory of a hook instance
+}
+
+// WebhooksConfig configures the delivery of webhooks
+type WebhooksConfig struct {
+	// DeliveryInterval is how often queued deliveries are posted; 0 disables
+	DeliveryInterval time.Duration
+}
+
+// SyncConfig configures the zero-knowledge sync server, which stores the
+// encrypted files of editors syncing through it without reading them
+type SyncConfig struct {
+	Server bool   // serve /api/sync
+	Path   string // where the encrypted blobs are kept
+	Addr   string // host:port to listen on when serving sync
 }
 
 type AppConfig struct {
@@ -45,10 +103,39 @@ type DatabaseConfig struct {
 }
 
 type SessionConfig struct {
+	Store              string   // "sqlite", "memory" or "cookie"
+	Secrets            []string // cookie store keys, newest first
 	CookieName         string
 	GCInterval         time.Duration
 	IdleExpiration     time.Duration
 	AbsoluteExpiration time.Duration
+	CookieSecure       string // "auto", "always" or "never"
+	CookieSameSite     string // "lax", "strict" or "none"
+	CookieDomain       string
+	CookieHostPrefix   bool // use the __Host- prefix on secure requests
+}
+
+type LoginConfig struct {
+	LimiterStore     string // "sqlite" or "memory"
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	ResetAfter       time.Duration
+	GCInterval       time.Duration
+}
+
+// OIDCConfig configures single sign-on; it is disabled when Issuer is empty
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	LinkByEmail  bool
+	GroupsClaim  string
+	GroupTeams   string // "group=teamID:role,..."
 }
 
 type QueriesConfig struct {
@@ -80,10 +167,36 @@ func Load() (*Config, error) {
 			Path: getEnv("DATABASE_PATH", "dz.db"),
 		},
 		Session: SessionConfig{
+			Store:              getEnv("SESSION_STORE", "sqlite"),
+			Secrets:            getList("SESSION_SECRET", nil),
 			GCInterval:         getDuration("SESSION_GC_INTERVAL", 30*time.Minute),
 			IdleExpiration:     getDuration("SESSION_IDLE_EXPIRATION", 1*time.Hour),
 			AbsoluteExpiration: getDuration("SESSION_ABSOLUTE_EXPIRATION", 12*time.Hour),
 			CookieName:         getEnv("SESSION_COOKIE_NAME", "session_id"),
+			CookieSecure:       getEnv("SESSION_COOKIE_SECURE", "auto"),
+			CookieSameSite:     getEnv("SESSION_COOKIE_SAMESITE", "lax"),
+			CookieDomain:       getEnv("SESSION_COOKIE_DOMAIN", ""),
+			CookieHostPrefix:   getBool("SESSION_COOKIE_HOST_PREFIX", false),
+		},
+		Login: LoginConfig{
+			LimiterStore:     getEnv("LOGIN_LIMITER_STORE", "sqlite"),
+			FreeAttempts:     getInt("LOGIN_FREE_ATTEMPTS", 3),
+			BaseDelay:        getDuration("LOGIN_BASE_DELAY", 1*time.Second),
+			MaxDelay:         getDuration("LOGIN_MAX_DELAY", 5*time.Minute),
+			LockoutThreshold: getInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+			LockoutDuration:  getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			ResetAfter:       getDuration("LOGIN_RESET_AFTER", 1*time.Hour),
+			GCInterval:       getDuration("LOGIN_GC_INTERVAL", 30*time.Minute),
+		},
+		OIDC: OIDCConfig{
+			Issuer:       getEnv("OIDC_ISSUER", ""),
+			ClientID:     getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:  getEnv("OIDC_REDIRECT_URL", "http://127.0.0.1:3000/api/auth/oidc/callback"),
+			Scopes:       getList("OIDC_SCOPES", []string{"openid", "email", "profile"}),
+			LinkByEmail:  getBool("OIDC_LINK_BY_EMAIL", true),
+			GroupsClaim:  getEnv("OIDC_GROUPS_CLAIM", ""),
+			GroupTeams:   getEnv("OIDC_GROUP_TEAMS", ""),
 		},
 		Queries: QueriesConfig{
 			CreateUser:     getEnv("QUERY_CREATE_USER", "create_user.sql"),
@@ -94,11 +207,38 @@ func Load() (*Config, error) {
 			MaxFileSize: getInt64("MEDIA_MAX_FILE_SIZE", 10*1024*1024), // 10MB default
 		},
 		Content: ContentConfig{
-			BasePath:    getEnv("CONTENT_PATH", "dz_content"),
-			ThemesPath:  getEnv("CONTENT_THEMES_PATH", "dz_content/themes"),
-			PluginsPath: getEnv("CONTENT_PLUGINS_PATH", "dz_content/plugins"),
-			UploadsPath: getEnv("CONTENT_UPLOADS_PATH", "dz_content/uploads"),
-			VaultPath:   getEnv("VAULT_PATH", "dz_content/vault"),
+			BasePath:       getEnv("CONTENT_PATH", "dz_content"),
+			ThemesPath:     getEnv("CONTENT_THEMES_PATH", "dz_content/themes"),
+			PluginsPath:    getEnv("CONTENT_PLUGINS_PATH", "dz_content/plugins"),
+			UploadsPath:    getEnv("CONTENT_UPLOADS_PATH", "dz_content/uploads"),
+			VaultPath:      getEnv("VAULT_PATH", "dz_content/vault"),
+			VaultsPath:     getEnv("VAULTS_PATH", "dz_content/vaults"),
+			AnonymousVault: getBool("VAULT_ANONYMOUS_ACCESS", true),
+		},
+		Admin: AdminConfig{
+			SQLConsole: getBool("ADMIN_SQL_CONSOLE", false),
+			SQLTimeout: getDuration("ADMIN_SQL_TIMEOUT", 5*time.Second),
+			SQLMaxRows: getInt("ADMIN_SQL_MAX_ROWS", 1000),
+		},
+		Posts: PostsConfig{
+			SchedulerInterval: getDuration("POSTS_SCHEDULER_INTERVAL", time.Minute),
+		},
+		Site: SiteConfig{
+			URL:         getEnv("SITE_URL", ""),
+			FeedContent: getEnv("SITE_FEED_CONTENT", "full"),
+			FeedLimit:   getInt("SITE_FEED_LIMIT", 50),
+		},
+		Plugins: PluginsConfig{
+			HookTimeout:  getDuration("PLUGINS_HOOK_TIMEOUT", 2*time.Second),
+			HookMemoryMB: getInt("PLUGINS_HOOK_MEMORY_MB", 64),
+		},
+		Webhooks: WebhooksConfig{
+			DeliveryInterval: getDuration("WEBHOOKS_DELIVERY_INTERVAL", 10*time.Second),
+		},
+		Sync: SyncConfig{
+			Server: getBool("SYNC_SERVER", false),
+			Path:   getEnv("SYNC_PATH", "dz_content/sync"),
+			Addr:   getEnv("SYNC_ADDR", "127.0.0.1:3000"),
 		},
 		// Admin defaults
 		DefaultAdminEmail:    getEnv("DEFAULT_ADMIN_EMAIL", "admin@localhost.com"),
@@ -142,6 +282,30 @@ func getInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getInt(key string, defaultValue int) int {
+	return int(getInt64(key, int64(defaultValue)))
+}
+
+func getBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		result, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Printf("Invalid bool for %s: %v, using default", key, err)
+			return defaultValue
+		}
+		return result
+	}
+	return defaultValue
+}
+
+// getList reads a comma or space separated list
+func getList(key string, defaultValue []string) []string {
+	if value := os.Getenv(key); value != "" {
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	return defaultValue
+}
+
 // MustLoad panics if config cannot be loaded
 func MustLoad() *Config {
 	cfg, err := Load()
@@ -2,9 +2,92 @@ package dbx
 
 import (
 	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
+// ErrTableNotFound is returned for tables that don't exist
+var ErrTableNotFound = errors.New("table not found")
+
+// ErrUnknownColumn is returned when sorting by a column the table lacks
+var ErrUnknownColumn = errors.New("unknown column")
+
+// ErrNotReadOnly is returned for console queries that aren't a single SELECT
+var ErrNotReadOnly = errors.New("only a single SELECT, WITH or EXPLAIN statement is allowed")
+
+// ErrRedactedColumn is returned for console queries that read, filter or
+// sort by a sensitive column, whatever their results are called
+var ErrRedactedColumn = errors.New("query reads redacted columns")
+
+// Redacted replaces the value of sensitive columns in admin views
+const Redacted = "[redacted]"
+
+// sensitiveColumns are redacted in every table
+var sensitiveColumns = map[string]bool{
+	"password_hash": true,
+	"token":         true,
+	"token_hash":    true,
+	"secret":        true,
+}
+
+// sensitiveTableColumns are redacted only in the named table. A session's
+// id is its cookie value, so it is as secret as the data.
+var sensitiveTableColumns = map[string]map[string]bool{
+	"sessions": {"id": true, "data": true},
+}
+
+// IsSensitiveColumn reports whether admin views hide column of table.
+// Pass an empty table for results that don't come from a single table.
+func IsSensitiveColumn(table, column string) bool {
+	c := strings.ToLower(column)
+	if sensitiveColumns[c] || strings.HasSuffix(c, "_token") || strings.HasSuffix(c, "_secret") {
+		return true
+	}
+	return sensitiveTableColumns[strings.ToLower(table)][c]
+}
+
+// TableColumn is one row of PRAGMA table_info
+type TableColumn struct {
+	CID       int     `db:"cid" json:"cid"`
+	Name      string  `db:"name" json:"name"`
+	Type      string  `db:"type" json:"type"`
+	NotNull   bool    `db:"notnull" json:"not_null"`
+	Default   *string `db:"dflt_value" json:"default"`
+	PK        int     `db:"pk" json:"pk"` // position in the primary key, 0 if not part of it
+	Sensitive bool    `db:"-" json:"sensitive"`
+}
+
+// TableQuery selects a page of table rows
+type TableQuery struct {
+	Table  string
+	Sort   string // column name; defaults to the primary key
+	Desc   bool
+	Limit  int
+	Offset int
+}
+
+// TablePage is one page of table rows with sensitive values redacted
+type TablePage struct {
+	Columns []string         `json:"columns"`
+	Rows    []map[string]any `json:"rows"`
+	Total   int              `json:"total"`
+}
+
+// QueryResult is the output of a console query with sensitive values redacted
+type QueryResult struct {
+	Columns   []string `json:"columns"`
+	Rows      [][]any  `json:"rows"`
+	Truncated bool     `json:"truncated"`
+}
+
 // GetAllTables returns all table names in the database
 func (d *DB) GetAllTables(ctx context.Context) ([]string, error) {
 	query := MustQuery("admin_get_all_tables.sql")
@@ -48,20 +131,32 @@ func (d *DB) checkTableExists(ctx context.Context, tableName string) (bool, erro
 	return count > 0, nil
 }
 
-// GetTableData returns all rows from a table
-func (d *DB) GetTableData(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+// requireTable returns ErrTableNotFound unless tableName exists
+func (d *DB) requireTable(ctx context.Context, tableName string) error {
 	exists, err := d.checkTableExists(ctx, tableName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check table existence: %w", err)
+		return fmt.Errorf("failed to check table existence: %w", err)
 	}
-
 	if !exists {
-		return nil, fmt.Errorf("table %s does not exist", tableName)
+		return fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+	}
+	return nil
+}
+
+// quoteIdent quotes a table or column name for interpolation into SQL
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// GetTableData returns all rows from a table, with sensitive columns redacted
+func (d *DB) GetTableData(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+	if err := d.requireTable(ctx, tableName); err != nil {
+		return nil, err
 	}
 
 	// Load query template and inject validated table name
 	queryTemplate := MustQuery("admin_get_table_data.sql")
-	query := fmt.Sprintf(queryTemplate, tableName)
+	query := fmt.Sprintf(queryTemplate, quoteIdent(tableName))
 
 	rows, err := d.DBX.QueryxContext(ctx, query)
 	if err != nil {
@@ -75,8 +170,342 @@ func (d *DB) GetTableData(ctx context.Context, tableName string) ([]map[string]i
 		if err := rows.MapScan(row); err != nil {
 			return nil, err
 		}
+		redactRow(tableName, row)
 		results = append(results, row)
 	}
 
 	return results, nil
 }
+
+// GetTableSchema returns the columns of a table
+func (d *DB) GetTableSchema(ctx context.Context, tableName string) ([]TableColumn, error) {
+	if err := d.requireTable(ctx, tableName); err != nil {
+		return nil, err
+	}
+	q := MustQuery("admin_get_table_schema.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{"table_name": tableName})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make([]TableColumn, 0)
+	for rows.Next() {
+		var c TableColumn
+		if err := rows.StructScan(&c); err != nil {
+			return nil, err
+		}
+		c.Sensitive = IsSensitiveColumn(tableName, c.Name)
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// GetTableRows returns a sorted page of a table's rows
+func (d *DB) GetTableRows(ctx context.Context, tq TableQuery) (*TablePage, error) {
+	schema, err := d.GetTableSchema(ctx, tq.Table)
+	if err != nil {
+		return nil, err
+	}
+	sortCol, err := sortColumn(schema, tq.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if tq.Limit <= 0 {
+		tq.Limit = 100
+	}
+
+	page := &TablePage{Rows: make([]map[string]any, 0)}
+	for _, c := range schema {
+		page.Columns = append(page.Columns, c.Name)
+	}
+
+	table := quoteIdent(tq.Table)
+	count := fmt.Sprintf(MustQuery("admin_count_table_rows.sql"), table)
+	if err := d.DBX.GetContext(ctx, &page.Total, count); err != nil {
+		return nil, err
+	}
+
+	order := "ASC"
+	if tq.Desc {
+		order = "DESC"
+	}
+	q := fmt.Sprintf(MustQuery("admin_get_table_page.sql"), table, quoteIdent(sortCol), order)
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{
+		"limit":  tq.Limit,
+		"offset": max(tq.Offset, 0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := make(map[string]any)
+		if err := rows.MapScan(row); err != nil {
+			return nil, err
+		}
+		redactRow(tq.Table, row)
+		page.Rows = append(page.Rows, row)
+	}
+	return page, rows.Err()
+}
+
+// sortColumn validates name against schema, defaulting to the first
+// primary key column (or the first column when there is none)
+func sortColumn(schema []TableColumn, name string) (string, error) {
+	if name == "" {
+		for _, c := range schema {
+			if c.PK == 1 {
+				return c.Name, nil
+			}
+		}
+		return schema[0].Name, nil
+	}
+	for _, c := range schema {
+		if c.Name == name {
+			return c.Name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownColumn, name)
+}
+
+// ExportTableCSV writes every row of a table to w as CSV with a header row
+func (d *DB) ExportTableCSV(ctx context.Context, tableName string, w io.Writer) error {
+	if err := d.requireTable(ctx, tableName); err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf(MustQuery("admin_get_table_data.sql"), quoteIdent(tableName))
+	rows, err := d.DBX.QueryxContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for rows.Next() {
+		values, err := rows.SliceScan()
+		if err != nil {
+			return err
+		}
+		for i, v := range values {
+			if IsSensitiveColumn(tableName, columns[i]) {
+				record[i] = Redacted
+			} else {
+				record[i] = csvValue(v)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadOnlyQuery runs a single SELECT statement from the admin console.
+// It runs in a read-only transaction on a connection with query_only set,
+// is cancelled after timeout and returns at most maxRows rows. Queries
+// that touch a sensitive column are refused with ErrRedactedColumn before
+// they run; result columns with sensitive names are redacted on top.
+func (d *DB) ReadOnlyQuery(ctx context.Context, query string, timeout time.Duration, maxRows int) (*QueryResult, error) {
+	query, err := readOnlyStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pragma sticks to the connection, so take one for ourselves and
+	// make sure it's reset before it goes back to the pool
+	conn, err := d.DBX.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA query_only = OFF")
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	tx, err := conn.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if cols, err := redactedReads(ctx, tx, query); err != nil {
+		return nil, err
+	} else if len(cols) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRedactedColumn, strings.Join(cols, ", "))
+	}
+
+	rows, err := tx.QueryxContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &QueryResult{Rows: make([][]any, 0)}
+	if result.Columns, err = rows.Columns(); err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		if len(result.Rows) == maxRows {
+			result.Truncated = true
+			break
+		}
+		values, err := rows.SliceScan()
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range result.Columns {
+			if IsSensitiveColumn("", c) {
+				values[i] = Redacted
+			} else if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, rows.Err()
+}
+
+// readOnlyStatement trims query and rejects anything but one SELECT, WITH
+// or EXPLAIN statement. query_only is the real guard; this just gives a
+// clearer error and keeps statements like ATTACH out.
+func readOnlyStatement(query string) (string, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+	fields := strings.Fields(query)
+	if len(fields) == 0 || strings.Contains(query, ";") {
+		return "", ErrNotReadOnly
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "EXPLAIN":
+		return query, nil
+	}
+	return "", ErrNotReadOnly
+}
+
+// btreeColumn is a column of a table or index b-tree, by root page
+type btreeColumn struct {
+	Root  int64  `db:"rootpage"`
+	Table string `db:"tbl"`
+	Pos   int64  `db:"pos"` // in the table or index record
+	Name  string `db:"name"`
+	Key   bool   `db:"key"` // of an index, rather than an appended column
+}
+
+// seekOps compare an index key against a value, so on an index of a
+// sensitive column they let a query binary-search its values
+var seekOps = map[string]bool{
+	"SeekGE": true, "SeekGT": true, "SeekLE": true, "SeekLT": true,
+	"IdxGE": true, "IdxGT": true, "IdxLE": true, "IdxLT": true,
+	"Found": true, "NotFound": true, "NoConflict": true, "IfNoHope": true,
+}
+
+// redactedReads compiles query with EXPLAIN and returns the sensitive
+// columns, as "table.column", its program reads or seeks on. This works on
+// what SQLite will actually run, so aliases, CTE column lists, views and
+// subqueries can't hide a column the way they hide its name.
+func redactedReads(ctx context.Context, tx *sqlx.Tx, query string) ([]string, error) {
+	if strings.EqualFold(strings.Fields(query)[0], "EXPLAIN") {
+		return nil, nil // shows the program without running it
+	}
+
+	var cols []btreeColumn
+	if err := tx.SelectContext(ctx, &cols, MustQuery("admin_get_btree_columns.sql")); err != nil {
+		return nil, err
+	}
+	btrees := map[int64][]btreeColumn{}
+	for _, c := range cols {
+		btrees[c.Root] = append(btrees[c.Root], c)
+	}
+
+	rows, err := tx.QueryContext(ctx, "EXPLAIN "+query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cursors := map[int64][]btreeColumn{}
+	found := map[string]bool{}
+	flag := func(c btreeColumn) {
+		if IsSensitiveColumn(c.Table, c.Name) {
+			found[c.Table+"."+c.Name] = true
+		}
+	}
+	for rows.Next() {
+		var addr, p1, p2, p3, p5 int64
+		var opcode string
+		var p4, comment any
+		if err := rows.Scan(&addr, &opcode, &p1, &p2, &p3, &p4, &p5, &comment); err != nil {
+			return nil, err
+		}
+		switch {
+		case opcode == "OpenRead" || opcode == "ReopenIdx":
+			if p3 == 0 {
+				cursors[p1] = btrees[p2]
+			}
+		case opcode == "OpenDup":
+			cursors[p1] = cursors[p2]
+		case opcode == "Column":
+			for _, c := range cursors[p1] {
+				if c.Pos == p2 {
+					flag(c)
+				}
+			}
+		case seekOps[opcode]:
+			for _, c := range cursors[p1] {
+				if c.Key {
+					flag(c)
+				}
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0, len(found))
+	for c := range found {
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func redactRow(table string, row map[string]any) {
+	for c := range row {
+		if IsSensitiveColumn(table, c) {
+			row[c] = Redacted
+		}
+	}
+}
+
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
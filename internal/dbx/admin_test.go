@@ -1,8 +1,14 @@
 package dbx
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
+	"errors"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDB_GetAllTables(t *testing.T) {
@@ -112,3 +118,179 @@ func TestDB_checkTableExists(t *testing.T) {
 		}
 	})
 }
+
+func TestDB_GetTableRows(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, email := range []string{"b@example.com", "a@example.com", "c@example.com"} {
+		if _, err := db.CreateUser(ctx, email, "secret-hash", ""); err != nil {
+			t.Fatalf("CreateUser() returned error: %v", err)
+		}
+	}
+
+	t.Run("pages and sorts", func(t *testing.T) {
+		page, err := db.GetTableRows(ctx, TableQuery{Table: "users", Sort: "email", Limit: 2, Offset: 1})
+		if err != nil {
+			t.Fatalf("GetTableRows() returned error: %v", err)
+		}
+		if page.Total != 3 || len(page.Rows) != 2 {
+			t.Fatalf("GetTableRows() total = %d, rows = %d, want 3, 2", page.Total, len(page.Rows))
+		}
+		if page.Rows[0]["email"] != "b@example.com" {
+			t.Errorf("first row email = %v, want b@example.com", page.Rows[0]["email"])
+		}
+	})
+
+	t.Run("redacts sensitive columns", func(t *testing.T) {
+		page, err := db.GetTableRows(ctx, TableQuery{Table: "users"})
+		if err != nil {
+			t.Fatalf("GetTableRows() returned error: %v", err)
+		}
+		for _, row := range page.Rows {
+			if row["password_hash"] != Redacted {
+				t.Errorf("password_hash = %v, want %s", row["password_hash"], Redacted)
+			}
+		}
+	})
+
+	t.Run("rejects unknown sort columns", func(t *testing.T) {
+		_, err := db.GetTableRows(ctx, TableQuery{Table: "users", Sort: "email; DROP TABLE users"})
+		if !errors.Is(err, ErrUnknownColumn) {
+			t.Errorf("GetTableRows() error = %v, want ErrUnknownColumn", err)
+		}
+	})
+
+	t.Run("rejects unknown tables", func(t *testing.T) {
+		_, err := db.GetTableRows(ctx, TableQuery{Table: "nonexistent_table"})
+		if !errors.Is(err, ErrTableNotFound) {
+			t.Errorf("GetTableRows() error = %v, want ErrTableNotFound", err)
+		}
+	})
+}
+
+func TestDB_GetTableSchema(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	schema, err := db.GetTableSchema(context.Background(), "sessions")
+	if err != nil {
+		t.Fatalf("GetTableSchema() returned error: %v", err)
+	}
+	columns := map[string]TableColumn{}
+	for _, c := range schema {
+		columns[c.Name] = c
+	}
+	if id := columns["id"]; id.PK != 1 || !id.Sensitive {
+		t.Errorf("id column = %+v, want primary key and sensitive", id)
+	}
+	if !columns["data"].Sensitive || columns["ip"].Sensitive {
+		t.Errorf("data, ip sensitive = %v, %v, want true, false", columns["data"].Sensitive, columns["ip"].Sensitive)
+	}
+}
+
+func TestDB_ExportTableCSV(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	db.CreateUser(ctx, "a@example.com", "secret-hash", "Ann, Jr.")
+
+	var buf bytes.Buffer
+	if err := db.ExportTableCSV(ctx, "users", &buf); err != nil {
+		t.Fatalf("ExportTableCSV() returned error: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("export has %d records, want header and one row", len(records))
+	}
+	if strings.Contains(buf.String(), "secret-hash") {
+		t.Error("export contains the password hash")
+	}
+	if !slices.Contains(records[1], "Ann, Jr.") {
+		t.Errorf("row = %v, want display name", records[1])
+	}
+}
+
+func TestDB_ReadOnlyQuery(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	db.CreateUser(ctx, "a@example.com", "secret-hash", "")
+	db.CreateUser(ctx, "b@example.com", "secret-hash", "")
+
+	t.Run("runs selects", func(t *testing.T) {
+		res, err := db.ReadOnlyQuery(ctx, "SELECT email, 'not a hash' AS password_hash FROM users ORDER BY email;", time.Second, 1)
+		if err != nil {
+			t.Fatalf("ReadOnlyQuery() returned error: %v", err)
+		}
+		if len(res.Rows) != 1 || !res.Truncated {
+			t.Errorf("ReadOnlyQuery() rows = %d, truncated = %v, want 1, true", len(res.Rows), res.Truncated)
+		}
+		if res.Rows[0][0] != "a@example.com" || res.Rows[0][1] != Redacted {
+			t.Errorf("row = %v", res.Rows[0])
+		}
+	})
+
+	for _, q := range []string{
+		"SELECT email, password_hash FROM users",
+		"SELECT password_hash AS x FROM users",
+		"SELECT id FROM sessions",
+		"SELECT s.data AS d FROM sessions AS s",
+		"WITH t(a, b) AS (SELECT email, password_hash FROM users) SELECT b FROM t",
+		"SELECT (SELECT max(password_hash) FROM users) AS h",
+		"SELECT email FROM users WHERE password_hash LIKE 'a%'",
+		"SELECT count(*) FROM sessions WHERE id > 'm'",
+	} {
+		t.Run("refuses "+q, func(t *testing.T) {
+			if _, err := db.ReadOnlyQuery(ctx, q, time.Second, 10); !errors.Is(err, ErrRedactedColumn) {
+				t.Errorf("ReadOnlyQuery(%q) error = %v, want ErrRedactedColumn", q, err)
+			}
+		})
+	}
+
+	t.Run("allows the rest of sensitive tables", func(t *testing.T) {
+		for _, q := range []string{
+			"SELECT count(*) FROM sessions",
+			"SELECT user_id, created_at FROM sessions WHERE user_id = 1",
+			"SELECT id, email FROM users",
+			"EXPLAIN SELECT password_hash FROM users",
+		} {
+			if _, err := db.ReadOnlyQuery(ctx, q, time.Second, 10); err != nil {
+				t.Errorf("ReadOnlyQuery(%q) returned error: %v", q, err)
+			}
+		}
+	})
+
+	for _, q := range []string{
+		"DELETE FROM users",
+		"PRAGMA query_only = OFF",
+		"SELECT 1; DELETE FROM users",
+		"WITH x AS (SELECT 1) DELETE FROM users",
+		"ATTACH DATABASE 'other.db' AS other",
+	} {
+		t.Run("rejects "+q, func(t *testing.T) {
+			if _, err := db.ReadOnlyQuery(ctx, q, time.Second, 10); err == nil {
+				t.Errorf("ReadOnlyQuery(%q) succeeded, want error", q)
+			}
+		})
+	}
+
+	t.Run("leaves the connection writable", func(t *testing.T) {
+		if _, err := db.CreateUser(ctx, "c@example.com", "secret-hash", ""); err != nil {
+			t.Errorf("CreateUser() after console query returned error: %v", err)
+		}
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		q := "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) FROM n"
+		if _, err := db.ReadOnlyQuery(ctx, q, 50*time.Millisecond, 10); err == nil {
+			t.Error("ReadOnlyQuery() of an endless query succeeded, want timeout")
+		}
+	})
+}
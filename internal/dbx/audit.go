@@ -0,0 +1,61 @@
+package dbx
+
+import (
+	"context"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// auditTimeLayout matches how SQLite's CURRENT_TIMESTAMP stores created_at
+const auditTimeLayout = "2006-01-02 15:04:05"
+
+// RecordAuditEvent appends an event to the audit log
+func (d *DB) RecordAuditEvent(ctx context.Context, e *models.AuditEvent) error {
+	q := MustQuery("create_audit_event.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, e)
+	return err
+}
+
+// GetAuditEvents returns matching events, newest first
+func (d *DB) GetAuditEvents(ctx context.Context, f models.AuditFilter) ([]models.AuditEvent, error) {
+	q := MustQuery("get_audit_events.sql")
+
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	args := map[string]any{
+		"actor":     f.Actor,
+		"action":    f.Action,
+		"target":    f.Target,
+		"vault":     f.Vault,
+		"since":     formatAuditTime(f.Since),
+		"until":     formatAuditTime(f.Until),
+		"before_id": f.BeforeID,
+		"limit":     f.Limit,
+	}
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.AuditEvent, 0)
+	for rows.Next() {
+		var e models.AuditEvent
+		if err := rows.StructScan(&e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func formatAuditTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(auditTimeLayout)
+}
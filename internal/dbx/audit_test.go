@@ -0,0 +1,68 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestDB_AuditLog(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, e := range []models.AuditEvent{
+		{Action: models.AuditLogin, ActorEmail: "a@example.com"},
+		{Action: models.AuditFileWrite, ActorEmail: "a@example.com", Target: "notes/a.md", HashBefore: "old", HashAfter: "new"},
+		{Action: models.AuditFileDelete, ActorEmail: "b@example.com", Target: "notes/b.md"},
+		{Action: models.AuditMemberRemoved, ActorEmail: "b@example.com", Target: "teams/1/members/2"},
+	} {
+		if err := db.RecordAuditEvent(ctx, &e); err != nil {
+			t.Fatalf("RecordAuditEvent() returned error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter models.AuditFilter
+		want   int
+	}{
+		{"everything", models.AuditFilter{}, 4},
+		{"by actor", models.AuditFilter{Actor: "b@example.com"}, 2},
+		{"by action prefix", models.AuditFilter{Action: "file"}, 2},
+		{"by exact action", models.AuditFilter{Action: models.AuditFileWrite}, 1},
+		{"by folder", models.AuditFilter{Target: "notes"}, 2},
+		{"in the future", models.AuditFilter{Since: time.Now().Add(time.Hour)}, 0},
+		{"limit", models.AuditFilter{Limit: 3}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := db.GetAuditEvents(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("GetAuditEvents() returned error: %v", err)
+			}
+			if len(events) != tt.want {
+				t.Errorf("GetAuditEvents() returned %d events, want %d", len(events), tt.want)
+			}
+		})
+	}
+
+	t.Run("pages with a cursor", func(t *testing.T) {
+		first, _ := db.GetAuditEvents(ctx, models.AuditFilter{Limit: 2})
+		rest, _ := db.GetAuditEvents(ctx, models.AuditFilter{Limit: 2, BeforeID: first[1].ID})
+		if len(rest) != 2 || rest[0].ID >= first[1].ID {
+			t.Errorf("second page = %+v, want the two oldest events", rest)
+		}
+	})
+
+	t.Run("is append-only", func(t *testing.T) {
+		if _, err := db.DBX.ExecContext(ctx, "UPDATE audit_log SET action = 'x'"); err == nil {
+			t.Error("UPDATE audit_log succeeded, want error")
+		}
+		if _, err := db.DBX.ExecContext(ctx, "DELETE FROM audit_log"); err == nil {
+			t.Error("DELETE FROM audit_log succeeded, want error")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package dbx
+
+import (
+	"context"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func collectionArgs(c *models.Collection) map[string]any {
+	return map[string]any{
+		"id":          c.ID,
+		"user_id":     c.UserID,
+		"name":        c.Name,
+		"description": c.Description,
+		"vault":       c.Vault,
+		"query":       c.Query,
+		"team_id":     c.TeamID,
+	}
+}
+
+// CreateCollection stores a new collection with its ordered paths; paths
+// should be empty for smart collections
+func (d *DB) CreateCollection(ctx context.Context, c *models.Collection, paths []string) (*models.Collection, error) {
+	return d.saveCollection(ctx, "create_collection.sql", c, paths)
+}
+
+// UpdateCollection saves the fields of a collection its owner may change.
+// A nil paths leaves the items alone; an empty one clears them.
+func (d *DB) UpdateCollection(ctx context.Context, c *models.Collection, paths []string) (*models.Collection, error) {
+	return d.saveCollection(ctx, "update_collection.sql", c, paths)
+}
+
+func (d *DB) saveCollection(ctx context.Context, query string, c *models.Collection, paths []string) (*models.Collection, error) {
+	tx, err := d.DBX.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareNamedContext(ctx, MustQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var saved models.Collection
+	if err := stmt.GetContext(ctx, &saved, collectionArgs(c)); err != nil {
+		return nil, err
+	}
+
+	if paths != nil {
+		if _, err := tx.NamedExecContext(ctx, MustQuery("delete_collection_items.sql"), map[string]any{"collection_id": saved.ID}); err != nil {
+			return nil, err
+		}
+		for i, p := range paths {
+			if _, err := tx.NamedExecContext(ctx, MustQuery("add_collection_item.sql"), map[string]any{
+				"collection_id": saved.ID,
+				"position":      i,
+				"path":          p,
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &saved, tx.Commit()
+}
+
+// GetCollection returns a collection by id, or nil if it does not exist
+func (d *DB) GetCollection(ctx context.Context, id int64) (*models.Collection, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery("get_collection_by_id.sql"), map[string]any{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var c models.Collection
+	if err := rows.StructScan(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetCollectionsByUser returns the collections a user owns or that are
+// shared with one of their teams, newest first
+func (d *DB) GetCollectionsByUser(ctx context.Context, userID int64) ([]models.Collection, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery("get_collections_by_user.sql"), map[string]any{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := make([]models.Collection, 0)
+	for rows.Next() {
+		var c models.Collection
+		if err := rows.StructScan(&c); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+// GetCollectionItems returns the note paths of a collection in order
+func (d *DB) GetCollectionItems(ctx context.Context, collectionID int64) ([]string, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery("get_collection_items.sql"), map[string]any{"collection_id": collectionID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := make([]string, 0)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// DeleteCollection deletes a collection owned by userID with its items
+func (d *DB) DeleteCollection(ctx context.Context, id, userID int64) error {
+	q := MustQuery("delete_collection.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"id": id, "user_id": userID})
+	return err
+}
+
+// RenameCollectionItems points items at oldPath, or at notes below it when
+// it is a folder, in collections of vault at their new location
+func (d *DB) RenameCollectionItems(ctx context.Context, vault, oldPath, newPath string) error {
+	q := MustQuery("rename_collection_items.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{
+		"vault":    vault,
+		"old_path": oldPath,
+		"new_path": newPath,
+	})
+	return err
+}
+
+// RemoveCollectionItems drops path, or the notes below it when it is a
+// folder, from the collections of vault
+func (d *DB) RemoveCollectionItems(ctx context.Context, vault, path string) error {
+	q := MustQuery("remove_collection_items.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"vault": vault, "path": path})
+	return err
+}
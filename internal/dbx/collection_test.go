@@ -0,0 +1,95 @@
+package dbx
+
+import (
+	"strings"
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestCollections(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	owner, _ := db.CreateUser(ctx, "owner@example.com", "hash", "Owner")
+	member, _ := db.CreateUser(ctx, "member@example.com", "hash", "Member")
+	team, _ := db.CreateTeam(ctx, "Docs", nil, owner.ID)
+	db.AddTeamMember(ctx, team.ID, member.ID, models.RoleViewer)
+
+	reading, err := db.CreateCollection(ctx, &models.Collection{UserID: owner.ID, Name: "Reading", Vault: "users/1"},
+		[]string{"b.md", "notes/a.md", "notes/deep/c.md"})
+	if err != nil || reading.ID == 0 || reading.Smart() {
+		t.Fatalf("CreateCollection() = %+v, %v", reading, err)
+	}
+	items := func(id int64) string {
+		paths, err := db.GetCollectionItems(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(paths, ",")
+	}
+	if got := items(reading.ID); got != "b.md,notes/a.md,notes/deep/c.md" {
+		t.Errorf("items = %q, want them in order", got)
+	}
+
+	t.Run("updates fields and items", func(t *testing.T) {
+		reading.Name = "To read"
+		updated, err := db.UpdateCollection(ctx, reading, nil)
+		if err != nil || updated.Name != "To read" {
+			t.Fatalf("UpdateCollection() = %+v, %v", updated, err)
+		}
+		if got := items(reading.ID); got != "b.md,notes/a.md,notes/deep/c.md" {
+			t.Errorf("items after update without paths = %q", got)
+		}
+		db.UpdateCollection(ctx, reading, []string{"notes/deep/c.md", "notes/a.md", "b.md"})
+		if got := items(reading.ID); got != "notes/deep/c.md,notes/a.md,b.md" {
+			t.Errorf("items after reorder = %q", got)
+		}
+	})
+
+	t.Run("follows renames and deletes", func(t *testing.T) {
+		db.RenameCollectionItems(ctx, "users/1", "notes", "archive")
+		db.RenameCollectionItems(ctx, "users/2", "b.md", "elsewhere.md")
+		if got := items(reading.ID); got != "archive/deep/c.md,archive/a.md,b.md" {
+			t.Errorf("items after rename = %q", got)
+		}
+		db.RemoveCollectionItems(ctx, "users/1", "archive/deep")
+		db.RemoveCollectionItems(ctx, "users/1", "b")
+		if got := items(reading.ID); got != "archive/a.md,b.md" {
+			t.Errorf("items after delete = %q", got)
+		}
+	})
+
+	t.Run("lists own and shared collections", func(t *testing.T) {
+		smart, err := db.CreateCollection(ctx, &models.Collection{
+			UserID: owner.ID, Name: "Drafts", Vault: "teams/1", Query: "tag:draft", TeamID: &team.ID,
+		}, nil)
+		if err != nil || !smart.Smart() || smart.TeamID == nil {
+			t.Fatalf("CreateCollection(smart) = %+v, %v", smart, err)
+		}
+		if got, _ := db.GetCollectionsByUser(ctx, owner.ID); len(got) != 2 {
+			t.Errorf("owner sees %d collections, want 2", len(got))
+		}
+		got, _ := db.GetCollectionsByUser(ctx, member.ID)
+		if len(got) != 1 || got[0].ID != smart.ID {
+			t.Errorf("member sees %+v, want the shared one", got)
+		}
+	})
+
+	t.Run("deletes only own collections", func(t *testing.T) {
+		db.DeleteCollection(ctx, reading.ID, member.ID)
+		if got, _ := db.GetCollection(ctx, reading.ID); got == nil {
+			t.Fatal("another user deleted the collection")
+		}
+		if err := db.DeleteCollection(ctx, reading.ID, owner.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := db.GetCollection(ctx, reading.ID); got != nil || err != nil {
+			t.Errorf("GetCollection(deleted) = %+v, %v; want nil", got, err)
+		}
+		if got := items(reading.ID); got != "" {
+			t.Errorf("items of deleted collection = %q", got)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package dbx
+
+import (
+	"context"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func formArgs(f *models.Form) map[string]any {
+	return map[string]any{
+		"id":            f.ID,
+		"name":          f.Name,
+		"description":   f.Description,
+		"fields":        f.Fields,
+		"capture_vault": f.CaptureVault,
+		"capture_path":  f.CapturePath,
+		"capture_role":  f.CaptureRole,
+		"created_by":    f.CreatedBy,
+	}
+}
+
+// CreateForm stores a new form and returns it
+func (d *DB) CreateForm(ctx context.Context, f *models.Form) (*models.Form, error) {
+	return d.saveForm(ctx, "create_form.sql", f)
+}
+
+// UpdateForm stores the changes to a form and returns it
+func (d *DB) UpdateForm(ctx context.Context, f *models.Form) (*models.Form, error) {
+	return d.saveForm(ctx, "update_form.sql", f)
+}
+
+func (d *DB) saveForm(ctx context.Context, query string, f *models.Form) (*models.Form, error) {
+	stmt, err := d.DBX.PrepareNamedContext(ctx, MustQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var saved models.Form
+	if err := stmt.GetContext(ctx, &saved, formArgs(f)); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetForms returns every form
+func (d *DB) GetForms(ctx context.Context) ([]models.Form, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery("get_all_forms.sql"), map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forms := make([]models.Form, 0)
+	for rows.Next() {
+		var f models.Form
+		if err := rows.StructScan(&f); err != nil {
+			return nil, err
+		}
+		forms = append(forms, f)
+	}
+	return forms, rows.Err()
+}
+
+// GetForm returns a form by id, or nil if none exists
+func (d *DB) GetForm(ctx context.Context, id int64) (*models.Form, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery("get_form_by_id.sql"), map[string]any{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var f models.Form
+	if err := rows.StructScan(&f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// DeleteForm deletes a form and its submissions
+func (d *DB) DeleteForm(ctx context.Context, id int64) error {
+	q := MustQuery("delete_form.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"id": id})
+	return err
+}
+
+// CreateFormSubmission stores what a visitor submitted through a form
+func (d *DB) CreateFormSubmission(ctx context.Context, formID int64, data models.SubmissionData, ip, userAgent string) (*models.FormSubmission, error) {
+	stmt, err := d.DBX.PrepareNamedContext(ctx, MustQuery("create_form_submission.sql"))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var s models.FormSubmission
+	args := map[string]any{
+		"form_id":    formID,
+		"data":       data,
+		"ip":         ip,
+		"user_agent": userAgent,
+	}
+	if err := stmt.GetContext(ctx, &s, args); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetFormSubmissions returns the submissions of a form, oldest first
+func (d *DB) GetFormSubmissions(ctx context.Context, formID int64) ([]models.FormSubmission, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery("get_form_submissions.sql"), map[string]any{"form_id": formID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := make([]models.FormSubmission, 0)
+	for rows.Next() {
+		var s models.FormSubmission
+		if err := rows.StructScan(&s); err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
@@ -0,0 +1,73 @@
+package dbx
+
+import (
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestForms(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	max := 10.0
+	form, err := db.CreateForm(ctx, &models.Form{
+		Name: "Contact",
+		Fields: models.FormFields{
+			{Name: "email", Type: models.FieldEmail, Required: true},
+			{Name: "rating", Type: models.FieldNumber, Max: &max},
+		},
+		CaptureVault: "users/1",
+		CapturePath:  "inbox.md",
+	})
+	if err != nil {
+		t.Fatalf("CreateForm() error = %v", err)
+	}
+
+	t.Run("round-trips the field schema", func(t *testing.T) {
+		got, err := db.GetForm(ctx, form.ID)
+		if err != nil || got == nil {
+			t.Fatalf("GetForm() = %v, %v", got, err)
+		}
+		if len(got.Fields) != 2 || got.Fields[0].Type != models.FieldEmail || *got.Fields[1].Max != 10 || got.CapturePath != "inbox.md" {
+			t.Errorf("GetForm() = %+v", got)
+		}
+		if missing, err := db.GetForm(ctx, form.ID+1); missing != nil || err != nil {
+			t.Errorf("GetForm(missing) = %v, %v", missing, err)
+		}
+	})
+
+	t.Run("updates", func(t *testing.T) {
+		form.Name = "Contact us"
+		form.Fields = form.Fields[:1]
+		updated, err := db.UpdateForm(ctx, form)
+		if err != nil || updated.Name != "Contact us" || len(updated.Fields) != 1 {
+			t.Errorf("UpdateForm() = %+v, %v", updated, err)
+		}
+		forms, _ := db.GetForms(ctx)
+		if len(forms) != 1 {
+			t.Errorf("GetForms() returned %d forms, want 1", len(forms))
+		}
+	})
+
+	t.Run("stores submissions", func(t *testing.T) {
+		if _, err := db.CreateFormSubmission(ctx, form.ID, models.SubmissionData{"email": "a@example.com"}, "10.0.0.1", "test"); err != nil {
+			t.Fatalf("CreateFormSubmission() error = %v", err)
+		}
+		db.CreateFormSubmission(ctx, form.ID, models.SubmissionData{"email": "b@example.com"}, "10.0.0.2", "test")
+		subs, err := db.GetFormSubmissions(ctx, form.ID)
+		if err != nil || len(subs) != 2 || subs[0].Data["email"] != "a@example.com" || subs[1].IP != "10.0.0.2" {
+			t.Errorf("GetFormSubmissions() = %+v, %v", subs, err)
+		}
+	})
+
+	t.Run("deletes submissions with the form", func(t *testing.T) {
+		if err := db.DeleteForm(ctx, form.ID); err != nil {
+			t.Fatal(err)
+		}
+		if subs, _ := db.GetFormSubmissions(ctx, form.ID); len(subs) != 0 {
+			t.Errorf("%d submissions left after deleting the form", len(subs))
+		}
+	})
+}
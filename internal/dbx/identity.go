@@ -0,0 +1,43 @@
+package dbx
+
+import (
+	"context"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// GetUserByIdentity returns the user linked to an external identity, or nil
+func (d *DB) GetUserByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	q := MustQuery("get_user_by_identity.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{
+		"issuer":  issuer,
+		"subject": subject,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	var u models.User
+	if err := rows.StructScan(&u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// LinkUserIdentity links an external identity to a local user
+func (d *DB) LinkUserIdentity(ctx context.Context, issuer, subject string, userID int64, email string) error {
+	q := MustQuery("create_user_identity.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{
+		"issuer":  issuer,
+		"subject": subject,
+		"user_id": userID,
+		"email":   email,
+	})
+	return err
+}
@@ -0,0 +1,95 @@
+package dbx
+
+import (
+	"context"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// GetLoginThrottle returns the throttle state for key, or nil if none is recorded
+func (d *DB) GetLoginThrottle(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	q := MustQuery("get_login_throttle.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{"key": key})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	var t models.LoginThrottle
+	if err := rows.StructScan(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// UpsertLoginThrottle creates or replaces the throttle state for a key
+func (d *DB) UpsertLoginThrottle(ctx context.Context, t *models.LoginThrottle) error {
+	q := MustQuery("upsert_login_throttle.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{
+		"key":             t.Key,
+		"failures":        t.Failures,
+		"last_failure_at": t.LastFailureAt,
+		"locked_until":    t.LockedUntil,
+	})
+	return err
+}
+
+// DeleteLoginThrottle forgets the throttle state for a key
+func (d *DB) DeleteLoginThrottle(ctx context.Context, key string) error {
+	q := MustQuery("delete_login_throttle.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"key": key})
+	return err
+}
+
+// CleanLoginThrottles removes throttle state that has been quiet since threshold
+func (d *DB) CleanLoginThrottles(ctx context.Context, threshold time.Time) error {
+	q := MustQuery("clean_login_throttles.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"threshold": threshold})
+	return err
+}
+
+// RecordLoginAttempt appends a login attempt to the audit trail
+func (d *DB) RecordLoginAttempt(ctx context.Context, a *models.LoginAttempt) error {
+	q := MustQuery("create_login_attempt.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{
+		"email":      a.Email,
+		"ip":         a.IP,
+		"user_agent": a.UserAgent,
+		"success":    a.Success,
+		"reason":     a.Reason,
+	})
+	return err
+}
+
+// GetLoginAttempts returns the most recent login attempts, optionally for one email
+func (d *DB) GetLoginAttempts(ctx context.Context, email string, limit int) ([]models.LoginAttempt, error) {
+	q := MustQuery("get_login_attempts.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{
+		"email": email,
+		"limit": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := make([]models.LoginAttempt, 0)
+	for rows.Next() {
+		var a models.LoginAttempt
+		if err := rows.StructScan(&a); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
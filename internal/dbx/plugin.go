@@ -0,0 +1,105 @@
+package dbx
+
+import (
+	"context"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// AddPlugin registers an installed plugin, inactive. Registering one that
+// exists updates its details and keeps whether it is active.
+func (d *DB) AddPlugin(ctx context.Context, p *models.Plugin) (*models.Plugin, error) {
+	stmt, err := d.DBX.PrepareNamedContext(ctx, MustQuery("add_plugin.sql"))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var saved models.Plugin
+	args := map[string]any{
+		"name":            p.Name,
+		"display_name":    p.DisplayName,
+		"description":     p.Description,
+		"version":         p.Version,
+		"sidebar_icon":    p.SidebarIcon,
+		"sidebar_title":   p.SidebarTitle,
+		"sidebar_link":    p.SidebarLink,
+		"metadata_schema": p.MetadataSchema,
+		"hook_module":     p.HookModule,
+		"capabilities":    p.Capabilities,
+	}
+	if err := stmt.GetContext(ctx, &saved, args); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetPlugins returns every installed plugin by name
+func (d *DB) GetPlugins(ctx context.Context) ([]models.Plugin, error) {
+	return d.queryPlugins(ctx, "get_all_plugins.sql")
+}
+
+// GetActivePlugins returns the enabled plugins by name
+func (d *DB) GetActivePlugins(ctx context.Context) ([]models.Plugin, error) {
+	return d.queryPlugins(ctx, "get_active_plugins.sql")
+}
+
+func (d *DB) queryPlugins(ctx context.Context, query string) ([]models.Plugin, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery(query), map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plugins := make([]models.Plugin, 0)
+	for rows.Next() {
+		var p models.Plugin
+		if err := rows.StructScan(&p); err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, rows.Err()
+}
+
+// GetPlugin returns the plugin with name, or nil if it is not installed
+func (d *DB) GetPlugin(ctx context.Context, name string) (*models.Plugin, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery("get_plugin_by_name.sql"), map[string]any{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var p models.Plugin
+	if err := rows.StructScan(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetPluginActive enables or disables a plugin
+func (d *DB) SetPluginActive(ctx context.Context, name string, active bool) error {
+	q := MustQuery("update_plugin_status.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"name": name, "is_active": active})
+	return err
+}
+
+// SetPluginGrants replaces the capabilities granted to a plugin
+func (d *DB) SetPluginGrants(ctx context.Context, name string, granted models.Capabilities) error {
+	q := MustQuery("set_plugin_grants.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"name": name, "granted_capabilities": granted})
+	return err
+}
+
+// DeletePlugin unregisters a plugin
+func (d *DB) DeletePlugin(ctx context.Context, name string) error {
+	q := MustQuery("delete_plugin.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"name": name})
+	return err
+}
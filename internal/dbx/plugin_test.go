@@ -0,0 +1,68 @@
+package dbx
+
+import (
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestPlugins(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	p, err := db.AddPlugin(ctx, &models.Plugin{
+		Name:        "core.kanban",
+		DisplayName: "Kanban",
+		Version:     "1.0.0",
+		SidebarLink: "/kanban",
+		MetadataSchema: models.PluginSchema{
+			{Key: "status", Label: "Status", Type: models.PluginFieldSelect, Options: []string{"todo", "done"}},
+		},
+	})
+	if err != nil || p.ID == 0 || p.IsActive {
+		t.Fatalf("AddPlugin() = %+v, %v", p, err)
+	}
+	if len(p.MetadataSchema) != 1 || p.MetadataSchema[0].Options[1] != "done" {
+		t.Errorf("metadata schema = %+v", p.MetadataSchema)
+	}
+	db.AddPlugin(ctx, &models.Plugin{Name: "core.zettelkasten", DisplayName: "Zettelkasten", Version: "0.1.0"})
+
+	if err := db.SetPluginActive(ctx, "core.kanban", true); err != nil {
+		t.Fatal(err)
+	}
+	active, _ := db.GetActivePlugins(ctx)
+	if len(active) != 1 || active[0].Name != "core.kanban" {
+		t.Errorf("GetActivePlugins() = %+v", active)
+	}
+
+	// Reinstalling updates the details and keeps the plugin enabled
+	again, err := db.AddPlugin(ctx, &models.Plugin{Name: "core.kanban", DisplayName: "Kanban Board", Version: "1.1.0"})
+	if err != nil || again.ID != p.ID || again.Version != "1.1.0" || !again.IsActive || len(again.MetadataSchema) != 0 {
+		t.Errorf("AddPlugin(again) = %+v, %v", again, err)
+	}
+
+	t.Run("keeps grants across reinstalls", func(t *testing.T) {
+		caps := models.Capabilities{models.CapReadNotes, models.CapWriteNotes}
+		db.AddPlugin(ctx, &models.Plugin{Name: "core.kanban", DisplayName: "Kanban", Version: "1.2.0", HookModule: "hooks.wasm", Capabilities: caps})
+		if err := db.SetPluginGrants(ctx, "core.kanban", models.Capabilities{models.CapReadNotes}); err != nil {
+			t.Fatal(err)
+		}
+		// The new version no longer asks to read notes
+		db.AddPlugin(ctx, &models.Plugin{Name: "core.kanban", DisplayName: "Kanban", Version: "1.3.0", HookModule: "hooks.wasm", Capabilities: caps[1:]})
+		got, _ := db.GetPlugin(ctx, "core.kanban")
+		if got.HookModule != "hooks.wasm" || len(got.Granted) != 1 || len(got.Active()) != 0 {
+			t.Errorf("after reinstall = %+v, active %v", got, got.Active())
+		}
+	})
+
+	if err := db.DeletePlugin(ctx, "core.kanban"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := db.GetPlugin(ctx, "core.kanban"); got != nil || err != nil {
+		t.Errorf("GetPlugin(deleted) = %+v, %v; want nil", got, err)
+	}
+	if all, _ := db.GetPlugins(ctx); len(all) != 1 || all[0].Name != "core.zettelkasten" {
+		t.Errorf("GetPlugins() = %+v", all)
+	}
+}
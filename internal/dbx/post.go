@@ -0,0 +1,158 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func postArgs(p *models.Post) map[string]any {
+	var publishAt *time.Time
+	if p.PublishAt != nil {
+		t := p.PublishAt.UTC().Truncate(time.Second)
+		publishAt = &t
+	}
+	return map[string]any{
+		"id":           p.ID,
+		"author_id":    p.AuthorID,
+		"title":        p.Title,
+		"slug":         p.Slug,
+		"content":      p.Content,
+		"status":       p.Status,
+		"visibility":   p.Visibility,
+		"format":       p.Format,
+		"excerpt":      p.Excerpt,
+		"publish_at":   publishAt,
+		"source_vault": p.SourceVault,
+		"source_path":  p.SourcePath,
+	}
+}
+
+// uniquePostSlug returns slug, or slug with a numeric suffix if another
+// post already uses it
+func (d *DB) uniquePostSlug(ctx context.Context, slug string, id int64) (string, error) {
+	candidate := slug
+	for n := 2; ; n++ {
+		other, err := d.GetPostBySlug(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if other == nil || other.ID == id {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", slug, n)
+	}
+}
+
+// CreatePost stores a new post, suffixing its slug if it is taken
+func (d *DB) CreatePost(ctx context.Context, p *models.Post) (*models.Post, error) {
+	return d.savePost(ctx, "create_post.sql", p)
+}
+
+// UpdatePost saves every field of an existing post, suffixing its slug if
+// another post has it
+func (d *DB) UpdatePost(ctx context.Context, p *models.Post) (*models.Post, error) {
+	return d.savePost(ctx, "update_post.sql", p)
+}
+
+func (d *DB) savePost(ctx context.Context, query string, p *models.Post) (*models.Post, error) {
+	slug, err := d.uniquePostSlug(ctx, p.Slug, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	args := postArgs(p)
+	args["slug"] = slug
+
+	stmt, err := d.DBX.PrepareNamedContext(ctx, MustQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var saved models.Post
+	if err := stmt.GetContext(ctx, &saved, args); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetPosts returns posts newest first, optionally only those with status
+func (d *DB) GetPosts(ctx context.Context, status string) ([]models.Post, error) {
+	return d.queryPosts(ctx, "get_all_posts.sql", map[string]any{"status": status})
+}
+
+// GetPublishedPosts returns up to limit public, published posts, most
+// recently published first
+func (d *DB) GetPublishedPosts(ctx context.Context, limit int) ([]models.Post, error) {
+	return d.queryPosts(ctx, "get_published_posts.sql", map[string]any{"limit": limit})
+}
+
+// PublishDuePosts publishes scheduled posts whose publish_at is not after
+// now and returns them
+func (d *DB) PublishDuePosts(ctx context.Context, now time.Time) ([]models.Post, error) {
+	return d.queryPosts(ctx, "publish_due_posts.sql", map[string]any{"now": now.UTC()})
+}
+
+func (d *DB) queryPosts(ctx context.Context, query string, args map[string]any) ([]models.Post, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery(query), args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]models.Post, 0)
+	for rows.Next() {
+		var p models.Post
+		if err := rows.StructScan(&p); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+// GetPost returns a post by id, or nil if it does not exist
+func (d *DB) GetPost(ctx context.Context, id int64) (*models.Post, error) {
+	return d.getPost(ctx, "get_post_by_id.sql", map[string]any{"id": id})
+}
+
+// GetPostBySlug returns the post with slug, or nil if there is none
+func (d *DB) GetPostBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	return d.getPost(ctx, "get_post_by_slug.sql", map[string]any{"slug": slug})
+}
+
+// GetPostBySource returns the post promoted from a vault note, or nil
+func (d *DB) GetPostBySource(ctx context.Context, vault, path string) (*models.Post, error) {
+	return d.getPost(ctx, "get_post_by_source.sql", map[string]any{"source_vault": vault, "source_path": path})
+}
+
+func (d *DB) getPost(ctx context.Context, query string, args map[string]any) (*models.Post, error) {
+	posts, err := d.queryPosts(ctx, query, args)
+	if err != nil || len(posts) == 0 {
+		return nil, err
+	}
+	return &posts[0], nil
+}
+
+// DeletePost deletes a post
+func (d *DB) DeletePost(ctx context.Context, id int64) error {
+	q := MustQuery("delete_post.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"id": id})
+	return err
+}
+
+// RenamePostSources keeps posts promoted from oldPath, or from notes below
+// it when it is a folder, attached to their note
+func (d *DB) RenamePostSources(ctx context.Context, vault, oldPath, newPath string) error {
+	q := MustQuery("rename_post_sources.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{
+		"vault":    vault,
+		"old_path": oldPath,
+		"new_path": newPath,
+	})
+	return err
+}
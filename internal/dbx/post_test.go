@@ -0,0 +1,90 @@
+package dbx
+
+import (
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestPosts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	newPost := func(title, slug, status string) *models.Post {
+		t.Helper()
+		now := time.Now()
+		p, err := db.CreatePost(ctx, &models.Post{
+			Title: title, Slug: slug, Status: status, Visibility: models.PostPublic,
+			Format: models.FormatMarkdown, PublishAt: &now,
+		})
+		if err != nil {
+			t.Fatalf("CreatePost(%s) error = %v", title, err)
+		}
+		return p
+	}
+
+	first := newPost("Hello", "hello", models.PostPublished)
+	second := newPost("Hello again", "hello", models.PostDraft)
+	third := newPost("Hello thrice", "hello", models.PostPublished)
+
+	t.Run("suffixes taken slugs", func(t *testing.T) {
+		if first.Slug != "hello" || second.Slug != "hello-2" || third.Slug != "hello-3" {
+			t.Errorf("slugs = %q, %q, %q", first.Slug, second.Slug, third.Slug)
+		}
+		second.Slug = "hello-2"
+		second.Title = "Renamed"
+		updated, err := db.UpdatePost(ctx, second)
+		if err != nil || updated.Slug != "hello-2" || updated.Title != "Renamed" {
+			t.Errorf("UpdatePost() keeping own slug = %+v, %v", updated, err)
+		}
+		second.Slug = "hello"
+		if updated, _ := db.UpdatePost(ctx, second); updated.Slug != "hello-2" {
+			t.Errorf("UpdatePost() to a taken slug = %q, want its own hello-2", updated.Slug)
+		}
+		third.Slug = "hello"
+		if updated, _ := db.UpdatePost(ctx, third); updated.Slug != "hello-3" {
+			t.Errorf("UpdatePost() to a taken slug = %q, want hello-3", updated.Slug)
+		}
+	})
+
+	t.Run("filters by status", func(t *testing.T) {
+		drafts, _ := db.GetPosts(ctx, models.PostDraft)
+		all, _ := db.GetPosts(ctx, "")
+		if len(drafts) != 1 || len(all) != 3 {
+			t.Errorf("drafts = %d, all = %d", len(drafts), len(all))
+		}
+		live, _ := db.GetPublishedPosts(ctx, 1)
+		if len(live) != 1 {
+			t.Errorf("GetPublishedPosts(1) = %d posts", len(live))
+		}
+	})
+
+	t.Run("tracks promoted notes", func(t *testing.T) {
+		p, err := db.CreatePost(ctx, &models.Post{
+			Title: "From a note", Slug: "from-a-note", Status: models.PostDraft, Visibility: models.PostPublic,
+			Format: models.FormatMarkdown, SourceVault: "users/1", SourcePath: "blog/a.md",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.RenamePostSources(ctx, "users/1", "blog", "posts")
+		got, _ := db.GetPostBySource(ctx, "users/1", "posts/a.md")
+		if got == nil || got.ID != p.ID {
+			t.Errorf("GetPostBySource() after folder rename = %+v", got)
+		}
+		if got, _ := db.GetPostBySource(ctx, "users/2", "posts/a.md"); got != nil {
+			t.Error("GetPostBySource() matched another vault")
+		}
+	})
+
+	t.Run("deletes", func(t *testing.T) {
+		if err := db.DeletePost(ctx, first.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := db.GetPost(ctx, first.ID); got != nil {
+			t.Errorf("GetPost() after delete = %+v", got)
+		}
+	})
+}
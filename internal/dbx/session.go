@@ -4,7 +4,6 @@ import (
 	"context"
 	"dragonbytelabs/dz/internal/models"
 	"encoding/json"
-	"log"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -40,7 +39,7 @@ func (d *DB) GetSessionById(ctx context.Context, id string) (*models.Session, er
 }
 
 // WriteSession creates or updates a session
-func (d *DB) CreateSession(ctx context.Context, id string, data map[string]any, createdAt, lastActivityAt time.Time) error {
+func (d *DB) CreateSession(ctx context.Context, id string, data map[string]any, meta models.SessionMeta, createdAt, lastActivityAt time.Time) error {
 	q := MustQuery("create_session.sql")
 
 	dataJSON, err := json.Marshal(data)
@@ -48,9 +47,6 @@ func (d *DB) CreateSession(ctx context.Context, id string, data map[string]any,
 		return err
 	}
 
-	log.Printf("WriteSession: id=%s, data=%s, created=%v, lastActivity=%v",
-		id, string(dataJSON), createdAt, lastActivityAt) // Add logging
-
 	stmt, err := d.DBX.PrepareNamedContext(ctx, q)
 	if err != nil {
 		return err
@@ -60,19 +56,38 @@ func (d *DB) CreateSession(ctx context.Context, id string, data map[string]any,
 	args := map[string]interface{}{
 		"id":               id,
 		"data":             string(dataJSON),
+		"user_id":          meta.UserID,
+		"user_agent":       meta.UserAgent,
+		"ip":               meta.IP,
 		"created_at":       createdAt,
 		"last_activity_at": lastActivityAt,
 	}
 
-	result, err := stmt.ExecContext(ctx, args)
-	if err != nil {
+	if _, err := stmt.ExecContext(ctx, args); err != nil {
 		return err
 	}
+	return nil
+}
 
-	rowsAffected, _ := result.RowsAffected()
-	log.Printf("WriteSession: affected %d rows", rowsAffected) // Add logging
+// GetSessionsByUser returns all sessions belonging to a user, most recently active first
+func (d *DB) GetSessionsByUser(ctx context.Context, userID string) ([]models.Session, error) {
+	q := MustQuery("get_sessions_by_user.sql")
 
-	return nil
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]interface{}{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]models.Session, 0)
+	for rows.Next() {
+		var s models.Session
+		if err := rows.StructScan(&s); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
 }
 
 // DeleteSession removes a session by ID
@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"dragonbytelabs/dz/internal/models"
 )
 
 func TestDB_CreateSession(t *testing.T) {
@@ -17,7 +19,7 @@ func TestDB_CreateSession(t *testing.T) {
 		data := map[string]any{"key": "value"}
 		now := time.Now()
 
-		err := db.CreateSession(ctx, id, data, now, now)
+		err := db.CreateSession(ctx, id, data, models.SessionMeta{}, now, now)
 		if err != nil {
 			t.Fatalf("CreateSession() returned error: %v", err)
 		}
@@ -42,13 +44,13 @@ func TestDB_CreateSession(t *testing.T) {
 		now := time.Now()
 
 		// Create first
-		err := db.CreateSession(ctx, id, data1, now, now)
+		err := db.CreateSession(ctx, id, data1, models.SessionMeta{}, now, now)
 		if err != nil {
 			t.Fatalf("CreateSession() first call returned error: %v", err)
 		}
 
 		// Update with same ID
-		err = db.CreateSession(ctx, id, data2, now, now)
+		err = db.CreateSession(ctx, id, data2, models.SessionMeta{}, now, now)
 		if err != nil {
 			t.Fatalf("CreateSession() second call returned error: %v", err)
 		}
@@ -91,7 +93,7 @@ func TestDB_DeleteSession(t *testing.T) {
 	id := "session-to-delete"
 	data := map[string]any{"key": "value"}
 	now := time.Now()
-	err := db.CreateSession(ctx, id, data, now, now)
+	err := db.CreateSession(ctx, id, data, models.SessionMeta{}, now, now)
 	if err != nil {
 		t.Fatalf("CreateSession() returned error: %v", err)
 	}
@@ -130,21 +132,21 @@ func TestDB_CleanExpiredSessions(t *testing.T) {
 	now := time.Now()
 
 	// Valid session
-	err := db.CreateSession(ctx, "valid-session", map[string]any{}, now, now)
+	err := db.CreateSession(ctx, "valid-session", map[string]any{}, models.SessionMeta{}, now, now)
 	if err != nil {
 		t.Fatalf("CreateSession() returned error: %v", err)
 	}
 
 	// Idle-expired session (old last activity)
 	oldActivity := now.Add(-2 * time.Hour)
-	err = db.CreateSession(ctx, "idle-expired", map[string]any{}, now, oldActivity)
+	err = db.CreateSession(ctx, "idle-expired", map[string]any{}, models.SessionMeta{}, now, oldActivity)
 	if err != nil {
 		t.Fatalf("CreateSession() returned error: %v", err)
 	}
 
 	// Absolute-expired session (old creation)
 	oldCreation := now.Add(-13 * time.Hour)
-	err = db.CreateSession(ctx, "absolute-expired", map[string]any{}, oldCreation, now)
+	err = db.CreateSession(ctx, "absolute-expired", map[string]any{}, models.SessionMeta{}, oldCreation, now)
 	if err != nil {
 		t.Fatalf("CreateSession() returned error: %v", err)
 	}
@@ -175,3 +177,30 @@ func TestDB_CleanExpiredSessions(t *testing.T) {
 		t.Error("CleanExpiredSessions() did not remove absolute-expired session")
 	}
 }
+
+func TestDB_GetSessionsByUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	meta := models.SessionMeta{UserID: "user-a", UserAgent: "curl/8", IP: "192.0.2.7"}
+	db.CreateSession(ctx, "a-1", map[string]any{}, meta, now, now.Add(-time.Minute))
+	db.CreateSession(ctx, "a-2", map[string]any{}, meta, now, now)
+	db.CreateSession(ctx, "b-1", map[string]any{}, models.SessionMeta{UserID: "user-b"}, now, now)
+
+	sessions, err := db.GetSessionsByUser(ctx, "user-a")
+	if err != nil {
+		t.Fatalf("GetSessionsByUser() returned error: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("GetSessionsByUser() returned %d sessions, want 2", len(sessions))
+	}
+	if sessions[0].ID != "a-2" {
+		t.Errorf("GetSessionsByUser()[0].ID = %q, want most recent %q", sessions[0].ID, "a-2")
+	}
+	if sessions[0].UserAgent != "curl/8" || sessions[0].IP != "192.0.2.7" {
+		t.Errorf("GetSessionsByUser()[0] metadata = %q/%q", sessions[0].UserAgent, sessions[0].IP)
+	}
+}
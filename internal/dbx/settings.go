@@ -0,0 +1,34 @@
+package dbx
+
+import "context"
+
+// GetSiteSetting returns the value of a site setting, or "" if it isn't set
+func (d *DB) GetSiteSetting(ctx context.Context, key string) (string, error) {
+	q := MustQuery("get_site_setting.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{"setting_key": key})
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", rows.Err()
+	}
+
+	var value string
+	if err := rows.Scan(&value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// SetSiteSetting creates or replaces a site setting
+func (d *DB) SetSiteSetting(ctx context.Context, key, value string) error {
+	q := MustQuery("update_site_setting.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{
+		"setting_key":   key,
+		"setting_value": value,
+	})
+	return err
+}
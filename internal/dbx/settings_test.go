@@ -0,0 +1,22 @@
+package dbx
+
+import "testing"
+
+func TestSiteSettings(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	if v, err := db.GetSiteSetting(ctx, "active_theme"); err != nil || v != "" {
+		t.Fatalf("GetSiteSetting() unset = %q, %v; want empty", v, err)
+	}
+	if err := db.SetSiteSetting(ctx, "active_theme", "plain"); err != nil {
+		t.Fatalf("SetSiteSetting() error = %v", err)
+	}
+	if err := db.SetSiteSetting(ctx, "active_theme", "dark"); err != nil {
+		t.Fatalf("SetSiteSetting() update error = %v", err)
+	}
+	if v, err := db.GetSiteSetting(ctx, "active_theme"); err != nil || v != "dark" {
+		t.Errorf("GetSiteSetting() = %q, %v; want dark", v, err)
+	}
+}
@@ -0,0 +1,122 @@
+package dbx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// generateShareToken creates a random 32-byte token encoded as base64 URL-safe string
+func generateShareToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to generate share token")
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// CreateShareLink creates a link to the note at path in vault with a fresh
+// token. passwordHash may be empty for links without a password.
+func (d *DB) CreateShareLink(ctx context.Context, vault, path string, createdBy int64, passwordHash string, expiresAt time.Time) (*models.ShareLink, error) {
+	q := MustQuery("create_share_link.sql")
+
+	stmt, err := d.DBX.PrepareNamedContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var link models.ShareLink
+	args := map[string]any{
+		"token":         generateShareToken(),
+		"vault":         vault,
+		"path":          path,
+		"created_by":    createdBy,
+		"password_hash": passwordHash,
+		"expires_at":    expiresAt.UTC(),
+	}
+	if err := stmt.GetContext(ctx, &link, args); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// GetShareLinkByToken returns the link with token, or nil if none exists
+func (d *DB) GetShareLinkByToken(ctx context.Context, token string) (*models.ShareLink, error) {
+	return d.getShareLink(ctx, "get_share_link_by_token.sql", map[string]any{"token": token})
+}
+
+// GetShareLink returns a link by id, or nil if none exists
+func (d *DB) GetShareLink(ctx context.Context, id int64) (*models.ShareLink, error) {
+	return d.getShareLink(ctx, "get_share_link_by_id.sql", map[string]any{"id": id})
+}
+
+func (d *DB) getShareLink(ctx context.Context, query string, args map[string]any) (*models.ShareLink, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery(query), args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var link models.ShareLink
+	if err := rows.StructScan(&link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// GetShareLinksByUser returns the links a user created, newest first
+func (d *DB) GetShareLinksByUser(ctx context.Context, userID int64) ([]models.ShareLink, error) {
+	q := MustQuery("get_share_links_by_user.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{"created_by": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make([]models.ShareLink, 0)
+	for rows.Next() {
+		var link models.ShareLink
+		if err := rows.StructScan(&link); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// RevokeShareLink disables a link; revoking it again is a no-op
+func (d *DB) RevokeShareLink(ctx context.Context, id int64) error {
+	q := MustQuery("revoke_share_link.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"id": id})
+	return err
+}
+
+// RecordShareView counts one view of a link
+func (d *DB) RecordShareView(ctx context.Context, id int64) error {
+	q := MustQuery("record_share_view.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"id": id})
+	return err
+}
+
+// RenameShareLinks points the links to oldPath, or to notes below it when
+// it is a folder, at their new location
+func (d *DB) RenameShareLinks(ctx context.Context, vault, oldPath, newPath string) error {
+	q := MustQuery("rename_share_links.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{
+		"vault":    vault,
+		"old_path": oldPath,
+		"new_path": newPath,
+	})
+	return err
+}
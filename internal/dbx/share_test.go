@@ -0,0 +1,76 @@
+package dbx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShareLinks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	user, err := db.CreateUser(ctx, "sharer@example.com", "hash", "Sharer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	link, err := db.CreateShareLink(ctx, "users/1", "notes/a.md", user.ID, "", expires)
+	if err != nil {
+		t.Fatalf("CreateShareLink() error = %v", err)
+	}
+	if len(link.Token) < 40 || !link.ExpiresAt.Equal(expires) || !link.Active(time.Now()) {
+		t.Errorf("CreateShareLink() = %+v", link)
+	}
+	other, _ := db.CreateShareLink(ctx, "users/1", "notes/ab.md", user.ID, "", expires)
+	if other.Token == link.Token {
+		t.Error("tokens are not unique")
+	}
+
+	t.Run("looks up by token", func(t *testing.T) {
+		got, err := db.GetShareLinkByToken(ctx, link.Token)
+		if err != nil || got == nil || got.ID != link.ID {
+			t.Fatalf("GetShareLinkByToken() = %+v, %v", got, err)
+		}
+		if got, err := db.GetShareLinkByToken(ctx, "nope"); got != nil || err != nil {
+			t.Errorf("GetShareLinkByToken(unknown) = %+v, %v; want nil", got, err)
+		}
+	})
+
+	t.Run("counts views", func(t *testing.T) {
+		db.RecordShareView(ctx, link.ID)
+		db.RecordShareView(ctx, link.ID)
+		got, _ := db.GetShareLink(ctx, link.ID)
+		if got.ViewCount != 2 || got.LastViewedAt == nil {
+			t.Errorf("after two views = %+v", got)
+		}
+	})
+
+	t.Run("follows renames", func(t *testing.T) {
+		db.RenameShareLinks(ctx, "users/1", "notes", "archive/notes")
+		db.RenameShareLinks(ctx, "users/1", "archive/notes/ab.md", "archive/notes/b.md")
+		db.RenameShareLinks(ctx, "users/2", "archive/notes/a.md", "elsewhere.md")
+		a, _ := db.GetShareLink(ctx, link.ID)
+		b, _ := db.GetShareLink(ctx, other.ID)
+		if a.Path != "archive/notes/a.md" || b.Path != "archive/notes/b.md" {
+			t.Errorf("paths after rename = %q, %q", a.Path, b.Path)
+		}
+	})
+
+	t.Run("revokes", func(t *testing.T) {
+		if err := db.RevokeShareLink(ctx, link.ID); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := db.GetShareLink(ctx, link.ID)
+		if got.RevokedAt == nil || got.Active(time.Now()) {
+			t.Errorf("revoked link = %+v", got)
+		}
+	})
+
+	t.Run("lists a user's links", func(t *testing.T) {
+		links, err := db.GetShareLinksByUser(ctx, user.ID)
+		if err != nil || len(links) != 2 {
+			t.Errorf("GetShareLinksByUser() = %d links, %v", len(links), err)
+		}
+	})
+}
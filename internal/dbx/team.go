@@ -0,0 +1,366 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// ErrInvitationNotPending is returned when responding to an invitation that
+// was already accepted or declined
+var ErrInvitationNotPending = errors.New("invitation is no longer pending")
+
+// AddTeamMember adds a user to a team with the given role
+func (d *DB) AddTeamMember(ctx context.Context, teamID, userID int64, role string) (*models.TeamMember, error) {
+	q := MustQuery("add_team_member.sql")
+
+	stmt, err := d.DBX.PrepareNamedContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var m models.TeamMember
+	args := map[string]any{
+		"team_id": teamID,
+		"user_id": userID,
+		"role":    role,
+	}
+	if err := stmt.GetContext(ctx, &m, args); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetTeamMember returns a user's membership in a team, or nil if they are not a member
+func (d *DB) GetTeamMember(ctx context.Context, teamID, userID int64) (*models.TeamMember, error) {
+	q := MustQuery("get_team_member.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{
+		"team_id": teamID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	var m models.TeamMember
+	if err := rows.StructScan(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// CreateTeam creates a team and makes ownerID its owner
+func (d *DB) CreateTeam(ctx context.Context, name string, description *string, ownerID int64) (*models.Team, error) {
+	tx, err := d.DBX.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareNamedContext(ctx, MustQuery("create_team.sql"))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var team models.Team
+	args := map[string]any{
+		"name":        name,
+		"description": description,
+		"avatar_url":  nil,
+	}
+	if err := stmt.GetContext(ctx, &team, args); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.NamedExecContext(ctx, MustQuery("add_team_member.sql"), map[string]any{
+		"team_id": team.ID,
+		"user_id": ownerID,
+		"role":    models.RoleOwner,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &team, tx.Commit()
+}
+
+// GetTeamByID returns a team, or nil if it does not exist
+func (d *DB) GetTeamByID(ctx context.Context, id int64) (*models.Team, error) {
+	q := MustQuery("get_team_by_id.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	var t models.Team
+	if err := rows.StructScan(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTeamsByUser returns the teams a user belongs to, newest first
+func (d *DB) GetTeamsByUser(ctx context.Context, userID int64) ([]models.Team, error) {
+	q := MustQuery("get_teams_by_user.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := make([]models.Team, 0)
+	for rows.Next() {
+		var t models.Team
+		if err := rows.StructScan(&t); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+// UpdateTeam saves a team's name, description and avatar
+func (d *DB) UpdateTeam(ctx context.Context, team *models.Team) (*models.Team, error) {
+	q := MustQuery("update_team.sql")
+
+	stmt, err := d.DBX.PrepareNamedContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var t models.Team
+	if err := stmt.GetContext(ctx, &t, team); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteTeam removes a team along with its memberships and invitations
+func (d *DB) DeleteTeam(ctx context.Context, id int64) error {
+	q := MustQuery("delete_team.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"id": id})
+	return err
+}
+
+// GetTeamMembers returns a team's members with their email and display name
+func (d *DB) GetTeamMembers(ctx context.Context, teamID int64) ([]models.TeamMemberDetail, error) {
+	q := MustQuery("get_team_member_details.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{"team_id": teamID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]models.TeamMemberDetail, 0)
+	for rows.Next() {
+		var m models.TeamMemberDetail
+		if err := rows.StructScan(&m); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// UpdateTeamMemberRole changes a member's role
+func (d *DB) UpdateTeamMemberRole(ctx context.Context, teamID, userID int64, role string) error {
+	q := MustQuery("update_team_member_role.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{
+		"team_id": teamID,
+		"user_id": userID,
+		"role":    role,
+	})
+	return err
+}
+
+// RemoveTeamMember removes a user from a team
+func (d *DB) RemoveTeamMember(ctx context.Context, teamID, userID int64) error {
+	q := MustQuery("remove_team_member.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{
+		"team_id": teamID,
+		"user_id": userID,
+	})
+	return err
+}
+
+// CountTeamOwners returns how many owners a team has
+func (d *DB) CountTeamOwners(ctx context.Context, teamID int64) (int, error) {
+	q, args, err := d.DBX.BindNamed(MustQuery("count_team_owners.sql"), map[string]any{"team_id": teamID})
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	err = d.DBX.GetContext(ctx, &n, q, args...)
+	return n, err
+}
+
+// TransferTeamOwnership makes toUserID an owner and demotes fromUserID to admin
+func (d *DB) TransferTeamOwnership(ctx context.Context, teamID, fromUserID, toUserID int64) error {
+	tx, err := d.DBX.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := MustQuery("update_team_member_role.sql")
+	for _, change := range []struct {
+		userID int64
+		role   string
+	}{
+		{toUserID, models.RoleOwner},
+		{fromUserID, models.RoleAdmin},
+	} {
+		if _, err := tx.NamedExecContext(ctx, q, map[string]any{
+			"team_id": teamID,
+			"user_id": change.userID,
+			"role":    change.role,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CreateTeamInvitation records a pending invitation for an email address
+func (d *DB) CreateTeamInvitation(ctx context.Context, teamID int64, email, role string, invitedBy int64) (*models.TeamInvitation, error) {
+	q := MustQuery("create_team_invitation.sql")
+
+	stmt, err := d.DBX.PrepareNamedContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var inv models.TeamInvitation
+	args := map[string]any{
+		"team_id":    teamID,
+		"email":      strings.ToLower(strings.TrimSpace(email)),
+		"role":       role,
+		"invited_by": invitedBy,
+	}
+	if err := stmt.GetContext(ctx, &inv, args); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// GetTeamInvitation returns an invitation, or nil if it does not exist
+func (d *DB) GetTeamInvitation(ctx context.Context, id int64) (*models.TeamInvitation, error) {
+	q := MustQuery("get_team_invitation.sql")
+
+	rows, err := d.DBX.NamedQueryContext(ctx, q, map[string]any{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	var inv models.TeamInvitation
+	if err := rows.StructScan(&inv); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// GetTeamInvitations returns a team's pending invitations
+func (d *DB) GetTeamInvitations(ctx context.Context, teamID int64) ([]models.TeamInvitation, error) {
+	return d.listInvitations(ctx, "get_team_invitations.sql", map[string]any{"team_id": teamID})
+}
+
+// GetInvitationsByEmail returns the pending invitations addressed to an email
+func (d *DB) GetInvitationsByEmail(ctx context.Context, email string) ([]models.TeamInvitation, error) {
+	return d.listInvitations(ctx, "get_invitations_by_email.sql", map[string]any{"email": email})
+}
+
+func (d *DB) listInvitations(ctx context.Context, query string, args map[string]any) ([]models.TeamInvitation, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery(query), args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := make([]models.TeamInvitation, 0)
+	for rows.Next() {
+		var inv models.TeamInvitation
+		if err := rows.StructScan(&inv); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// AcceptTeamInvitation marks a pending invitation accepted and adds the user
+// to the team with the invited role
+func (d *DB) AcceptTeamInvitation(ctx context.Context, inv *models.TeamInvitation, userID int64) error {
+	tx, err := d.DBX.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.NamedExecContext(ctx, MustQuery("update_team_invitation_status.sql"), map[string]any{
+		"id":     inv.ID,
+		"status": models.InvitationAccepted,
+	})
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvitationNotPending
+	}
+
+	if _, err := tx.NamedExecContext(ctx, MustQuery("add_team_member.sql"), map[string]any{
+		"team_id": inv.TeamID,
+		"user_id": userID,
+		"role":    inv.Role,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeclineTeamInvitation marks a pending invitation declined
+func (d *DB) DeclineTeamInvitation(ctx context.Context, id int64) error {
+	res, err := d.DBX.NamedExecContext(ctx, MustQuery("update_team_invitation_status.sql"), map[string]any{
+		"id":     id,
+		"status": models.InvitationDeclined,
+	})
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvitationNotPending
+	}
+	return nil
+}
+
+// DeleteTeamInvitation withdraws an invitation
+func (d *DB) DeleteTeamInvitation(ctx context.Context, id int64) error {
+	q := MustQuery("delete_team_invitation.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]any{"id": id})
+	return err
+}
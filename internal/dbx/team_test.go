@@ -0,0 +1,78 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestDB_Teams(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	owner, _ := db.CreateUser(ctx, "owner@example.com", "hash", "Owner")
+	member, _ := db.CreateUser(ctx, "member@example.com", "hash", "Member")
+
+	team, err := db.CreateTeam(ctx, "Docs", nil, owner.ID)
+	if err != nil {
+		t.Fatalf("CreateTeam() returned error: %v", err)
+	}
+
+	t.Run("lists teams and members", func(t *testing.T) {
+		teams, err := db.GetTeamsByUser(ctx, owner.ID)
+		if err != nil || len(teams) != 1 || teams[0].Name != "Docs" {
+			t.Errorf("GetTeamsByUser() = %v, %v, want [Docs]", teams, err)
+		}
+		members, err := db.GetTeamMembers(ctx, team.ID)
+		if err != nil || len(members) != 1 || members[0].Email != "owner@example.com" || members[0].Role != models.RoleOwner {
+			t.Errorf("GetTeamMembers() = %+v, %v, want owner only", members, err)
+		}
+	})
+
+	t.Run("invitations are accepted once", func(t *testing.T) {
+		inv, err := db.CreateTeamInvitation(ctx, team.ID, " Member@Example.com ", models.RoleEditor, owner.ID)
+		if err != nil {
+			t.Fatalf("CreateTeamInvitation() returned error: %v", err)
+		}
+		pending, _ := db.GetInvitationsByEmail(ctx, "member@example.com")
+		if len(pending) != 1 {
+			t.Fatalf("GetInvitationsByEmail() returned %d invitations, want 1", len(pending))
+		}
+
+		if err := db.AcceptTeamInvitation(ctx, inv, member.ID); err != nil {
+			t.Fatalf("AcceptTeamInvitation() returned error: %v", err)
+		}
+		if err := db.AcceptTeamInvitation(ctx, inv, member.ID); err != ErrInvitationNotPending {
+			t.Errorf("AcceptTeamInvitation() twice error = %v, want %v", err, ErrInvitationNotPending)
+		}
+		m, _ := db.GetTeamMember(ctx, team.ID, member.ID)
+		if m == nil || m.Role != models.RoleEditor {
+			t.Errorf("GetTeamMember() = %+v, want editor", m)
+		}
+	})
+
+	t.Run("transfers ownership", func(t *testing.T) {
+		if err := db.TransferTeamOwnership(ctx, team.ID, owner.ID, member.ID); err != nil {
+			t.Fatalf("TransferTeamOwnership() returned error: %v", err)
+		}
+		n, err := db.CountTeamOwners(ctx, team.ID)
+		if err != nil || n != 1 {
+			t.Errorf("CountTeamOwners() = %d, %v, want 1", n, err)
+		}
+		m, _ := db.GetTeamMember(ctx, team.ID, member.ID)
+		if m.Role != models.RoleOwner {
+			t.Errorf("new owner role = %s, want owner", m.Role)
+		}
+	})
+
+	t.Run("deleting a team removes memberships", func(t *testing.T) {
+		if err := db.DeleteTeam(ctx, team.ID); err != nil {
+			t.Fatalf("DeleteTeam() returned error: %v", err)
+		}
+		if m, _ := db.GetTeamMember(ctx, team.ID, owner.ID); m != nil {
+			t.Error("membership survived team deletion")
+		}
+	})
+}
@@ -194,3 +194,21 @@ func (d *DB) UpdateUserEmail(ctx context.Context, userID, email string) (*models
 	}
 	return &u, nil
 }
+
+// SetUserAdmin grants or revokes site administration
+func (d *DB) SetUserAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	q := MustQuery("set_user_admin.sql")
+
+	_, err := d.DBX.NamedExecContext(ctx, q, map[string]interface{}{
+		"id":       userID,
+		"is_admin": isAdmin,
+	})
+	return err
+}
+
+// CountAdmins returns the number of site administrators
+func (d *DB) CountAdmins(ctx context.Context) (int, error) {
+	var n int
+	err := d.DBX.GetContext(ctx, &n, MustQuery("count_admins.sql"))
+	return n, err
+}
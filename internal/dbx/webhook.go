@@ -0,0 +1,168 @@
+package dbx
+
+import (
+	"context"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func webhookArgs(h *models.Webhook) map[string]any {
+	return map[string]any{
+		"id":         h.ID,
+		"url":        h.URL,
+		"secret":     h.Secret,
+		"events":     h.Events,
+		"paths":      h.Paths,
+		"vault":      h.Vault,
+		"is_active":  h.IsActive,
+		"created_by": h.CreatedBy,
+	}
+}
+
+// CreateWebhook stores a new webhook and returns it
+func (d *DB) CreateWebhook(ctx context.Context, h *models.Webhook) (*models.Webhook, error) {
+	return d.saveWebhook(ctx, "create_webhook.sql", h)
+}
+
+// UpdateWebhook stores the changes to a webhook and returns it
+func (d *DB) UpdateWebhook(ctx context.Context, h *models.Webhook) (*models.Webhook, error) {
+	return d.saveWebhook(ctx, "update_webhook.sql", h)
+}
+
+func (d *DB) saveWebhook(ctx context.Context, query string, h *models.Webhook) (*models.Webhook, error) {
+	stmt, err := d.DBX.PrepareNamedContext(ctx, MustQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var saved models.Webhook
+	if err := stmt.GetContext(ctx, &saved, webhookArgs(h)); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetWebhooks returns every webhook
+func (d *DB) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	return d.queryWebhooks(ctx, "get_all_webhooks.sql", map[string]any{})
+}
+
+// GetActiveWebhooks returns the webhooks that receive events
+func (d *DB) GetActiveWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	return d.queryWebhooks(ctx, "get_active_webhooks.sql", map[string]any{})
+}
+
+// GetWebhook returns a webhook by id, or nil if none exists
+func (d *DB) GetWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	hooks, err := d.queryWebhooks(ctx, "get_webhook_by_id.sql", map[string]any{"id": id})
+	if err != nil || len(hooks) == 0 {
+		return nil, err
+	}
+	return &hooks[0], nil
+}
+
+func (d *DB) queryWebhooks(ctx context.Context, query string, args map[string]any) ([]models.Webhook, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery(query), args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := make([]models.Webhook, 0)
+	for rows.Next() {
+		var h models.Webhook
+		if err := rows.StructScan(&h); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook with its deliveries
+func (d *DB) DeleteWebhook(ctx context.Context, id int64) error {
+	_, err := d.DBX.NamedExecContext(ctx, MustQuery("delete_webhook.sql"), map[string]any{"id": id})
+	return err
+}
+
+// QueueWebhookDelivery queues payload for a webhook, due at once
+func (d *DB) QueueWebhookDelivery(ctx context.Context, webhookID int64, event, payload string) (*models.WebhookDelivery, error) {
+	stmt, err := d.DBX.PrepareNamedContext(ctx, MustQuery("create_webhook_delivery.sql"))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var saved models.WebhookDelivery
+	args := map[string]any{
+		"webhook_id":      webhookID,
+		"event":           event,
+		"payload":         payload,
+		"next_attempt_at": time.Now().UTC(),
+	}
+	if err := stmt.GetContext(ctx, &saved, args); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetDueWebhookDeliveries returns up to limit pending deliveries whose
+// next attempt is not after now, oldest first
+func (d *DB) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return d.queryDeliveries(ctx, "get_due_webhook_deliveries.sql", map[string]any{"now": now.UTC(), "limit": limit})
+}
+
+// GetWebhookDeliveries returns the latest limit deliveries of a webhook,
+// newest first
+func (d *DB) GetWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]models.WebhookDelivery, error) {
+	return d.queryDeliveries(ctx, "get_webhook_deliveries.sql", map[string]any{"webhook_id": webhookID, "limit": limit})
+}
+
+// GetWebhookDelivery returns a delivery by id, or nil if none exists
+func (d *DB) GetWebhookDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	deliveries, err := d.queryDeliveries(ctx, "get_webhook_delivery_by_id.sql", map[string]any{"id": id})
+	if err != nil || len(deliveries) == 0 {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+func (d *DB) queryDeliveries(ctx context.Context, query string, args map[string]any) ([]models.WebhookDelivery, error) {
+	rows, err := d.DBX.NamedQueryContext(ctx, MustQuery(query), args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		var dl models.WebhookDelivery
+		if err := rows.StructScan(&dl); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, dl)
+	}
+	return deliveries, rows.Err()
+}
+
+// UpdateWebhookDelivery records the outcome of an attempt: its status,
+// attempt count, next attempt, response and error
+func (d *DB) UpdateWebhookDelivery(ctx context.Context, dl *models.WebhookDelivery) error {
+	var next *time.Time
+	if dl.NextAttemptAt != nil {
+		t := dl.NextAttemptAt.UTC()
+		next = &t
+	}
+	_, err := d.DBX.NamedExecContext(ctx, MustQuery("update_webhook_delivery.sql"), map[string]any{
+		"id":              dl.ID,
+		"status":          dl.Status,
+		"attempts":        dl.Attempts,
+		"next_attempt_at": next,
+		"response_code":   dl.ResponseCode,
+		"error":           dl.Error,
+		"delivered_at":    dl.DeliveredAt,
+	})
+	return err
+}
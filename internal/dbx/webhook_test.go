@@ -0,0 +1,80 @@
+package dbx
+
+import (
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestWebhooks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	h, err := db.CreateWebhook(ctx, &models.Webhook{
+		URL:      "https://ci.example.com/hook",
+		Secret:   "s3cret",
+		Events:   models.StringList{models.AuditFileWrite},
+		Paths:    models.StringList{"projects/**"},
+		IsActive: true,
+	})
+	if err != nil || h.ID == 0 || h.Secret != "s3cret" || h.Paths[0] != "projects/**" {
+		t.Fatalf("CreateWebhook() = %+v, %v", h, err)
+	}
+	db.CreateWebhook(ctx, &models.Webhook{URL: "https://chat.example.com/hook", Secret: "x"})
+	if active, _ := db.GetActiveWebhooks(ctx); len(active) != 1 || active[0].ID != h.ID {
+		t.Errorf("GetActiveWebhooks() = %+v", active)
+	}
+
+	h.Events = append(h.Events, models.AuditFileDelete)
+	updated, err := db.UpdateWebhook(ctx, h)
+	if err != nil || len(updated.Events) != 2 {
+		t.Errorf("UpdateWebhook() = %+v, %v", updated, err)
+	}
+
+	t.Run("queues deliveries until they are due", func(t *testing.T) {
+		first, err := db.QueueWebhookDelivery(ctx, h.ID, models.AuditFileWrite, `{"n":1}`)
+		if err != nil || first.Status != models.DeliveryPending || first.Attempts != 0 {
+			t.Fatalf("QueueWebhookDelivery() = %+v, %v", first, err)
+		}
+		second, _ := db.QueueWebhookDelivery(ctx, h.ID, models.AuditFileWrite, `{"n":2}`)
+
+		later := time.Now().Add(time.Hour)
+		first.Attempts, first.NextAttemptAt = 1, &later
+		if err := db.UpdateWebhookDelivery(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		due, err := db.GetDueWebhookDeliveries(ctx, time.Now().Add(time.Second), 10)
+		if err != nil || len(due) != 1 || due[0].ID != second.ID {
+			t.Errorf("GetDueWebhookDeliveries() = %+v, %v; want the second only", due, err)
+		}
+		if due, _ := db.GetDueWebhookDeliveries(ctx, later.Add(time.Second), 10); len(due) != 2 {
+			t.Errorf("after the retry time %d due, want 2", len(due))
+		}
+
+		code, now := 200, time.Now()
+		second.Status, second.Attempts, second.NextAttemptAt = models.DeliveryDelivered, 1, nil
+		second.ResponseCode, second.DeliveredAt = &code, &now
+		db.UpdateWebhookDelivery(ctx, second)
+		got, _ := db.GetWebhookDelivery(ctx, second.ID)
+		if got.Status != models.DeliveryDelivered || *got.ResponseCode != 200 || got.DeliveredAt == nil || got.NextAttemptAt != nil {
+			t.Errorf("GetWebhookDelivery() = %+v", got)
+		}
+
+		log, _ := db.GetWebhookDeliveries(ctx, h.ID, 10)
+		if len(log) != 2 || log[0].ID != second.ID {
+			t.Errorf("GetWebhookDeliveries() = %+v, want newest first", log)
+		}
+	})
+
+	if err := db.DeleteWebhook(ctx, h.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetWebhook(ctx, h.ID); got != nil {
+		t.Errorf("GetWebhook() after delete = %+v", got)
+	}
+	if log, _ := db.GetWebhookDeliveries(ctx, h.ID, 10); len(log) != 0 {
+		t.Errorf("deliveries after delete = %d, want them dropped", len(log))
+	}
+}
@@ -0,0 +1,314 @@
+// Package feed writes RSS 2.0, Atom and JSON Feed documents and serves
+// them with conditional GET support.
+package feed
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Feed is a list of entries in a format-neutral shape
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // the page the feed belongs to
+	FeedURL     string // the feed itself
+	Updated     time.Time
+	Items       []Item // newest first
+}
+
+// Item is one entry of a feed
+type Item struct {
+	ID        string // stable identifier; defaults to Link
+	Title     string
+	Link      string
+	Summary   string // plain text
+	Content   string // HTML; empty for summary-only feeds
+	Published time.Time
+	Updated   time.Time
+	Tags      []string
+}
+
+func (it *Item) id() string {
+	if it.ID != "" {
+		return it.ID
+	}
+	return it.Link
+}
+
+// Format is a feed document type
+type Format struct {
+	Name        string
+	File        string // file name on the site, e.g. "feed.xml"
+	ContentType string
+	render      func(*Feed) ([]byte, error)
+}
+
+// Supported formats
+var (
+	RSS  = Format{"rss", "feed.xml", "application/rss+xml; charset=utf-8", (*Feed).RSS}
+	Atom = Format{"atom", "atom.xml", "application/atom+xml; charset=utf-8", (*Feed).Atom}
+	JSON = Format{"json", "feed.json", "application/feed+json; charset=utf-8", (*Feed).JSON}
+)
+
+// Formats lists every supported format
+var Formats = []Format{RSS, Atom, JSON}
+
+// ByFile returns the format whose file name is file
+func ByFile(file string) (Format, bool) {
+	for _, f := range Formats {
+		if f.File == file {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// Render writes f in the format
+func (format Format) Render(f *Feed) ([]byte, error) {
+	return format.render(f)
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Content string     `xml:"xmlns:content,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          *atomLink `xml:"atom:link,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Description string   `xml:"description,omitempty"`
+	Content     *cdata   `xml:"content:encoded,omitempty"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+// RSS renders f as RSS 2.0, with full content in content:encoded
+func (f *Feed) RSS() ([]byte, error) {
+	ch := rssChannel{
+		Title:       f.Title,
+		Link:        f.Link,
+		Description: f.Description,
+	}
+	if ch.Description == "" {
+		ch.Description = f.Title
+	}
+	if f.FeedURL != "" {
+		ch.Self = &atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"}
+	}
+	if !f.Updated.IsZero() {
+		ch.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, it := range f.Items {
+		item := rssItem{
+			Title:       it.Title,
+			Link:        it.Link,
+			GUID:        rssGUID{IsPermaLink: it.ID == "", Value: it.id()},
+			Description: it.Summary,
+			Categories:  it.Tags,
+		}
+		if !it.Published.IsZero() {
+			item.PubDate = it.Published.UTC().Format(time.RFC1123Z)
+		}
+		if it.Content != "" {
+			item.Content = &cdata{it.Content}
+		}
+		ch.Items = append(ch.Items, item)
+	}
+	return marshalXML(rssDoc{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Content: "http://purl.org/rss/1.0/modules/content/",
+		Channel: ch,
+	})
+}
+
+type atomDoc struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// Atom renders f as an Atom 1.0 feed
+func (f *Feed) Atom() ([]byte, error) {
+	doc := atomDoc{
+		Title:   f.Title,
+		ID:      f.FeedURL,
+		Updated: atomTime(f.Updated),
+		Links:   []atomLink{{Href: f.Link, Rel: "alternate", Type: "text/html"}},
+	}
+	if doc.ID == "" {
+		doc.ID = f.Link
+	}
+	if f.FeedURL != "" {
+		doc.Links = append(doc.Links, atomLink{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"})
+	}
+	for _, it := range f.Items {
+		e := atomEntry{
+			Title:   it.Title,
+			ID:      it.id(),
+			Link:    atomLink{Href: it.Link, Rel: "alternate", Type: "text/html"},
+			Updated: atomTime(it.Updated),
+		}
+		if !it.Published.IsZero() {
+			e.Published = atomTime(it.Published)
+		}
+		if it.Summary != "" {
+			e.Summary = &atomText{Type: "text", Value: it.Summary}
+		}
+		if it.Content != "" {
+			e.Content = &atomText{Type: "html", Value: it.Content}
+		}
+		for _, t := range it.Tags {
+			e.Categories = append(e.Categories, atomCategory{Term: t})
+		}
+		doc.Entries = append(doc.Entries, e)
+	}
+	return marshalXML(doc)
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func marshalXML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url,omitempty"`
+	FeedURL     string     `json:"feed_url,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string     `json:"id"`
+	URL           string     `json:"url,omitempty"`
+	Title         string     `json:"title,omitempty"`
+	ContentHTML   string     `json:"content_html,omitempty"`
+	ContentText   string     `json:"content_text,omitempty"`
+	Summary       string     `json:"summary,omitempty"`
+	DatePublished *time.Time `json:"date_published,omitempty"`
+	DateModified  *time.Time `json:"date_modified,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+}
+
+// JSON renders f as JSON Feed 1.1
+func (f *Feed) JSON() ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.FeedURL,
+		Description: f.Description,
+		Items:       []jsonItem{},
+	}
+	for _, it := range f.Items {
+		item := jsonItem{
+			ID:          it.id(),
+			URL:         it.Link,
+			Title:       it.Title,
+			ContentHTML: it.Content,
+			Summary:     it.Summary,
+			Tags:        it.Tags,
+		}
+		// Every item needs content; summary-only feeds carry it as text
+		if item.ContentHTML == "" {
+			item.ContentText = it.Summary
+		}
+		if !it.Published.IsZero() {
+			t := it.Published.UTC()
+			item.DatePublished = &t
+		}
+		if !it.Updated.IsZero() {
+			t := it.Updated.UTC()
+			item.DateModified = &t
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Serve writes body with an ETag and Last-Modified, answering conditional
+// requests (If-None-Match, If-Modified-Since) with 304 Not Modified
+func Serve(w http.ResponseWriter, r *http.Request, body []byte, contentType string, modTime time.Time) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16])))
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(body))
+}
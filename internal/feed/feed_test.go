@@ -0,0 +1,141 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return &Feed{
+		Title:   "Notes & more",
+		Link:    "https://example.com/",
+		FeedURL: "https://example.com/feed.xml",
+		Updated: day,
+		Items: []Item{
+			{Title: "Full", Link: "https://example.com/full.html", Summary: "Short", Content: "<p>All <b>of</b> it</p>",
+				Published: day.Add(-time.Hour), Updated: day, Tags: []string{"go"}},
+			{Title: "Summary only", Link: "https://example.com/summary.html", Summary: "Just this", Updated: day.Add(-time.Hour)},
+		},
+	}
+}
+
+func TestRSS(t *testing.T) {
+	b, err := testFeed().RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				GUID    string `xml:"guid"`
+				PubDate string `xml:"pubDate"`
+				Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("RSS() is not XML: %v\n%s", err, b)
+	}
+	items := doc.Channel.Items
+	if doc.Channel.Title != "Notes & more" || len(items) != 2 {
+		t.Fatalf("RSS() = %+v", doc)
+	}
+	if items[0].Content != "<p>All <b>of</b> it</p>" || items[0].PubDate != "Wed, 01 May 2024 11:00:00 +0000" || items[0].GUID != "https://example.com/full.html" {
+		t.Errorf("first item = %+v", items[0])
+	}
+	if items[1].Content != "" {
+		t.Errorf("summary-only item has content %q", items[1].Content)
+	}
+}
+
+func TestAtom(t *testing.T) {
+	b, err := testFeed().Atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		XMLName xml.Name
+		ID      string `xml:"id"`
+		Entries []struct {
+			Updated string `xml:"updated"`
+			Content struct {
+				Type  string `xml:"type,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Atom() is not XML: %v\n%s", err, b)
+	}
+	if doc.XMLName.Space != "http://www.w3.org/2005/Atom" || doc.ID != "https://example.com/feed.xml" || len(doc.Entries) != 2 {
+		t.Fatalf("Atom() = %+v", doc)
+	}
+	if e := doc.Entries[0]; e.Updated != "2024-05-01T12:00:00Z" || e.Content.Type != "html" || e.Content.Value != "<p>All <b>of</b> it</p>" {
+		t.Errorf("first entry = %+v", e)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	b, err := testFeed().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	items := doc["items"].([]any)
+	if doc["version"] != "https://jsonfeed.org/version/1.1" || len(items) != 2 {
+		t.Fatalf("JSON() = %s", b)
+	}
+	if first := items[0].(map[string]any); first["content_html"] != "<p>All <b>of</b> it</p>" || first["date_modified"] != "2024-05-01T12:00:00Z" {
+		t.Errorf("first item = %v", first)
+	}
+	if second := items[1].(map[string]any); second["content_text"] != "Just this" {
+		t.Errorf("summary-only item = %v", second)
+	}
+}
+
+func TestServe(t *testing.T) {
+	body, _ := testFeed().Atom()
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/atom.xml", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		Serve(rec, req, body, Atom.ContentType, modified)
+		return rec
+	}
+
+	rec := get("", "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != string(body) || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("Serve() = %v %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("Last-Modified") != "Wed, 01 May 2024 12:00:00 GMT" || rec.Header().Get("Content-Type") != Atom.ContentType {
+		t.Errorf("Serve() headers = %v", rec.Header())
+	}
+
+	for _, tt := range []struct {
+		header, value string
+		want          int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", `"other"`, http.StatusOK},
+		{"If-Modified-Since", "Wed, 01 May 2024 12:00:00 GMT", http.StatusNotModified},
+		{"If-Modified-Since", "Tue, 30 Apr 2024 12:00:00 GMT", http.StatusOK},
+	} {
+		if rec := get(tt.header, tt.value); rec.Code != tt.want {
+			t.Errorf("%s: %s status = %v, want %v", tt.header, tt.value, rec.Code, tt.want)
+		}
+	}
+}
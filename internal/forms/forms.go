@@ -0,0 +1,243 @@
+// Package forms validates form schemas and the submissions visitors send
+// through them, and formats submissions as CSV rows and vault captures.
+package forms
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/ratelimit"
+)
+
+// HoneypotField is a hidden input that people leave empty and bots fill in.
+// Field names can't start with an underscore, so it never clashes.
+const HoneypotField = "_website"
+
+// Limits on what a form holds
+const (
+	maxFields     = 50
+	maxTextLength = 5000 // default cap of text fields
+	dateLayout    = "2006-01-02"
+)
+
+var fieldName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{0,63}$`)
+
+// SubmitPolicy limits submissions per client: a few in a row are free,
+// then each waits longer, and a flood locks the client out for an hour
+func SubmitPolicy() ratelimit.Policy {
+	return ratelimit.Policy{
+		FreeAttempts:     5,
+		BaseDelay:        10 * time.Second,
+		MaxDelay:         10 * time.Minute,
+		LockoutThreshold: 30,
+		LockoutDuration:  time.Hour,
+		ResetAfter:       time.Hour,
+	}
+}
+
+// Validate checks a form before it is saved: a name, and fields with
+// unique names, known types and settings that fit their type
+func Validate(f *models.Form) error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" {
+		return errors.New("name required")
+	}
+	if len(f.Fields) == 0 {
+		return errors.New("a form needs at least one field")
+	}
+	if len(f.Fields) > maxFields {
+		return fmt.Errorf("a form has at most %d fields", maxFields)
+	}
+
+	seen := map[string]bool{}
+	for i := range f.Fields {
+		field := &f.Fields[i]
+		if !fieldName.MatchString(field.Name) {
+			return fmt.Errorf("field %d: invalid name %q: use letters, digits, - and _", i+1, field.Name)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("field %q: duplicate name", field.Name)
+		}
+		seen[field.Name] = true
+		if field.Label = strings.TrimSpace(field.Label); field.Label == "" {
+			field.Label = field.Name
+		}
+
+		switch field.Type {
+		case models.FieldText, models.FieldEmail, models.FieldCheckbox, models.FieldDate:
+		case models.FieldNumber:
+			if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+				return fmt.Errorf("field %q: min is above max", field.Name)
+			}
+		case models.FieldSelect:
+			if len(field.Options) == 0 {
+				return fmt.Errorf("field %q: select fields need options", field.Name)
+			}
+		default:
+			return fmt.Errorf("field %q: unknown type %q", field.Name, field.Type)
+		}
+		if field.Type != models.FieldSelect && len(field.Options) > 0 {
+			return fmt.Errorf("field %q: only select fields have options", field.Name)
+		}
+		if field.Type != models.FieldNumber && (field.Min != nil || field.Max != nil) {
+			return fmt.Errorf("field %q: only number fields have min and max", field.Name)
+		}
+		if field.MaxLen < 0 || field.MaxLen > maxTextLength || (field.MaxLen > 0 && field.Type != models.FieldText) {
+			return fmt.Errorf("field %q: max_length must be between 1 and %d on text fields", field.Name, maxTextLength)
+		}
+	}
+	return nil
+}
+
+// FieldErrors maps field names to what is wrong with their value
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, name+": "+e[name])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Clean validates submitted values against the fields of a form and
+// returns them normalised: trimmed text, checkboxes as "true" or "false".
+// Values for unknown fields are dropped.
+func Clean(fields models.FormFields, values url.Values) (models.SubmissionData, error) {
+	data := models.SubmissionData{}
+	errs := FieldErrors{}
+	for _, field := range fields {
+		value := strings.TrimSpace(values.Get(field.Name))
+
+		if field.Type == models.FieldCheckbox {
+			checked := value != "" && value != "false" && value != "off" && value != "0"
+			if field.Required && !checked {
+				errs[field.Name] = "must be checked"
+			}
+			data[field.Name] = strconv.FormatBool(checked)
+			continue
+		}
+		if value == "" {
+			if field.Required {
+				errs[field.Name] = "required"
+			}
+			data[field.Name] = ""
+			continue
+		}
+
+		if msg := check(field, value); msg != "" {
+			errs[field.Name] = msg
+			continue
+		}
+		data[field.Name] = value
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return data, nil
+}
+
+// check returns what is wrong with a non-empty value, or ""
+func check(field models.FormField, value string) string {
+	switch field.Type {
+	case models.FieldText:
+		limit := field.MaxLen
+		if limit == 0 {
+			limit = maxTextLength
+		}
+		if utf8.RuneCountInString(value) > limit {
+			return fmt.Sprintf("at most %d characters", limit)
+		}
+	case models.FieldEmail:
+		addr, err := mail.ParseAddress(value)
+		if err != nil || addr.Address != value {
+			return "not an email address"
+		}
+	case models.FieldNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "not a number"
+		}
+		if field.Min != nil && n < *field.Min {
+			return fmt.Sprintf("at least %v", *field.Min)
+		}
+		if field.Max != nil && n > *field.Max {
+			return fmt.Sprintf("at most %v", *field.Max)
+		}
+	case models.FieldSelect:
+		for _, opt := range field.Options {
+			if value == opt {
+				return ""
+			}
+		}
+		return "not one of the options"
+	case models.FieldDate:
+		if _, err := time.Parse(dateLayout, value); err != nil {
+			return "not a date (YYYY-MM-DD)"
+		}
+	}
+	return ""
+}
+
+// Capture formats a submission as a markdown section to append to a note
+func Capture(form *models.Form, s *models.FormSubmission) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n## %s · %s\n\n", form.Name, s.CreatedAt.UTC().Format("2006-01-02 15:04 UTC"))
+	for _, field := range form.Fields {
+		// Keep submitted text on its line so it can't add markdown structure
+		value := strings.Join(strings.Fields(s.Data[field.Name]), " ")
+		fmt.Fprintf(&b, "- **%s**: %s\n", field.Label, value)
+	}
+	return b.String()
+}
+
+// WriteCSV writes the submissions of a form as CSV, one column per field
+func WriteCSV(w io.Writer, form *models.Form, subs []models.FormSubmission) error {
+	cw := csv.NewWriter(w)
+	header := []string{"id", "submitted_at"}
+	for _, field := range form.Fields {
+		header = append(header, field.Name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, s := range subs {
+		record := []string{strconv.FormatInt(s.ID, 10), s.CreatedAt.UTC().Format(time.RFC3339)}
+		for _, field := range form.Fields {
+			value := s.Data[field.Name]
+			if field.Type != models.FieldNumber {
+				value = csvSafe(value)
+			}
+			record = append(record, value)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe keeps spreadsheets from running submitted values as formulas
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
@@ -0,0 +1,125 @@
+package forms
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func ptr(f float64) *float64 { return &f }
+
+func contactForm() *models.Form {
+	return &models.Form{
+		Name: "Contact",
+		Fields: models.FormFields{
+			{Name: "name", Label: "Your name", Type: models.FieldText, Required: true, MaxLen: 10},
+			{Name: "email", Type: models.FieldEmail, Required: true},
+			{Name: "age", Type: models.FieldNumber, Min: ptr(0), Max: ptr(150)},
+			{Name: "topic", Type: models.FieldSelect, Options: []string{"sales", "support"}},
+			{Name: "agree", Type: models.FieldCheckbox, Required: true},
+			{Name: "when", Type: models.FieldDate},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	f := contactForm()
+	if err := Validate(f); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if f.Fields[1].Label != "email" {
+		t.Errorf("label defaults to %q, want the name", f.Fields[1].Label)
+	}
+
+	for name, fields := range map[string]models.FormFields{
+		"no fields":         {},
+		"bad name":          {{Name: "_hidden", Type: models.FieldText}},
+		"duplicate":         {{Name: "a", Type: models.FieldText}, {Name: "a", Type: models.FieldEmail}},
+		"unknown type":      {{Name: "a", Type: "color"}},
+		"select no options": {{Name: "a", Type: models.FieldSelect}},
+		"options on text":   {{Name: "a", Type: models.FieldText, Options: []string{"x"}}},
+		"min above max":     {{Name: "a", Type: models.FieldNumber, Min: ptr(5), Max: ptr(1)}},
+		"max on date":       {{Name: "a", Type: models.FieldDate, Max: ptr(1)}},
+		"max length email":  {{Name: "a", Type: models.FieldEmail, MaxLen: 5}},
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			if err := Validate(&models.Form{Name: "x", Fields: fields}); err == nil {
+				t.Error("Validate() succeeded")
+			}
+		})
+	}
+	if err := Validate(&models.Form{Name: " ", Fields: contactForm().Fields}); err == nil {
+		t.Error("Validate() accepted a form without a name")
+	}
+}
+
+func TestClean(t *testing.T) {
+	fields := contactForm().Fields
+
+	t.Run("normalises valid values", func(t *testing.T) {
+		data, err := Clean(fields, url.Values{
+			"name": {"  Ada "}, "email": {"ada@example.com"}, "age": {"36"}, "topic": {"support"},
+			"agree": {"on"}, "when": {"2024-05-01"}, "extra": {"dropped"},
+		})
+		if err != nil {
+			t.Fatalf("Clean() error = %v", err)
+		}
+		if data["name"] != "Ada" || data["agree"] != "true" || data["age"] != "36" || data["when"] != "2024-05-01" {
+			t.Errorf("Clean() = %v", data)
+		}
+		if _, ok := data["extra"]; ok {
+			t.Error("Clean() kept an unknown field")
+		}
+	})
+
+	t.Run("reports every bad field", func(t *testing.T) {
+		_, err := Clean(fields, url.Values{
+			"name": {"Much too long"}, "email": {"Ada <ada@example.com>"}, "age": {"-1"},
+			"topic": {"other"}, "agree": {"false"}, "when": {"May 1st"},
+		})
+		errs, ok := err.(FieldErrors)
+		if !ok || len(errs) != 6 {
+			t.Fatalf("Clean() error = %v, want 6 field errors", err)
+		}
+		if errs["agree"] != "must be checked" || errs["age"] != "at least 0" {
+			t.Errorf("errors = %v", errs)
+		}
+	})
+
+	t.Run("requires required fields", func(t *testing.T) {
+		_, err := Clean(fields, url.Values{"agree": {"true"}})
+		if errs, ok := err.(FieldErrors); !ok || errs["name"] != "required" || errs["email"] != "required" || len(errs) != 2 {
+			t.Errorf("Clean() error = %v", err)
+		}
+	})
+}
+
+func TestCaptureAndCSV(t *testing.T) {
+	f := contactForm()
+	Validate(f)
+	sub := models.FormSubmission{
+		ID:        7,
+		Data:      models.SubmissionData{"name": "Ada\n# Injected", "email": "ada@example.com", "age": "-3", "topic": "=HYPERLINK(\"x\")"},
+		CreatedAt: time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC),
+	}
+
+	capture := Capture(f, &sub)
+	if !strings.HasPrefix(capture, "\n## Contact · 2024-05-01 09:30 UTC\n\n") || !strings.Contains(capture, "- **Your name**: Ada # Injected\n") {
+		t.Errorf("Capture() = %q", capture)
+	}
+
+	var b strings.Builder
+	if err := WriteCSV(&b, f, []models.FormSubmission{sub}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if lines[0] != "id,submitted_at,name,email,age,topic,agree,when" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if !strings.Contains(b.String(), `,-3,"'=HYPERLINK(""x"")",`) {
+		t.Errorf("CSV does not defuse formulas:\n%s", b.String())
+	}
+}
@@ -0,0 +1,459 @@
+// Package hooks runs the server-side hooks of plugins: WebAssembly modules
+// called before notes are created, saved, renamed or deleted, whichever way
+// the change reaches a vault.
+//
+// A hooks module is a WASI reactor (e.g. built with GOOS=wasip1
+// -buildmode=c-shared) exporting its memory and
+//
+//	alloc(size i32) i32                   memory for the host to write into
+//	on_create_note(ptr i32, len i32) i64  and any of on_save, on_rename
+//	                                      and on_delete
+//
+// A hook receives a JSON event {"vault", "path", "old_path", "content"} and
+// returns 0, or the location ptr<<32 | len of a JSON response
+// {"content": "...", "error": "..."}. An error refuses the change; content
+// replaces the note being written by on_create_note and on_save. Creating a
+// note runs on_create_note then on_save. A module that traps, runs out of
+// time or memory, or answers garbage is logged and skipped, so a broken
+// plugin can't block writes.
+//
+// The host functions, imported from the "dz" module, are
+//
+//	log(ptr i32, len i32)
+//	read_note(ptr i32, len i32) i64       needs read_notes; the note at the
+//	                                      path as ptr<<32 | len, or 0
+//	write_note(path_ptr i32, path_len i32, ptr i32, len i32) i32
+//	                                      needs write_notes; 0 on success
+//
+// Notes are read and written in the vault of the change with the rights of
+// whoever made it, and writes made by hooks don't run hooks again. Every
+// call gets a fresh instance with no filesystem, environment or network.
+package hooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// Hook exports
+const (
+	OnCreateNote = "on_create_note"
+	OnSave       = "on_save"
+	OnRename     = "on_rename"
+	OnDelete     = "on_delete"
+)
+
+// hooksFor lists the hooks run for each vault operation, in order
+var hooksFor = map[string][]string{
+	vault.OpCreate: {OnCreateNote, OnSave},
+	vault.OpWrite:  {OnSave},
+	vault.OpRename: {OnRename},
+	vault.OpDelete: {OnDelete},
+}
+
+// maxLogLine caps what a plugin writes to the log at once
+const maxLogLine = 4096
+
+// Source lists the installed plugins
+type Source func(ctx context.Context) ([]models.Plugin, error)
+
+// Options limits the hooks; zero fields take the defaults
+type Options struct {
+	MemoryLimitMB int           // memory of an instance; default 64
+	Timeout       time.Duration // per hook call; default 2s
+	Refresh       time.Duration // how often plugins are reloaded; default 5s
+}
+
+// Runtime loads the hooks modules of the active plugins and runs them
+type Runtime struct {
+	rt     wazero.Runtime
+	dir    string
+	source Source
+	opts   Options
+
+	mu      sync.Mutex
+	modules map[string]*module
+	failed  map[string]string // stamps of modules that didn't compile
+	checked time.Time
+}
+
+// module is the compiled hooks module of a plugin
+type module struct {
+	plugin   models.Plugin
+	caps     models.Capabilities
+	file     string
+	stamp    string // size and modification time the module was compiled at
+	compiled wazero.CompiledModule
+}
+
+// event is the input of a hook
+type event struct {
+	Vault   string `json:"vault"`
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// response is the output of a hook
+type response struct {
+	Content *string `json:"content"`
+	Error   string  `json:"error"`
+}
+
+// call is the state of a hook call, carried in its context for the host
+// functions
+type call struct {
+	plugin string
+	caps   models.Capabilities
+	vault  *vault.Vault
+}
+
+type callKey struct{}
+
+// fromHookKey marks the context of writes made by hooks
+type fromHookKey struct{}
+
+// New returns a runtime for the plugins listed by source, installed below
+// dir. The active plugins are loaded right away and reloaded as they change.
+func New(ctx context.Context, dir string, source Source, opts Options) (*Runtime, error) {
+	if opts.MemoryLimitMB <= 0 {
+		opts.MemoryLimitMB = 64
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	if opts.Refresh <= 0 {
+		opts.Refresh = 5 * time.Second
+	}
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(opts.MemoryLimitMB)*16). // 64 KiB pages
+		WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		rt.Close(ctx)
+		return nil, err
+	}
+	_, err := rt.NewHostModuleBuilder("dz").
+		NewFunctionBuilder().WithFunc(hostLog).Export("log").
+		NewFunctionBuilder().WithFunc(hostReadNote).Export("read_note").
+		NewFunctionBuilder().WithFunc(hostWriteNote).Export("write_note").
+		Instantiate(ctx)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, err
+	}
+
+	r := &Runtime{rt: rt, dir: dir, source: source, opts: opts, modules: map[string]*module{}, failed: map[string]string{}}
+	r.mu.Lock()
+	r.reload(ctx)
+	r.mu.Unlock()
+	return r, nil
+}
+
+// Close releases the runtime and its modules
+func (r *Runtime) Close(ctx context.Context) error {
+	return r.rt.Close(ctx)
+}
+
+// active returns the modules of the active plugins by name, reloading them
+// when they may have changed
+func (r *Runtime) active(ctx context.Context) []*module {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) >= r.opts.Refresh {
+		r.reload(ctx)
+	}
+	mods := make([]*module, 0, len(r.modules))
+	for _, m := range r.modules {
+		mods = append(mods, m)
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].plugin.Name < mods[j].plugin.Name })
+	return mods
+}
+
+// reload compiles the modules of newly active or updated plugins and drops
+// the ones no longer active. r.mu must be held.
+func (r *Runtime) reload(ctx context.Context) {
+	r.checked = time.Now()
+	plugins, err := r.source(ctx)
+	if err != nil {
+		log.Printf("hooks: listing plugins: %v", err)
+		return
+	}
+	keep := map[string]bool{}
+	for _, p := range plugins {
+		if !p.IsActive || p.HookModule == "" || !filepath.IsLocal(filepath.FromSlash(p.HookModule)) {
+			continue
+		}
+		file := filepath.Join(r.dir, p.Name, filepath.FromSlash(p.HookModule))
+		info, err := os.Stat(file)
+		if err != nil {
+			log.Printf("hooks: plugin %s: %v", p.Name, err)
+			continue
+		}
+		stamp := fmt.Sprintf("%d/%d", info.Size(), info.ModTime().UnixNano())
+		keep[p.Name] = true
+
+		if m := r.modules[p.Name]; m != nil && m.file == file && m.stamp == stamp {
+			// Modules in use are never changed, so grants apply to a copy
+			updated := *m
+			updated.plugin, updated.caps = p, p.Active()
+			r.modules[p.Name] = &updated
+			continue
+		}
+		if r.failed[p.Name] == file+"@"+stamp {
+			delete(keep, p.Name)
+			continue
+		}
+		if old := r.modules[p.Name]; old != nil {
+			old.compiled.Close(ctx)
+			delete(r.modules, p.Name)
+		}
+		m, err := r.compile(ctx, p, file)
+		if err != nil {
+			log.Printf("hooks: plugin %s: %v", p.Name, err)
+			r.failed[p.Name] = file + "@" + stamp
+			delete(keep, p.Name)
+			continue
+		}
+		delete(r.failed, p.Name)
+		m.stamp = stamp
+		r.modules[p.Name] = m
+	}
+	for name, m := range r.modules {
+		if !keep[name] {
+			m.compiled.Close(ctx)
+			delete(r.modules, name)
+		}
+	}
+}
+
+// compile compiles and checks the hooks module of p
+func (r *Runtime) compile(ctx context.Context, p models.Plugin, file string) (*module, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := r.rt.CompileModule(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExports(compiled); err != nil {
+		compiled.Close(ctx)
+		return nil, err
+	}
+	return &module{plugin: p, caps: p.Active(), file: file, compiled: compiled}, nil
+}
+
+// checkExports makes sure the module exports what the host calls with the
+// expected signatures
+func checkExports(compiled wazero.CompiledModule) error {
+	if len(compiled.ExportedMemories()) == 0 {
+		return errors.New("hooks module exports no memory")
+	}
+	funcs := compiled.ExportedFunctions()
+	if !signature(funcs["alloc"], []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}) {
+		return errors.New("hooks module must export alloc(i32) i32")
+	}
+	for _, name := range []string{OnCreateNote, OnSave, OnRename, OnDelete} {
+		if f := funcs[name]; f != nil && !signature(f, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}) {
+			return fmt.Errorf("hooks module must export %s(i32, i32) i64", name)
+		}
+	}
+	return nil
+}
+
+func signature(f api.FunctionDefinition, params, results []api.ValueType) bool {
+	return f != nil && string(f.ParamTypes()) == string(params) && string(f.ResultTypes()) == string(results)
+}
+
+// isNote reports whether the hooks see changes to p
+func isNote(p string) bool {
+	return strings.EqualFold(path.Ext(p), ".md")
+}
+
+// Hook runs the hooks of the active plugins for c; it is a vault.Hook
+func (r *Runtime) Hook(ctx context.Context, v *vault.Vault, c *vault.Change) error {
+	if ctx.Value(fromHookKey{}) != nil || !(isNote(c.Path) || isNote(c.OldPath)) {
+		return nil
+	}
+	names := hooksFor[c.Op]
+	if len(names) == 0 {
+		return nil
+	}
+	for _, m := range r.active(ctx) {
+		for _, name := range names {
+			if m.compiled.ExportedFunctions()[name] == nil {
+				continue
+			}
+			resp, err := r.call(ctx, m, name, v, c)
+			if err != nil {
+				log.Printf("hooks: plugin %s: %s %s: %v", m.plugin.Name, name, c.Path, err)
+				continue
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("%w by plugin %s: %s", vault.ErrRejected, m.plugin.Name, resp.Error)
+			}
+			if resp.Content != nil && (name == OnCreateNote || name == OnSave) {
+				c.Content = *resp.Content
+			}
+		}
+	}
+	return nil
+}
+
+// call runs hook name of m for c in a fresh instance
+func (r *Runtime) call(ctx context.Context, m *module, name string, v *vault.Vault, c *vault.Change) (*response, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, callKey{}, &call{plugin: m.plugin.Name, caps: m.caps, vault: v})
+
+	out := &logWriter{plugin: m.plugin.Name}
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStdout(out).
+		WithStderr(out).
+		WithRandSource(rand.Reader).
+		WithSysWalltime().
+		WithSysNanotime()
+	mod, err := r.rt.InstantiateModule(ctx, m.compiled, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer mod.Close(context.WithoutCancel(ctx))
+
+	input, err := json.Marshal(event{Vault: v.Name(), Path: c.Path, OldPath: c.OldPath, Content: c.Content})
+	if err != nil {
+		return nil, err
+	}
+	ptr, err := writeGuest(ctx, mod, input)
+	if err != nil {
+		return nil, err
+	}
+	results, err := mod.ExportedFunction(name).Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, err
+	}
+	resp := &response{}
+	if results[0] == 0 {
+		return resp, nil
+	}
+	b, ok := mod.Memory().Read(uint32(results[0]>>32), uint32(results[0]))
+	if !ok {
+		return nil, errors.New("response out of memory range")
+	}
+	if err := json.Unmarshal(b, resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return resp, nil
+}
+
+// writeGuest copies b into memory allocated by the guest
+func writeGuest(ctx context.Context, mod api.Module, b []byte) (uint32, error) {
+	results, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(b)))
+	if err != nil {
+		return 0, err
+	}
+	ptr := uint32(results[0])
+	if !mod.Memory().Write(ptr, b) {
+		return 0, errors.New("alloc returned memory out of range")
+	}
+	return ptr, nil
+}
+
+func callFrom(ctx context.Context) *call {
+	c, _ := ctx.Value(callKey{}).(*call)
+	return c
+}
+
+// readString reads a string the guest passed to a host function
+func readString(mod api.Module, ptr, n uint32) (string, bool) {
+	b, ok := mod.Memory().Read(ptr, n)
+	return string(b), ok
+}
+
+func hostLog(ctx context.Context, mod api.Module, ptr, n uint32) {
+	c := callFrom(ctx)
+	if msg, ok := readString(mod, ptr, min(n, maxLogLine)); ok && c != nil {
+		log.Printf("plugin %s: %s", c.plugin, msg)
+	}
+}
+
+func hostReadNote(ctx context.Context, mod api.Module, ptr, n uint32) uint64 {
+	c := callFrom(ctx)
+	if c == nil {
+		return 0
+	}
+	if !c.caps.Has(models.CapReadNotes) {
+		log.Printf("plugin %s: read_note denied: %s not granted", c.plugin, models.CapReadNotes)
+		return 0
+	}
+	p, ok := readString(mod, ptr, n)
+	if !ok || !isNote(p) {
+		return 0
+	}
+	res, err := c.vault.ReadFile(ctx, p)
+	if err != nil {
+		return 0
+	}
+	out, err := writeGuest(ctx, mod, []byte(res.Content))
+	if err != nil {
+		return 0
+	}
+	return uint64(out)<<32 | uint64(len(res.Content))
+}
+
+func hostWriteNote(ctx context.Context, mod api.Module, pathPtr, pathLen, ptr, n uint32) uint32 {
+	const failed = ^uint32(0) // -1
+	c := callFrom(ctx)
+	if c == nil {
+		return failed
+	}
+	if !c.caps.Has(models.CapWriteNotes) {
+		log.Printf("plugin %s: write_note denied: %s not granted", c.plugin, models.CapWriteNotes)
+		return failed
+	}
+	p, ok := readString(mod, pathPtr, pathLen)
+	if !ok || !isNote(p) {
+		return failed
+	}
+	content, ok := readString(mod, ptr, n)
+	if !ok {
+		return failed
+	}
+	if _, err := c.vault.WriteFile(context.WithValue(ctx, fromHookKey{}, true), p, vault.WriteRequest{Content: content}); err != nil {
+		log.Printf("plugin %s: write_note %s: %v", c.plugin, p, err)
+		return failed
+	}
+	return 0
+}
+
+// logWriter sends what a plugin prints to the server log
+type logWriter struct {
+	plugin string
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	if msg := strings.TrimRight(string(p[:min(len(p), maxLogLine)]), "\n"); msg != "" {
+		log.Printf("plugin %s: %s", w.plugin, msg)
+	}
+	return len(p), nil
+}
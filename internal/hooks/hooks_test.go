@@ -0,0 +1,223 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+var (
+	buildOnce sync.Once
+	guestWasm []byte
+	buildErr  error
+)
+
+// guest builds the hooks module in testdata/guest
+func guest(t *testing.T) []byte {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found")
+	}
+	buildOnce.Do(func() {
+		out := filepath.Join(os.TempDir(), "dz-hooks-guest.wasm")
+		cmd := exec.Command(goBin, "build", "-buildmode=c-shared", "-o", out, ".")
+		cmd.Dir = filepath.Join("testdata", "guest")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if msg, err := cmd.CombinedOutput(); err != nil {
+			buildErr = errors.New(string(msg))
+			return
+		}
+		guestWasm, buildErr = os.ReadFile(out)
+		os.Remove(out)
+	})
+	if buildErr != nil {
+		t.Fatalf("building guest: %v", buildErr)
+	}
+	return guestWasm
+}
+
+// setup installs the guest as plugin test.guest and returns a vault hooked
+// to a runtime running it with the plugin as *p
+func setup(t *testing.T, p *models.Plugin, opts Options) (*vault.Vault, *Runtime) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "test.guest"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test.guest", "hooks.wasm"), guest(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := func(ctx context.Context) ([]models.Plugin, error) {
+		return []models.Plugin{*p}, nil
+	}
+	ctx := context.Background()
+	rt, err := New(ctx, dir, source, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rt.Close(ctx) })
+
+	v, err := vault.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Hook(rt.Hook)
+	return v, rt
+}
+
+func guestPlugin(granted ...string) *models.Plugin {
+	return &models.Plugin{
+		Name:         "test.guest",
+		IsActive:     true,
+		HookModule:   "hooks.wasm",
+		Capabilities: models.Capabilities{models.CapReadNotes, models.CapWriteNotes},
+		Granted:      granted,
+	}
+}
+
+func read(t *testing.T, v *vault.Vault, p string) string {
+	t.Helper()
+	res, err := v.ReadFile(context.Background(), p)
+	if err != nil {
+		t.Fatalf("ReadFile(%s): %v", p, err)
+	}
+	return res.Content
+}
+
+func TestRuntime_Hooks(t *testing.T) {
+	v, _ := setup(t, guestPlugin(), Options{})
+	ctx := context.Background()
+
+	if _, err := v.WriteFile(ctx, "stamp/a.md", vault.WriteRequest{Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, v, "stamp/a.md"); got != "created: hi\n(saved)" {
+		t.Errorf("created note = %q, want on_create_note then on_save applied", got)
+	}
+	if _, err := v.WriteFile(ctx, "stamp/a.md", vault.WriteRequest{Content: "edit"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, v, "stamp/a.md"); got != "edit\n(saved)" {
+		t.Errorf("saved note = %q, want on_save applied", got)
+	}
+
+	_, err := v.WriteFile(ctx, "b.md", vault.WriteRequest{Content: "forbidden"})
+	if !errors.Is(err, vault.ErrRejected) || !strings.Contains(err.Error(), "no forbidden words") {
+		t.Errorf("WriteFile() error = %v, want the plugin's rejection", err)
+	}
+	if _, err := v.ReadFile(ctx, "b.md"); err == nil {
+		t.Error("rejected note was written")
+	}
+
+	// Only notes are hooked
+	if _, err := v.WriteFile(ctx, "data.txt", vault.WriteRequest{Content: "forbidden"}); err != nil {
+		t.Errorf("WriteFile(data.txt) = %v, want no hooks for other files", err)
+	}
+
+	v.WriteFile(ctx, "keep.md", vault.WriteRequest{Content: "x"})
+	if err := v.DeleteFile(ctx, "keep.md"); !errors.Is(err, vault.ErrRejected) {
+		t.Errorf("DeleteFile(keep.md) = %v, want rejected", err)
+	}
+	if err := v.DeleteFile(ctx, "stamp/a.md"); err != nil {
+		t.Errorf("DeleteFile() = %v", err)
+	}
+}
+
+func TestRuntime_FailOpen(t *testing.T) {
+	v, _ := setup(t, guestPlugin(), Options{Timeout: 200 * time.Millisecond})
+	ctx := context.Background()
+
+	for _, p := range []string{"loop.md", "trap.md"} {
+		start := time.Now()
+		if _, err := v.WriteFile(ctx, p, vault.WriteRequest{Content: "x"}); err != nil {
+			t.Errorf("WriteFile(%s) = %v, want a broken hook skipped", p, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("WriteFile(%s) took %v, want the timeout to stop the hook", p, elapsed)
+		}
+	}
+}
+
+func TestRuntime_Capabilities(t *testing.T) {
+	p := guestPlugin()
+	v, rt := setup(t, p, Options{Refresh: time.Nanosecond})
+	ctx := context.Background()
+	v.WriteFile(ctx, "source.md", vault.WriteRequest{Content: "original"})
+
+	v.WriteFile(ctx, "copy.md", vault.WriteRequest{Content: "x"})
+	if got := read(t, v, "copy.md"); got != "read failed" {
+		t.Errorf("without grants copy.md = %q, want read_note denied", got)
+	}
+
+	// Grants asked for but not requested by the manifest don't count
+	*p = *guestPlugin(models.CapReadNotes)
+	p.Capabilities = models.Capabilities{models.CapWriteNotes}
+	v.WriteFile(ctx, "copy.md", vault.WriteRequest{Content: "x"})
+	if got := read(t, v, "copy.md"); got != "read failed" {
+		t.Errorf("with an unrequested grant copy.md = %q, want read_note denied", got)
+	}
+
+	*p = *guestPlugin(models.CapReadNotes)
+	v.WriteFile(ctx, "copy.md", vault.WriteRequest{Content: "x"})
+	if got := read(t, v, "copy.md"); got != "write failed" {
+		t.Errorf("with read_notes copy.md = %q, want write_note denied", got)
+	}
+
+	*p = *guestPlugin(models.CapReadNotes, models.CapWriteNotes)
+	v.WriteFile(ctx, "copy.md", vault.WriteRequest{Content: "x"})
+	if got := read(t, v, "copy.md"); got != "copied created: original" {
+		t.Errorf("with both grants copy.md = %q", got)
+	}
+	// The plugin's own write skips the hooks, or on_create_note would prefix it
+	if got := read(t, v, "copied.md"); got != "created: original" {
+		t.Errorf("copied.md = %q, want the source unchanged", got)
+	}
+
+	// Host functions act with the rights of the change
+	c := &vault.Change{Op: vault.OpWrite, Path: "copy.md"}
+	if err := rt.Hook(vault.WithRole(ctx, models.RoleViewer), v, c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Content != "write failed" {
+		t.Errorf("as a viewer content = %q, want write_note refused", c.Content)
+	}
+
+	// Disabled plugins stop running
+	p.IsActive = false
+	if _, err := v.WriteFile(ctx, "b.md", vault.WriteRequest{Content: "forbidden"}); err != nil {
+		t.Errorf("WriteFile() = %v, want disabled plugin skipped", err)
+	}
+}
+
+func TestRuntime_InvalidModule(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "bad"), 0o755)
+	os.WriteFile(filepath.Join(dir, "bad", "hooks.wasm"), []byte("not wasm"), 0o644)
+	source := func(ctx context.Context) ([]models.Plugin, error) {
+		return []models.Plugin{{Name: "bad", IsActive: true, HookModule: "hooks.wasm"}}, nil
+	}
+	ctx := context.Background()
+	rt, err := New(ctx, dir, source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close(ctx)
+	v, err := vault.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Hook(rt.Hook)
+	if _, err := v.WriteFile(ctx, "a.md", vault.WriteRequest{Content: "x"}); err != nil {
+		t.Errorf("WriteFile() = %v, want a plugin that doesn't compile skipped", err)
+	}
+}
@@ -0,0 +1,114 @@
+//go:build wasip1
+
+// Command guest is the hooks module of the tests, built with
+// GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"unsafe"
+)
+
+//go:wasmimport dz log
+func hostLog(ptr unsafe.Pointer, n uint32)
+
+//go:wasmimport dz read_note
+func hostReadNote(ptr unsafe.Pointer, n uint32) uint64
+
+//go:wasmimport dz write_note
+func hostWriteNote(pathPtr unsafe.Pointer, pathLen uint32, ptr unsafe.Pointer, n uint32) uint32
+
+// pinned keeps the buffers handed to the host alive
+var pinned [][]byte
+
+func pin(b []byte) uint32 {
+	if len(b) == 0 {
+		b = make([]byte, 1)[:0]
+	}
+	pinned = append(pinned, b)
+	return uint32(uintptr(unsafe.Pointer(unsafe.SliceData(b))))
+}
+
+//go:wasmexport alloc
+func alloc(n uint32) uint32 {
+	return pin(make([]byte, n, n+1))
+}
+
+type event struct {
+	Vault   string `json:"vault"`
+	Path    string `json:"path"`
+	OldPath string `json:"old_path"`
+	Content string `json:"content"`
+}
+
+func input(ptr, n uint32) event {
+	var e event
+	json.Unmarshal(unsafe.Slice((*byte)(unsafe.Pointer(uintptr(ptr))), n), &e)
+	return e
+}
+
+func reply(v map[string]any) uint64 {
+	b, _ := json.Marshal(v)
+	return uint64(pin(b))<<32 | uint64(len(b))
+}
+
+func logf(s string) {
+	hostLog(unsafe.Pointer(unsafe.StringData(s)), uint32(len(s)))
+}
+
+func readNote(p string) (string, bool) {
+	r := hostReadNote(unsafe.Pointer(unsafe.StringData(p)), uint32(len(p)))
+	if r == 0 {
+		return "", false
+	}
+	return string(unsafe.Slice((*byte)(unsafe.Pointer(uintptr(r>>32))), uint32(r))), true
+}
+
+func writeNote(p, content string) bool {
+	return hostWriteNote(unsafe.Pointer(unsafe.StringData(p)), uint32(len(p)),
+		unsafe.Pointer(unsafe.StringData(content)), uint32(len(content))) == 0
+}
+
+//go:wasmexport on_create_note
+func onCreateNote(ptr, n uint32) uint64 {
+	e := input(ptr, n)
+	return reply(map[string]any{"content": "created: " + e.Content})
+}
+
+//go:wasmexport on_save
+func onSave(ptr, n uint32) uint64 {
+	e := input(ptr, n)
+	logf("saving " + e.Path)
+	switch {
+	case strings.Contains(e.Content, "forbidden"):
+		return reply(map[string]any{"error": "no forbidden words"})
+	case e.Path == "loop.md":
+		for {
+		}
+	case e.Path == "trap.md":
+		panic("boom")
+	case e.Path == "copy.md":
+		src, ok := readNote("source.md")
+		if !ok {
+			return reply(map[string]any{"content": "read failed"})
+		}
+		if !writeNote("copied.md", src) {
+			return reply(map[string]any{"content": "write failed"})
+		}
+		return reply(map[string]any{"content": "copied " + src})
+	case strings.HasPrefix(e.Path, "stamp/"):
+		return reply(map[string]any{"content": e.Content + "\n(saved)"})
+	}
+	return 0
+}
+
+//go:wasmexport on_delete
+func onDelete(ptr, n uint32) uint64 {
+	if input(ptr, n).Path == "keep.md" {
+		return reply(map[string]any{"error": "keep.md stays"})
+	}
+	return 0
+}
+
+func main() {}
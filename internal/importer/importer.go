@@ -0,0 +1,648 @@
+// Package importer converts the exports of other note-taking tools into
+// deez notes: Obsidian vaults, Logseq graphs and Notion exports.
+//
+// Each converter reads the export from an fs.FS and returns a Result: the
+// files to write, with links, block references, daily notes, tags and
+// attachments rewritten to deez conventions, and a Report of what couldn't
+// be converted. Converted notes get id and created frontmatter when they
+// have none. Daily notes go to daily/YYYY-MM-DD.md and attachments to
+// attachments/.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/lint"
+	"dragonbytelabs/dz/internal/markdown"
+	"dragonbytelabs/dz/internal/vault"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Folders imported files go to
+const (
+	AttachmentsDir = "attachments"
+	DailyDir       = "daily"
+)
+
+// dailyLayout names daily notes, the way the editor does
+const dailyLayout = "2006-01-02"
+
+// Warning is something an importer couldn't convert
+type Warning struct {
+	Path    string `json:"path"` // source path
+	Message string `json:"message"`
+}
+
+// Report summarises an import
+type Report struct {
+	Source      string    `json:"source"` // obsidian, logseq or notion
+	Notes       int       `json:"notes"`
+	Attachments int       `json:"attachments"`
+	Written     int       `json:"written"`
+	Skipped     []string  `json:"skipped"` // vault paths that already existed
+	Warnings    []Warning `json:"warnings"`
+}
+
+func (r *Report) warn(p, format string, args ...any) {
+	r.Warnings = append(r.Warnings, Warning{Path: p, Message: fmt.Sprintf(format, args...)})
+}
+
+// File is a converted file
+type File struct {
+	Path    string // vault path
+	Content []byte
+}
+
+// Result is a converted export, ready to be written to a vault
+type Result struct {
+	Files  []File // notes, then attachments
+	Report *Report
+}
+
+// Write writes the files into v with the role in ctx. Files that exist in
+// the vault are left alone and listed as skipped; with dryRun nothing is
+// written.
+func (r *Result) Write(ctx context.Context, v *vault.Vault, dryRun bool) error {
+	r.Report.Skipped = []string{}
+	for _, f := range r.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := v.ReadFile(ctx, f.Path); !errors.Is(err, fs.ErrNotExist) {
+			r.Report.Skipped = append(r.Report.Skipped, f.Path)
+			continue
+		}
+		if !dryRun {
+			if _, err := v.WriteFile(ctx, f.Path, vault.WriteRequest{Content: string(f.Content)}); err != nil {
+				return fmt.Errorf("writing %s: %w", f.Path, err)
+			}
+		}
+		r.Report.Written++
+	}
+	return nil
+}
+
+// note is a note being converted
+type note struct {
+	src     string     // source path, for warnings
+	path    string     // vault path
+	fm      *yaml.Node // mapping; nil when the source frontmatter is invalid
+	header  string     // the invalid source frontmatter, kept as written
+	body    string
+	created time.Time // for notes without a created key
+}
+
+// converter holds what the converters share
+type converter struct {
+	report      *Report
+	notes       []*note
+	attachments []File
+	ids         map[string]bool
+	taken       map[string]bool   // vault paths in use, lower-cased
+	moved       map[string]string // source path to vault path
+	index       *markdown.Index   // the converted notes, as deez resolves links
+}
+
+func newConverter(source string) *converter {
+	return &converter{
+		report: &Report{Source: source, Skipped: []string{}, Warnings: []Warning{}},
+		ids:    map[string]bool{},
+		taken:  map[string]bool{},
+		moved:  map[string]string{},
+	}
+}
+
+// claim returns p, or "name 2.ext", "name 3.ext"... when p is in use, so
+// that two sources never land on the same vault path
+func (c *converter) claim(p string) string {
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for i := 1; ; i++ {
+		candidate := p
+		if i > 1 {
+			candidate = fmt.Sprintf("%s %d%s", base, i, ext)
+		}
+		if !c.taken[strings.ToLower(candidate)] {
+			c.taken[strings.ToLower(candidate)] = true
+			return candidate
+		}
+	}
+}
+
+// newNote parses src, a markdown file from the export, into a note
+func (c *converter) newNote(srcPath, vaultPath string, src []byte, created time.Time) *note {
+	n := &note{src: srcPath, path: vaultPath, created: created}
+	c.notes = append(c.notes, n)
+	header, body, ok := splitFrontmatter(string(src))
+	n.body = body
+	var doc yaml.Node
+	switch err := yaml.Unmarshal([]byte(header), &doc); {
+	case !ok:
+		n.fm = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	case err != nil:
+		c.report.warn(srcPath, "frontmatter is not valid YAML, kept as written without id and created: %v", err)
+		n.header = header
+	case len(doc.Content) == 0:
+		n.fm = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	case doc.Content[0].Kind != yaml.MappingNode:
+		c.report.warn(srcPath, "frontmatter is not a YAML mapping, kept as written without id and created")
+		n.header = header
+	default:
+		n.fm = doc.Content[0]
+	}
+	return n
+}
+
+// attach adds a file to import as is
+func (c *converter) attach(vaultPath string, content []byte) {
+	c.attachments = append(c.attachments, File{Path: vaultPath, Content: content})
+}
+
+// indexNotes indexes the converted notes so links can be checked against
+// deez's link resolution
+func (c *converter) indexNotes() {
+	c.index = markdown.NewIndex()
+	for _, n := range c.notes {
+		var fm markdown.Frontmatter
+		if n.fm != nil {
+			_ = n.fm.Decode(&fm)
+		}
+		c.index.Add(n.path, fm)
+	}
+}
+
+// linkTarget returns how a wiki link written as target should point at the
+// note at vault path p: target itself when deez resolves it to p, else p
+// without its extension
+func (c *converter) linkTarget(target, p string) string {
+	if got, ok := c.index.Resolve(target); ok && got == p {
+		return target
+	}
+	return strings.TrimSuffix(p, ".md")
+}
+
+// relink points the wiki link l at the vault path p: a note, or a file in
+// attachments/, which wiki links name by file name. A rewritten link keeps
+// what it displayed.
+func (c *converter) relink(l markdown.Link, p string) markdown.Link {
+	if !isMarkdown(p) {
+		l.Target = path.Base(p)
+		return l
+	}
+	target := c.linkTarget(l.Target, p)
+	if target != l.Target && l.Alias == "" && l.Heading == "" && !l.Embed {
+		l.Alias = l.Target
+	}
+	l.Target = target
+	return l
+}
+
+// result renders the notes, adding the required frontmatter keys
+func (c *converter) result() *Result {
+	sort.SliceStable(c.notes, func(i, j int) bool { return c.notes[i].path < c.notes[j].path })
+	sort.SliceStable(c.attachments, func(i, j int) bool { return c.attachments[i].Path < c.attachments[j].Path })
+
+	res := &Result{Report: c.report}
+	for _, n := range c.notes {
+		res.Files = append(res.Files, File{Path: n.path, Content: []byte(c.render(n))})
+	}
+	res.Files = append(res.Files, c.attachments...)
+	c.report.Notes, c.report.Attachments = len(c.notes), len(c.attachments)
+	return res
+}
+
+func (c *converter) render(n *note) string {
+	if n.fm == nil {
+		return "---\n" + n.header + "---\n" + n.body
+	}
+	if id := getString(n.fm, "id"); id == "" {
+		setFirst(n.fm, "id", c.newID(n.created))
+	} else if !lint.ValidID(id) {
+		c.report.warn(n.src, "id %q isn't in the YYYYMMDDhhmmss-xxx format; kept so links to it still work", id)
+	}
+	if getString(n.fm, "created") == "" {
+		setAfter(n.fm, "id", "created", n.created.UTC().Format(time.RFC3339))
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(n.fm); err != nil {
+		// Nodes decoded from YAML always encode
+		panic(err)
+	}
+	enc.Close()
+	return "---\n" + buf.String() + "---\n" + n.body
+}
+
+func (c *converter) newID(t time.Time) string {
+	for {
+		if id := lint.NewID(t); !c.ids[id] {
+			c.ids[id] = true
+			return id
+		}
+	}
+}
+
+// splitFrontmatter splits a leading "---" block off src
+func splitFrontmatter(src string) (header, body string, ok bool) {
+	first, rest, found := strings.Cut(src, "\n")
+	if !found || strings.TrimRight(first, " \t\r") != "---" {
+		return "", src, false
+	}
+	for offset := 0; offset < len(rest); {
+		line, _, _ := strings.Cut(rest[offset:], "\n")
+		end := offset + len(line) + 1
+		if strings.TrimSpace(line) == "---" {
+			return rest[:offset], rest[min(end, len(rest)):], true
+		}
+		offset = end
+	}
+	return "", src, false
+}
+
+/* Frontmatter editing. Keys are edited in place so the rest of the header
+   keeps its order and formatting. */
+
+func lookup(m *yaml.Node, key string) (int, *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i, m.Content[i+1]
+		}
+	}
+	return -1, nil
+}
+
+func getString(m *yaml.Node, key string) string {
+	if _, v := lookup(m, key); v != nil && v.Kind == yaml.ScalarNode {
+		return strings.TrimSpace(v.Value)
+	}
+	return ""
+}
+
+func valueNode(value any) *yaml.Node {
+	var n yaml.Node
+	if err := n.Encode(value); err != nil {
+		panic(err)
+	}
+	return &n
+}
+
+func keyNode(key string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+}
+
+// set replaces the value of key, appending the key when it is missing
+func set(m *yaml.Node, key string, value any) {
+	if i, _ := lookup(m, key); i >= 0 {
+		m.Content[i+1] = valueNode(value)
+		return
+	}
+	m.Content = append(m.Content, keyNode(key), valueNode(value))
+}
+
+// setFirst sets key, moving it to the top of the mapping
+func setFirst(m *yaml.Node, key string, value any) {
+	remove(m, key)
+	m.Content = append([]*yaml.Node{keyNode(key), valueNode(value)}, m.Content...)
+}
+
+// setAfter sets key, placing it after the key after when that is present
+func setAfter(m *yaml.Node, after, key string, value any) {
+	remove(m, key)
+	i, _ := lookup(m, after)
+	at := i + 2
+	if i < 0 {
+		at = 0
+	}
+	m.Content = append(m.Content[:at], append([]*yaml.Node{keyNode(key), valueNode(value)}, m.Content[at:]...)...)
+}
+
+func remove(m *yaml.Node, key string) {
+	if i, _ := lookup(m, key); i >= 0 {
+		m.Content = append(m.Content[:i], m.Content[i+2:]...)
+	}
+}
+
+// rename moves the value of from to the key to, unless to is already set
+func rename(m *yaml.Node, from, to string) {
+	i, _ := lookup(m, from)
+	if i < 0 {
+		return
+	}
+	if j, _ := lookup(m, to); j >= 0 {
+		return
+	}
+	m.Content[i] = keyNode(to)
+}
+
+// list reads key as a list of strings, accepting a sequence or a comma or
+// space separated string, as the tools write tags and aliases
+func list(m *yaml.Node, key string, spaces bool) []string {
+	_, v := lookup(m, key)
+	if v == nil {
+		return nil
+	}
+	var items []string
+	switch v.Kind {
+	case yaml.SequenceNode:
+		for _, item := range v.Content {
+			items = append(items, item.Value)
+		}
+	case yaml.ScalarNode:
+		sep := func(r rune) bool { return r == ',' || (spaces && r == ' ') }
+		items = strings.FieldsFunc(v.Value, sep)
+	}
+	var out []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// addTags merges tags into the note's tags, as a sequence without
+// duplicates or leading #
+func addTags(m *yaml.Node, tags ...string) {
+	have := list(m, "tags", true)
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range append(have, tags...) {
+		t = TagName(t)
+		if t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	if len(out) == 0 {
+		remove(m, "tags")
+		return
+	}
+	set(m, "tags", out)
+}
+
+// splitList splits a list the tools write as text, like "[[a b]], c, #d"
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(item), "#"))
+		item = strings.TrimSuffix(strings.TrimPrefix(item, "[["), "]]")
+		if item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+var tagReplacer = strings.NewReplacer(" ", "-", "[", "", "]", "", "#", "")
+
+// TagName turns a tag as other tools write it, e.g. "#[[Project X]]", into
+// a deez tag, "Project-X"
+func TagName(tag string) string {
+	return strings.Trim(tagReplacer.Replace(strings.TrimSpace(tag)), "-")
+}
+
+/* Body rewriting */
+
+var codeSpans = regexp.MustCompile("(?ms)^ {0,3}```.*?^ {0,3}```[^\n]*$|^ {0,3}~~~.*?^ {0,3}~~~[^\n]*$|`[^`\n]*`")
+
+// outsideCode applies fn to the parts of body outside code blocks and
+// inline code
+func outsideCode(body string, fn func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range codeSpans.FindAllStringIndex(body, -1) {
+		b.WriteString(fn(body[last:span[0]]))
+		b.WriteString(body[span[0]:span[1]])
+		last = span[1]
+	}
+	b.WriteString(fn(body[last:]))
+	return b.String()
+}
+
+var wikiLinkPattern = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+?)\]\]`)
+
+// rewriteWikiLinks calls fn with each [[link]] of s, replacing it with what
+// fn returns
+func rewriteWikiLinks(s string, fn func(l markdown.Link) string) string {
+	return wikiLinkPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := wikiLinkPattern.FindStringSubmatch(m)
+		l := markdown.ParseWikiLink(sub[2])
+		l.Embed = sub[1] == "!"
+		if l.Target == "" {
+			return m
+		}
+		return fn(l)
+	})
+}
+
+// wikiLink writes l in wiki link syntax
+func wikiLink(l markdown.Link) string {
+	var b strings.Builder
+	if l.Embed {
+		b.WriteByte('!')
+	}
+	b.WriteString("[[")
+	b.WriteString(l.Target)
+	if l.Heading != "" {
+		b.WriteString("#" + l.Heading)
+	}
+	if l.Alias != "" {
+		b.WriteString("|" + l.Alias)
+	}
+	b.WriteString("]]")
+	return b.String()
+}
+
+var markdownLinkPattern = regexp.MustCompile(`(!?)\[([^\]\n]*)\]\((<[^>\n]+>|[^)\s]+)((?:\s+"[^"\n]*")?)\)`)
+
+// mdLink is a markdown link or image
+type mdLink struct {
+	image bool
+	text  string
+	dest  string // unescaped, without the <> of "<path with spaces>"
+	title string // ` "title"`, if any
+}
+
+func (l mdLink) String() string {
+	bang := ""
+	if l.image {
+		bang = "!"
+	}
+	return fmt.Sprintf("%s[%s](%s%s)", bang, l.text, escapePath(l.dest), l.title)
+}
+
+// rewriteMarkdownLinks calls fn with each [text](dest) of s that points
+// inside the export, replacing it with what fn returns
+func rewriteMarkdownLinks(s string, fn func(l mdLink) string) string {
+	return markdownLinkPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := markdownLinkPattern.FindStringSubmatch(m)
+		dest := strings.TrimSuffix(strings.TrimPrefix(sub[3], "<"), ">")
+		if markdown.IsExternal(dest) {
+			return m
+		}
+		if unescaped, err := url.PathUnescape(dest); err == nil {
+			dest = unescaped
+		}
+		return fn(mdLink{image: sub[1] == "!", text: sub[2], dest: dest, title: sub[4]})
+	})
+}
+
+// escapePath escapes a vault path for a markdown link destination
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// relPath returns the path of the vault path to relative to the folder dir
+func relPath(dir, to string) string {
+	if dir == "." || dir == "" {
+		return to
+	}
+	from := strings.Split(dir, "/")
+	parts := strings.Split(to, "/")
+	i := 0
+	for i < len(from) && i < len(parts)-1 && from[i] == parts[i] {
+		i++
+	}
+	return strings.Repeat("../", len(from)-i) + strings.Join(parts[i:], "/")
+}
+
+// resolveRelative resolves a link destination written in the note at
+// notePath against the export's root
+func resolveRelative(notePath, dest string) string {
+	dest, _, _ = strings.Cut(dest, "#")
+	if strings.HasPrefix(dest, "/") {
+		return path.Clean(strings.TrimPrefix(dest, "/"))
+	}
+	return path.Join(path.Dir(notePath), dest)
+}
+
+// sanitizeName replaces the characters file systems refuse in a file or
+// folder name
+var sanitizeName = strings.NewReplacer(`\`, "-", ":", "-", "*", "-", "?", "", `"`, "'", "<", "(", ">", ")", "|", "-")
+
+// isMarkdown reports whether p names a markdown file
+func isMarkdown(p string) bool {
+	return strings.EqualFold(path.Ext(p), ".md")
+}
+
+/* Dates. The tools name daily notes with moment.js (Obsidian) or date-fns
+   (Logseq) formats, which are converted to Go layouts. */
+
+// ordinal stands for the "st" of "1st" in layouts; Go has no such verb
+const ordinal = "<ord>"
+
+// Format tokens and their Go layouts, longest first
+var (
+	momentTokens = []string{
+		"YYYY", "2006", "YY", "06", "MMMM", "January", "MMM", "Jan", "MM", "01", "M", "1",
+		"Do", "2" + ordinal, "DD", "02", "D", "2", "dddd", "Monday", "ddd", "Mon",
+	}
+	dateFnsTokens = []string{
+		"yyyy", "2006", "yy", "06", "MMMM", "January", "MMM", "Jan", "MM", "01", "M", "1",
+		"do", "2" + ordinal, "dd", "02", "d", "2", "EEEE", "Monday", "EEE", "Mon", "E", "Mon",
+	}
+)
+
+// goLayout converts format to a Go layout. Text in quote, [moment] or
+// 'date-fns', is kept as is.
+func goLayout(format string, tokens []string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		if q := format[i]; q == '[' || q == '\'' {
+			end := byte(']')
+			if q == '\'' {
+				end = '\''
+			}
+			if j := strings.IndexByte(format[i+1:], end); j >= 0 {
+				b.WriteString(format[i+1 : i+1+j])
+				i += j + 2
+				continue
+			}
+		}
+		matched := false
+		for t := 0; t < len(tokens); t += 2 {
+			if strings.HasPrefix(format[i:], tokens[t]) {
+				b.WriteString(tokens[t+1])
+				i += len(tokens[t])
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+var ordinalSuffix = regexp.MustCompile(`(\d)(?:st|nd|rd|th)\b`)
+
+// parseDate parses s with a layout from goLayout
+func parseDate(layout, s string) (time.Time, bool) {
+	if strings.Contains(layout, ordinal) {
+		layout = strings.ReplaceAll(layout, ordinal, "")
+		s = ordinalSuffix.ReplaceAllString(s, "$1")
+	}
+	t, err := time.Parse(layout, s)
+	return t, err == nil
+}
+
+// formatDate formats t with a layout from goLayout
+func formatDate(layout string, t time.Time) string {
+	suffix := "th"
+	switch d := t.Day(); {
+	case d == 1 || d == 21 || d == 31:
+		suffix = "st"
+	case d == 2 || d == 22:
+		suffix = "nd"
+	case d == 3 || d == 23:
+		suffix = "rd"
+	}
+	return strings.ReplaceAll(t.Format(layout), ordinal, suffix)
+}
+
+// daily makes n a daily note for day
+func daily(n *note, day time.Time) {
+	if n.fm == nil {
+		return
+	}
+	if getString(n.fm, "date") == "" {
+		set(n.fm, "date", day.Format(dailyLayout))
+	}
+	if getString(n.fm, "type") == "" {
+		set(n.fm, "type", "daily-note")
+	}
+	addTags(n.fm, "daily")
+}
+
+// dailyPath is the vault path of the daily note for day
+func dailyPath(day time.Time) string {
+	return path.Join(DailyDir, day.Format(dailyLayout)+".md")
+}
+
+// readFile reads a file of the export with its modification time
+func readFile(src fs.FS, p string) ([]byte, time.Time, error) {
+	b, err := fs.ReadFile(src, p)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	mtime := time.Now()
+	if info, err := fs.Stat(src, p); err == nil && !info.ModTime().IsZero() {
+		mtime = info.ModTime()
+	}
+	return b, mtime, nil
+}
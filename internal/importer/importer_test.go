@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"dragonbytelabs/dz/internal/lint"
+	"dragonbytelabs/dz/internal/markdown"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// mapFS builds an export from file contents
+func mapFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	mtime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for p, content := range files {
+		fsys[p] = &fstest.MapFile{Data: []byte(content), ModTime: mtime}
+	}
+	return fsys
+}
+
+// files returns the converted files by vault path
+func files(t *testing.T, res *Result) map[string]string {
+	t.Helper()
+	out := map[string]string{}
+	for _, f := range res.Files {
+		if _, ok := out[f.Path]; ok {
+			t.Errorf("%s converted twice", f.Path)
+		}
+		out[f.Path] = string(f.Content)
+	}
+	return out
+}
+
+// parse parses a converted note, failing the test on invalid frontmatter
+func parse(t *testing.T, content string) *markdown.Note {
+	t.Helper()
+	n, err := markdown.Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("converted note has invalid frontmatter: %v\n%s", err, content)
+	}
+	return n
+}
+
+// warned reports whether the report has a warning about p containing text
+func warned(r *Report, p, text string) bool {
+	for _, w := range r.Warnings {
+		if w.Path == p && strings.Contains(w.Message, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConvertedNotes(t *testing.T) {
+	c := newConverter("test")
+	c.newNote("a.md", "a.md", []byte("plain"), time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	c.newNote("b.md", "b.md", []byte("---\ntitle: B\nid: 20230101000000-abc\n---\nbody"), time.Now())
+	c.newNote("c.md", "c.md", []byte("---\nid: 7\ncreated: 2020-01-01\n---\n"), time.Now())
+	c.newNote("d.md", "d.md", []byte("---\ntitle: [\n---\nbody"), time.Now())
+	got := files(t, c.result())
+
+	a := parse(t, got["a.md"])
+	if !lint.ValidID(a.Frontmatter.ID) || a.Frontmatter.Created != "2024-05-01T10:00:00Z" || string(a.Body) != "plain" {
+		t.Errorf("a.md = %q, want id and created added", got["a.md"])
+	}
+	if !strings.HasPrefix(got["a.md"], "---\nid: ") {
+		t.Errorf("a.md = %q, want id first", got["a.md"])
+	}
+	if b := parse(t, got["b.md"]); b.Frontmatter.ID != "20230101000000-abc" || b.Frontmatter.Title != "B" {
+		t.Errorf("b.md = %q, want its id kept", got["b.md"])
+	}
+	if c := parse(t, got["c.md"]); c.Frontmatter.ID != "7" || c.Frontmatter.Created != "2020-01-01" {
+		t.Errorf("c.md = %q, want its keys kept", got["c.md"])
+	}
+	if got["d.md"] != "---\ntitle: [\n---\nbody" {
+		t.Errorf("d.md = %q, want invalid frontmatter kept as written", got["d.md"])
+	}
+	if !warned(c.report, "c.md", "format") || !warned(c.report, "d.md", "not valid YAML") {
+		t.Errorf("warnings = %+v", c.report.Warnings)
+	}
+}
+
+func TestResultWrite(t *testing.T) {
+	ctx := context.Background()
+	v, err := vault.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.WriteFile(ctx, "a.md", vault.WriteRequest{Content: "mine"})
+	res := &Result{Report: &Report{}, Files: []File{{Path: "a.md", Content: []byte("theirs")}, {Path: "b.md", Content: []byte("b")}}}
+
+	if err := res.Write(ctx, v, true); err != nil || res.Report.Written != 1 {
+		t.Fatalf("dry run: Write() = %v, written %d", err, res.Report.Written)
+	}
+	if _, err := v.ReadFile(ctx, "b.md"); err == nil {
+		t.Error("dry run wrote b.md")
+	}
+
+	res.Report.Written = 0
+	if err := res.Write(ctx, v, false); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if res.Report.Written != 1 || strings.Join(res.Report.Skipped, ",") != "a.md" {
+		t.Errorf("report = %+v, want b.md written and a.md skipped", res.Report)
+	}
+	if a, _ := v.ReadFile(ctx, "a.md"); a.Content != "mine" {
+		t.Errorf("a.md = %q, want it left alone", a.Content)
+	}
+}
+
+func TestDateFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		tokens []string
+		text   string
+	}{
+		{"YYYY-MM-DD", momentTokens, "2024-05-01"},
+		{"YYYY/MM/DD", momentTokens, "2024/05/01"},
+		{"dddd, MMMM Do YYYY", momentTokens, "Wednesday, May 1st 2024"},
+		{"[Journal] YYYY-MM-DD", momentTokens, "Journal 2024-05-01"},
+		{"MMM do, yyyy", dateFnsTokens, "May 1st, 2024"},
+		{"yyyy_MM_dd", dateFnsTokens, "2024_05_01"},
+		{"EEE, dd.MM.yyyy", dateFnsTokens, "Wed, 01.05.2024"},
+	}
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		layout := goLayout(tt.format, tt.tokens)
+		if got, ok := parseDate(layout, tt.text); !ok || !got.Equal(day) {
+			t.Errorf("parseDate(%q, %q) = %v, %v", tt.format, tt.text, got, ok)
+		}
+		if got := formatDate(layout, day); got != tt.text {
+			t.Errorf("formatDate(%q) = %q, want %q", tt.format, got, tt.text)
+		}
+	}
+	if _, ok := parseDate(goLayout("YYYY-MM-DD", momentTokens), "Meeting notes"); ok {
+		t.Error("parsed a note name as a date")
+	}
+}
@@ -0,0 +1,304 @@
+package importer
+
+import (
+	"context"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/markdown"
+)
+
+// Logseq's defaults for journal names
+const (
+	logseqTitleFormat = "MMM do, yyyy"
+	logseqFileFormat  = "yyyy_MM_dd"
+)
+
+var (
+	logseqProperty  = regexp.MustCompile(`^([A-Za-z0-9_-]+):: ?(.*)$`)
+	logseqBlockID   = regexp.MustCompile(`(?m)^[ \t]*id:: ([0-9a-fA-F-]{36})[ \t]*$`)
+	logseqHidden    = regexp.MustCompile(`(?m)^[ \t]*(?:id|collapsed):: .*(?:\n|$)`)
+	logseqBlockRef  = regexp.MustCompile(`\(\(([0-9a-fA-F-]{36})\)\)`)
+	logseqMacro     = regexp.MustCompile(`\{\{([^{}\n]*)\}\}`)
+	logseqEmbed     = regexp.MustCompile(`^embed\s+(\[\[[^\[\]\n]+\]\]|\(\([0-9a-fA-F-]{36}\)\))$`)
+	logseqTag       = regexp.MustCompile(`#\[\[([^\[\]\n]+)\]\]`)
+	logseqImageSize = regexp.MustCompile(`\)\{:[^{}\n]*\}`)
+	logseqTask      = regexp.MustCompile(`(?m)^([ \t]*- )(TODO|LATER|NOW|DOING|WAITING|IN-PROGRESS|DONE|CANCELED|CANCELLED) (.*)$`)
+)
+
+// logseqBlock is a block other blocks reference with ((uuid))
+type logseqBlock struct {
+	page string // source path
+	text string
+}
+
+// Logseq converts the Logseq graph src. Pages move to the vault's root, with
+// namespaces as folders and their title in frontmatter; journals move to
+// daily/ and assets to attachments/. Page properties become frontmatter,
+// tasks become checkboxes, #[[multi word]] tags become #multi-word and
+// block references and embeds link to the block's page.
+func Logseq(ctx context.Context, src fs.FS) (*Result, error) {
+	c := newConverter("logseq")
+
+	titleFormat, fileFormat := logseqTitleFormat, logseqFileFormat
+	if b, err := fs.ReadFile(src, "logseq/config.edn"); err == nil {
+		if f, ok := ednString(b, ":journal/page-title-format"); ok {
+			titleFormat = f
+		}
+		if f, ok := ednString(b, ":journal/file-name-format"); ok {
+			fileFormat = f
+		}
+	}
+	titleLayout, fileLayout := goLayout(titleFormat, dateFnsTokens), goLayout(fileFormat, dateFnsTokens)
+
+	var notes, files []string
+	err := fs.WalkDir(src, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if (p != "." && strings.HasPrefix(d.Name(), ".")) || p == "logseq" {
+			// settings, backups and recycled pages
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		switch top, _, _ := strings.Cut(p, "/"); {
+		case d.IsDir():
+		case top == "draws" || top == "whiteboards":
+			c.report.warn(p, "%s have no deez equivalent and weren't imported", top)
+		case isMarkdown(p):
+			notes = append(notes, p)
+		case strings.EqualFold(path.Ext(p), ".org"):
+			c.report.warn(p, "org-mode pages aren't supported and weren't imported")
+		default:
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	titles := map[string]string{} // lower-cased page title or alias to source path
+	blocks := map[string]logseqBlock{}
+	byPath := map[string]*note{}
+	for _, p := range notes {
+		b, mtime, err := readFile(src, p)
+		if err != nil {
+			return nil, err
+		}
+		stem := strings.TrimSuffix(path.Base(p), path.Ext(p))
+		n := c.newNote(p, "", b, mtime)
+		byPath[p] = n
+
+		day, isJournal := time.Time{}, false
+		if strings.HasPrefix(p, "journals/") {
+			day, isJournal = parseDate(fileLayout, stem)
+		}
+		if isJournal {
+			n.path = c.claim(dailyPath(day))
+			titles[strings.ToLower(formatDate(titleLayout, day))] = p
+			logseqProperties(n, c.report)
+			daily(n, day)
+		} else {
+			title := logseqPageTitle(stem)
+			props := logseqProperties(n, c.report)
+			if t := props["title"]; t != "" {
+				title = t
+			}
+			var segs []string
+			for _, seg := range strings.Split(title, "/") {
+				if seg = strings.TrimSpace(sanitizeName.Replace(seg)); seg != "" && seg != "." && seg != ".." {
+					segs = append(segs, seg)
+				}
+			}
+			if len(segs) == 0 {
+				segs = []string{stem}
+			}
+			n.path = c.claim(strings.Join(segs, "/") + ".md")
+			if n.fm != nil {
+				setFirst(n.fm, "title", title)
+			}
+			titles[strings.ToLower(title)] = p
+		}
+		if n.fm != nil {
+			for _, a := range list(n.fm, "aliases", false) {
+				if _, ok := titles[strings.ToLower(a)]; !ok {
+					titles[strings.ToLower(a)] = p
+				}
+			}
+		}
+		c.moved[p] = n.path
+
+		lines := strings.Split(n.body, "\n")
+		for i, line := range lines {
+			if m := logseqBlockID.FindStringSubmatch(line); m != nil {
+				blocks[strings.ToLower(m[1])] = logseqBlock{page: p, text: logseqBlockText(lines[:i])}
+			}
+		}
+	}
+	for _, p := range files {
+		b, _, err := readFile(src, p)
+		if err != nil {
+			return nil, err
+		}
+		c.moved[p] = c.claim(path.Join(AttachmentsDir, path.Base(p)))
+		c.attach(c.moved[p], b)
+	}
+	c.indexNotes()
+
+	for _, p := range notes {
+		n := byPath[p]
+		blockLink := func(uuid string, embed bool) (string, bool) {
+			b, ok := blocks[strings.ToLower(uuid)]
+			if !ok {
+				c.report.warn(p, "block ((%s)) wasn't found and was left as is", uuid)
+				return "", false
+			}
+			c.report.warn(p, "block reference ((%s)) now links to the page %s", uuid, c.moved[b.page])
+			l := c.relink(markdown.Link{Target: strings.TrimSuffix(c.moved[b.page], ".md"), Embed: embed}, c.moved[b.page])
+			if !embed {
+				l.Alias = b.text
+			}
+			return wikiLink(l), true
+		}
+
+		n.body = outsideCode(n.body, func(s string) string {
+			s = logseqHidden.ReplaceAllString(s, "")
+			s = logseqMacro.ReplaceAllStringFunc(s, func(m string) string {
+				inner := strings.TrimSpace(m[2 : len(m)-2])
+				e := logseqEmbed.FindStringSubmatch(inner)
+				switch {
+				case e == nil:
+					c.report.warn(p, "macro %s has no deez equivalent and was left as is", m)
+					return m
+				case strings.HasPrefix(e[1], "(("):
+					if link, ok := blockLink(e[1][2:len(e[1])-2], true); ok {
+						return link
+					}
+					return m
+				}
+				return "!" + e[1]
+			})
+			s = logseqBlockRef.ReplaceAllStringFunc(s, func(m string) string {
+				if link, ok := blockLink(m[2:len(m)-2], false); ok {
+					return link
+				}
+				return m
+			})
+			s = logseqTag.ReplaceAllStringFunc(s, func(m string) string {
+				return "#" + TagName(m[3:len(m)-2])
+			})
+			s = rewriteWikiLinks(s, func(l markdown.Link) string {
+				if srcPath, ok := titles[strings.ToLower(l.Target)]; ok {
+					l = c.relink(l, c.moved[srcPath])
+				}
+				return wikiLink(l)
+			})
+			s = rewriteMarkdownLinks(s, func(l mdLink) string {
+				dest, frag, _ := strings.Cut(l.dest, "#")
+				newPath, ok := c.moved[resolveRelative(p, dest)]
+				if !ok {
+					return l.String()
+				}
+				l.dest = relPath(path.Dir(n.path), newPath)
+				if frag != "" {
+					l.dest += "#" + frag
+				}
+				return l.String()
+			})
+			s = logseqImageSize.ReplaceAllString(s, ")")
+			return logseqTask.ReplaceAllStringFunc(s, func(m string) string {
+				t := logseqTask.FindStringSubmatch(m)
+				switch t[2] {
+				case "DONE":
+					return t[1] + "[x] " + t[3]
+				case "CANCELED", "CANCELLED":
+					return t[1] + "[x] ~~" + t[3] + "~~"
+				}
+				return t[1] + "[ ] " + t[3]
+			})
+		})
+	}
+	return c.result(), nil
+}
+
+// logseqPageTitle returns the title of a page from its file name: Logseq
+// writes the / of namespaces as ___ and escapes what file names can't hold
+func logseqPageTitle(stem string) string {
+	title := strings.ReplaceAll(stem, "___", "/")
+	if t, err := url.PathUnescape(title); err == nil {
+		title = t
+	}
+	return title
+}
+
+// logseqProperties moves the key:: value lines at the top of a page into
+// its frontmatter and returns them
+func logseqProperties(n *note, report *Report) map[string]string {
+	props := map[string]string{}
+	lines := strings.SplitAfter(n.body, "\n")
+	i := 0
+	for ; i < len(lines); i++ {
+		m := logseqProperty.FindStringSubmatch(strings.TrimRight(lines[i], "\r\n"))
+		if m == nil {
+			break
+		}
+		key, value := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+		props[key] = value
+		if n.fm == nil {
+			continue
+		}
+		switch key {
+		case "id", "filters", "collapsed":
+			// Logseq's own bookkeeping
+		case "title":
+		case "tags":
+			addTags(n.fm, splitList(value)...)
+		case "alias":
+			set(n.fm, "aliases", splitList(value))
+		default:
+			set(n.fm, key, value)
+		}
+	}
+	if i > 0 {
+		if n.fm == nil {
+			report.warn(n.src, "page properties weren't moved into the invalid frontmatter")
+			return props
+		}
+		n.body = strings.TrimLeft(strings.Join(lines[i:], ""), "\r\n")
+	}
+	return props
+}
+
+// logseqBlockText returns the text of the last block started in lines,
+// without its task marker, to show for a reference to it
+func logseqBlockText(lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if text, ok := strings.CutPrefix(strings.TrimLeft(lines[i], " \t"), "- "); ok {
+			if t := logseqTask.FindStringSubmatch("- " + text); t != nil {
+				text = t[3]
+			}
+			return strings.NewReplacer("[", "", "]", "", "|", "-").Replace(strings.TrimSpace(text))
+		}
+	}
+	return ""
+}
+
+// ednString returns the string value of key in an EDN map such as
+// logseq/config.edn
+func ednString(b []byte, key string) (string, bool) {
+	m := regexp.MustCompile(regexp.QuoteMeta(key) + `\s+"([^"]*)"`).FindSubmatch(b)
+	if m == nil {
+		return "", false
+	}
+	return string(m[1]), true
+}
@@ -0,0 +1,80 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLogseq(t *testing.T) {
+	const uuid = "6650a1b2-0000-4000-8000-000000000001"
+	src := mapFS(map[string]string{
+		"logseq/config.edn":           `{:journal/page-title-format "MMM do, yyyy" :file/name-format :triple-lowbar}`,
+		"logseq/bak/pages/x.md":       "backup",
+		"pages/Project___Alpha.md":    "title:: Project/Alpha\ntags:: [[work]], #big idea\nalias:: PA\nstatus:: active\n\n- TODO write the plan\n- DONE kick off\n  collapsed:: true\n- Key decision\n  id:: " + uuid + "\n- ![chart](../assets/chart_1714557600.png){:height 200, :width 300}",
+		"pages/Reading List.md":       "- #[[to read]] [[project/alpha]]\n- {{query (todo now)}}",
+		"journals/2024_05_01.md":      "- met about [[Project/Alpha]]\n- see ((" + uuid + "))\n- {{embed ((" + uuid + "))}}\n- {{embed [[PA]]}}\n- ((6650a1b2-0000-4000-8000-00000000ffff))",
+		"assets/chart_1714557600.png": "png",
+		"draws/sketch.excalidraw":     "{}",
+	})
+	res, err := Logseq(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Logseq() returned error: %v", err)
+	}
+	got := files(t, res)
+	for _, p := range []string{"Project/Alpha.md", "Reading List.md", "daily/2024-05-01.md", "attachments/chart_1714557600.png"} {
+		if _, ok := got[p]; !ok {
+			t.Errorf("%s is missing from %v", p, res.Files)
+		}
+	}
+	if len(res.Files) != 4 {
+		t.Errorf("converted %d files, want 4", len(res.Files))
+	}
+
+	alpha := parse(t, got["Project/Alpha.md"])
+	fm := alpha.Frontmatter
+	if fm.Title != "Project/Alpha" || strings.Join(fm.Tags, ",") != "work,big-idea" || strings.Join(fm.Aliases, ",") != "PA" || fm.Extra["status"] != "active" {
+		t.Errorf("Project/Alpha.md frontmatter = %+v", fm)
+	}
+	wantAlpha := "- [ ] write the plan\n- [x] kick off\n- Key decision\n- ![chart](../attachments/chart_1714557600.png)"
+	if string(alpha.Body) != wantAlpha {
+		t.Errorf("Project/Alpha.md body = %q, want %q", alpha.Body, wantAlpha)
+	}
+
+	if reading := string(parse(t, got["Reading List.md"]).Body); reading != "- #to-read [[project/alpha]]\n- {{query (todo now)}}" {
+		t.Errorf("Reading List.md body = %q", reading)
+	}
+
+	day := parse(t, got["daily/2024-05-01.md"])
+	wantDay := "- met about [[Project/Alpha]]\n- see [[Project/Alpha|Key decision]]\n- ![[Project/Alpha]]\n- ![[PA]]\n- ((6650a1b2-0000-4000-8000-00000000ffff))"
+	if string(day.Body) != wantDay || day.Frontmatter.Extra["type"] != "daily-note" {
+		t.Errorf("daily note = %q, want body %q", got["daily/2024-05-01.md"], wantDay)
+	}
+
+	for _, w := range []struct{ path, text string }{
+		{"pages/Reading List.md", "{{query (todo now)}}"},
+		{"journals/2024_05_01.md", "now links to the page"},
+		{"journals/2024_05_01.md", "wasn't found"},
+		{"draws/sketch.excalidraw", "draws"},
+	} {
+		if !warned(res.Report, w.path, w.text) {
+			t.Errorf("no warning for %s about %q in %+v", w.path, w.text, res.Report.Warnings)
+		}
+	}
+}
+
+func TestLogseq_JournalLinks(t *testing.T) {
+	src := mapFS(map[string]string{
+		"logseq/config.edn":      `{:journal/page-title-format "yyyy-MM-dd EEEE"}`,
+		"journals/2024_05_01.md": "- first",
+		"pages/Log.md":           "- [[2024-05-01 Wednesday]] and [[May 1st, 2024]]",
+	})
+	res, err := Logseq(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Logseq() returned error: %v", err)
+	}
+	body := string(parse(t, files(t, res)["Log.md"]).Body)
+	if body != "- [[daily/2024-05-01|2024-05-01 Wednesday]] and [[May 1st, 2024]]" {
+		t.Errorf("Log.md body = %q, want the journal link pointed at the daily note", body)
+	}
+}
@@ -0,0 +1,248 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/markdown"
+)
+
+var (
+	// notionID is the id Notion appends to the names it exports
+	notionID       = regexp.MustCompile(` [0-9a-f]{32}$`)
+	notionProperty = regexp.MustCompile(`^([^:\n]{1,40}): (.*)$`)
+	notionLink     = regexp.MustCompile(`https?://(?:www\.)?notion\.(?:so|site)/[^\s)>\]]*`)
+)
+
+// Date layouts of Notion's date properties
+var notionDateLayouts = []string{
+	"January 2, 2006 3:04 PM",
+	"January 2, 2006",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"2006-01-02",
+}
+
+// notionFile is a file of the export; large exports are split into zips
+// inside the zip, so files may come from several file systems
+type notionFile struct {
+	fsys fs.FS
+	path string
+}
+
+// Notion converts the Notion markdown export src, usually the export's zip
+// opened with zip.NewReader. Notion's ids are dropped from names, each
+// page's heading becomes its title and its properties frontmatter, and
+// links between pages become wiki links. Files, including the CSV of each
+// database, go to attachments/; the database's pages are imported as notes.
+func Notion(ctx context.Context, src fs.FS) (*Result, error) {
+	c := newConverter("notion")
+
+	var files []notionFile
+	var cleanup []func()
+	defer func() {
+		for _, fn := range cleanup {
+			fn()
+		}
+	}()
+	var walk func(fsys fs.FS) error
+	walk = func(fsys fs.FS) error {
+		return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			switch ext := strings.ToLower(path.Ext(p)); {
+			case d.IsDir() || strings.HasPrefix(d.Name(), "."):
+			case ext == ".zip":
+				part, closePart, err := openNested(fsys, p)
+				if err != nil {
+					return fmt.Errorf("opening %s: %w", p, err)
+				}
+				cleanup = append(cleanup, closePart)
+				return walk(part)
+			case ext == ".html":
+				c.report.warn(p, "HTML exports aren't supported; export from Notion as Markdown & CSV")
+			default:
+				files = append(files, notionFile{fsys: fsys, path: p})
+			}
+			return nil
+		})
+	}
+	if err := walk(src); err != nil {
+		return nil, err
+	}
+
+	byPath := map[string]*note{}
+	var notes []string
+	for _, f := range files {
+		b, mtime, err := readFile(f.fsys, f.path)
+		if err != nil {
+			return nil, err
+		}
+		dest := notionPath(f.path)
+		if !isMarkdown(f.path) {
+			if strings.EqualFold(path.Ext(f.path), ".csv") {
+				c.report.warn(f.path, "databases are exported as CSV, kept in %s; their pages were imported as notes", AttachmentsDir)
+			}
+			c.moved[f.path] = c.claim(path.Join(AttachmentsDir, path.Base(dest)))
+			c.attach(c.moved[f.path], b)
+			continue
+		}
+		n := c.newNote(f.path, c.claim(dest), b, mtime)
+		c.moved[f.path] = n.path
+		byPath[f.path] = n
+		notes = append(notes, f.path)
+		notionHeader(n, c.report)
+	}
+	c.indexNotes()
+
+	for _, p := range notes {
+		n := byPath[p]
+		n.body = outsideCode(n.body, func(s string) string {
+			s = rewriteMarkdownLinks(s, func(l mdLink) string {
+				dest, frag, _ := strings.Cut(l.dest, "#")
+				newPath, ok := c.moved[resolveRelative(p, dest)]
+				if !ok {
+					if strings.HasSuffix(dest, ".md") {
+						c.report.warn(p, "link to %s, which isn't in the export, was left as is", dest)
+					}
+					return l.String()
+				}
+				wl := c.relink(markdown.Link{Target: strings.TrimSuffix(path.Base(newPath), ".md"), Embed: l.image}, newPath)
+				if frag != "" && isMarkdown(newPath) {
+					wl.Heading = frag
+				}
+				if l.text != "" && l.text != wl.Target && l.text != path.Base(wl.Target) && !l.image {
+					wl.Alias = l.text
+				}
+				return wikiLink(wl)
+			})
+			for _, link := range notionLink.FindAllString(s, -1) {
+				c.report.warn(p, "link to the Notion page %s was left as is", link)
+			}
+			return s
+		})
+	}
+	return c.result(), nil
+}
+
+// notionPath returns the vault path of a file of the export, without the
+// ids Notion adds to page and folder names
+func notionPath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		ext := ""
+		if i == len(segs)-1 {
+			ext = path.Ext(seg)
+		}
+		if stem := notionID.ReplaceAllString(strings.TrimSuffix(seg, ext), ""); stem != "" {
+			segs[i] = stem + ext
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// notionHeader moves the page's heading and the property lines below it
+// into frontmatter
+func notionHeader(n *note, report *Report) {
+	if n.fm == nil {
+		return
+	}
+	body := strings.TrimLeft(n.body, "\r\n")
+	first, rest, _ := strings.Cut(body, "\n")
+	title, ok := strings.CutPrefix(strings.TrimRight(first, "\r"), "# ")
+	if !ok {
+		return
+	}
+	if title = strings.TrimSpace(title); title != strings.TrimSuffix(path.Base(n.path), ".md") {
+		set(n.fm, "title", title)
+	}
+	n.body = strings.TrimLeft(rest, "\r\n")
+
+	// Properties are the first paragraph, if every line of it is one
+	para, after, _ := strings.Cut(n.body, "\n\n")
+	lines := strings.Split(strings.TrimRight(para, "\r\n"), "\n")
+	for _, line := range lines {
+		if !notionProperty.MatchString(strings.TrimRight(line, "\r")) {
+			return
+		}
+	}
+	for _, line := range lines {
+		m := notionProperty.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		key, value := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+		switch strings.ToLower(key) {
+		case "tags":
+			addTags(n.fm, splitList(value)...)
+		case "created", "created time", "date created":
+			if t, ok := notionDate(value); ok {
+				set(n.fm, "created", t.Format(time.RFC3339))
+				continue
+			}
+			report.warn(n.src, "%s date %q wasn't understood and was kept as %s", key, value, snakeCase(key))
+			set(n.fm, snakeCase(key), value)
+		case "last edited time", "updated":
+			if t, ok := notionDate(value); ok {
+				set(n.fm, "updated", t.Format(time.RFC3339))
+				continue
+			}
+			set(n.fm, snakeCase(key), value)
+		default:
+			set(n.fm, snakeCase(key), value)
+		}
+	}
+	n.body = strings.TrimLeft(after, "\r\n")
+}
+
+func notionDate(s string) (time.Time, bool) {
+	for _, layout := range notionDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// snakeCase turns a property name like "Due Date" into a frontmatter key
+func snakeCase(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), "_")
+}
+
+// openNested opens the zip at p in fsys. zip.Reader needs random access,
+// so the zip is copied to a temporary file first.
+func openNested(fsys fs.FS, p string) (fs.FS, func(), error) {
+	src, err := fsys.Open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp("", "dz-notion-*.zip")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, src)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return zr, cleanup, nil
+}
@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// zipOf zips files, in order of names
+func zipOf(t *testing.T, names []string, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(files[name])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNotion(t *testing.T) {
+	const (
+		planID = " 0123456789abcdef0123456789abcdef"
+		taskID = " fedcba9876543210fedcba9876543210"
+		dbID   = " 00112233445566778899aabbccddeeff"
+	)
+	part := zipOf(t, []string{"Plan" + planID + "/Tasks" + dbID + "/Write spec" + taskID + ".md"}, map[string][]byte{
+		"Plan" + planID + "/Tasks" + dbID + "/Write spec" + taskID + ".md": []byte("# Write spec\n\nStatus: Done\nDue Date: May 3, 2024\n\nBack to [Plan](../../Plan%200123456789abcdef0123456789abcdef.md)"),
+	})
+	names := []string{
+		"Plan" + planID + ".md",
+		"Plan" + planID + "/diagram.png",
+		"Plan" + planID + "/Tasks" + dbID + ".csv",
+		"Export-Part-2.zip",
+		"Old" + planID + ".html",
+	}
+	export := zipOf(t, names, map[string][]byte{
+		names[0]: []byte("# Q3 Plan\n\nTags: work, planning\nCreated: May 1, 2024 10:00 AM\nOwner: Ana\n\nNote: this paragraph stays.\n\n" +
+			"See [Write spec](Plan%200123456789abcdef0123456789abcdef/Tasks%2000112233445566778899aabbccddeeff/Write%20spec%20fedcba9876543210fedcba9876543210.md), " +
+			"[the db](Plan%200123456789abcdef0123456789abcdef/Tasks%2000112233445566778899aabbccddeeff.csv) and [gone](Gone%2011112222333344445555666677778888.md)\n" +
+			"![diagram](Plan%200123456789abcdef0123456789abcdef/diagram.png)\n" +
+			"[elsewhere](https://www.notion.so/team/Roadmap-0123456789abcdef)"),
+		names[1]: []byte("png"),
+		names[2]: []byte("Name,Status\nWrite spec,Done\n"),
+		names[3]: part,
+		names[4]: []byte("<html>"),
+	})
+	zr, err := zip.NewReader(bytes.NewReader(export), int64(len(export)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Notion(context.Background(), zr)
+	if err != nil {
+		t.Fatalf("Notion() returned error: %v", err)
+	}
+	got := files(t, res)
+	var paths []string
+	for _, f := range res.Files {
+		paths = append(paths, f.Path)
+	}
+	want := "Plan.md,Plan/Tasks/Write spec.md,attachments/Tasks.csv,attachments/diagram.png"
+	if strings.Join(paths, ",") != want {
+		t.Errorf("files = %v, want %s", paths, want)
+	}
+
+	plan := parse(t, got["Plan.md"])
+	fm := plan.Frontmatter
+	if fm.Title != "Q3 Plan" || fm.Created != "2024-05-01T10:00:00Z" || strings.Join(fm.Tags, ",") != "work,planning" || fm.Extra["owner"] != "Ana" {
+		t.Errorf("Plan.md frontmatter = %+v", fm)
+	}
+	wantBody := "Note: this paragraph stays.\n\nSee [[Write spec]], [[Tasks.csv|the db]] and [gone](Gone%2011112222333344445555666677778888.md)\n![[diagram.png]]\n[elsewhere](https://www.notion.so/team/Roadmap-0123456789abcdef)"
+	if string(plan.Body) != wantBody {
+		t.Errorf("Plan.md body = %q, want %q", plan.Body, wantBody)
+	}
+
+	spec := parse(t, got["Plan/Tasks/Write spec.md"])
+	if spec.Frontmatter.Title != "" || spec.Frontmatter.Extra["status"] != "Done" || spec.Frontmatter.Extra["due_date"] != "May 3, 2024" || string(spec.Body) != "Back to [[Plan]]" {
+		t.Errorf("Write spec.md = %q", got["Plan/Tasks/Write spec.md"])
+	}
+
+	for _, w := range []struct{ path, text string }{
+		{"Plan" + planID + ".md", "isn't in the export"},
+		{"Plan" + planID + ".md", "notion.so"},
+		{"Plan" + planID + "/Tasks" + dbID + ".csv", "CSV"},
+		{"Old" + planID + ".html", "HTML"},
+	} {
+		if !warned(res.Report, w.path, w.text) {
+			t.Errorf("no warning for %s about %q in %+v", w.path, w.text, res.Report.Warnings)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/markdown"
+)
+
+// obsidianDaily is .obsidian/daily-notes.json
+type obsidianDaily struct {
+	Folder string `json:"folder"`
+	Format string `json:"format"` // moment.js, defaults to YYYY-MM-DD
+}
+
+var (
+	// blockMarker is the ^id Obsidian ends a block with to make it linkable
+	blockMarker = regexp.MustCompile(`(?m)(?:^|[ \t]+)\^[A-Za-z0-9-]+[ \t]*$`)
+	// embedSize is the width, or widthxheight, of an embed: ![[x.png|300]]
+	embedSize = regexp.MustCompile(`^\d+(?:x\d+)?$`)
+)
+
+// Obsidian converts the Obsidian vault src. Notes keep their folders; daily
+// notes, found with the core daily notes settings, move to daily/ and every
+// other file to attachments/. Block references lose their ^block part,
+// since deez links to notes and headings only, and tag and alias keys
+// become tags and aliases.
+func Obsidian(ctx context.Context, src fs.FS) (*Result, error) {
+	c := newConverter("obsidian")
+
+	var cfg obsidianDaily
+	if b, err := fs.ReadFile(src, ".obsidian/daily-notes.json"); err == nil {
+		_ = json.Unmarshal(b, &cfg)
+	}
+	if cfg.Format == "" {
+		cfg.Format = "YYYY-MM-DD"
+	}
+	dailyFolder := strings.Trim(cfg.Folder, "/")
+	layout := goLayout(cfg.Format, momentTokens)
+
+	var notes, files []string
+	err := fs.WalkDir(src, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p != "." && strings.HasPrefix(d.Name(), ".") {
+			// .obsidian, .trash, .git...
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case d.IsDir():
+		case isMarkdown(p):
+			notes = append(notes, p)
+		case strings.EqualFold(path.Ext(p), ".canvas"):
+			c.report.warn(p, "canvases have no deez equivalent and weren't imported")
+		default:
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Place everything first, so links can point at the new paths
+	byPath := map[string]*note{}
+	for _, p := range notes {
+		b, mtime, err := readFile(src, p)
+		if err != nil {
+			return nil, err
+		}
+		dest, day, isDaily := p, time.Time{}, false
+		rel, inFolder := p, true
+		if dailyFolder != "" {
+			rel, inFolder = strings.CutPrefix(p, dailyFolder+"/")
+		}
+		if inFolder {
+			day, isDaily = parseDate(layout, strings.TrimSuffix(rel, path.Ext(rel)))
+		}
+		if isDaily {
+			dest = dailyPath(day)
+		}
+		n := c.newNote(p, c.claim(dest), b, mtime)
+		c.moved[p] = n.path
+		byPath[p] = n
+		if n.fm != nil {
+			rename(n.fm, "tag", "tags")
+			rename(n.fm, "alias", "aliases")
+			if aliases := list(n.fm, "aliases", false); len(aliases) > 0 {
+				set(n.fm, "aliases", aliases)
+			}
+			if _, v := lookup(n.fm, "tags"); v != nil {
+				addTags(n.fm)
+			}
+		}
+		if isDaily {
+			daily(n, day)
+		}
+	}
+	for _, p := range files {
+		b, _, err := readFile(src, p)
+		if err != nil {
+			return nil, err
+		}
+		c.moved[p] = c.claim(path.Join(AttachmentsDir, path.Base(p)))
+		c.attach(c.moved[p], b)
+	}
+	c.indexNotes()
+
+	targets := obsidianTargets(append(notes, files...))
+	for _, p := range notes {
+		n := byPath[p]
+		n.body = outsideCode(n.body, func(s string) string {
+			s = rewriteWikiLinks(s, func(l markdown.Link) string {
+				if strings.HasPrefix(l.Heading, "^") {
+					c.report.warn(p, "block reference [[%s#%s]] now links to the note", l.Target, l.Heading)
+					l.Heading = ""
+				}
+				if l.Embed && embedSize.MatchString(l.Alias) {
+					l.Alias = ""
+				}
+				if srcPath, ok := targets.lookup(l.Target); ok {
+					l = c.relink(l, c.moved[srcPath])
+				}
+				return wikiLink(l)
+			})
+			s = rewriteMarkdownLinks(s, func(l mdLink) string {
+				dest, frag, _ := strings.Cut(l.dest, "#")
+				srcPath, ok := targets.lookupRelative(p, dest)
+				if !ok {
+					return l.String()
+				}
+				if strings.HasPrefix(frag, "^") {
+					c.report.warn(p, "block reference %s#%s now links to the note", dest, frag)
+					frag = ""
+				}
+				l.dest = relPath(path.Dir(n.path), c.moved[srcPath])
+				if frag != "" {
+					l.dest += "#" + frag
+				}
+				return l.String()
+			})
+			return blockMarker.ReplaceAllString(s, "")
+		})
+	}
+	return c.result(), nil
+}
+
+// obsidianLinks resolves link targets the way Obsidian does: by path from
+// the vault's root, then by file name, shortest path first
+type obsidianLinks struct {
+	paths map[string]string   // lower-cased path, notes without .md
+	names map[string][]string // lower-cased file name, notes without .md
+}
+
+func obsidianTargets(paths []string) *obsidianLinks {
+	paths = append([]string(nil), paths...)
+	sort.Slice(paths, func(i, j int) bool {
+		if a, b := strings.Count(paths[i], "/"), strings.Count(paths[j], "/"); a != b {
+			return a < b
+		}
+		return paths[i] < paths[j]
+	})
+	t := &obsidianLinks{paths: map[string]string{}, names: map[string][]string{}}
+	for _, p := range paths {
+		key := strings.ToLower(p)
+		if isMarkdown(p) {
+			key = strings.TrimSuffix(key, path.Ext(key))
+		}
+		t.paths[key] = p
+		t.names[path.Base(key)] = append(t.names[path.Base(key)], p)
+	}
+	return t
+}
+
+func (t *obsidianLinks) lookup(target string) (string, bool) {
+	key := strings.ToLower(strings.TrimPrefix(target, "/"))
+	if isMarkdown(key) {
+		key = strings.TrimSuffix(key, path.Ext(key))
+	}
+	if p, ok := t.paths[key]; ok {
+		return p, true
+	}
+	if ps := t.names[path.Base(key)]; len(ps) > 0 && !strings.Contains(key, "/") {
+		return ps[0], true
+	}
+	return "", false
+}
+
+// lookupRelative resolves a markdown link destination of the note at from,
+// relative to its folder first
+func (t *obsidianLinks) lookupRelative(from, dest string) (string, bool) {
+	if dest == "" {
+		return "", false
+	}
+	if p, ok := t.lookup(resolveRelative(from, dest)); ok {
+		return p, true
+	}
+	return t.lookup(dest)
+}
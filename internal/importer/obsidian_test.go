@@ -0,0 +1,76 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestObsidian(t *testing.T) {
+	src := mapFS(map[string]string{
+		".obsidian/app.json":         `{"attachmentFolderPath":"Assets"}`,
+		".obsidian/daily-notes.json": `{"folder":"Journal","format":"YYYY/MM/DD"}`,
+		".trash/old.md":              "deleted",
+		"Home.md":                    "---\ntag: [\"#project\", work]\nalias: Start\n---\nSee [[Plan]], [[Plan#^abc123|the goal]], [[Meeting]] and [[Nowhere]].\n![[diagram.png|300]] [doc](Assets/spec%20v2.pdf)\n`[[Plan]]` stays",
+		"Projects/Plan.md":           "Goal ^abc123\n\n- step\n^list1\n[home](../Home.md)",
+		"Archive/2023/Plan.md":       "old plan",
+		"Work/Meeting.md":            "[[Plan]]",
+		"Journal/2024/05/01.md":      "---\nmood: good\n---\ntoday [[Home]]",
+		"Journal/ideas.md":           "not a daily note",
+		"Assets/diagram.png":         "png",
+		"Assets/spec v2.pdf":         "pdf",
+		"Board.canvas":               "{}",
+	})
+	res, err := Obsidian(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Obsidian() returned error: %v", err)
+	}
+	got := files(t, res)
+
+	want := []string{"Archive/2023/Plan.md", "Home.md", "Journal/ideas.md", "Projects/Plan.md", "Work/Meeting.md", "daily/2024-05-01.md", "attachments/diagram.png", "attachments/spec v2.pdf"}
+	var paths []string
+	for _, f := range res.Files {
+		paths = append(paths, f.Path)
+	}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", paths, want)
+	}
+	if res.Report.Notes != 6 || res.Report.Attachments != 2 {
+		t.Errorf("report = %+v", res.Report)
+	}
+
+	home := parse(t, got["Home.md"])
+	if strings.Join(home.Frontmatter.Tags, ",") != "project,work" || strings.Join(home.Frontmatter.Aliases, ",") != "Start" {
+		t.Errorf("Home.md frontmatter = %+v", home.Frontmatter)
+	}
+	for _, s := range []string{
+		"[[Projects/Plan|Plan]]",     // Obsidian picks the shallower Plan, deez the first by path
+		"[[Projects/Plan|the goal]]", // the block reference links to the note
+		"[[Meeting]]",                // still resolves after the move
+		"[[Nowhere]]",                // missing notes are left alone
+		"![[diagram.png]]",           // the size is dropped
+		"[doc](attachments/spec%20v2.pdf)",
+		"`[[Plan]]` stays",
+	} {
+		if !strings.Contains(string(home.Body), s) {
+			t.Errorf("Home.md body = %q, want %s", home.Body, s)
+		}
+	}
+	if !warned(res.Report, "Home.md", "block reference") || !warned(res.Report, "Board.canvas", "canvas") {
+		t.Errorf("warnings = %+v", res.Report.Warnings)
+	}
+
+	plan := string(parse(t, got["Projects/Plan.md"]).Body)
+	if plan != "Goal\n\n- step\n\n[home](../Home.md)" {
+		t.Errorf("Projects/Plan.md body = %q, want block markers removed", plan)
+	}
+
+	day := parse(t, got["daily/2024-05-01.md"])
+	if day.Frontmatter.Extra["type"] != "daily-note" || day.Frontmatter.Extra["date"] != "2024-05-01" || day.Frontmatter.Extra["mood"] != "good" ||
+		strings.Join(day.Frontmatter.Tags, ",") != "daily" || string(day.Body) != "today [[Home]]" {
+		t.Errorf("daily note = %q", got["daily/2024-05-01.md"])
+	}
+	if _, ok := got[".trash/old.md"]; ok {
+		t.Error("imported the trash")
+	}
+}
@@ -0,0 +1,530 @@
+// Package lint checks a whole vault for the problems the editor only sees
+// one note at a time: missing or invalid frontmatter, duplicate note ids,
+// broken and ambiguous links, orphan notes, temp files left behind by
+// interrupted writes and paths that differ only in case.
+//
+// Check builds a Report of Issues; Report.Fix repairs the ones marked
+// Fixable, i.e. those that can be repaired without changing what any link
+// resolves to or losing data.
+package lint
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/markdown"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// Issue kinds
+const (
+	MissingFrontmatter = "missing_frontmatter"
+	InvalidFrontmatter = "invalid_frontmatter" // the YAML doesn't parse
+	MissingKey         = "missing_key"         // a required key is missing
+	InvalidID          = "invalid_id"
+	InvalidDate        = "invalid_date"
+	DuplicateID        = "duplicate_id"
+	BrokenLink         = "broken_link"
+	AmbiguousLink      = "ambiguous_link"
+	Orphan             = "orphan"
+	StrayTemp          = "stray_temp"
+	CaseCollision      = "case_collision"
+)
+
+// Severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// RequiredKeys are the frontmatter keys every note must have
+var RequiredKeys = []string{"id", "created"}
+
+// validID matches note ids, YYYYMMDDhhmmss-xxx
+var validID = regexp.MustCompile(`^\d{14}-[a-z0-9]{3}$`)
+
+// tempSuffix is the extension of the files vault.WriteFile renames into place
+const tempSuffix = ".tmp"
+
+// Issue is a problem found in a vault
+type Issue struct {
+	Kind     string   `json:"kind"`
+	Severity string   `json:"severity"`
+	Path     string   `json:"path"`
+	Message  string   `json:"message"`
+	Related  []string `json:"related,omitempty"` // other paths involved, e.g. the other notes with the id
+	Fixable  bool     `json:"fixable"`
+	Fixed    bool     `json:"fixed,omitempty"`
+	FixError string   `json:"fix_error,omitempty"`
+
+	fix func(ctx context.Context) error
+}
+
+// Report is the result of checking a vault
+type Report struct {
+	Vault    string  `json:"vault"`
+	Notes    int     `json:"notes"`
+	Files    int     `json:"files"`
+	Errors   int     `json:"errors"`
+	Warnings int     `json:"warnings"`
+	Fixed    int     `json:"fixed"`
+	Issues   []Issue `json:"issues"`
+}
+
+// OK reports whether the check found no errors. Warnings don't count.
+func (r *Report) OK() bool {
+	return r.Errors == 0
+}
+
+// note is a markdown file being checked
+type note struct {
+	path    string
+	content string
+	hash    string
+	mtime   time.Time
+	parsed  *markdown.Note
+}
+
+// Check scans every note and file of v that ctx may read
+func Check(ctx context.Context, v *vault.Vault) (*Report, error) {
+	files, err := v.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	c := &checker{
+		v:       v,
+		report:  &Report{Vault: v.Name(), Files: len(files), Issues: []Issue{}},
+		index:   markdown.NewIndex(),
+		files:   make(map[string]bool, len(files)),
+		names:   make(map[string]bool, len(files)),
+		usedIDs: map[string]bool{},
+		edits:   map[*note]*noteEdit{},
+	}
+	for _, f := range files {
+		c.files[f.Path] = true
+		c.names[path.Base(f.Path)] = true
+	}
+
+	var notes []*note
+	for _, f := range files {
+		if !strings.EqualFold(path.Ext(f.Path), ".md") {
+			continue
+		}
+		res, err := v.ReadFile(ctx, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		n := &note{path: f.Path, content: res.Content, hash: res.Hash, mtime: res.MTime}
+		parsed, err := markdown.Parse([]byte(res.Content))
+		if err != nil {
+			c.add(Issue{Kind: InvalidFrontmatter, Severity: SeverityError, Path: n.path, Message: "frontmatter is not valid YAML: " + err.Error()})
+			parsed = &markdown.Note{Body: []byte(res.Content)}
+		} else {
+			n.parsed = parsed
+		}
+		if id := parsed.Frontmatter.ID; id != "" {
+			c.usedIDs[id] = true
+		}
+		c.index.Add(n.path, parsed.Frontmatter)
+		notes = append(notes, n)
+	}
+	c.report.Notes = len(notes)
+
+	for _, n := range notes {
+		c.checkFrontmatter(n)
+	}
+	c.checkDuplicateIDs(notes)
+	c.checkLinks(notes)
+	c.checkTempFiles(files)
+	c.checkCase(files)
+	return c.report, nil
+}
+
+// Fix repairs the fixable issues, marking each Fixed or recording why it
+// couldn't be. Notes changed since the check are left alone.
+func (r *Report) Fix(ctx context.Context) {
+	for i := range r.Issues {
+		is := &r.Issues[i]
+		if !is.Fixable || is.Fixed || is.fix == nil {
+			continue
+		}
+		if err := is.fix(ctx); err != nil {
+			is.FixError = err.Error()
+			continue
+		}
+		is.Fixed = true
+		r.Fixed++
+	}
+}
+
+type checker struct {
+	v       *vault.Vault
+	report  *Report
+	index   *markdown.Index
+	files   map[string]bool // vault paths
+	names   map[string]bool // file names, for links that omit the folder
+	usedIDs map[string]bool
+	edits   map[*note]*noteEdit
+}
+
+func (c *checker) add(is Issue) {
+	switch is.Severity {
+	case SeverityError:
+		c.report.Errors++
+	case SeverityWarning:
+		c.report.Warnings++
+	}
+	c.report.Issues = append(c.report.Issues, is)
+}
+
+// noteEdit collects the frontmatter keys to set in a note, so that several
+// fixes to one note make a single write
+type noteEdit struct {
+	keys    map[string]string
+	written bool
+}
+
+// setKeys returns a fix that sets keys in the frontmatter of n. The fixes of
+// a note share its edit, and whichever runs first writes them all.
+func (c *checker) setKeys(n *note, keys map[string]string) func(ctx context.Context) error {
+	e := c.edits[n]
+	if e == nil {
+		e = &noteEdit{keys: map[string]string{}}
+		c.edits[n] = e
+	}
+	for k, v := range keys {
+		e.keys[k] = v
+	}
+	return func(ctx context.Context) error {
+		if e.written {
+			return nil
+		}
+		content := SetFrontmatter(n.content, e.keys)
+		if _, err := c.v.WriteFile(ctx, n.path, vault.WriteRequest{Content: content, IfMatch: n.hash}); err != nil {
+			return err
+		}
+		e.written = true
+		return nil
+	}
+}
+
+func (c *checker) checkFrontmatter(n *note) {
+	if n.parsed == nil {
+		return
+	}
+	if !n.parsed.HasFrontmatter {
+		c.add(Issue{
+			Kind: MissingFrontmatter, Severity: SeverityError, Path: n.path,
+			Message: "note has no frontmatter",
+			Fixable: true,
+			fix:     c.setKeys(n, map[string]string{"id": c.newID(n.mtime), "created": n.mtime.UTC().Format(time.RFC3339)}),
+		})
+		return
+	}
+
+	fm := n.parsed.Frontmatter
+	var missing []string
+	set := map[string]string{}
+	if fm.ID == "" {
+		missing = append(missing, "id")
+		set["id"] = c.newID(n.mtime)
+	}
+	if fm.Created == "" {
+		missing = append(missing, "created")
+		set["created"] = n.mtime.UTC().Format(time.RFC3339)
+	}
+	if len(missing) > 0 {
+		c.add(Issue{
+			Kind: MissingKey, Severity: SeverityError, Path: n.path,
+			Message: "missing required frontmatter keys: " + strings.Join(missing, ", "),
+			Fixable: true,
+			fix:     c.setKeys(n, set),
+		})
+	}
+
+	if fm.ID != "" && !validID.MatchString(fm.ID) {
+		c.add(Issue{Kind: InvalidID, Severity: SeverityError, Path: n.path,
+			Message: fmt.Sprintf("id %q is not in the YYYYMMDDhhmmss-xxx format", fm.ID)})
+	}
+	if _, ok := fm.CreatedAt(); fm.Created != "" && !ok {
+		c.add(Issue{Kind: InvalidDate, Severity: SeverityError, Path: n.path,
+			Message: fmt.Sprintf("created %q is not a valid timestamp", fm.Created)})
+	}
+	if _, ok := fm.UpdatedAt(); fm.Updated != "" && !ok {
+		c.add(Issue{Kind: InvalidDate, Severity: SeverityError, Path: n.path,
+			Message: fmt.Sprintf("updated %q is not a valid timestamp", fm.Updated)})
+	}
+}
+
+// checkDuplicateIDs reports notes sharing an id. Links to the id resolve to
+// the alphabetically first note, so giving the others new ids is safe.
+func (c *checker) checkDuplicateIDs(notes []*note) {
+	byID := map[string][]*note{}
+	for _, n := range notes {
+		if n.parsed != nil && n.parsed.Frontmatter.ID != "" {
+			byID[n.parsed.Frontmatter.ID] = append(byID[n.parsed.Frontmatter.ID], n)
+		}
+	}
+	for _, n := range notes {
+		if n.parsed == nil {
+			continue
+		}
+		id := n.parsed.Frontmatter.ID
+		same := byID[id]
+		if len(same) < 2 || same[0] == n {
+			continue
+		}
+		c.add(Issue{
+			Kind: DuplicateID, Severity: SeverityError, Path: n.path,
+			Message: fmt.Sprintf("id %q is also used by %s, which links to it resolve to", id, same[0].path),
+			Related: []string{same[0].path},
+			Fixable: true,
+			fix:     c.setKeys(n, map[string]string{"id": c.newID(n.mtime)}),
+		})
+	}
+}
+
+func (c *checker) checkLinks(notes []*note) {
+	linked := map[string]bool{}  // notes other notes link to
+	linking := map[string]bool{} // notes with links to other notes
+	for _, n := range notes {
+		body := []byte(n.content)
+		if n.parsed != nil {
+			body = n.parsed.Body
+		}
+		for _, l := range markdown.Links(body) {
+			if l.Kind == markdown.LinkWiki && isAttachment(l.Target) {
+				if !c.files[strings.TrimPrefix(l.Target, "/")] && !c.names[path.Base(l.Target)] {
+					c.add(Issue{Kind: BrokenLink, Severity: SeverityError, Path: n.path,
+						Message: fmt.Sprintf("%s links to missing file %q", linkSyntax(l), l.Target)})
+				}
+				continue
+			}
+
+			if l.Kind == markdown.LinkMarkdown {
+				target, ok := c.index.ResolveLink(n.path, l)
+				if !ok {
+					c.add(Issue{Kind: BrokenLink, Severity: SeverityError, Path: n.path,
+						Message: fmt.Sprintf("%s links to missing note %s", linkSyntax(l), target)})
+					continue
+				}
+				if target != n.path {
+					linked[target], linking[n.path] = true, true
+				}
+				continue
+			}
+
+			candidates := c.index.Candidates(l.Target)
+			switch {
+			case len(candidates) == 0:
+				c.add(Issue{Kind: BrokenLink, Severity: SeverityError, Path: n.path,
+					Message: fmt.Sprintf("%s doesn't match any note", linkSyntax(l))})
+				continue
+			case len(candidates) > 1:
+				c.add(Issue{Kind: AmbiguousLink, Severity: SeverityWarning, Path: n.path,
+					Message: fmt.Sprintf("%s matches %d notes and resolves to %s", linkSyntax(l), len(candidates), candidates[0]),
+					Related: candidates})
+			}
+			if candidates[0] != n.path {
+				linked[candidates[0]], linking[n.path] = true, true
+			}
+		}
+	}
+
+	if len(notes) < 2 {
+		return
+	}
+	for _, n := range notes {
+		if !linked[n.path] && !linking[n.path] {
+			c.add(Issue{Kind: Orphan, Severity: SeverityWarning, Path: n.path,
+				Message: "no other note links to this note and it links to none"})
+		}
+	}
+}
+
+// checkTempFiles reports the temp files of writes that never completed.
+// Those next to the file they were meant to replace are stale copies and
+// safe to delete; the others may hold the only copy of a new note.
+func (c *checker) checkTempFiles(files []vault.FileInfo) {
+	for _, f := range files {
+		if !strings.HasSuffix(f.Path, tempSuffix) {
+			continue
+		}
+		target := strings.TrimSuffix(f.Path, tempSuffix)
+		if c.files[target] {
+			p := f.Path
+			c.add(Issue{
+				Kind: StrayTemp, Severity: SeverityWarning, Path: p,
+				Message: "left behind by an interrupted write of " + target,
+				Related: []string{target},
+				Fixable: true,
+				fix: func(ctx context.Context) error {
+					return c.v.DeleteFile(ctx, p)
+				},
+			})
+			continue
+		}
+		c.add(Issue{Kind: StrayTemp, Severity: SeverityWarning, Path: f.Path,
+			Message: fmt.Sprintf("left behind by an interrupted write of %s, which doesn't exist; rename it if it holds the note", target)})
+	}
+}
+
+// checkCase reports files and folders whose paths differ only in case,
+// which can't coexist on case-insensitive file systems. Each collision is
+// reported once, at the shallowest folder where the spellings part.
+func (c *checker) checkCase(files []vault.FileInfo) {
+	spellings := map[string][]string{} // lower-cased path -> distinct spellings
+	var keys []string
+	for _, f := range files {
+		segs := strings.Split(f.Path, "/")
+		for i := range segs {
+			p := strings.Join(segs[:i+1], "/")
+			key := strings.ToLower(p)
+			if _, ok := spellings[key]; !ok {
+				keys = append(keys, key)
+			}
+			if !slices.Contains(spellings[key], p) {
+				spellings[key] = append(spellings[key], p)
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		di, dj := strings.Count(keys[i], "/"), strings.Count(keys[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return keys[i] < keys[j]
+	})
+	colliding := map[string]bool{}
+	for _, key := range keys {
+		if parent := path.Dir(key); parent != "." && colliding[parent] {
+			colliding[key] = true
+			continue
+		}
+		if s := spellings[key]; len(s) > 1 {
+			colliding[key] = true
+			c.add(Issue{Kind: CaseCollision, Severity: SeverityError, Path: s[0],
+				Message: fmt.Sprintf("%s differ only in case", strings.Join(s, ", ")),
+				Related: s[1:]})
+		}
+	}
+}
+
+// newID returns an unused note id for a note last modified at t
+func (c *checker) newID(t time.Time) string {
+	for {
+		id := NewID(t)
+		if !c.usedIDs[id] {
+			c.usedIDs[id] = true
+			return id
+		}
+	}
+}
+
+const idAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// NewID returns a note id for t in the editor's YYYYMMDDhhmmss-xxx format
+func NewID(t time.Time) string {
+	suffix := make([]byte, 3)
+	for i := range suffix {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(idAlphabet))))
+		if err != nil {
+			panic("failed to generate note id")
+		}
+		suffix[i] = idAlphabet[n.Int64()]
+	}
+	return t.UTC().Format("20060102150405") + "-" + string(suffix)
+}
+
+// ValidID reports whether id is in the YYYYMMDDhhmmss-xxx format
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// SetFrontmatter sets keys in the frontmatter of a note, replacing the lines
+// of keys it has and adding the others at the top, so the rest of the
+// header is kept as written. A note without frontmatter gets one.
+func SetFrontmatter(content string, keys map[string]string) string {
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool { return keyOrder(names[i]) < keyOrder(names[j]) })
+
+	lines := strings.SplitAfter(content, "\n")
+	end := -1
+	if len(lines) > 0 && strings.TrimRight(lines[0], " \t\r\n") == "---" {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		var b strings.Builder
+		b.WriteString("---\n")
+		for _, k := range names {
+			fmt.Fprintf(&b, "%s: %s\n", k, keys[k])
+		}
+		b.WriteString("---\n")
+		return b.String() + content
+	}
+
+	var added []string
+	for _, k := range names {
+		line := fmt.Sprintf("%s: %s\n", k, keys[k])
+		replaced := false
+		for i := 1; i < end; i++ {
+			if strings.HasPrefix(lines[i], k+":") {
+				lines[i], replaced = line, true
+				break
+			}
+		}
+		if !replaced {
+			added = append(added, line)
+		}
+	}
+	out := append([]string{lines[0]}, added...)
+	out = append(out, lines[1:]...)
+	return strings.Join(out, "")
+}
+
+func keyOrder(k string) int {
+	for i, r := range RequiredKeys {
+		if k == r {
+			return i
+		}
+	}
+	return len(RequiredKeys)
+}
+
+// isAttachment reports whether a wiki link target names a file other than
+// a note, e.g. ![[diagram.png]]
+func isAttachment(target string) bool {
+	ext := path.Ext(target)
+	return attachmentExt.MatchString(ext) && !strings.EqualFold(ext, ".md")
+}
+
+var attachmentExt = regexp.MustCompile(`^\.[0-9]*[a-zA-Z][a-zA-Z0-9]*$`)
+
+func linkSyntax(l markdown.Link) string {
+	if l.Kind == markdown.LinkMarkdown {
+		return fmt.Sprintf("(%s)", l.Target)
+	}
+	if l.Embed {
+		return fmt.Sprintf("![[%s]]", l.Target)
+	}
+	return fmt.Sprintf("[[%s]]", l.Target)
+}
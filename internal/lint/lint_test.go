@@ -0,0 +1,167 @@
+package lint
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func newTestVault(t *testing.T, files map[string]string) *vault.Vault {
+	t.Helper()
+	dir := t.TempDir()
+	for p, content := range files {
+		abs := filepath.Join(dir, filepath.FromSlash(p))
+		os.MkdirAll(filepath.Dir(abs), 0o755)
+		if err := os.WriteFile(abs, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := vault.New(dir)
+	if err != nil {
+		t.Fatalf("vault.New() returned error: %v", err)
+	}
+	return v
+}
+
+// issues returns the paths of the issues of kind
+func issues(r *Report, kind string) []string {
+	var out []string
+	for _, is := range r.Issues {
+		if is.Kind == kind {
+			out = append(out, is.Path)
+		}
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	fm := func(id string) string {
+		return "---\nid: " + id + "\ncreated: 2024-05-01T10:00:00Z\n---\n"
+	}
+	v := newTestVault(t, map[string]string{
+		"index.md":           fm("20240501100000-aaa") + "[[plan]] [[Ideas]] [[missing]] ![[diagram.png]] ![[gone.png]] [b](b.md)",
+		"projects/plan.md":   fm("20240501100000-bbb") + "[[index]]",
+		"archive/plan.md":    fm("20240501100000-bbb") + "old",
+		"Ideas.md":           "no frontmatter",
+		"b.md":               "---\ntitle: B\n---\n",
+		"bad.md":             "---\nid: 42\ncreated: yesterday\n---\n",
+		"broken.md":          "---\nid: [\n---\n",
+		"lonely.md":          fm("20240501100000-ccc") + "alone",
+		"diagram.png":        "png",
+		"Ideas.md.tmp":       "stale",
+		"new.md.tmp":         "unsaved",
+		"Clients/acme.md":    fm("20240501100000-ddd") + "[[index]]",
+		"clients/initech.md": fm("20240501100000-eee") + "[[index]]",
+		"projects/README.md": fm("20240501100000-fff") + "[[index]]",
+		"projects/readme.md": fm("20240501100000-ggg") + "[[index]]",
+		"projects/unique.md": fm("20240501100000-hhh") + "[[projects/plan]]",
+	})
+
+	r, err := Check(context.Background(), v)
+	if err != nil {
+		t.Fatalf("Check() returned error: %v", err)
+	}
+	tests := []struct {
+		kind string
+		want []string
+	}{
+		{MissingFrontmatter, []string{"Ideas.md"}},
+		{MissingKey, []string{"b.md"}},
+		{InvalidFrontmatter, []string{"broken.md"}},
+		{InvalidID, []string{"bad.md"}},
+		{InvalidDate, []string{"bad.md"}},
+		{DuplicateID, []string{"projects/plan.md"}}, // archive/plan.md sorts first and keeps the id
+		{BrokenLink, []string{"index.md", "index.md"}},
+		{AmbiguousLink, []string{"index.md"}},
+		{StrayTemp, []string{"Ideas.md.tmp", "new.md.tmp"}},
+		{CaseCollision, []string{"Clients", "projects/README.md"}},
+	}
+	for _, tt := range tests {
+		if got := issues(r, tt.kind); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s issues = %v, want %v", tt.kind, got, tt.want)
+		}
+	}
+	orphans := strings.Join(issues(r, Orphan), ",")
+	if !strings.Contains(orphans, "lonely.md") || strings.Contains(orphans, "index.md") || strings.Contains(orphans, "projects/unique.md") {
+		t.Errorf("orphans = %v, want lonely.md but not linked or linking notes", orphans)
+	}
+	if r.OK() || r.Notes != 13 {
+		t.Errorf("report = %d notes, OK() = %v", r.Notes, r.OK())
+	}
+}
+
+func TestFix(t *testing.T) {
+	v := newTestVault(t, map[string]string{
+		"a.md":     "---\nid: 20240501100000-aaa\ncreated: 2024-05-01\n---\n[[b]] [[c]]",
+		"b.md":     "---\nid: 20240501100000-aaa\ncreated: 2024-05-01\n---\n[[a]]",
+		"c.md":     "# Plain\n[[a]]",
+		"d.md":     "---\ntitle: D\ntags: [x]\n---\n[[a]]",
+		"a.md.tmp": "stale",
+		"e.md.tmp": "maybe the only copy",
+	})
+	ctx := context.Background()
+	r, err := Check(ctx, v)
+	if err != nil {
+		t.Fatalf("Check() returned error: %v", err)
+	}
+	r.Fix(ctx)
+	if r.Fixed != 4 {
+		t.Errorf("Fixed = %d, want 4: %+v", r.Fixed, r.Issues)
+	}
+
+	again, _ := Check(ctx, v)
+	for _, is := range again.Issues {
+		if is.Kind != StrayTemp || is.Path != "e.md.tmp" {
+			t.Errorf("left after fixing: %+v", is)
+		}
+	}
+
+	c, _ := v.ReadFile(ctx, "c.md")
+	if !strings.HasPrefix(c.Content, "---\nid: ") || !strings.HasSuffix(c.Content, "---\n# Plain\n[[a]]") {
+		t.Errorf("c.md = %q, want frontmatter added above the note", c.Content)
+	}
+	d, _ := v.ReadFile(ctx, "d.md")
+	if !strings.Contains(d.Content, "title: D\ntags: [x]\n---\n[[a]]") || !strings.Contains(d.Content, "created: ") {
+		t.Errorf("d.md = %q, want the keys added and the rest kept", d.Content)
+	}
+	a, _ := v.ReadFile(ctx, "a.md")
+	if !strings.Contains(a.Content, "id: 20240501100000-aaa") {
+		t.Errorf("a.md = %q, want it to keep the id links resolve to", a.Content)
+	}
+}
+
+func TestFix_SkipsChangedNotes(t *testing.T) {
+	v := newTestVault(t, map[string]string{"a.md": "plain"})
+	ctx := context.Background()
+	r, _ := Check(ctx, v)
+	v.WriteFile(ctx, "a.md", vault.WriteRequest{Content: "edited meanwhile"})
+
+	r.Fix(ctx)
+	if r.Fixed != 0 || r.Issues[0].FixError == "" {
+		t.Errorf("issues = %+v, want the fix to fail", r.Issues)
+	}
+	if got, _ := v.ReadFile(ctx, "a.md"); got.Content != "edited meanwhile" {
+		t.Errorf("a.md = %q, want the edit kept", got.Content)
+	}
+}
+
+func TestSetFrontmatter(t *testing.T) {
+	tests := []struct {
+		content string
+		keys    map[string]string
+		want    string
+	}{
+		{"body", map[string]string{"created": "c", "id": "i"}, "---\nid: i\ncreated: c\n---\nbody"},
+		{"---\ntitle: T\n---\nbody", map[string]string{"id": "i"}, "---\nid: i\ntitle: T\n---\nbody"},
+		{"---\nid: old\ntitle: T\n---\nbody", map[string]string{"id": "new"}, "---\nid: new\ntitle: T\n---\nbody"},
+	}
+	for _, tt := range tests {
+		if got := SetFrontmatter(tt.content, tt.keys); got != tt.want {
+			t.Errorf("SetFrontmatter(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
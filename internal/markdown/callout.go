@@ -0,0 +1,91 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// calloutHeader matches the first line of a callout, e.g. "[!warning]- Careful"
+var calloutHeader = regexp.MustCompile(`^\[!([A-Za-z][\w-]*)\][+-]?[ \t]*(.*)$`)
+
+// Attributes that turn a blockquote into a callout
+var (
+	calloutKindAttr  = []byte("data-callout")
+	calloutTitleAttr = []byte("data-callout-title")
+)
+
+// calloutTransformer marks blockquotes starting with "[!kind] title" as
+// callouts and drops that line from the quoted text
+type calloutTransformer struct{}
+
+func (t *calloutTransformer) Transform(node *ast.Paragraph, reader text.Reader, pc parser.Context) {
+	quote := node.Parent()
+	if quote == nil || quote.Kind() != ast.KindBlockquote || quote.FirstChild() != node {
+		return
+	}
+	lines := node.Lines()
+	if lines.Len() == 0 {
+		return
+	}
+	first := lines.At(0)
+	m := calloutHeader.FindSubmatch(util.TrimRightSpace(first.Value(reader.Source())))
+	if m == nil {
+		return
+	}
+
+	kind := strings.ToLower(string(m[1]))
+	title := strings.TrimSpace(string(m[2]))
+	if title == "" {
+		title = strings.ToUpper(kind[:1]) + kind[1:]
+	}
+	quote.SetAttribute(calloutKindAttr, []byte(kind))
+	quote.SetAttribute(calloutTitleAttr, []byte(title))
+
+	if lines.Len() == 1 {
+		quote.RemoveChild(quote, node)
+		return
+	}
+	rest := lines.Sliced(1, lines.Len())
+	lines.Clear()
+	lines.AppendAll(rest)
+	node.SetLines(lines)
+}
+
+// calloutRenderer renders callouts in place of the default blockquote
+type calloutRenderer struct{}
+
+func (r *calloutRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindBlockquote, r.render)
+}
+
+func (r *calloutRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	kind, ok := n.AttributeString(string(calloutKindAttr))
+	if !ok {
+		if entering {
+			w.WriteString("<blockquote>\n")
+		} else {
+			w.WriteString("</blockquote>\n")
+		}
+		return ast.WalkContinue, nil
+	}
+	if !entering {
+		w.WriteString("</div>\n</div>\n")
+		return ast.WalkContinue, nil
+	}
+
+	title, _ := n.AttributeString(string(calloutTitleAttr))
+	w.WriteString(`<div class="callout callout-`)
+	w.Write(util.EscapeHTML(kind.([]byte)))
+	w.WriteString(`" data-callout="`)
+	w.Write(util.EscapeHTML(kind.([]byte)))
+	w.WriteString("\">\n<div class=\"callout-title\">")
+	w.Write(util.EscapeHTML(title.([]byte)))
+	w.WriteString("</div>\n<div class=\"callout-content\">\n")
+	return ast.WalkContinue, nil
+}
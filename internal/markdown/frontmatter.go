@@ -0,0 +1,175 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Frontmatter is the YAML header of a note. Keys without a field end up in Extra.
+type Frontmatter struct {
+	ID      string         `yaml:"id,omitempty" json:"id,omitempty"`
+	Title   string         `yaml:"title,omitempty" json:"title,omitempty"`
+	Created string         `yaml:"created,omitempty" json:"created,omitempty"`
+	Updated string         `yaml:"updated,omitempty" json:"updated,omitempty"`
+	Tags    StringList     `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Aliases StringList     `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	Publish bool           `yaml:"publish,omitempty" json:"publish,omitempty"`
+	Extra   map[string]any `yaml:",inline" json:"extra,omitempty"`
+}
+
+// Summary returns the excerpt (or description) key of the frontmatter
+func (fm *Frontmatter) Summary() string {
+	for _, key := range []string{"excerpt", "description"} {
+		if s, _ := fm.Extra[key].(string); strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// CreatedAt parses the created date, reporting false if it is missing or
+// not a date
+func (fm *Frontmatter) CreatedAt() (time.Time, bool) {
+	return ParseDate(fm.Created)
+}
+
+// UpdatedAt parses the updated date, reporting false if it is missing or
+// not a date
+func (fm *Frontmatter) UpdatedAt() (time.Time, bool) {
+	return ParseDate(fm.Updated)
+}
+
+// dateLayouts are the ways notes write dates, most precise first. Dates
+// without a zone are UTC.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseDate parses a frontmatter date such as "2024-05-01" or
+// "2024-05-01T09:30:00+02:00"
+func ParseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// StringList accepts either a YAML sequence or a single string
+type StringList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (l *StringList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		if n.Value != "" {
+			*l = StringList{n.Value}
+		}
+		return nil
+	}
+	var list []string
+	if err := n.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Note is a parsed markdown file
+type Note struct {
+	Frontmatter    Frontmatter
+	HasFrontmatter bool
+	Body           []byte // content after the frontmatter
+}
+
+var frontmatterDelim = []byte("---")
+
+// Parse splits a note into its frontmatter and body. A note without a
+// leading "---" block has empty frontmatter and is all body.
+func Parse(src []byte) (*Note, error) {
+	n := &Note{Body: src}
+
+	rest, ok := cutLine(src, frontmatterDelim)
+	if !ok {
+		return n, nil
+	}
+	var header []byte
+	for len(rest) > 0 {
+		line, next := splitLine(rest)
+		if bytes.Equal(bytes.TrimSpace(line), frontmatterDelim) {
+			if err := yaml.Unmarshal(header, &n.Frontmatter); err != nil {
+				return nil, err
+			}
+			n.HasFrontmatter = true
+			n.Body = next
+			return n, nil
+		}
+		header = append(header, line...)
+		header = append(header, '\n')
+		rest = next
+	}
+	// No closing delimiter: treat the whole file as body
+	return n, nil
+}
+
+// cutLine returns what follows the first line of src if that line is want
+func cutLine(src, want []byte) ([]byte, bool) {
+	line, rest := splitLine(src)
+	if !bytes.Equal(bytes.TrimRight(line, " \t\r"), want) {
+		return nil, false
+	}
+	return rest, true
+}
+
+func splitLine(src []byte) (line, rest []byte) {
+	if i := bytes.IndexByte(src, '\n'); i >= 0 {
+		return bytes.TrimSuffix(src[:i], []byte("\r")), src[i+1:]
+	}
+	return src, nil
+}
+
+// inlineTag matches #tags in note bodies, the same way the editor does
+var inlineTag = regexp.MustCompile(`(?:^|[^#\w])#([a-zA-Z][a-zA-Z0-9_/-]*)`)
+
+// Tags returns the frontmatter tags and the #tags in the body, sorted and
+// without duplicates. Tags inside code are not recognised.
+func (n *Note) Tags() []string {
+	seen := map[string]bool{}
+	for _, t := range n.Frontmatter.Tags {
+		seen[t] = true
+	}
+	for _, m := range inlineTag.FindAllSubmatch(stripCode(n.Body), -1) {
+		seen[string(m[1])] = true
+	}
+
+	tags := make([]string, 0, len(seen))
+	for t := range seen {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+var codeBlocks = []*regexp.Regexp{
+	regexp.MustCompile("(?ms)^ {0,3}```.*?^ {0,3}```"),
+	regexp.MustCompile("(?ms)^ {0,3}~~~.*?^ {0,3}~~~"),
+	regexp.MustCompile("`[^`\n]*`"),
+}
+
+func stripCode(body []byte) []byte {
+	for _, re := range codeBlocks {
+		body = re.ReplaceAll(body, nil)
+	}
+	return body
+}
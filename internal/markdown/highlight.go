@@ -0,0 +1,70 @@
+package markdown
+
+import (
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Highlight is the AST node of ==highlighted text==
+type Highlight struct {
+	ast.BaseInline
+}
+
+// KindHighlight is the NodeKind of Highlight
+var KindHighlight = ast.NewNodeKind("Highlight")
+
+// Kind implements ast.Node
+func (n *Highlight) Kind() ast.NodeKind { return KindHighlight }
+
+// Dump implements ast.Node
+func (n *Highlight) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type highlightDelimiterProcessor struct{}
+
+func (p *highlightDelimiterProcessor) IsDelimiter(b byte) bool { return b == '=' }
+
+func (p *highlightDelimiterProcessor) CanOpenCloser(opener, closer *parser.Delimiter) bool {
+	return opener.Char == closer.Char
+}
+
+func (p *highlightDelimiterProcessor) OnMatch(consumes int) ast.Node { return &Highlight{} }
+
+var highlightDelimiters = &highlightDelimiterProcessor{}
+
+type highlightParser struct{}
+
+func (p *highlightParser) Trigger() []byte { return []byte{'='} }
+
+func (p *highlightParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	before := block.PrecendingCharacter()
+	line, segment := block.PeekLine()
+	node := parser.ScanDelimiter(line, before, 2, highlightDelimiters)
+	// Exactly two: "=" and "===" are left alone
+	if node == nil || node.OriginalLength != 2 || before == '=' {
+		return nil
+	}
+	node.Segment = segment.WithStop(segment.Start + node.OriginalLength)
+	block.Advance(node.OriginalLength)
+	pc.PushDelimiter(node)
+	return node
+}
+
+type highlightRenderer struct{}
+
+func (r *highlightRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindHighlight, r.render)
+}
+
+func (r *highlightRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.WriteString("<mark>")
+	} else {
+		w.WriteString("</mark>")
+	}
+	return ast.WalkContinue, nil
+}
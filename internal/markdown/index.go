@@ -0,0 +1,124 @@
+package markdown
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Index resolves link targets to note paths with the vault's link rules,
+// the same order the editor uses:
+//
+//  1. exact frontmatter id
+//  2. exact vault path, with or without .md
+//  3. file name without .md, ignoring folders
+//  4. frontmatter title, ignoring case
+//  5. frontmatter alias, ignoring case
+//
+// Ties go to the alphabetically first path.
+type Index struct {
+	paths []string
+	notes map[string]Frontmatter
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{notes: make(map[string]Frontmatter)}
+}
+
+// Add indexes the note at the vault path p
+func (ix *Index) Add(p string, fm Frontmatter) {
+	if _, ok := ix.notes[p]; !ok {
+		i := sort.SearchStrings(ix.paths, p)
+		ix.paths = append(ix.paths, "")
+		copy(ix.paths[i+1:], ix.paths[i:])
+		ix.paths[i] = p
+	}
+	ix.notes[p] = fm
+}
+
+// Paths returns the indexed note paths in order
+func (ix *Index) Paths() []string {
+	return ix.paths
+}
+
+// Frontmatter returns the frontmatter of an indexed note
+func (ix *Index) Frontmatter(p string) (Frontmatter, bool) {
+	fm, ok := ix.notes[p]
+	return fm, ok
+}
+
+// Resolve returns the path of the note target refers to
+func (ix *Index) Resolve(target string) (string, bool) {
+	if c := ix.Candidates(target); len(c) > 0 {
+		return c[0], true
+	}
+	return "", false
+}
+
+// Candidates returns every note target could refer to by the first rule
+// that matches, in order. More than one means the link is ambiguous and
+// Resolve picks the first.
+func (ix *Index) Candidates(target string) []string {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil
+	}
+	var out []string
+	for _, p := range ix.paths {
+		if ix.notes[p].ID == target {
+			out = append(out, p)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+
+	withExt := target
+	if !strings.EqualFold(path.Ext(target), ".md") {
+		withExt += ".md"
+	}
+	if _, ok := ix.notes[strings.TrimPrefix(withExt, "/")]; ok {
+		return []string{strings.TrimPrefix(withExt, "/")}
+	}
+
+	for _, p := range ix.paths {
+		if path.Base(p) == withExt {
+			out = append(out, p)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	for _, p := range ix.paths {
+		if strings.EqualFold(ix.notes[p].Title, target) {
+			out = append(out, p)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	for _, p := range ix.paths {
+		for _, a := range ix.notes[p].Aliases {
+			if strings.EqualFold(a, target) {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// ResolveLink resolves a link found in the note at from. Markdown links are
+// relative to that note's folder; wiki links use Resolve.
+func (ix *Index) ResolveLink(from string, l Link) (string, bool) {
+	if l.Kind == LinkMarkdown {
+		p := path.Join(path.Dir(from), l.Target)
+		if strings.HasPrefix(l.Target, "/") {
+			p = strings.TrimPrefix(path.Clean(l.Target), "/")
+		}
+		_, ok := ix.notes[p]
+		return p, ok
+	}
+	return ix.Resolve(l.Target)
+}
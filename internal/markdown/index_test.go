@@ -0,0 +1,77 @@
+package markdown
+
+import "testing"
+
+func TestIndex_Resolve(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("20240101000000-abc.md", Frontmatter{ID: "20240101000000-abc", Title: "Zettel"})
+	ix.Add("projects/plan.md", Frontmatter{Title: "The Plan", Aliases: StringList{"roadmap"}})
+	ix.Add("archive/plan.md", Frontmatter{})
+	ix.Add("Zettel.md", Frontmatter{})
+
+	tests := []struct {
+		target string
+		want   string
+		ok     bool
+	}{
+		{"20240101000000-abc", "20240101000000-abc.md", true},
+		{"projects/plan", "projects/plan.md", true},
+		{"projects/plan.md", "projects/plan.md", true},
+		{"plan", "archive/plan.md", true}, // file names tie-break alphabetically
+		{"Zettel", "Zettel.md", true},     // file name before title
+		{"the plan", "projects/plan.md", true},
+		{"ROADMAP", "projects/plan.md", true},
+		{"nothing", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ix.Resolve(tt.target)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Resolve(%q) = %q, %v, want %q, %v", tt.target, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIndex_Candidates(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("projects/plan.md", Frontmatter{Title: "Plan"})
+	ix.Add("archive/plan.md", Frontmatter{})
+	ix.Add("ideas.md", Frontmatter{Aliases: StringList{"Plan B"}})
+
+	if got := ix.Candidates("plan"); len(got) != 2 || got[0] != "archive/plan.md" {
+		t.Errorf("Candidates(plan) = %v, want both plan.md files", got)
+	}
+	if got := ix.Candidates("projects/plan"); len(got) != 1 {
+		t.Errorf("Candidates(projects/plan) = %v, want the exact path only", got)
+	}
+	if got := ix.Candidates("plan b"); len(got) != 1 || got[0] != "ideas.md" {
+		t.Errorf("Candidates(plan b) = %v", got)
+	}
+	if got := ix.Candidates("nothing"); len(got) != 0 {
+		t.Errorf("Candidates(nothing) = %v, want none", got)
+	}
+}
+
+func TestIndex_ResolveLink(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("a/b.md", Frontmatter{})
+	ix.Add("c.md", Frontmatter{})
+
+	tests := []struct {
+		from string
+		link Link
+		want string
+		ok   bool
+	}{
+		{"a/x.md", Link{Kind: LinkMarkdown, Target: "b.md"}, "a/b.md", true},
+		{"a/x.md", Link{Kind: LinkMarkdown, Target: "../c.md"}, "c.md", true},
+		{"a/x.md", Link{Kind: LinkMarkdown, Target: "/c.md"}, "c.md", true},
+		{"a/x.md", Link{Kind: LinkMarkdown, Target: "c.md"}, "a/c.md", false},
+		{"a/x.md", Link{Kind: LinkWiki, Target: "c"}, "c.md", true},
+	}
+	for _, tt := range tests {
+		got, ok := ix.ResolveLink(tt.from, tt.link)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ResolveLink(%q, %+v) = %q, %v, want %q, %v", tt.from, tt.link, got, ok, tt.want, tt.ok)
+		}
+	}
+}
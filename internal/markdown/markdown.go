@@ -0,0 +1,205 @@
+// Package markdown renders vault notes to HTML. On top of CommonMark and
+// GitHub Flavored Markdown it understands YAML frontmatter, [[wiki links]]
+// and ![[embeds]], ==highlights== and "> [!note]" callouts.
+package markdown
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Options customises how links render
+type Options struct {
+	// WikiLink returns the href of a wiki link or embed. Returning false
+	// renders the link as plain text.
+	WikiLink func(Link) (string, bool)
+	// URL rewrites the destination of a markdown link or image. Returning
+	// false renders a link as its plain text and leaves an image as is.
+	URL func(dest string, image bool) (string, bool)
+}
+
+func newMarkdown(opts Options) goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithExtensions(extension.GFM, extension.Footnote),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithInlineParsers(
+				// Ahead of the standard link parser at 200
+				util.Prioritized(&wikiLinkParser{}, 199),
+				util.Prioritized(&highlightParser{}, 500),
+			),
+			parser.WithParagraphTransformers(util.Prioritized(&calloutTransformer{}, 500)),
+			parser.WithASTTransformers(util.Prioritized(&urlTransformer{opts.URL}, 500)),
+		),
+		goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+			util.Prioritized(&wikiLinkRenderer{resolve: opts.WikiLink}, 500),
+			util.Prioritized(&highlightRenderer{}, 500),
+			util.Prioritized(&calloutRenderer{}, 500),
+		)),
+	)
+}
+
+// Render writes the HTML of a note body (without frontmatter) to w
+func Render(w io.Writer, body []byte, opts Options) error {
+	return newMarkdown(opts).Convert(body, w)
+}
+
+// Links returns the outgoing links of a note body: wiki links and embeds,
+// and markdown links to other .md files
+func Links(body []byte) []Link {
+	doc := newMarkdown(Options{}).Parser().Parse(text.NewReader(body))
+
+	links := []Link{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *WikiLink:
+			links = append(links, n.Link)
+		case *ast.Link:
+			if l, ok := markdownLink(string(n.Destination)); ok {
+				links = append(links, l)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
+
+// markdownLink interprets the destination of a markdown link as a link
+// to a note, e.g. "../ideas/other%20note.md#goals"
+func markdownLink(dest string) (Link, bool) {
+	if IsExternal(dest) {
+		return Link{}, false
+	}
+	target, heading, _ := strings.Cut(dest, "#")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	if !strings.EqualFold(pathExt(target), ".md") {
+		return Link{}, false
+	}
+	return Link{Kind: LinkMarkdown, Target: target, Heading: heading}, true
+}
+
+// IsExternal reports whether a link destination leaves the vault, i.e.
+// it has a scheme, is protocol-relative or only names an anchor
+func IsExternal(dest string) bool {
+	if dest == "" || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "//") {
+		return true
+	}
+	u, err := url.Parse(dest)
+	return err != nil || u.Scheme != ""
+}
+
+func pathExt(p string) string {
+	if i := strings.LastIndexByte(p, '.'); i >= 0 && !strings.Contains(p[i:], "/") {
+		return p[i:]
+	}
+	return ""
+}
+
+// urlTransformer applies Options.URL to markdown links and images
+type urlTransformer struct {
+	rewrite func(dest string, image bool) (string, bool)
+}
+
+func (t *urlTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	if t.rewrite == nil {
+		return
+	}
+	var unlink []*ast.Link
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Link:
+			if dest, ok := t.rewrite(string(n.Destination), false); ok {
+				n.Destination = []byte(dest)
+			} else {
+				unlink = append(unlink, n)
+			}
+		case *ast.Image:
+			if dest, ok := t.rewrite(string(n.Destination), true); ok {
+				n.Destination = []byte(dest)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	// Replace links that go nowhere with their text
+	for _, n := range unlink {
+		parent := n.Parent()
+		for c := n.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			parent.InsertBefore(parent, n, c)
+			c = next
+		}
+		parent.RemoveChild(parent, n)
+	}
+}
+
+// HeadingID returns the id a heading gets in rendered HTML, for linking
+// to [[note#heading]]
+func HeadingID(heading string) string {
+	doc := newMarkdown(Options{}).Parser().Parse(text.NewReader([]byte("# " + heading)))
+	if h := doc.FirstChild(); h != nil {
+		if id, ok := h.AttributeString("id"); ok {
+			return string(id.([]byte))
+		}
+	}
+	return ""
+}
+
+// Excerpt returns the plain text of the first paragraph of a note body,
+// cut at a word boundary to at most max runes
+func Excerpt(body []byte, max int) string {
+	doc := newMarkdown(Options{}).Parser().Parse(text.NewReader(body))
+
+	var b strings.Builder
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if b.Len() > 0 && !entering && n.Kind() == ast.KindParagraph {
+			return ast.WalkStop, nil
+		}
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading, *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.RawHTML, *ast.Image:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			b.Write(n.Segment.Value(body))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		case *WikiLink:
+			if !n.Link.Embed {
+				b.WriteString(n.Link.Text())
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	s := strings.Join(strings.Fields(b.String()), " ")
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max])
+		if i := strings.LastIndexByte(s, ' '); i > 0 {
+			s = s[:i]
+		}
+		s = strings.TrimRight(s, ",.;:") + "…"
+	}
+	return s
+}
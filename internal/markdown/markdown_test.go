@@ -0,0 +1,214 @@
+package markdown
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func render(t *testing.T, src string, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Render(&buf, []byte(src), opts); err != nil {
+		t.Fatalf("Render() returned error: %v", err)
+	}
+	return buf.String()
+}
+
+func TestParse(t *testing.T) {
+	t.Run("splits frontmatter", func(t *testing.T) {
+		n, err := Parse([]byte("---\ntitle: Hello\ntags: [a, b]\naliases: hi\npublish: true\nstatus: draft\n---\n# Body\n"))
+		if err != nil {
+			t.Fatalf("Parse() returned error: %v", err)
+		}
+		fm := n.Frontmatter
+		if !n.HasFrontmatter || fm.Title != "Hello" || !fm.Publish {
+			t.Errorf("frontmatter = %+v", fm)
+		}
+		if !reflect.DeepEqual([]string(fm.Tags), []string{"a", "b"}) || !reflect.DeepEqual([]string(fm.Aliases), []string{"hi"}) {
+			t.Errorf("tags, aliases = %v, %v", fm.Tags, fm.Aliases)
+		}
+		if fm.Extra["status"] != "draft" {
+			t.Errorf("extra = %v, want status", fm.Extra)
+		}
+		if string(n.Body) != "# Body\n" {
+			t.Errorf("body = %q", n.Body)
+		}
+	})
+
+	t.Run("treats notes without frontmatter as body", func(t *testing.T) {
+		for _, src := range []string{"# Just text\n", "---\nnever closed\n"} {
+			n, err := Parse([]byte(src))
+			if err != nil || n.HasFrontmatter || string(n.Body) != src {
+				t.Errorf("Parse(%q) = %+v, %v", src, n, err)
+			}
+		}
+	})
+
+	t.Run("reports invalid yaml", func(t *testing.T) {
+		if _, err := Parse([]byte("---\ntags: [unclosed\n---\n")); err == nil {
+			t.Error("Parse() succeeded, want error")
+		}
+	})
+}
+
+func TestNote_Tags(t *testing.T) {
+	n, _ := Parse([]byte("---\ntags: [zeta]\n---\nAbout #go and #go and #web/dev, not issue#1.\n\n```\n#comment\n```\n`#code`\n"))
+	want := []string{"go", "web/dev", "zeta"}
+	if got := n.Tags(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Tags() = %v, want %v", got, want)
+	}
+}
+
+func TestFrontmatterDates(t *testing.T) {
+	n, err := Parse([]byte("---\ncreated: 2024-05-01\nupdated: 2024-05-02T09:30:00+02:00\ndescription: About dates\n---\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm := n.Frontmatter
+	if got, ok := fm.CreatedAt(); !ok || !got.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("CreatedAt() = %v, %v", got, ok)
+	}
+	if got, ok := fm.UpdatedAt(); !ok || !got.Equal(time.Date(2024, 5, 2, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("UpdatedAt() = %v, %v", got, ok)
+	}
+	if fm.Summary() != "About dates" {
+		t.Errorf("Summary() = %q", fm.Summary())
+	}
+	for _, s := range []string{"", "yesterday", "2024-13-01"} {
+		if _, ok := ParseDate(s); ok {
+			t.Errorf("ParseDate(%q) succeeded", s)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		max  int
+		want string
+	}{
+		"first paragraph": {"# Title\n\nSee **bold** and [[Other|that]]\nnote.\n\nSecond.\n", 100, "See bold and that note."},
+		"skips code":      {"```\ncode\n```\n\n![[pic.png]] Text\n", 100, "Text"},
+		"cuts at words":   {"one two three four\n", 12, "one two…"},
+		"empty":           {"# Only a heading\n", 100, ""},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Excerpt([]byte(tt.body), tt.max); got != tt.want {
+				t.Errorf("Excerpt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	opts := Options{
+		WikiLink: func(l Link) (string, bool) {
+			if l.Target == "missing" {
+				return "", false
+			}
+			return "/n/" + l.Target + ".html", true
+		},
+		URL: func(dest string, image bool) (string, bool) {
+			return dest, dest != "gone.md"
+		},
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want []string
+		not  []string
+	}{
+		{
+			name: "wiki link with alias",
+			src:  "See [[Other note#Goals|the goals]].",
+			want: []string{`<a class="internal-link" href="/n/Other%20note.html">the goals</a>`},
+		},
+		{
+			name: "unresolved wiki link is plain text",
+			src:  "See [[missing]].",
+			want: []string{"<p>See missing.</p>"},
+			not:  []string{"<a"},
+		},
+		{
+			name: "image embed",
+			src:  "![[pic.png]]",
+			want: []string{`<img src="/n/pic.png.html" alt="pic.png">`},
+		},
+		{
+			name: "highlight",
+			src:  "This is ==important== but a == b and ===c===.",
+			want: []string{"<mark>important</mark>", "a == b", "===c==="},
+		},
+		{
+			name: "callout with title",
+			src:  "> [!warning] Mind the gap\n> Body **text**",
+			want: []string{`<div class="callout callout-warning" data-callout="warning">`, `<div class="callout-title">Mind the gap</div>`, "<strong>text</strong>"},
+			not:  []string{"<blockquote>", "[!warning]"},
+		},
+		{
+			name: "callout default title",
+			src:  "> [!tip]\n> Body",
+			want: []string{`<div class="callout-title">Tip</div>`},
+		},
+		{
+			name: "plain blockquote",
+			src:  "> quoted",
+			want: []string{"<blockquote>\n<p>quoted</p>\n</blockquote>"},
+		},
+		{
+			name: "markdown link rejected by URL",
+			src:  "[gone](gone.md) [kept](kept.md)",
+			want: []string{"<p>gone <a href=\"kept.md\">kept</a></p>"},
+		},
+		{
+			name: "code is left alone",
+			src:  "`[[not]]`\n\n```\n==no==\n```",
+			want: []string{"<code>[[not]]</code>", "==no=="},
+			not:  []string{"<mark>", "internal-link"},
+		},
+		{
+			name: "raw html is dropped",
+			src:  "<script>alert(1)</script>",
+			not:  []string{"<script>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := render(t, tt.src, opts)
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("Render() = %q, want it to contain %q", got, w)
+				}
+			}
+			for _, n := range tt.not {
+				if strings.Contains(got, n) {
+					t.Errorf("Render() = %q, want it not to contain %q", got, n)
+				}
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	got := Links([]byte("[[a]] ![[b.png]] [[c#Intro|see c]] [d](sub/d%20e.md#x) [web](https://example.com) [img](pic.png)"))
+	want := []Link{
+		{Kind: LinkWiki, Target: "a"},
+		{Kind: LinkWiki, Target: "b.png", Embed: true},
+		{Kind: LinkWiki, Target: "c", Heading: "Intro", Alias: "see c"},
+		{Kind: LinkMarkdown, Target: "sub/d e.md", Heading: "x"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Links() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHeadingID(t *testing.T) {
+	if got := HeadingID("Goals & Plans"); got != "goals--plans" {
+		t.Errorf("HeadingID() = %q, want %q", got, "goals--plans")
+	}
+}
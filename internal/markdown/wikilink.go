@@ -0,0 +1,154 @@
+package markdown
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Link kinds
+const (
+	LinkWiki     = "wiki"     // [[target#heading|alias]]
+	LinkMarkdown = "markdown" // [text](path.md#heading)
+)
+
+// Link is an outgoing link of a note
+type Link struct {
+	Kind    string
+	Target  string // note id, path, filename, title or alias
+	Heading string
+	Alias   string // display text, if given
+	Embed   bool   // ![[target]]
+}
+
+// Text is what a link displays
+func (l Link) Text() string {
+	switch {
+	case l.Alias != "":
+		return l.Alias
+	case l.Heading != "":
+		return l.Target + " > " + l.Heading
+	}
+	return l.Target
+}
+
+// ParseWikiLink parses the inside of [[...]]
+func ParseWikiLink(inner string) Link {
+	l := Link{Kind: LinkWiki}
+	target, alias, _ := strings.Cut(inner, "|")
+	target, heading, _ := strings.Cut(target, "#")
+	l.Target = strings.TrimSpace(target)
+	l.Heading = strings.TrimSpace(heading)
+	l.Alias = strings.TrimSpace(alias)
+	return l
+}
+
+// imageExts are the embeds rendered as <img>
+var imageExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".svg": true, ".webp": true, ".avif": true, ".bmp": true,
+}
+
+// IsImage reports whether target names an image file
+func IsImage(target string) bool {
+	return imageExts[strings.ToLower(path.Ext(target))]
+}
+
+// WikiLink is the AST node of a [[wiki link]] or ![[embed]]
+type WikiLink struct {
+	ast.BaseInline
+	Link Link
+}
+
+// KindWikiLink is the NodeKind of WikiLink
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// Kind implements ast.Node
+func (n *WikiLink) Kind() ast.NodeKind { return KindWikiLink }
+
+// Dump implements ast.Node
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Link.Target}, nil)
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte { return []byte{'!', '['} }
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	embed := line[0] == '!'
+	if embed {
+		line = line[1:]
+	}
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line[2:], []byte("]]"))
+	if end <= 0 {
+		return nil
+	}
+	inner := line[2 : 2+end]
+	if bytes.ContainsAny(inner, "[]") {
+		return nil
+	}
+
+	n := &WikiLink{Link: ParseWikiLink(string(inner))}
+	n.Link.Embed = embed
+	if n.Link.Target == "" {
+		return nil
+	}
+	consumed := 2 + end + 2
+	if embed {
+		consumed++
+	}
+	block.Advance(consumed)
+	return n
+}
+
+type wikiLinkRenderer struct {
+	resolve func(Link) (string, bool)
+}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.render)
+}
+
+func (r *wikiLinkRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	l := node.(*WikiLink).Link
+	label := util.EscapeHTML([]byte(l.Text()))
+
+	href, ok := "", false
+	if r.resolve != nil {
+		href, ok = r.resolve(l)
+	}
+	if !ok {
+		w.Write(label)
+		return ast.WalkSkipChildren, nil
+	}
+
+	dest := util.EscapeHTML(util.URLEscape([]byte(href), true))
+	if l.Embed && IsImage(l.Target) {
+		w.WriteString(`<img src="`)
+		w.Write(dest)
+		w.WriteString(`" alt="`)
+		w.Write(label)
+		w.WriteString(`">`)
+		return ast.WalkSkipChildren, nil
+	}
+	w.WriteString(`<a class="internal-link" href="`)
+	w.Write(dest)
+	w.WriteString(`">`)
+	w.Write(label)
+	w.WriteString(`</a>`)
+	return ast.WalkSkipChildren, nil
+}
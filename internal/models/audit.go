@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// Audit actions. Related actions share a prefix so they can be filtered together.
+const (
+	AuditLogin          = "auth.login"
+	AuditLoginFailed    = "auth.login_failed"
+	AuditLogout         = "auth.logout"
+	AuditTokenCreated   = "auth.token_created"
+	AuditTokenRevoked   = "auth.token_revoked"
+	AuditTeamCreated    = "team.created"
+	AuditTeamDeleted    = "team.deleted"
+	AuditMemberInvited  = "team.member_invited"
+	AuditMemberJoined   = "team.member_joined"
+	AuditMemberDeclined = "team.member_declined"
+	AuditMemberRole     = "team.member_role_changed"
+	AuditMemberRemoved  = "team.member_removed"
+	AuditOwnerChanged   = "team.ownership_transferred"
+	AuditFileCreate     = "file.create"
+	AuditFileWrite      = "file.write"
+	AuditFileRename     = "file.rename"
+	AuditFileDelete     = "file.delete"
+	AuditFolderCreate   = "file.folder_create"
+	AuditFolderDelete   = "file.folder_delete"
+	AuditVaultACL       = "vault.acl_changed"
+	AuditVaultExport    = "vault.exported"
+	AuditVaultImport    = "vault.imported"
+	AuditVaultUnlocked  = "vault.unlocked"
+	AuditUnlockFailed   = "vault.unlock_failed"
+	AuditAdminTableRead = "admin.table_read"
+	AuditAdminSQLQuery  = "admin.sql_query"
+	AuditSiteTheme      = "site.theme_changed"
+	AuditPostCreated    = "post.created"
+	AuditPostUpdated    = "post.updated"
+	AuditPostPublished  = "post.published"
+	AuditPostDeleted    = "post.deleted"
+	AuditFormCreated    = "form.created"
+	AuditFormUpdated    = "form.updated"
+	AuditFormDeleted    = "form.deleted"
+	AuditFormExported   = "form.submissions_exported"
+	AuditPluginEnabled  = "plugin.enabled"
+	AuditPluginDisabled = "plugin.disabled"
+	AuditPluginGrants   = "plugin.capabilities_changed"
+	AuditWebhookCreated = "webhook.created"
+	AuditWebhookUpdated = "webhook.updated"
+	AuditWebhookDeleted = "webhook.deleted"
+	AuditWebhookResent  = "webhook.redelivered"
+)
+
+// AuditEvent is one entry of the append-only audit log
+type AuditEvent struct {
+	ID         int64     `db:"id" json:"id"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	ActorID    *int64    `db:"actor_id" json:"actor_id,omitempty"`
+	ActorEmail string    `db:"actor_email" json:"actor_email,omitempty"`
+	IP         string    `db:"ip" json:"ip,omitempty"`
+	Action     string    `db:"action" json:"action"`
+	Target     string    `db:"target" json:"target,omitempty"`
+	Vault      string    `db:"vault" json:"vault,omitempty"`
+	HashBefore string    `db:"hash_before" json:"hash_before,omitempty"`
+	HashAfter  string    `db:"hash_after" json:"hash_after,omitempty"`
+	Details    string    `db:"details" json:"details,omitempty"`
+}
+
+// AuditFilter narrows an audit log query. Zero values match everything.
+type AuditFilter struct {
+	Actor    string // actor email
+	Action   string // exact action or prefix, e.g. "file" or "team.member_removed"
+	Target   string // exact target or folder prefix
+	Vault    string
+	Since    time.Time
+	Until    time.Time
+	BeforeID int64 // cursor: only events older than this id
+	Limit    int
+}
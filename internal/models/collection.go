@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Collection is a set of notes from one vault: either the ordered paths
+// stored with it or, when Query is set, the notes matching a saved search
+type Collection struct {
+	ID          int64     `db:"id" json:"id"`
+	UserID      int64     `db:"user_id" json:"user_id"`
+	Name        string    `db:"name" json:"name"`
+	Description *string   `db:"description" json:"description,omitempty"`
+	Vault       string    `db:"vault" json:"vault"`               // vault name, e.g. users/3 or teams/7
+	Query       string    `db:"query" json:"query,omitempty"`     // search query of a smart collection
+	TeamID      *int64    `db:"team_id" json:"team_id,omitempty"` // team the collection is shared with
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Smart reports whether the collection is evaluated from its query
+func (c *Collection) Smart() bool {
+	return c.Query != ""
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Form is a set of fields visitors fill in and submit at /f/{id}
+type Form struct {
+	ID           int64      `db:"id" json:"id"`
+	Name         string     `db:"name" json:"name"`
+	Description  string     `db:"description" json:"description"`
+	Fields       FormFields `db:"fields" json:"fields"`
+	CaptureVault string     `db:"capture_vault" json:"capture_vault,omitempty"`
+	CapturePath  string     `db:"capture_path" json:"capture_path,omitempty"`
+	CaptureRole  string     `db:"capture_role" json:"capture_role,omitempty"` // the creator's, in CaptureVault
+	CreatedBy    *int64     `db:"created_by" json:"created_by,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// FormField is one input of a form
+type FormField struct {
+	Name     string   `json:"name"` // key in submissions
+	Label    string   `json:"label,omitempty"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Options  []string `json:"options,omitempty"`    // select only
+	Min      *float64 `json:"min,omitempty"`        // number only
+	Max      *float64 `json:"max,omitempty"`        // number only
+	MaxLen   int      `json:"max_length,omitempty"` // text only; 0 means the default cap
+}
+
+// Form field types
+const (
+	FieldText     = "text"
+	FieldEmail    = "email"
+	FieldNumber   = "number"
+	FieldSelect   = "select"
+	FieldCheckbox = "checkbox"
+	FieldDate     = "date"
+)
+
+// FormFields is stored as a JSON array
+type FormFields []FormField
+
+// Value implements driver.Valuer
+func (f FormFields) Value() (driver.Value, error) {
+	if f == nil {
+		f = FormFields{}
+	}
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (f *FormFields) Scan(src any) error {
+	return scanJSON(src, f)
+}
+
+// FormSubmission is what a visitor sent through a form
+type FormSubmission struct {
+	ID        int64          `db:"id" json:"id"`
+	FormID    int64          `db:"form_id" json:"form_id"`
+	Data      SubmissionData `db:"data" json:"data"`
+	IP        string         `db:"ip" json:"ip"`
+	UserAgent string         `db:"user_agent" json:"user_agent"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+}
+
+// SubmissionData maps field names to submitted values; it is stored as a
+// JSON object
+type SubmissionData map[string]string
+
+// Value implements driver.Valuer
+func (d SubmissionData) Value() (driver.Value, error) {
+	if d == nil {
+		d = SubmissionData{}
+	}
+	b, err := json.Marshal(d)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (d *SubmissionData) Scan(src any) error {
+	return scanJSON(src, d)
+}
+
+func scanJSON(src any, dst any) error {
+	switch v := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T as JSON", src)
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// LoginThrottle is the failure history the login limiter keeps for one key
+// (a client IP or an account).
+type LoginThrottle struct {
+	Key           string    `db:"key" json:"key"`
+	Failures      int       `db:"failures" json:"failures"`
+	LastFailureAt time.Time `db:"last_failure_at" json:"last_failure_at"`
+	LockedUntil   time.Time `db:"locked_until" json:"locked_until"`
+}
+
+// LoginAttempt is a single recorded login attempt.
+type LoginAttempt struct {
+	ID        int64     `db:"id" json:"id"`
+	Email     string    `db:"email" json:"email"`
+	IP        string    `db:"ip" json:"ip"`
+	UserAgent string    `db:"user_agent" json:"user_agent"`
+	Success   bool      `db:"success" json:"success"`
+	Reason    string    `db:"reason" json:"reason"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
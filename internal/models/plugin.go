@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// Plugin is an installed plugin as registered in dz_plugins
+type Plugin struct {
+	ID             int64        `db:"id" json:"id"`
+	Name           string       `db:"name" json:"name"` // manifest id
+	DisplayName    string       `db:"display_name" json:"display_name"`
+	Description    string       `db:"description" json:"description,omitempty"`
+	Version        string       `db:"version" json:"version"`
+	IsActive       bool         `db:"is_active" json:"is_active"`
+	SidebarIcon    string       `db:"sidebar_icon" json:"sidebar_icon,omitempty"`
+	SidebarTitle   string       `db:"sidebar_title" json:"sidebar_title,omitempty"`
+	SidebarLink    string       `db:"sidebar_link" json:"sidebar_link,omitempty"`
+	MetadataSchema PluginSchema `db:"metadata_schema" json:"metadata_schema"`
+	HookModule     string       `db:"hook_module" json:"hook_module,omitempty"` // WebAssembly module with server-side hooks
+	Capabilities   Capabilities `db:"capabilities" json:"capabilities"`         // asked for by the manifest
+	Granted        Capabilities `db:"granted_capabilities" json:"granted_capabilities"`
+	CreatedAt      time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time    `db:"updated_at" json:"updated_at"`
+}
+
+// PluginField is a frontmatter key a plugin defines, as the editor's
+// plugin registry describes it
+type PluginField struct {
+	Key         string   `json:"key"`
+	Label       string   `json:"label"`
+	Type        string   `json:"type"`
+	Options     []string `json:"options,omitempty"`
+	Default     any      `json:"default,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// Plugin metadata field types
+const (
+	PluginFieldString  = "string"
+	PluginFieldNumber  = "number"
+	PluginFieldBoolean = "boolean"
+	PluginFieldDate    = "date"
+	PluginFieldArray   = "array"
+	PluginFieldSelect  = "select"
+)
+
+// PluginSchema is stored as a JSON array
+type PluginSchema []PluginField
+
+// Value implements driver.Valuer
+func (s PluginSchema) Value() (driver.Value, error) {
+	if s == nil {
+		s = PluginSchema{}
+	}
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (s *PluginSchema) Scan(src any) error {
+	return scanJSON(src, s)
+}
+
+// Capabilities a plugin's hooks can be granted
+const (
+	CapReadNotes  = "read_notes"
+	CapWriteNotes = "write_notes"
+)
+
+// ValidCapability reports whether c is a known capability
+func ValidCapability(c string) bool {
+	return c == CapReadNotes || c == CapWriteNotes
+}
+
+// Capabilities is a set of capabilities, stored as a JSON array
+type Capabilities []string
+
+// Has reports whether c is in the set
+func (cs Capabilities) Has(c string) bool {
+	for _, have := range cs {
+		if have == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements driver.Valuer
+func (cs Capabilities) Value() (driver.Value, error) {
+	if cs == nil {
+		cs = Capabilities{}
+	}
+	b, err := json.Marshal(cs)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (cs *Capabilities) Scan(src any) error {
+	return scanJSON(src, cs)
+}
+
+// Active returns the capabilities the plugin's hooks may use: those it
+// asked for and was granted
+func (p *Plugin) Active() Capabilities {
+	var caps Capabilities
+	for _, c := range p.Granted {
+		if p.Capabilities.Has(c) {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Post is a page of site content with a publishing workflow
+type Post struct {
+	ID          int64      `db:"id" json:"id"`
+	AuthorID    *int64     `db:"author_id" json:"author_id,omitempty"`
+	Title       string     `db:"title" json:"title"`
+	Slug        string     `db:"slug" json:"slug"`
+	Content     string     `db:"content" json:"content"`
+	Status      string     `db:"status" json:"status"`
+	Visibility  string     `db:"visibility" json:"visibility"`
+	Format      string     `db:"format" json:"format"`
+	Excerpt     string     `db:"excerpt" json:"excerpt"`
+	PublishAt   *time.Time `db:"publish_at" json:"publish_at,omitempty"`
+	SourceVault string     `db:"source_vault" json:"source_vault,omitempty"`
+	SourcePath  string     `db:"source_path" json:"source_path,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// Post statuses
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled" // published by the scheduler at PublishAt
+	PostPublished = "published"
+)
+
+// Post visibilities
+const (
+	PostPublic   = "public"   // listed on the site and in feeds
+	PostUnlisted = "unlisted" // reachable by slug only
+	PostPrivate  = "private"  // signed-in users only
+)
+
+// Post formats
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
@@ -5,6 +5,16 @@ import "time"
 type Session struct {
 	ID             string    `db:"id" json:"id"`
 	Data           string    `db:"data" json:"data"`
+	UserID         string    `db:"user_id" json:"user_id"`
+	UserAgent      string    `db:"user_agent" json:"user_agent"`
+	IP             string    `db:"ip" json:"ip"`
 	CreatedAt      time.Time `db:"created_at" json:"created_at"`
 	LastActivityAt time.Time `db:"last_activity_at" json:"last_activity_at"`
 }
+
+// SessionMeta is the metadata stored alongside a session's data
+type SessionMeta struct {
+	UserID    string
+	UserAgent string
+	IP        string
+}
@@ -0,0 +1,6 @@
+package models
+
+// Site setting keys
+const (
+	SettingActiveTheme = "active_theme" // theme the public site renders with
+)
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ShareLink grants read-only access to one note to anyone holding its token
+type ShareLink struct {
+	ID           int64      `db:"id" json:"id"`
+	Token        string     `db:"token" json:"token"`
+	Vault        string     `db:"vault" json:"vault"`
+	Path         string     `db:"path" json:"path"`
+	CreatedBy    *int64     `db:"created_by" json:"created_by,omitempty"`
+	PasswordHash string     `db:"password_hash" json:"-"`
+	ExpiresAt    time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt    *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	ViewCount    int64      `db:"view_count" json:"view_count"`
+	LastViewedAt *time.Time `db:"last_viewed_at" json:"last_viewed_at,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+}
+
+// HasPassword reports whether viewers must enter a password
+func (s *ShareLink) HasPassword() bool {
+	return s.PasswordHash != ""
+}
+
+// CheckPassword compares password with the link's password hash
+func (s *ShareLink) CheckPassword(password string) error {
+	return checkPassword(s.PasswordHash, password)
+}
+
+// Active reports whether the link can be viewed at now
+func (s *ShareLink) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
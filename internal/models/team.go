@@ -17,3 +17,64 @@ type TeamMember struct {
 	Role     string    `db:"role" json:"role"`
 	JoinedAt time.Time `db:"joined_at" json:"joined_at"`
 }
+
+// Team roles, from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// ValidRole reports whether role is one of the defined team roles
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants at least the privileges of min
+func RoleAtLeast(role, min string) bool {
+	return roleRank[role] >= roleRank[min] && roleRank[role] > 0
+}
+
+// HasRole reports whether the member holds at least the given role
+func (m *TeamMember) HasRole(min string) bool {
+	return RoleAtLeast(m.Role, min)
+}
+
+// Outranks reports whether the member holds a strictly higher role than role
+func (m *TeamMember) Outranks(role string) bool {
+	return roleRank[m.Role] > roleRank[role]
+}
+
+// TeamMemberDetail is a membership joined with the member's profile
+type TeamMemberDetail struct {
+	TeamMember
+	Email       string  `db:"email" json:"email"`
+	DisplayName *string `db:"display_name" json:"display_name,omitempty"`
+}
+
+// Invitation statuses
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+type TeamInvitation struct {
+	ID          int64      `db:"id" json:"id"`
+	TeamID      int64      `db:"team_id" json:"team_id"`
+	Email       string     `db:"email" json:"email"`
+	Role        string     `db:"role" json:"role"`
+	InvitedBy   *int64     `db:"invited_by" json:"invited_by,omitempty"`
+	Status      string     `db:"status" json:"status"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	RespondedAt *time.Time `db:"responded_at" json:"responded_at,omitempty"`
+}
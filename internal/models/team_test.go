@@ -76,3 +76,34 @@ func TestTeamMember_Struct(t *testing.T) {
 		t.Errorf("TeamMember.JoinedAt = %v, want %v", member.JoinedAt, now)
 	}
 }
+
+func TestRoleAtLeast(t *testing.T) {
+	tests := []struct {
+		role, min string
+		want      bool
+	}{
+		{RoleOwner, RoleAdmin, true},
+		{RoleAdmin, RoleAdmin, true},
+		{RoleEditor, RoleAdmin, false},
+		{RoleViewer, RoleViewer, true},
+		{"bogus", RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := RoleAtLeast(tt.role, tt.min); got != tt.want {
+			t.Errorf("RoleAtLeast(%q, %q) = %v, want %v", tt.role, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestTeamMember_Outranks(t *testing.T) {
+	admin := TeamMember{Role: RoleAdmin}
+	if !admin.Outranks(RoleEditor) {
+		t.Error("admin.Outranks(editor) = false, want true")
+	}
+	if admin.Outranks(RoleAdmin) {
+		t.Error("admin.Outranks(admin) = true, want false")
+	}
+	if !ValidRole(RoleOwner) || ValidRole("root") {
+		t.Error("ValidRole() accepted or rejected the wrong roles")
+	}
+}
@@ -13,10 +13,21 @@ type User struct {
 	PasswordHash string     `db:"password_hash" json:"-"`
 	DisplayName  *string    `db:"display_name" json:"display_name,omitempty"`
 	AvatarURL    *string    `db:"avatar_url" json:"avatar_url,omitempty"`
+	IsAdmin      bool       `db:"is_admin" json:"is_admin"`
 	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt    *time.Time `db:"updated_at" json:"updated_at,omitempty"`
 }
 
 func (u *User) CheckPassword(password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
+	return checkPassword(u.PasswordHash, password)
+}
+
+// HashPassword returns the bcrypt hash stored for a password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func checkPassword(hash, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// Webhook posts signed JSON payloads to URL when the events it subscribes
+// to happen
+type Webhook struct {
+	ID        int64      `db:"id" json:"id"`
+	URL       string     `db:"url" json:"url"`
+	Secret    string     `db:"secret" json:"-"`
+	Events    StringList `db:"events" json:"events"`
+	Paths     StringList `db:"paths" json:"paths"` // patterns such as projects/**; empty matches every path
+	Vault     string     `db:"vault" json:"vault,omitempty"`
+	IsActive  bool       `db:"is_active" json:"is_active"`
+	CreatedBy *int64     `db:"created_by" json:"created_by,omitempty"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// WebhookEvents are the events webhooks can subscribe to, named after
+// their audit actions
+var WebhookEvents = []string{
+	AuditFileCreate,
+	AuditFileWrite,
+	AuditFileRename,
+	AuditFileDelete,
+	AuditPostPublished,
+	AuditMemberJoined,
+}
+
+// ValidWebhookEvent reports whether webhooks can subscribe to event
+func ValidWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one payload queued for a webhook, and the outcome of
+// its attempts
+type WebhookDelivery struct {
+	ID            int64      `db:"id" json:"id"`
+	WebhookID     int64      `db:"webhook_id" json:"webhook_id"`
+	Event         string     `db:"event" json:"event"`
+	Payload       string     `db:"payload" json:"payload"`
+	Status        string     `db:"status" json:"status"`
+	Attempts      int        `db:"attempts" json:"attempts"`
+	NextAttemptAt *time.Time `db:"next_attempt_at" json:"next_attempt_at,omitempty"`
+	ResponseCode  *int       `db:"response_code" json:"response_code,omitempty"`
+	Error         string     `db:"error" json:"error,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	DeliveredAt   *time.Time `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed" // gave up after the last retry
+)
+
+// StringList is stored as a JSON array
+type StringList []string
+
+// Value implements driver.Valuer
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		l = StringList{}
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (l *StringList) Scan(src any) error {
+	return scanJSON(src, l)
+}
@@ -0,0 +1,149 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/webhooks"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TeamGrant is the team membership granted to members of an IdP group
+type TeamGrant struct {
+	TeamID int64
+	Role   string
+}
+
+// ProvisionOptions controls just-in-time user provisioning
+type ProvisionOptions struct {
+	// LinkByEmail links a new identity to an existing user with the same
+	// (verified) email instead of creating a second account
+	LinkByEmail bool
+	// GroupsClaim names the claim holding the user's groups, e.g. "groups"
+	GroupsClaim string
+	// GroupTeams maps group names to the team membership they grant
+	GroupTeams map[string]TeamGrant
+}
+
+// ParseGroupTeams parses "group=teamID:role" pairs separated by commas.
+// The role defaults to "viewer" when omitted. Groups can't grant "owner":
+// a team has one owner, changed only by transferring ownership.
+func ParseGroupTeams(s string) (map[string]TeamGrant, error) {
+	out := make(map[string]TeamGrant)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, grant, ok := strings.Cut(pair, "=")
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid group mapping %q", pair)
+		}
+		idStr, role, _ := strings.Cut(grant, ":")
+		teamID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid team id in group mapping %q", pair)
+		}
+		if role == "" {
+			role = models.RoleViewer
+		}
+		if !models.ValidRole(role) || role == models.RoleOwner {
+			return nil, fmt.Errorf("invalid role in group mapping %q: must be admin, editor or viewer", pair)
+		}
+		out[strings.TrimSpace(group)] = TeamGrant{TeamID: teamID, Role: role}
+	}
+	return out, nil
+}
+
+// Provision returns the local user for verified claims, linking or creating
+// one as needed, and applies any group-to-team mappings.
+func Provision(ctx context.Context, db *dbx.DB, claims *Claims, opts ProvisionOptions) (*models.User, error) {
+	user, err := db.GetUserByIdentity(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		user, err = linkOrCreate(ctx, db, claims, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.GroupsClaim != "" && len(opts.GroupTeams) > 0 {
+		applyGroupTeams(ctx, db, user, claims.Strings(opts.GroupsClaim), opts.GroupTeams)
+	}
+
+	return user, nil
+}
+
+func linkOrCreate(ctx context.Context, db *dbx.DB, claims *Claims, opts ProvisionOptions) (*models.User, error) {
+	if claims.Email == "" {
+		return nil, errors.New("oidc: identity has no email claim")
+	}
+
+	existing, err := db.GetUserByEmail(ctx, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	switch {
+	case existing != nil && opts.LinkByEmail && claims.EmailVerified:
+		user = existing
+		log.Printf("oidc: linking %s/%s to existing user %d", claims.Issuer, claims.Subject, user.ID)
+	case existing != nil:
+		return nil, errors.New("oidc: an account with this email already exists")
+	default:
+		// SSO users get an unusable random password; they can only sign in through the IdP
+		hash, err := bcrypt.GenerateFromPassword([]byte(RandomString()), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		name := claims.Name
+		if name == "" {
+			name = claims.Email
+		}
+		user, err = db.CreateUser(ctx, claims.Email, string(hash), name)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("oidc: provisioned user %d for %s/%s", user.ID, claims.Issuer, claims.Subject)
+	}
+
+	if err := db.LinkUserIdentity(ctx, claims.Issuer, claims.Subject, user.ID, claims.Email); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// applyGroupTeams adds the user to every mapped team they are not yet a member of.
+// Failures are logged rather than failing the login.
+func applyGroupTeams(ctx context.Context, db *dbx.DB, user *models.User, groups []string, mapping map[string]TeamGrant) {
+	for _, group := range groups {
+		grant, ok := mapping[group]
+		if !ok {
+			continue
+		}
+		member, err := db.GetTeamMember(ctx, grant.TeamID, user.ID)
+		if err != nil {
+			log.Printf("oidc: failed to check team %d membership: %v", grant.TeamID, err)
+			continue
+		}
+		if member != nil {
+			continue
+		}
+		if _, err := db.AddTeamMember(ctx, grant.TeamID, user.ID, grant.Role); err != nil {
+			log.Printf("oidc: failed to add user %d to team %d: %v", user.ID, grant.TeamID, err)
+			continue
+		}
+		webhooks.Notify(ctx, db, webhooks.MemberJoined(grant.TeamID, user.ID, grant.Role))
+	}
+}
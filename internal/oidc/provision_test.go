@@ -0,0 +1,113 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"dragonbytelabs/dz/internal/dbx"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestParseGroupTeams(t *testing.T) {
+	got, err := ParseGroupTeams("eng=1:editor, design=2")
+	if err != nil {
+		t.Fatalf("ParseGroupTeams() returned error: %v", err)
+	}
+	if got["eng"] != (TeamGrant{TeamID: 1, Role: "editor"}) {
+		t.Errorf("eng = %+v", got["eng"])
+	}
+	if got["design"] != (TeamGrant{TeamID: 2, Role: "viewer"}) {
+		t.Errorf("design = %+v", got["design"])
+	}
+
+	if _, err := ParseGroupTeams("eng=abc"); err == nil {
+		t.Error("ParseGroupTeams() should reject a non-numeric team id")
+	}
+	for _, role := range []string{"owner", "admn"} {
+		if _, err := ParseGroupTeams("eng=3:" + role); err == nil {
+			t.Errorf("ParseGroupTeams() should reject the role %q", role)
+		}
+	}
+}
+
+func TestProvision(t *testing.T) {
+	db := dbx.SetupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	t.Run("creates a user just in time", func(t *testing.T) {
+		claims := &Claims{Issuer: "https://idp", Subject: "a", Email: "new@example.com", Name: "New"}
+		user, err := Provision(ctx, db, claims, ProvisionOptions{})
+		if err != nil {
+			t.Fatalf("Provision() returned error: %v", err)
+		}
+		if user.Email != "new@example.com" {
+			t.Errorf("user.Email = %q", user.Email)
+		}
+
+		again, err := Provision(ctx, db, claims, ProvisionOptions{})
+		if err != nil {
+			t.Fatalf("Provision() second call returned error: %v", err)
+		}
+		if again.ID != user.ID {
+			t.Errorf("Provision() created a second user %d, want %d", again.ID, user.ID)
+		}
+	})
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	existing, _ := db.CreateUser(ctx, "existing@example.com", string(hash), "Existing")
+
+	t.Run("refuses unverified email links", func(t *testing.T) {
+		claims := &Claims{Issuer: "https://idp", Subject: "b", Email: "existing@example.com"}
+		if _, err := Provision(ctx, db, claims, ProvisionOptions{LinkByEmail: true}); err == nil {
+			t.Error("Provision() should not link an unverified email")
+		}
+	})
+
+	t.Run("links a verified email to the existing user", func(t *testing.T) {
+		claims := &Claims{Issuer: "https://idp", Subject: "b", Email: "existing@example.com", EmailVerified: true}
+		user, err := Provision(ctx, db, claims, ProvisionOptions{LinkByEmail: true})
+		if err != nil {
+			t.Fatalf("Provision() returned error: %v", err)
+		}
+		if user.ID != existing.ID {
+			t.Errorf("Provision() user.ID = %d, want %d", user.ID, existing.ID)
+		}
+	})
+
+	t.Run("maps groups to teams", func(t *testing.T) {
+		res, err := db.DBX.ExecContext(ctx, "INSERT INTO teams (name) VALUES ('Engineering')")
+		if err != nil {
+			t.Fatalf("failed to create team: %v", err)
+		}
+		teamID, _ := res.LastInsertId()
+
+		claims := &Claims{
+			Issuer:  "https://idp",
+			Subject: "c",
+			Email:   "grouped@example.com",
+			Raw:     map[string]any{"groups": []any{"eng", "unmapped"}},
+		}
+		opts := ProvisionOptions{
+			GroupsClaim: "groups",
+			GroupTeams:  map[string]TeamGrant{"eng": {TeamID: teamID, Role: "editor"}},
+		}
+		user, err := Provision(ctx, db, claims, opts)
+		if err != nil {
+			t.Fatalf("Provision() returned error: %v", err)
+		}
+		// A second login must not fail on the existing membership
+		if _, err := Provision(ctx, db, claims, opts); err != nil {
+			t.Fatalf("Provision() second call returned error: %v", err)
+		}
+
+		member, err := db.GetTeamMember(ctx, teamID, user.ID)
+		if err != nil {
+			t.Fatalf("GetTeamMember() returned error: %v", err)
+		}
+		if member == nil || member.Role != "editor" {
+			t.Errorf("GetTeamMember() = %+v, want editor membership", member)
+		}
+	})
+}
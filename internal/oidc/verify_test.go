@@ -0,0 +1,211 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/oidc/oidctest"
+)
+
+func setupProvider(t *testing.T) (*oidctest.Provider, *Provider) {
+	t.Helper()
+
+	fake := oidctest.NewProvider("deez")
+	t.Cleanup(fake.Close)
+
+	p, err := Discover(context.Background(), Config{
+		Issuer:      fake.Issuer(),
+		ClientID:    "deez",
+		RedirectURL: "http://app.test/callback",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Discover() returned error: %v", err)
+	}
+	return fake, p
+}
+
+func TestDiscover(t *testing.T) {
+	fake, p := setupProvider(t)
+
+	if p.Discovery().TokenEndpoint != fake.Issuer()+"/token" {
+		t.Errorf("TokenEndpoint = %q, want %q", p.Discovery().TokenEndpoint, fake.Issuer()+"/token")
+	}
+
+	t.Run("rejects issuer mismatch", func(t *testing.T) {
+		_, err := Discover(context.Background(), Config{Issuer: fake.Issuer() + "/other", ClientID: "deez"}, nil)
+		if err == nil {
+			t.Error("Discover() should fail for an unknown issuer")
+		}
+	})
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	_, p := setupProvider(t)
+
+	u := p.AuthCodeURL("state-1", "nonce-1", CodeChallenge("verifier"))
+	for _, want := range []string{"code_challenge_method=S256", "state=state-1", "nonce=nonce-1", "client_id=deez"} {
+		if !strings.Contains(u, want) {
+			t.Errorf("AuthCodeURL() = %q, missing %q", u, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	fake, p := setupProvider(t)
+	ctx := context.Background()
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   fake.Issuer(),
+			"sub":   "user-1",
+			"aud":   []string{"deez", "other"},
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": "n-1",
+			"email": "user@example.com",
+		}
+	}
+
+	t.Run("accepts a valid token", func(t *testing.T) {
+		claims, err := p.Verify(ctx, fake.SignIDToken(valid()), "n-1")
+		if err != nil {
+			t.Fatalf("Verify() returned error: %v", err)
+		}
+		if claims.Subject != "user-1" || claims.Email != "user@example.com" {
+			t.Errorf("Verify() claims = %+v", claims)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		mutate func(map[string]any)
+		nonce  string
+	}{
+		{"wrong audience", func(c map[string]any) { c["aud"] = "someone-else" }, "n-1"},
+		{"wrong issuer", func(c map[string]any) { c["iss"] = "https://evil.example" }, "n-1"},
+		{"expired", func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "n-1"},
+		{"nonce mismatch", func(c map[string]any) {}, "n-2"},
+		{"missing subject", func(c map[string]any) { delete(c, "sub") }, "n-1"},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.mutate(claims)
+			if _, err := p.Verify(ctx, fake.SignIDToken(claims), tt.nonce); err == nil {
+				t.Error("Verify() should have failed")
+			}
+		})
+	}
+
+	t.Run("rejects a tampered payload", func(t *testing.T) {
+		token := fake.SignIDToken(valid())
+		parts := strings.Split(token, ".")
+		other := strings.Split(fake.SignIDToken(map[string]any{"sub": "admin"}), ".")
+		if _, err := p.Verify(ctx, parts[0]+"."+other[1]+"."+parts[2], "n-1"); err == nil {
+			t.Error("Verify() should reject a token whose payload was swapped")
+		}
+	})
+
+	t.Run("rejects alg none", func(t *testing.T) {
+		token := "eyJhbGciOiJub25lIn0." + strings.Split(fake.SignIDToken(valid()), ".")[1] + "."
+		if _, err := p.Verify(ctx, token, "n-1"); err == nil {
+			t.Error("Verify() should reject unsigned tokens")
+		}
+	})
+}
+
+// countingTransport counts the requests made for a path
+type countingTransport struct {
+	path string
+	n    atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Path == c.path {
+		c.n.Add(1)
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestVerify_JWKSRefetch(t *testing.T) {
+	fake := oidctest.NewProvider("deez")
+	t.Cleanup(fake.Close)
+	fetches := &countingTransport{path: "/jwks"}
+	p, err := Discover(context.Background(), Config{Issuer: fake.Issuer(), ClientID: "deez"}, &http.Client{Transport: fetches})
+	if err != nil {
+		t.Fatalf("Discover() returned error: %v", err)
+	}
+	ctx := context.Background()
+
+	token := fake.SignIDToken(map[string]any{
+		"iss": fake.Issuer(), "sub": "user-1", "aud": "deez", "exp": time.Now().Add(time.Minute).Unix(), "nonce": "n-1",
+	})
+	if _, err := p.Verify(ctx, token, "n-1"); err != nil {
+		t.Fatalf("Verify() returned error: %v", err)
+	}
+	// the same token under key ids the provider never published
+	rest := token[strings.Index(token, "."):]
+	unknown := func(kid string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"`+kid+`"}`)) + rest
+	}
+	for _, kid := range []string{"a", "b", "c", "d"} {
+		if _, err := p.Verify(ctx, unknown(kid), "n-1"); err == nil {
+			t.Errorf("Verify() with kid %q should have failed", kid)
+		}
+	}
+	if n := fetches.n.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+
+	p.mu.Lock()
+	p.fetched = time.Now().Add(-jwksRefetchInterval)
+	p.mu.Unlock()
+	p.Verify(ctx, unknown("e"), "n-1")
+	if n := fetches.n.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times after the interval, want 2", n)
+	}
+	if _, err := p.Verify(ctx, token, "n-1"); err != nil {
+		t.Errorf("Verify() with the cached key returned error: %v", err)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	fake, p := setupProvider(t)
+	ctx := context.Background()
+	fake.Claims = map[string]any{"sub": "user-2", "email": "two@example.com"}
+
+	verifier := RandomString()
+	redirect, err := fake.Authorize(p.AuthCodeURL("s", "n", CodeChallenge(verifier)))
+	if err != nil {
+		t.Fatalf("Authorize() returned error: %v", err)
+	}
+	code := redirect[strings.Index(redirect, "code=")+5 : strings.Index(redirect, "&")]
+
+	t.Run("rejects a wrong verifier", func(t *testing.T) {
+		if _, err := p.Exchange(ctx, code, "not-the-verifier"); err == nil {
+			t.Error("Exchange() should fail PKCE verification")
+		}
+	})
+
+	t.Run("exchanges a code for a verifiable id token", func(t *testing.T) {
+		redirect, _ := fake.Authorize(p.AuthCodeURL("s", "n", CodeChallenge(verifier)))
+		code := redirect[strings.Index(redirect, "code=")+5 : strings.Index(redirect, "&")]
+
+		tok, err := p.Exchange(ctx, code, verifier)
+		if err != nil {
+			t.Fatalf("Exchange() returned error: %v", err)
+		}
+		claims, err := p.Verify(ctx, tok.IDToken, "n")
+		if err != nil {
+			t.Fatalf("Verify() returned error: %v", err)
+		}
+		if claims.Subject != "user-2" {
+			t.Errorf("claims.Subject = %q, want %q", claims.Subject, "user-2")
+		}
+	})
+}
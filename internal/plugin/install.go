@@ -0,0 +1,236 @@
+package plugin
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Limits on what an installed plugin may unpack to
+const (
+	maxFiles     = 10000
+	maxTotalSize = 100 << 20
+)
+
+// Install copies the plugin at src, a directory or a .zip, .tar.gz or .tgz
+// archive, into dir/<id> and returns its manifest. An archive may hold the
+// plugin at its root or in a single top-level folder. An installed plugin
+// with the same id is replaced.
+func Install(src, dir string) (*Manifest, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	// Unpack next to the destination so the final move is a rename
+	staging, err := os.MkdirTemp(dir, ".install-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	unpacked := filepath.Join(staging, "plugin")
+	switch name := strings.ToLower(src); {
+	case info.IsDir():
+		err = copyDir(src, unpacked)
+	case strings.HasSuffix(name, ".zip"):
+		err = unzip(src, unpacked)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		err = untar(src, unpacked)
+	default:
+		return nil, fmt.Errorf("plugin: %s is not a directory, .zip, .tar.gz or .tgz", src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := pluginRoot(unpacked)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ReadManifest(os.DirFS(root))
+	if err != nil {
+		return nil, err
+	}
+	dest := filepath.Join(dir, m.ID)
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(root, dest); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Remove deletes the files of the installed plugin id
+func Remove(dir, id string) error {
+	if !ValidID(id) {
+		return fmt.Errorf("plugin: invalid id %q", id)
+	}
+	return os.RemoveAll(filepath.Join(dir, id))
+}
+
+// pluginRoot returns dir, or its only subfolder when the manifest is there
+func pluginRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+	return "", fmt.Errorf("plugin: missing %s", ManifestFile)
+}
+
+// unpacker writes archive entries below dir within the install limits
+type unpacker struct {
+	dir   string
+	files int
+	size  int64
+}
+
+func (u *unpacker) target(name string) (string, error) {
+	name = strings.TrimPrefix(filepath.FromSlash(name), "./")
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("plugin: archive entry %q escapes the plugin folder", name)
+	}
+	return filepath.Join(u.dir, name), nil
+}
+
+func (u *unpacker) mkdir(name string) error {
+	p, err := u.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, 0o755)
+}
+
+func (u *unpacker) write(name string, r io.Reader) error {
+	if u.files++; u.files > maxFiles {
+		return fmt.Errorf("plugin: archive has more than %d files", maxFiles)
+	}
+	p, err := u.target(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(r, maxTotalSize-u.size+1))
+	if u.size += n; u.size > maxTotalSize {
+		return fmt.Errorf("plugin: archive unpacks to more than %d MB", maxTotalSize>>20)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// unzip extracts regular files and folders; links and other entries are
+// skipped
+func unzip(src, dir string) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	u := &unpacker{dir: dir}
+	for _, f := range zr.File {
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+			err = u.mkdir(f.Name)
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			if rc, err = f.Open(); err == nil {
+				err = u.write(f.Name, rc)
+				rc.Close()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+// untar extracts regular files and folders of a gzipped tar archive; links
+// and other entries are skipped
+func untar(src, dir string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	u := &unpacker{dir: dir}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = u.mkdir(hdr.Name)
+		case tar.TypeReg:
+			err = u.write(hdr.Name, tr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+// copyDir copies the regular files and folders below src to dst, skipping
+// links
+func copyDir(src, dst string) error {
+	u := &unpacker{dir: dst}
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return u.mkdir(rel)
+		case d.Type().IsRegular():
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return u.write(filepath.ToSlash(rel), f)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,177 @@
+// Package plugin reads plugin manifests and installs plugins into the
+// plugins directory.
+//
+// A plugin is a directory with a plugin.json manifest at its root:
+//
+//	{
+//	  "id": "core.kanban",
+//	  "name": "Kanban",
+//	  "version": "1.2.0",
+//	  "description": "Boards from notes",
+//	  "sidebar_icon": "📋",
+//	  "sidebar_title": "Boards",
+//	  "sidebar_link": "/kanban",
+//	  "metadata_schema": [
+//	    {"key": "status", "label": "Status", "type": "select", "options": ["todo", "done"]}
+//	  ],
+//	  "hooks": {"module": "hooks.wasm", "capabilities": ["read_notes"]}
+//	}
+//
+// The id is lower case letters, digits, ".", "_" and "-", and the version a
+// semantic version; the name defaults to the id. The sidebar fields add an
+// entry to the app's sidebar, whose link must be a path such as /kanban.
+// metadata_schema lists the frontmatter keys the plugin adds, in the format
+// of the editor's plugin registry. hooks names a WebAssembly module in the
+// plugin run on the server around note changes, and the capabilities it
+// asks for; see package hooks.
+//
+// Plugins are installed from a directory or a .zip, .tar.gz or .tgz
+// archive holding one, into <plugins path>/<id>, and registered in the
+// database where they can be enabled and disabled.
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// ManifestFile is the name of the manifest at the root of a plugin
+const ManifestFile = "plugin.json"
+
+// Manifest describes a plugin
+type Manifest struct {
+	ID             string               `json:"id"`
+	Name           string               `json:"name"`
+	Version        string               `json:"version"`
+	Description    string               `json:"description"`
+	SidebarIcon    string               `json:"sidebar_icon"`
+	SidebarTitle   string               `json:"sidebar_title"`
+	SidebarLink    string               `json:"sidebar_link"`
+	MetadataSchema []models.PluginField `json:"metadata_schema"`
+	Hooks          *Hooks               `json:"hooks"`
+}
+
+// Hooks declares a plugin's server-side hooks
+type Hooks struct {
+	Module       string   `json:"module"` // path of the .wasm file in the plugin
+	Capabilities []string `json:"capabilities"`
+}
+
+var (
+	validID      = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+	validVersion = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+	validKey     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	fieldTypes   = map[string]bool{
+		models.PluginFieldString:  true,
+		models.PluginFieldNumber:  true,
+		models.PluginFieldBoolean: true,
+		models.PluginFieldDate:    true,
+		models.PluginFieldArray:   true,
+		models.PluginFieldSelect:  true,
+	}
+)
+
+// ValidID reports whether id can name a plugin
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// ReadManifest reads and validates the manifest at the root of fsys
+func ReadManifest(fsys fs.FS) (*Manifest, error) {
+	b, err := fs.ReadFile(fsys, ManifestFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("plugin: missing %s", ManifestFile)
+		}
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("plugin: %s: %w", ManifestFile, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if m.Hooks != nil {
+		if info, err := fs.Stat(fsys, m.Hooks.Module); err != nil || !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("plugin %s: missing hooks module %s", m.ID, m.Hooks.Module)
+		}
+	}
+	return &m, nil
+}
+
+// Validate checks the manifest and fills in the name and field labels
+func (m *Manifest) Validate() error {
+	if !ValidID(m.ID) {
+		return fmt.Errorf("plugin: invalid id %q", m.ID)
+	}
+	if !validVersion.MatchString(m.Version) {
+		return fmt.Errorf("plugin %s: invalid version %q", m.ID, m.Version)
+	}
+	if m.Name = strings.TrimSpace(m.Name); m.Name == "" {
+		m.Name = m.ID
+	}
+	// The link is rendered into the sidebar, so it may only point into the app
+	if m.SidebarLink != "" && (!strings.HasPrefix(m.SidebarLink, "/") ||
+		strings.HasPrefix(m.SidebarLink, "//") || strings.Contains(m.SidebarLink, `\`)) {
+		return fmt.Errorf("plugin %s: sidebar_link must be a path starting with /", m.ID)
+	}
+
+	seen := map[string]bool{}
+	for i := range m.MetadataSchema {
+		f := &m.MetadataSchema[i]
+		if !validKey.MatchString(f.Key) {
+			return fmt.Errorf("plugin %s: invalid metadata key %q", m.ID, f.Key)
+		}
+		if seen[f.Key] {
+			return fmt.Errorf("plugin %s: duplicate metadata key %q", m.ID, f.Key)
+		}
+		seen[f.Key] = true
+		if !fieldTypes[f.Type] {
+			return fmt.Errorf("plugin %s: metadata key %q has unknown type %q", m.ID, f.Key, f.Type)
+		}
+		if f.Type == models.PluginFieldSelect && len(f.Options) == 0 {
+			return fmt.Errorf("plugin %s: select key %q needs options", m.ID, f.Key)
+		}
+		if f.Label == "" {
+			f.Label = f.Key
+		}
+	}
+
+	if h := m.Hooks; h != nil {
+		if !fs.ValidPath(h.Module) || !strings.HasSuffix(h.Module, ".wasm") {
+			return fmt.Errorf("plugin %s: hooks module must be a .wasm file in the plugin", m.ID)
+		}
+		for _, c := range h.Capabilities {
+			if !models.ValidCapability(c) {
+				return fmt.Errorf("plugin %s: unknown capability %q", m.ID, c)
+			}
+		}
+	}
+	return nil
+}
+
+// Plugin returns the registry entry for the manifest
+func (m *Manifest) Plugin() *models.Plugin {
+	p := &models.Plugin{
+		Name:           m.ID,
+		DisplayName:    m.Name,
+		Description:    m.Description,
+		Version:        m.Version,
+		SidebarIcon:    m.SidebarIcon,
+		SidebarTitle:   m.SidebarTitle,
+		SidebarLink:    m.SidebarLink,
+		MetadataSchema: m.MetadataSchema,
+	}
+	if m.Hooks != nil {
+		p.HookModule = m.Hooks.Module
+		p.Capabilities = m.Hooks.Capabilities
+	}
+	return p
+}
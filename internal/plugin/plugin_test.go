@@ -0,0 +1,163 @@
+package plugin
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const kanban = `{"id":"core.kanban","version":"1.2.0","sidebar_link":"/kanban",
+"metadata_schema":[{"key":"status","type":"select","options":["todo","done"]}]}`
+
+func TestReadManifest(t *testing.T) {
+	m, err := ReadManifest(fstest.MapFS{ManifestFile: {Data: []byte(kanban)}})
+	if err != nil {
+		t.Fatalf("ReadManifest() error = %v", err)
+	}
+	if m.Name != "core.kanban" || m.MetadataSchema[0].Label != "status" {
+		t.Errorf("defaults not filled in: %+v", m)
+	}
+	if p := m.Plugin(); p.Name != "core.kanban" || p.DisplayName != "core.kanban" || p.SidebarLink != "/kanban" {
+		t.Errorf("Plugin() = %+v", p)
+	}
+
+	for name, manifest := range map[string]string{
+		"bad json":       `{"id":`,
+		"bad id":         `{"id":"../x","version":"1.0.0"}`,
+		"upper case id":  `{"id":"Kanban","version":"1.0.0"}`,
+		"bad version":    `{"id":"x","version":"latest"}`,
+		"external link":  `{"id":"x","version":"1.0.0","sidebar_link":"https://evil.example"}`,
+		"protocol link":  `{"id":"x","version":"1.0.0","sidebar_link":"//evil.example"}`,
+		"script link":    `{"id":"x","version":"1.0.0","sidebar_link":"javascript:alert(1)"}`,
+		"unknown type":   `{"id":"x","version":"1.0.0","metadata_schema":[{"key":"a","type":"color"}]}`,
+		"select no opts": `{"id":"x","version":"1.0.0","metadata_schema":[{"key":"a","type":"select"}]}`,
+		"duplicate key":  `{"id":"x","version":"1.0.0","metadata_schema":[{"key":"a","type":"string"},{"key":"a","type":"date"}]}`,
+		"hooks outside":  `{"id":"x","version":"1.0.0","hooks":{"module":"../hooks.wasm"}}`,
+		"hooks missing":  `{"id":"x","version":"1.0.0","hooks":{"module":"missing.wasm"}}`,
+		"unknown cap":    `{"id":"x","version":"1.0.0","hooks":{"module":"hooks.wasm","capabilities":["network"]}}`,
+	} {
+		fsys := fstest.MapFS{ManifestFile: {Data: []byte(manifest)}, "hooks.wasm": {Data: []byte("\x00asm")}}
+		if _, err := ReadManifest(fsys); err == nil {
+			t.Errorf("ReadManifest(%s) succeeded", name)
+		}
+	}
+	m, err = ReadManifest(fstest.MapFS{
+		ManifestFile:     {Data: []byte(`{"id":"x","version":"1.0.0","hooks":{"module":"bin/hooks.wasm","capabilities":["read_notes"]}}`)},
+		"bin/hooks.wasm": {Data: []byte("\x00asm")},
+	})
+	if err != nil {
+		t.Fatalf("ReadManifest(hooks) error = %v", err)
+	}
+	if p := m.Plugin(); p.HookModule != "bin/hooks.wasm" || !p.Capabilities.Has("read_notes") {
+		t.Errorf("plugin with hooks = %+v", p)
+	}
+	if _, err := ReadManifest(fstest.MapFS{}); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("ReadManifest(empty) error = %v", err)
+	}
+}
+
+func writeZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "plugin.zip")
+	f, _ := os.Create(p)
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	f.Close()
+	return p
+}
+
+func TestInstall(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "plugins")
+	read := func(rel string) string {
+		b, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		return string(b)
+	}
+
+	t.Run("from a directory", func(t *testing.T) {
+		src := t.TempDir()
+		os.WriteFile(filepath.Join(src, ManifestFile), []byte(kanban), 0o644)
+		os.MkdirAll(filepath.Join(src, "assets"), 0o755)
+		os.WriteFile(filepath.Join(src, "assets", "main.js"), []byte("v1"), 0o644)
+		m, err := Install(src, dir)
+		if err != nil || m.ID != "core.kanban" {
+			t.Fatalf("Install() = %+v, %v", m, err)
+		}
+		if read("core.kanban/assets/main.js") != "v1" {
+			t.Error("plugin files were not copied")
+		}
+	})
+
+	t.Run("from a zip with a top-level folder, replacing", func(t *testing.T) {
+		src := writeZip(t, map[string]string{
+			"kanban-1.3/" + ManifestFile: strings.Replace(kanban, "1.2.0", "1.3.0", 1),
+			"kanban-1.3/assets/main.js":  "v2",
+		})
+		m, err := Install(src, dir)
+		if err != nil || m.Version != "1.3.0" {
+			t.Fatalf("Install() = %+v, %v", m, err)
+		}
+		if read("core.kanban/assets/main.js") != "v2" {
+			t.Error("plugin was not replaced")
+		}
+	})
+
+	t.Run("from a tarball", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "plugin.tgz")
+		f, _ := os.Create(src)
+		gz := gzip.NewWriter(f)
+		tw := tar.NewWriter(gz)
+		manifest := `{"id":"notes.stats","version":"0.1.0"}`
+		tw.WriteHeader(&tar.Header{Name: "./" + ManifestFile, Mode: 0o644, Size: int64(len(manifest)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(manifest))
+		tw.WriteHeader(&tar.Header{Name: "link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink})
+		tw.Close()
+		gz.Close()
+		f.Close()
+		if m, err := Install(src, dir); err != nil || m.ID != "notes.stats" {
+			t.Fatalf("Install() = %+v, %v", m, err)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "notes.stats", "link")); err == nil {
+			t.Error("symlink was unpacked")
+		}
+	})
+
+	t.Run("rejects bad archives", func(t *testing.T) {
+		for name, files := range map[string]map[string]string{
+			"zip slip":       {ManifestFile: kanban, "../../evil.txt": "x"},
+			"no manifest":    {"readme.md": "x"},
+			"invalid plugin": {ManifestFile: `{"id":"x"}`},
+		} {
+			if _, err := Install(writeZip(t, files), dir); err == nil {
+				t.Errorf("Install(%s) succeeded", name)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil.txt")); err == nil {
+			t.Error("zip slip wrote outside the plugins folder")
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 2 {
+			t.Errorf("plugins folder has %d entries after failed installs, want 2", len(entries))
+		}
+	})
+
+	t.Run("removes", func(t *testing.T) {
+		if err := Remove(dir, "core.kanban"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "core.kanban")); err == nil {
+			t.Error("plugin folder still exists")
+		}
+		if err := Remove(dir, ".."); err == nil {
+			t.Error("Remove(..) succeeded")
+		}
+	})
+}
@@ -0,0 +1,138 @@
+// Package posts implements the publishing workflow of site posts: slugs,
+// draft, scheduled and published states, promotion of vault notes and the
+// scheduler that publishes posts when their time comes.
+package posts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"dragonbytelabs/dz/internal/markdown"
+	"dragonbytelabs/dz/internal/models"
+)
+
+// maxSlugLen keeps generated slugs readable in URLs
+const maxSlugLen = 80
+
+// Slugify turns a title into a URL slug, e.g. "Hello, World!" → "hello-world"
+func Slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		default:
+			dash = true
+		}
+		if b.Len() >= maxSlugLen {
+			break
+		}
+	}
+	slug := strings.TrimRight(b.String(), "-")
+	if slug == "" {
+		return "post"
+	}
+	return slug
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Prepare fills in defaults and checks a post before it is saved: a slug
+// from the title, the publish time of published posts, and a future
+// publish time for scheduled ones.
+func Prepare(p *models.Post, now time.Time) error {
+	p.Title = strings.TrimSpace(p.Title)
+	if p.Title == "" {
+		return errors.New("title required")
+	}
+	if p.Slug = strings.TrimSpace(p.Slug); p.Slug == "" {
+		p.Slug = Slugify(p.Title)
+	} else {
+		p.Slug = Slugify(p.Slug)
+	}
+
+	if p.Status == "" {
+		p.Status = models.PostDraft
+	}
+	if p.Visibility == "" {
+		p.Visibility = models.PostPublic
+	}
+	if p.Format == "" {
+		p.Format = models.FormatMarkdown
+	}
+	if !oneOf(p.Status, models.PostDraft, models.PostScheduled, models.PostPublished) {
+		return fmt.Errorf("invalid status %q", p.Status)
+	}
+	if !oneOf(p.Visibility, models.PostPublic, models.PostUnlisted, models.PostPrivate) {
+		return fmt.Errorf("invalid visibility %q", p.Visibility)
+	}
+	if !oneOf(p.Format, models.FormatMarkdown, models.FormatHTML) {
+		return fmt.Errorf("invalid format %q", p.Format)
+	}
+
+	switch p.Status {
+	case models.PostScheduled:
+		if p.PublishAt == nil || !p.PublishAt.After(now) {
+			return errors.New("scheduled posts need a publish_at in the future")
+		}
+	case models.PostPublished:
+		if p.PublishAt == nil {
+			p.PublishAt = &now
+		} else if p.PublishAt.After(now) {
+			return errors.New("publish_at is in the future; schedule the post instead")
+		}
+	}
+	return nil
+}
+
+// FromNote turns a vault note into a post: the frontmatter title (or the
+// file name) becomes the title and the markdown body the content. The
+// frontmatter keys slug and excerpt (or description) are used if present.
+func FromNote(notePath, content string) (models.Post, error) {
+	note, err := markdown.Parse([]byte(content))
+	if err != nil {
+		return models.Post{}, fmt.Errorf("frontmatter: %w", err)
+	}
+	p := models.Post{
+		Title:   note.Frontmatter.Title,
+		Content: strings.TrimSpace(string(note.Body)) + "\n",
+		Format:  models.FormatMarkdown,
+	}
+	if p.Title == "" {
+		p.Title = strings.TrimSuffix(path.Base(notePath), path.Ext(notePath))
+	}
+	slug, _ := note.Frontmatter.Extra["slug"].(string)
+	p.Slug = strings.TrimSpace(slug)
+	p.Excerpt = note.Frontmatter.Summary()
+	return p, nil
+}
+
+// Render returns the HTML of a post. Markdown posts render like notes, with
+// wiki links as plain text; HTML posts are returned as written.
+func Render(p *models.Post) (string, error) {
+	if p.Format == models.FormatHTML {
+		return p.Content, nil
+	}
+	var buf bytes.Buffer
+	if err := markdown.Render(&buf, []byte(p.Content), markdown.Options{}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
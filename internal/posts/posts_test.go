@@ -0,0 +1,118 @@
+package posts
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Hello, World!":          "hello-world",
+		"  Go 1.24 -- released ": "go-1-24-released",
+		"Ünïcode Straße":         "ünïcode-straße",
+		"!!!":                    "post",
+		strings.Repeat("a", 200): strings.Repeat("a", maxSlugLen),
+	}
+	for in, want := range tests {
+		if got := Slugify(in); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+
+	p := models.Post{Title: " My First Post "}
+	if err := Prepare(&p, now); err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "My First Post" || p.Slug != "my-first-post" || p.Status != models.PostDraft ||
+		p.Visibility != models.PostPublic || p.Format != models.FormatMarkdown || p.PublishAt != nil {
+		t.Errorf("defaults = %+v", p)
+	}
+
+	p = models.Post{Title: "x", Slug: "Custom Slug", Status: models.PostPublished}
+	if err := Prepare(&p, now); err != nil || p.Slug != "custom-slug" || !p.PublishAt.Equal(now) {
+		t.Errorf("published = %+v, %v", p, err)
+	}
+
+	for name, bad := range map[string]models.Post{
+		"no title":            {},
+		"unknown status":      {Title: "x", Status: "live"},
+		"unknown visibility":  {Title: "x", Visibility: "secret"},
+		"unknown format":      {Title: "x", Format: "docx"},
+		"scheduled in past":   {Title: "x", Status: models.PostScheduled, PublishAt: &earlier},
+		"scheduled no time":   {Title: "x", Status: models.PostScheduled},
+		"published in future": {Title: "x", Status: models.PostPublished, PublishAt: &later},
+	} {
+		if err := Prepare(&bad, now); err == nil {
+			t.Errorf("Prepare(%s) succeeded", name)
+		}
+	}
+}
+
+func TestFromNote(t *testing.T) {
+	p, err := FromNote("blog/launch-day.md", "---\ntags: [news]\nslug: we-launched\ndescription: The big day\n---\n\n# Launch\n\nIt shipped.\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "launch-day" || p.Slug != "we-launched" || p.Excerpt != "The big day" ||
+		p.Content != "# Launch\n\nIt shipped.\n" || p.Format != models.FormatMarkdown {
+		t.Errorf("FromNote() = %+v", p)
+	}
+
+	p, _ = FromNote("x.md", "---\ntitle: Titled\nexcerpt: Short\ndescription: Long\n---\nbody")
+	if p.Title != "Titled" || p.Excerpt != "Short" || p.Slug != "" {
+		t.Errorf("FromNote() with title = %+v", p)
+	}
+}
+
+func TestRender(t *testing.T) {
+	html, _ := Render(&models.Post{Format: models.FormatMarkdown, Content: "Hi **there** [[note]] <script>x</script>"})
+	if !strings.Contains(html, "<strong>there</strong>") || strings.Contains(html, "<script>") || strings.Contains(html, "internal-link") {
+		t.Errorf("Render(markdown) = %q", html)
+	}
+	if html, _ := Render(&models.Post{Format: models.FormatHTML, Content: "<b>raw</b>"}); html != "<b>raw</b>" {
+		t.Errorf("Render(html) = %q", html)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	db := dbx.SetupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	now := time.Now()
+	due, notYet := now.Add(-time.Minute), now.Add(time.Hour)
+	db.CreatePost(ctx, &models.Post{Title: "Due", Slug: "due", Status: models.PostScheduled, Visibility: models.PostPublic, Format: models.FormatMarkdown, PublishAt: &due})
+	db.CreatePost(ctx, &models.Post{Title: "Later", Slug: "later", Status: models.PostScheduled, Visibility: models.PostPublic, Format: models.FormatMarkdown, PublishAt: &notYet})
+	db.CreatePost(ctx, &models.Post{Title: "Draft", Slug: "draft", Status: models.PostDraft, Visibility: models.PostPublic, Format: models.FormatMarkdown, PublishAt: &due})
+
+	s := NewScheduler(db, 0)
+	s.now = func() time.Time { return now }
+	published, err := s.PublishDue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 1 || published[0].Slug != "due" || published[0].Status != models.PostPublished {
+		t.Errorf("PublishDue() = %+v", published)
+	}
+	if again, _ := s.PublishDue(ctx); len(again) != 0 {
+		t.Errorf("second PublishDue() = %d posts, want 0", len(again))
+	}
+
+	s.now = func() time.Time { return notYet.Add(time.Second) }
+	if published, _ := s.PublishDue(ctx); len(published) != 1 || published[0].Slug != "later" {
+		t.Errorf("PublishDue() an hour later = %+v", published)
+	}
+	events, _ := db.GetAuditEvents(ctx, models.AuditFilter{Action: models.AuditPostPublished})
+	if len(events) != 2 {
+		t.Errorf("published audit events = %d, want 2", len(events))
+	}
+}
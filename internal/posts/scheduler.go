@@ -0,0 +1,50 @@
+package posts
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"dragonbytelabs/dz/internal/audit"
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/webhooks"
+)
+
+// Scheduler publishes scheduled posts once their publish_at has passed
+type Scheduler struct {
+	db  *dbx.DB
+	now func() time.Time
+}
+
+// NewScheduler creates a scheduler and, if interval is positive, starts
+// checking for due posts every interval
+func NewScheduler(db *dbx.DB, interval time.Duration) *Scheduler {
+	s := &Scheduler{db: db, now: time.Now}
+	if interval > 0 {
+		go s.run(interval)
+	}
+	return s
+}
+
+func (s *Scheduler) run(d time.Duration) {
+	ticker := time.NewTicker(d)
+	for ; ; <-ticker.C {
+		if _, err := s.PublishDue(context.Background()); err != nil {
+			log.Printf("posts: publishing scheduled posts failed: %v", err)
+		}
+	}
+}
+
+// PublishDue publishes every scheduled post that is due and returns them
+func (s *Scheduler) PublishDue(ctx context.Context) ([]models.Post, error) {
+	published, err := s.db.PublishDuePosts(ctx, s.now())
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range published {
+		audit.Log(ctx, s.db, models.AuditEvent{Action: models.AuditPostPublished, Target: p.Slug, Details: "scheduled"})
+		webhooks.Notify(ctx, s.db, webhooks.PostPublished(&p))
+	}
+	return published, nil
+}
@@ -0,0 +1,646 @@
+// Package publish turns the notes of a vault marked `publish: true` into a
+// site with per-note pages, a tag index, backlinks and RSS, Atom and JSON
+// feeds, rendered through a theme. Write exports it as static HTML;
+// RenderFile serves it live.
+package publish
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/feed"
+	"dragonbytelabs/dz/internal/markdown"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/posts"
+	"dragonbytelabs/dz/internal/theme"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// ErrNotFound is returned by RenderFile for paths that aren't on the site
+var ErrNotFound = errors.New("publish: not found")
+
+// Feed content modes
+const (
+	FeedFull    = "full"    // entries carry the whole page
+	FeedExcerpt = "excerpt" // entries carry the summary only
+)
+
+// Options configures a site
+type Options struct {
+	Title string       // defaults to "Notes"
+	Theme *theme.Theme // defaults to the built-in theme
+	// BaseURL is the public URL of the site root, e.g.
+	// "https://example.com/notes/". Feeds need it for absolute links;
+	// without it their links are relative to the site root.
+	BaseURL     string
+	FeedContent string // FeedFull (the default) or FeedExcerpt
+	FeedLimit   int    // entries per feed, defaults to 50
+	// Posts are published posts to put on the site next to the notes
+	Posts []models.Post
+}
+
+// Page is a published note or post
+type Page struct {
+	Path        string // vault path of the note; empty for posts
+	URL         string // site-relative path of the page
+	Title       string
+	Summary     string // frontmatter excerpt or description, else the first paragraph
+	Tags        []*Tag
+	Frontmatter markdown.Frontmatter
+	Content     template.HTML
+	Backlinks   []*Page // published notes linking here, by title
+	Modified    time.Time
+	Published   time.Time // frontmatter created, else Updated
+	Updated     time.Time // frontmatter updated, else Modified
+
+	feedContent template.HTML // Content with links relative to the base URL
+}
+
+// Tag lists the pages carrying a tag
+type Tag struct {
+	Name  string
+	URL   string
+	Pages []*Page
+}
+
+// Site is a rendered set of published notes
+type Site struct {
+	Title string
+	URL   string  // Options.BaseURL
+	Pages []*Page // by title
+	Posts []*Page // newest first
+	Tags  []*Tag  // by name
+
+	vault  *vault.Vault
+	theme  *theme.Theme
+	opts   Options
+	assets map[string]bool // vault paths of files the pages reference
+	feeds  []*feedSet
+}
+
+// feedSet is the pages behind one set of feeds, one file per format
+type feedSet struct {
+	dir   string // site-relative folder of the feed files, "" for the root
+	title string
+	link  string // site-relative page the feeds belong to
+	pages []*Page
+}
+
+// PageData is what theme templates execute with. Root is the relative
+// path from the current page back to the site root, e.g. "../../".
+type PageData struct {
+	Site *Site
+	Page *Page // set for note pages
+	Tag  *Tag  // set for tag pages
+	Root string
+}
+
+// Site layout
+const (
+	notesDir  = "notes/"
+	postsDir  = "posts/"
+	assetsDir = "assets/"
+	tagsDir   = "tags/"
+	tagsIndex = "tags.html"
+)
+
+// Build reads the vault and renders every published note
+func Build(ctx context.Context, v *vault.Vault, opts Options) (*Site, error) {
+	if opts.Title == "" {
+		opts.Title = "Notes"
+	}
+	if opts.Theme == nil {
+		opts.Theme = theme.Default()
+	}
+	if opts.FeedContent == "" {
+		opts.FeedContent = FeedFull
+	}
+	if opts.FeedContent != FeedFull && opts.FeedContent != FeedExcerpt {
+		return nil, fmt.Errorf("publish: unknown feed content %q", opts.FeedContent)
+	}
+	if opts.FeedLimit <= 0 {
+		opts.FeedLimit = 50
+	}
+	if opts.BaseURL != "" && !strings.HasSuffix(opts.BaseURL, "/") {
+		opts.BaseURL += "/"
+	}
+	files, err := v.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Site{Title: opts.Title, URL: opts.BaseURL, vault: v, theme: opts.Theme, opts: opts, assets: map[string]bool{}}
+	b := &builder{
+		site:   s,
+		index:  markdown.NewIndex(),
+		pages:  map[string]*Page{},
+		bodies: map[string][]byte{},
+		tags:   map[*Page][]string{},
+	}
+	for _, f := range files {
+		if !strings.EqualFold(path.Ext(f.Path), ".md") {
+			b.files = append(b.files, f.Path)
+			continue
+		}
+		res, err := v.ReadFile(ctx, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		note, err := markdown.Parse([]byte(res.Content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: frontmatter: %w", f.Path, err)
+		}
+		b.index.Add(f.Path, note.Frontmatter)
+		if !note.Frontmatter.Publish {
+			continue
+		}
+
+		title := note.Frontmatter.Title
+		if title == "" {
+			title = strings.TrimSuffix(path.Base(f.Path), path.Ext(f.Path))
+		}
+		p := &Page{
+			Path:        f.Path,
+			URL:         notesDir + strings.TrimSuffix(f.Path, path.Ext(f.Path)) + ".html",
+			Title:       title,
+			Summary:     note.Frontmatter.Summary(),
+			Frontmatter: note.Frontmatter,
+			Modified:    f.MTime,
+			Updated:     f.MTime,
+		}
+		if p.Summary == "" {
+			p.Summary = markdown.Excerpt(note.Body, maxSummary)
+		}
+		if t, ok := note.Frontmatter.UpdatedAt(); ok {
+			p.Updated = t
+		}
+		p.Published = p.Updated
+		if t, ok := note.Frontmatter.CreatedAt(); ok {
+			p.Published = t
+		}
+		b.pages[f.Path] = p
+		b.bodies[f.Path] = note.Body
+		b.tags[p] = note.Tags()
+		s.Pages = append(s.Pages, p)
+	}
+	sort.Strings(b.files)
+	sort.Slice(s.Pages, func(i, j int) bool { return s.Pages[i].Title < s.Pages[j].Title })
+
+	tags := map[string]*Tag{}
+	tagURLs := map[string]bool{}
+	for _, p := range s.Pages {
+		if err := b.render(p); err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Path, err)
+		}
+		for _, name := range b.tags[p] {
+			t := tags[name]
+			if t == nil {
+				base := tagSlug(name)
+				slug := base
+				for n := 2; tagURLs[slug]; n++ {
+					slug = fmt.Sprintf("%s-%d", base, n)
+				}
+				tagURLs[slug] = true
+				t = &Tag{Name: name, URL: tagsDir + slug + ".html"}
+				tags[name] = t
+				s.Tags = append(s.Tags, t)
+			}
+			t.Pages = append(t.Pages, p)
+			p.Tags = append(p.Tags, t)
+		}
+	}
+	sort.Slice(s.Tags, func(i, j int) bool { return s.Tags[i].Name < s.Tags[j].Name })
+	for _, p := range s.Pages {
+		sort.Slice(p.Backlinks, func(i, j int) bool { return p.Backlinks[i].Title < p.Backlinks[j].Title })
+	}
+	for _, post := range opts.Posts {
+		p, err := postPage(&post)
+		if err != nil {
+			return nil, fmt.Errorf("post %s: %w", post.Slug, err)
+		}
+		s.Posts = append(s.Posts, p)
+	}
+	sort.SliceStable(s.Posts, func(i, j int) bool { return s.Posts[i].Published.After(s.Posts[j].Published) })
+	s.addFeeds()
+	return s, nil
+}
+
+// tagUnsafe matches what can't appear in an inline #tag
+var tagUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// tagSlug turns a tag name into its path under tags/: anything an inline
+// #tag couldn't contain becomes a dash and empty segments are dropped, so
+// frontmatter tags like "../x" or "a b?" stay inside the folder and linkable
+func tagSlug(name string) string {
+	var segs []string
+	for _, seg := range strings.Split(name, "/") {
+		if seg = strings.Trim(tagUnsafe.ReplaceAllString(seg, "-"), "-"); seg != "" {
+			segs = append(segs, seg)
+		}
+	}
+	if len(segs) == 0 {
+		return "tag"
+	}
+	return strings.Join(segs, "/")
+}
+
+// maxSummary caps summaries taken from the first paragraph of a page
+const maxSummary = 280
+
+// postPage turns a post into a page of the site
+func postPage(post *models.Post) (*Page, error) {
+	html, err := posts.Render(post)
+	if err != nil {
+		return nil, err
+	}
+	p := &Page{
+		URL:         postsDir + post.Slug + ".html",
+		Title:       post.Title,
+		Summary:     post.Excerpt,
+		Content:     template.HTML(html),
+		Modified:    post.UpdatedAt,
+		Updated:     post.UpdatedAt,
+		Published:   post.UpdatedAt,
+		feedContent: template.HTML(html),
+	}
+	if post.PublishAt != nil {
+		p.Published = *post.PublishAt
+	}
+	if p.Summary == "" && post.Format == models.FormatMarkdown {
+		p.Summary = markdown.Excerpt([]byte(post.Content), maxSummary)
+	}
+	return p, nil
+}
+
+// addFeeds sets up the feeds of the site: everything at the root, then
+// one per tag and one per folder of notes, including its subfolders
+func (s *Site) addFeeds() {
+	all := append(append([]*Page{}, s.Pages...), s.Posts...)
+	s.feeds = append(s.feeds, &feedSet{title: s.Title, link: "index.html", pages: all})
+	for _, t := range s.Tags {
+		s.feeds = append(s.feeds, &feedSet{
+			dir:   strings.TrimSuffix(t.URL, ".html") + "/",
+			title: s.Title + " · #" + t.Name,
+			link:  t.URL,
+			pages: t.Pages,
+		})
+	}
+	folders := map[string]*feedSet{}
+	for _, p := range s.Pages {
+		for dir := path.Dir(p.Path); dir != "."; dir = path.Dir(dir) {
+			f := folders[dir]
+			if f == nil {
+				f = &feedSet{dir: notesDir + dir + "/", title: s.Title + " · " + dir, link: "index.html"}
+				folders[dir] = f
+			}
+			f.pages = append(f.pages, p)
+		}
+	}
+	dirs := make([]string, 0, len(folders))
+	for dir := range folders {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		s.feeds = append(s.feeds, folders[dir])
+	}
+}
+
+// builder holds the state of one Build
+type builder struct {
+	site   *Site
+	index  *markdown.Index
+	pages  map[string]*Page   // published notes by vault path
+	bodies map[string][]byte  // their markdown
+	tags   map[*Page][]string // their tag names
+	files  []string           // vault paths of everything that isn't a note
+}
+
+// render renders a page's content with links relative to the page and
+// records it as a backlink of the pages it links to. Feeds get a copy
+// with links relative to the base URL.
+func (b *builder) render(p *Page) error {
+	content, linked, err := b.renderLinks(p, rootOf(p.URL))
+	if err != nil {
+		return err
+	}
+	p.Content = content
+	if p.feedContent, _, err = b.renderLinks(p, b.site.URL); err != nil {
+		return err
+	}
+	for target := range linked {
+		target.Backlinks = append(target.Backlinks, p)
+	}
+	return nil
+}
+
+// renderLinks renders a page's content with links starting at root,
+// returning the published pages it links to
+func (b *builder) renderLinks(p *Page, root string) (template.HTML, map[*Page]bool, error) {
+	dir := path.Dir(p.Path)
+	linked := map[*Page]bool{}
+
+	noteHref := func(target *Page, heading string) string {
+		if target != p {
+			linked[target] = true
+		}
+		href := root + target.URL
+		if heading != "" {
+			href += "#" + markdown.HeadingID(heading)
+		}
+		return href
+	}
+
+	opts := markdown.Options{
+		WikiLink: func(l markdown.Link) (string, bool) {
+			if !strings.EqualFold(path.Ext(l.Target), ".md") {
+				if asset, ok := b.findAsset(dir, l.Target); ok {
+					return root + assetsDir + asset, true
+				}
+			}
+			notePath, ok := b.index.Resolve(l.Target)
+			if target := b.pages[notePath]; ok && target != nil {
+				return noteHref(target, l.Heading), true
+			}
+			return "", false
+		},
+		URL: func(dest string, image bool) (string, bool) {
+			if markdown.IsExternal(dest) {
+				return dest, true
+			}
+			rel, fragment, _ := strings.Cut(dest, "#")
+			if unescaped, err := url.PathUnescape(rel); err == nil {
+				rel = unescaped
+			}
+			if strings.EqualFold(path.Ext(rel), ".md") {
+				notePath, _ := b.index.ResolveLink(p.Path, markdown.Link{Kind: markdown.LinkMarkdown, Target: rel})
+				if target := b.pages[notePath]; target != nil {
+					return noteHref(target, fragment), true
+				}
+				return "", false
+			}
+			if asset, ok := b.findAsset(dir, rel); ok {
+				return root + assetsDir + asset, true
+			}
+			return dest, true
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := markdown.Render(&buf, b.bodies[p.Path], opts); err != nil {
+		return "", nil, err
+	}
+	return template.HTML(buf.String()), linked, nil
+}
+
+// findAsset resolves a non-note file reference: a path relative to the
+// note's folder, a vault path, or a bare file name anywhere in the vault
+func (b *builder) findAsset(dir, ref string) (string, bool) {
+	candidates := []string{path.Join(dir, ref), strings.TrimPrefix(path.Clean("/"+ref), "/")}
+	for _, c := range candidates {
+		i := sort.SearchStrings(b.files, c)
+		if i < len(b.files) && b.files[i] == c {
+			b.site.assets[c] = true
+			return c, true
+		}
+	}
+	if !strings.Contains(ref, "/") {
+		for _, f := range b.files {
+			if path.Base(f) == ref {
+				b.site.assets[f] = true
+				return f, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Files lists the site-relative paths of every file on the site
+func (s *Site) Files() ([]string, error) {
+	files := []string{"index.html", tagsIndex}
+	for _, t := range s.Tags {
+		files = append(files, t.URL)
+	}
+	for _, p := range s.Pages {
+		files = append(files, p.URL)
+	}
+	for _, p := range s.Posts {
+		files = append(files, p.URL)
+	}
+	for _, f := range s.feeds {
+		for _, format := range feed.Formats {
+			files = append(files, f.dir+format.File)
+		}
+	}
+	static, err := s.theme.StaticFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range static {
+		files = append(files, theme.StaticDir+"/"+f)
+	}
+	assets := make([]string, 0, len(s.assets))
+	for a := range s.assets {
+		assets = append(assets, assetsDir+a)
+	}
+	sort.Strings(assets)
+	return append(files, assets...), nil
+}
+
+// RenderFile writes the site file at the site-relative path rel to w
+func (s *Site) RenderFile(ctx context.Context, rel string, w io.Writer) error {
+	page := func(name string, data PageData) error {
+		data.Site = s
+		data.Root = rootOf(rel)
+		var buf bytes.Buffer
+		if err := s.theme.Render(&buf, name, data); err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		_, err := buf.WriteTo(w)
+		return err
+	}
+
+	if f, format, ok := s.feedAt(rel); ok {
+		b, err := format.Render(s.feed(f, format))
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		_, err = w.Write(b)
+		return err
+	}
+
+	switch {
+	case rel == "index.html":
+		return page(theme.PageIndex, PageData{})
+	case rel == tagsIndex:
+		return page(theme.PageTags, PageData{})
+	case strings.HasPrefix(rel, tagsDir):
+		for _, t := range s.Tags {
+			if t.URL == rel {
+				return page(theme.PageTag, PageData{Tag: t})
+			}
+		}
+	case strings.HasPrefix(rel, notesDir):
+		for _, p := range s.Pages {
+			if p.URL == rel {
+				return page(theme.PageNote, PageData{Page: p})
+			}
+		}
+	case strings.HasPrefix(rel, postsDir):
+		for _, p := range s.Posts {
+			if p.URL == rel {
+				return page(theme.PageNote, PageData{Page: p})
+			}
+		}
+	case strings.HasPrefix(rel, assetsDir):
+		asset := strings.TrimPrefix(rel, assetsDir)
+		if !s.assets[asset] {
+			return ErrNotFound
+		}
+		res, err := s.vault.ReadFile(ctx, asset)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, res.Content)
+		return err
+	case strings.HasPrefix(rel, theme.StaticDir+"/"):
+		b, err := s.theme.StaticFile(strings.TrimPrefix(rel, theme.StaticDir+"/"))
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	return ErrNotFound
+}
+
+// ContentType returns the media type of the site file at rel
+func (s *Site) ContentType(rel string) string {
+	if format, ok := feed.ByFile(path.Base(rel)); ok {
+		return format.ContentType
+	}
+	if t := mime.TypeByExtension(path.Ext(rel)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// ModTime returns when the content of the site file at rel last changed,
+// or the zero time if that isn't known
+func (s *Site) ModTime(rel string) time.Time {
+	if f, _, ok := s.feedAt(rel); ok {
+		return latest(f.pages)
+	}
+	for _, pages := range [][]*Page{s.Pages, s.Posts} {
+		for _, p := range pages {
+			if p.URL == rel {
+				return p.Updated
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// feedAt finds the feed file at rel
+func (s *Site) feedAt(rel string) (*feedSet, feed.Format, bool) {
+	format, ok := feed.ByFile(path.Base(rel))
+	if !ok {
+		return nil, feed.Format{}, false
+	}
+	for _, f := range s.feeds {
+		if f.dir+format.File == rel {
+			return f, format, true
+		}
+	}
+	return nil, feed.Format{}, false
+}
+
+// latest returns the newest update time of pages
+func latest(pages []*Page) time.Time {
+	var t time.Time
+	for _, p := range pages {
+		if p.Updated.After(t) {
+			t = p.Updated
+		}
+	}
+	return t
+}
+
+// feed builds the entries of a feed set, newest first
+func (s *Site) feed(f *feedSet, format feed.Format) *feed.Feed {
+	pages := append([]*Page{}, f.pages...)
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Updated.After(pages[j].Updated) })
+	if len(pages) > s.opts.FeedLimit {
+		pages = pages[:s.opts.FeedLimit]
+	}
+
+	out := &feed.Feed{
+		Title:   f.title,
+		Link:    s.URL + f.link,
+		FeedURL: s.URL + f.dir + format.File,
+		Updated: latest(pages),
+	}
+	for _, p := range pages {
+		item := feed.Item{
+			Title:     p.Title,
+			Link:      s.URL + p.URL,
+			Summary:   p.Summary,
+			Published: p.Published,
+			Updated:   p.Updated,
+		}
+		if s.opts.FeedContent == FeedFull {
+			item.Content = string(p.feedContent)
+		}
+		for _, t := range p.Tags {
+			item.Tags = append(item.Tags, t.Name)
+		}
+		out.Items = append(out.Items, item)
+	}
+	return out
+}
+
+// Write renders the site into dir, creating it if needed
+func (s *Site) Write(ctx context.Context, dir string) error {
+	files, err := s.Files()
+	if err != nil {
+		return err
+	}
+	for _, rel := range files {
+		if !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return fmt.Errorf("%s: outside the site", rel)
+		}
+		var buf bytes.Buffer
+		if err := s.RenderFile(ctx, rel, &buf); err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rootOf returns the relative path from a site-relative page to the root
+func rootOf(rel string) string {
+	return strings.Repeat("../", strings.Count(rel, "/"))
+}
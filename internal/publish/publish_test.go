@@ -0,0 +1,272 @@
+package publish
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func writeVault(t *testing.T, files map[string]string) *vault.Vault {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := vault.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestPublish(t *testing.T) {
+	v := writeVault(t, map[string]string{
+		"home.md": "---\ntitle: Home\npublish: true\ntags: [intro]\n---\n" +
+			"Read [[Deep Note#Part Two|part two]], skip [[Secret]] and [the draft](drafts/draft.md).\n\n![[diagram.png]]\n",
+		"topics/deep.md":       "---\ntitle: Deep Note\npublish: true\n---\n# Part Two\n\nBack [home](../home.md). #topic\n\n![photo](img/photo.jpg)\n",
+		"secret.md":            "---\ntitle: Secret\n---\nprivate\n",
+		"drafts/draft.md":      "no frontmatter at all\n",
+		"media/diagram.png":    "png-bytes",
+		"topics/img/photo.jpg": "jpg-bytes",
+		"unused.png":           "unused",
+	})
+
+	ctx := context.Background()
+	site, err := Build(ctx, v, Options{Title: "My Site"})
+	if err != nil {
+		t.Fatalf("Build() returned error: %v", err)
+	}
+	if len(site.Pages) != 2 {
+		t.Fatalf("Build() published %d pages, want 2", len(site.Pages))
+	}
+
+	out := t.TempDir()
+	if err := site.Write(ctx, out); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	read := func(rel string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("missing %s: %v", rel, err)
+		}
+		return string(b)
+	}
+
+	home := read("notes/home.html")
+	deep := read("notes/topics/deep.html")
+
+	t.Run("links published notes relatively", func(t *testing.T) {
+		if !strings.Contains(home, `href="../notes/topics/deep.html#part-two">part two</a>`) {
+			t.Errorf("home page missing link to deep note:\n%s", home)
+		}
+		if !strings.Contains(deep, `href="../../notes/home.html">home</a>`) {
+			t.Errorf("deep page missing link home:\n%s", deep)
+		}
+	})
+
+	t.Run("renders links to unpublished notes as text", func(t *testing.T) {
+		if !strings.Contains(home, "skip Secret and the draft.") {
+			t.Errorf("home page should mention unpublished notes as text:\n%s", home)
+		}
+		if _, err := os.Stat(filepath.Join(out, "notes", "secret.html")); err == nil {
+			t.Error("unpublished note was written")
+		}
+	})
+
+	t.Run("copies referenced assets", func(t *testing.T) {
+		if !strings.Contains(home, `<img src="../assets/media/diagram.png"`) {
+			t.Errorf("home page missing embed:\n%s", home)
+		}
+		if !strings.Contains(deep, `<img src="../../assets/topics/img/photo.jpg"`) {
+			t.Errorf("deep page missing image:\n%s", deep)
+		}
+		if read("assets/media/diagram.png") != "png-bytes" {
+			t.Error("diagram.png not copied")
+		}
+		if _, err := os.Stat(filepath.Join(out, "assets", "unused.png")); err == nil {
+			t.Error("unreferenced asset was copied")
+		}
+	})
+
+	t.Run("lists backlinks", func(t *testing.T) {
+		if !strings.Contains(deep, "Linked from") || !strings.Contains(deep, `href="../../notes/home.html">Home</a>`) {
+			t.Errorf("deep page missing backlink section:\n%s", deep)
+		}
+	})
+
+	t.Run("indexes tags", func(t *testing.T) {
+		tags := read("tags.html")
+		if !strings.Contains(tags, `href="tags/intro.html"`) || !strings.Contains(tags, `href="tags/topic.html"`) {
+			t.Errorf("tag index missing tags:\n%s", tags)
+		}
+		if !strings.Contains(read("tags/topic.html"), `href="../notes/topics/deep.html"`) {
+			t.Error("topic tag page missing deep note")
+		}
+	})
+
+	t.Run("writes an index and stylesheet", func(t *testing.T) {
+		index := read("index.html")
+		if !strings.Contains(index, "<title>My Site</title>") || !strings.Contains(index, `href="notes/home.html"`) {
+			t.Errorf("index page:\n%s", index)
+		}
+		read("static/style.css")
+	})
+}
+
+func TestFeeds(t *testing.T) {
+	v := writeVault(t, map[string]string{
+		"first.md": "---\ntitle: First\npublish: true\ncreated: 2024-01-01\nupdated: 2024-02-01\ntags: [go]\n---\n" +
+			"The first note, see [[Second]].\n\nMore text.\n",
+		"blog/second.md":    "---\ntitle: Second\npublish: true\ncreated: 2024-03-01\ndescription: Number two\n---\nBody of two.\n",
+		"blog/old/third.md": "---\ntitle: Third\npublish: true\nupdated: 2023-01-01\n---\nThree.\n",
+		"draft.md":          "---\ntitle: Draft\n---\nnot published\n",
+	})
+	ctx := context.Background()
+	build := func(opts Options) *Site {
+		t.Helper()
+		site, err := Build(ctx, v, opts)
+		if err != nil {
+			t.Fatalf("Build() returned error: %v", err)
+		}
+		return site
+	}
+	render := func(site *Site, rel string) string {
+		t.Helper()
+		var buf strings.Builder
+		if err := site.RenderFile(ctx, rel, &buf); err != nil {
+			t.Fatalf("RenderFile(%s) returned error: %v", rel, err)
+		}
+		return buf.String()
+	}
+
+	site := build(Options{Title: "Blog", BaseURL: "https://example.com/site"})
+
+	t.Run("lists feeds for the site, tags and folders", func(t *testing.T) {
+		files, _ := site.Files()
+		all := strings.Join(files, ",")
+		for _, want := range []string{"feed.xml", "atom.xml", "feed.json", "tags/go/feed.xml", "notes/blog/atom.xml", "notes/blog/old/feed.json"} {
+			if !strings.Contains(","+all+",", ","+want+",") {
+				t.Errorf("Files() lacks %s: %v", want, files)
+			}
+		}
+		if err := site.RenderFile(ctx, "notes/missing/feed.xml", &strings.Builder{}); err != ErrNotFound {
+			t.Errorf("RenderFile(unknown folder feed) = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("orders entries and dates them from frontmatter", func(t *testing.T) {
+		rss := render(site, "feed.xml")
+		second, first, third := strings.Index(rss, "<title>Second</title>"), strings.Index(rss, "<title>First</title>"), strings.Index(rss, "<title>Third</title>")
+		if second < 0 || !(second < first && first < third) {
+			t.Errorf("feed entries out of order:\n%s", rss)
+		}
+		if !strings.Contains(rss, "<pubDate>Mon, 01 Jan 2024 00:00:00 +0000</pubDate>") {
+			t.Errorf("feed lacks the created date of First:\n%s", rss)
+		}
+		atom := render(site, "atom.xml")
+		if !strings.Contains(atom, "<updated>2024-02-01T00:00:00Z</updated>") {
+			t.Errorf("atom feed lacks the updated date of First:\n%s", atom)
+		}
+		if got := site.ModTime("notes/blog/old/feed.xml"); !got.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("ModTime(old folder feed) = %v", got)
+		}
+	})
+
+	t.Run("falls back to the file time", func(t *testing.T) {
+		for _, p := range site.Pages {
+			if p.Title == "Second" && (!p.Updated.Equal(p.Modified) || p.Published.Year() != 2024) {
+				t.Errorf("Second dated %v / %v, want mtime %v", p.Published, p.Updated, p.Modified)
+			}
+		}
+	})
+
+	t.Run("limits feeds to their tag or folder", func(t *testing.T) {
+		tag := render(site, "tags/go/feed.json")
+		if !strings.Contains(tag, `"title": "First"`) || strings.Contains(tag, `"title": "Second"`) {
+			t.Errorf("tag feed:\n%s", tag)
+		}
+		folder := render(site, "notes/blog/feed.json")
+		if !strings.Contains(folder, `"title": "Second"`) || !strings.Contains(folder, `"title": "Third"`) || strings.Contains(folder, `"title": "First"`) {
+			t.Errorf("folder feed:\n%s", folder)
+		}
+	})
+
+	t.Run("carries full content with absolute links", func(t *testing.T) {
+		feed := render(site, "feed.json")
+		if !strings.Contains(feed, `href=\"https://example.com/site/notes/blog/second.html\"`) {
+			t.Errorf("feed content lacks an absolute link:\n%s", feed)
+		}
+		if !strings.Contains(feed, `"summary": "Number two"`) || !strings.Contains(feed, `"summary": "The first note, see Second."`) {
+			t.Errorf("feed lacks summaries:\n%s", feed)
+		}
+		// The page itself keeps relative links
+		if page := render(site, "notes/first.html"); !strings.Contains(page, `href="../notes/blog/second.html"`) {
+			t.Errorf("page links changed:\n%s", page)
+		}
+	})
+
+	t.Run("carries excerpts only when asked", func(t *testing.T) {
+		excerpts := build(Options{FeedContent: FeedExcerpt})
+		feed := render(excerpts, "feed.json")
+		if strings.Contains(feed, "content_html") || !strings.Contains(feed, `"content_text": "Number two"`) {
+			t.Errorf("excerpt feed:\n%s", feed)
+		}
+		if _, err := Build(ctx, v, Options{FeedContent: "some"}); err == nil {
+			t.Error("Build() accepted an unknown feed content mode")
+		}
+	})
+}
+
+func TestPublish_TagPaths(t *testing.T) {
+	v := writeVault(t, map[string]string{
+		"a.md": "---\ntitle: A\npublish: true\ntags: [\"../../escaped\", \"a b?\", \"a-b\", \"#x\"]\n---\nA\n",
+	})
+	ctx := context.Background()
+	site, err := Build(ctx, v, Options{})
+	if err != nil {
+		t.Fatalf("Build() returned error: %v", err)
+	}
+	urls := map[string]string{}
+	for _, tag := range site.Tags {
+		urls[tag.Name] = tag.URL
+	}
+	want := map[string]string{
+		"../../escaped": "tags/escaped.html",
+		"a b?":          "tags/a-b.html",
+		"a-b":           "tags/a-b-2.html",
+		"#x":            "tags/x.html",
+	}
+	for name, url := range want {
+		if urls[name] != url {
+			t.Errorf("tag %q URL = %q, want %q", name, urls[name], url)
+		}
+	}
+
+	root := t.TempDir()
+	out := filepath.Join(root, "site")
+	if err := site.Write(ctx, out); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	for _, rel := range []string{"escaped.html", "escaped/feed.xml"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel))); err == nil {
+			t.Errorf("Write() escaped the output folder to %s", rel)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "tags", "escaped", "feed.xml")); err != nil {
+		t.Errorf("tag feed not written inside the site: %v", err)
+	}
+	note, _ := os.ReadFile(filepath.Join(out, "notes", "a.html"))
+	if !strings.Contains(string(note), `href="../tags/escaped.html">#../../escaped</a>`) {
+		t.Errorf("note page doesn't link the tag by name:\n%s", note)
+	}
+}
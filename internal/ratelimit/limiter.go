@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// Policy controls how quickly repeated failures are slowed down and locked out
+type Policy struct {
+	// FreeAttempts is the number of failures allowed before any delay applies
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure past FreeAttempts; it doubles per failure
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential backoff
+	MaxDelay time.Duration
+	// LockoutThreshold is the failure count that triggers a lockout
+	LockoutThreshold int
+	// LockoutDuration is how long a lockout lasts
+	LockoutDuration time.Duration
+	// ResetAfter forgets the failure history after this long without failures
+	ResetAfter time.Duration
+}
+
+// DefaultPolicy returns the policy used when nothing is configured
+func DefaultPolicy() Policy {
+	return Policy{
+		FreeAttempts:     3,
+		BaseDelay:        1 * time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+		ResetAfter:       1 * time.Hour,
+	}
+}
+
+// Limiter applies a Policy to failures recorded against arbitrary keys
+type Limiter struct {
+	mu     sync.Mutex
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+// NewLimiter creates a limiter and starts garbage collection of stale state
+func NewLimiter(store Store, policy Policy, gcInterval time.Duration) *Limiter {
+	l := &Limiter{
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+	}
+	if gcInterval > 0 {
+		go l.gc(gcInterval)
+	}
+	return l
+}
+
+// IPKey is the limiter key for a client IP
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// AccountKey is the limiter key for an account, normalised by email
+func AccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func (l *Limiter) gc(d time.Duration) {
+	ticker := time.NewTicker(d)
+	for range ticker.C {
+		if err := l.store.GC(l.now().Add(-l.policy.ResetAfter)); err != nil {
+			log.Printf("ratelimit: gc failed: %v", err)
+		}
+	}
+}
+
+// current returns the live throttle state for key, dropping it if it has expired
+func (l *Limiter) current(key string) (*models.LoginThrottle, error) {
+	t, err := l.store.Get(key)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	now := l.now()
+	if now.Sub(t.LastFailureAt) > l.policy.ResetAfter && !now.Before(t.LockedUntil) {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// Check reports how long key must wait before another attempt; zero means allowed
+func (l *Limiter) Check(key string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t, err := l.current(key)
+	if err != nil || t == nil {
+		return 0, err
+	}
+	if wait := t.LockedUntil.Sub(l.now()); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// Fail records a failure for key and returns the wait now imposed on it
+func (l *Limiter) Fail(key string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t, err := l.current(key)
+	if err != nil {
+		return 0, err
+	}
+	if t == nil {
+		t = &models.LoginThrottle{Key: key}
+	}
+
+	now := l.now()
+	t.Failures++
+	t.LastFailureAt = now
+	t.LockedUntil = now.Add(l.delay(t.Failures))
+
+	if err := l.store.Put(t); err != nil {
+		return 0, err
+	}
+	return t.LockedUntil.Sub(now), nil
+}
+
+// Reset clears the failure history for key, e.g. after a successful login
+func (l *Limiter) Reset(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.store.Delete(key)
+}
+
+// delay computes the wait imposed after the given number of failures
+func (l *Limiter) delay(failures int) time.Duration {
+	p := l.policy
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimiter(policy Policy) (*Limiter, *time.Time) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(NewInMemoryStore(), policy, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiter_Delay(t *testing.T) {
+	l, _ := newTestLimiter(Policy{
+		FreeAttempts:     2,
+		BaseDelay:        time.Second,
+		MaxDelay:         10 * time.Second,
+		LockoutThreshold: 8,
+		LockoutDuration:  time.Hour,
+	})
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, 1 * time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 8 * time.Second},
+		{7, 10 * time.Second},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := l.delay(tt.failures); got != tt.expected {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.expected)
+		}
+	}
+}
+
+func TestLimiter_FailAndCheck(t *testing.T) {
+	l, now := newTestLimiter(Policy{
+		FreeAttempts:     1,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 4,
+		LockoutDuration:  15 * time.Minute,
+		ResetAfter:       time.Hour,
+	})
+	key := AccountKey("User@Example.com ")
+
+	t.Run("allows first failure without delay", func(t *testing.T) {
+		wait, err := l.Fail(key)
+		if err != nil {
+			t.Fatalf("Fail() returned error: %v", err)
+		}
+		if wait != 0 {
+			t.Errorf("Fail() wait = %v, want 0", wait)
+		}
+		if wait, _ := l.Check(key); wait != 0 {
+			t.Errorf("Check() wait = %v, want 0", wait)
+		}
+	})
+
+	t.Run("backs off after free attempts", func(t *testing.T) {
+		wait, _ := l.Fail(key)
+		if wait != time.Second {
+			t.Errorf("Fail() wait = %v, want 1s", wait)
+		}
+		if wait, _ := l.Check(key); wait != time.Second {
+			t.Errorf("Check() wait = %v, want 1s", wait)
+		}
+
+		*now = now.Add(time.Second)
+		if wait, _ := l.Check(key); wait != 0 {
+			t.Errorf("Check() after backoff wait = %v, want 0", wait)
+		}
+	})
+
+	t.Run("locks out at threshold", func(t *testing.T) {
+		l.Fail(key)
+		wait, _ := l.Fail(key)
+		if wait != 15*time.Minute {
+			t.Errorf("Fail() wait = %v, want 15m", wait)
+		}
+
+		*now = now.Add(10 * time.Minute)
+		if wait, _ := l.Check(key); wait != 5*time.Minute {
+			t.Errorf("Check() during lockout wait = %v, want 5m", wait)
+		}
+	})
+
+	t.Run("forgets failures after reset window", func(t *testing.T) {
+		*now = now.Add(2 * time.Hour)
+		wait, _ := l.Fail(key)
+		if wait != 0 {
+			t.Errorf("Fail() after quiet period wait = %v, want 0", wait)
+		}
+	})
+
+	t.Run("reset clears state", func(t *testing.T) {
+		l.Fail(key)
+		if err := l.Reset(key); err != nil {
+			t.Fatalf("Reset() returned error: %v", err)
+		}
+		throttle, _ := l.store.Get(key)
+		if throttle != nil {
+			t.Error("Reset() did not clear throttle state")
+		}
+	})
+}
+
+func TestKeys(t *testing.T) {
+	if got := IPKey("10.0.0.1"); got != "ip:10.0.0.1" {
+		t.Errorf("IPKey() = %q, want %q", got, "ip:10.0.0.1")
+	}
+	if got := AccountKey("  Admin@Example.COM"); got != "account:admin@example.com" {
+		t.Errorf("AccountKey() = %q, want %q", got, "account:admin@example.com")
+	}
+}
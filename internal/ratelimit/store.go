@@ -0,0 +1,15 @@
+package ratelimit
+
+import (
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// Store interface for different throttle state backends
+type Store interface {
+	Get(key string) (*models.LoginThrottle, error)
+	Put(t *models.LoginThrottle) error
+	Delete(key string) error
+	GC(threshold time.Time) error
+}
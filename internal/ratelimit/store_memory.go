@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// InMemoryStore keeps throttle state in memory; it is lost on restart
+type InMemoryStore struct {
+	mu        sync.RWMutex
+	throttles map[string]models.LoginThrottle
+}
+
+// NewInMemoryStore creates a new in-memory throttle store
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		throttles: make(map[string]models.LoginThrottle),
+	}
+}
+
+func (s *InMemoryStore) Get(key string) (*models.LoginThrottle, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.throttles[key]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (s *InMemoryStore) Put(t *models.LoginThrottle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.throttles[t.Key] = *t
+	return nil
+}
+
+func (s *InMemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.throttles, key)
+	return nil
+}
+
+func (s *InMemoryStore) GC(threshold time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, t := range s.throttles {
+		if t.LastFailureAt.Before(threshold) && t.LockedUntil.Before(threshold) {
+			delete(s.throttles, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestInMemoryStore(t *testing.T) {
+	store := NewInMemoryStore()
+
+	t.Run("returns nil for unknown key", func(t *testing.T) {
+		throttle, err := store.Get("ip:unknown")
+		if err != nil {
+			t.Fatalf("Get() returned error: %v", err)
+		}
+		if throttle != nil {
+			t.Error("Get() should return nil for unknown key")
+		}
+	})
+
+	t.Run("put and get round trip", func(t *testing.T) {
+		store.Put(&models.LoginThrottle{Key: "ip:1", Failures: 3})
+
+		throttle, _ := store.Get("ip:1")
+		if throttle == nil || throttle.Failures != 3 {
+			t.Errorf("Get() = %+v, want 3 failures", throttle)
+		}
+	})
+
+	t.Run("delete removes key", func(t *testing.T) {
+		store.Delete("ip:1")
+		if throttle, _ := store.Get("ip:1"); throttle != nil {
+			t.Error("Delete() did not remove key")
+		}
+	})
+
+	t.Run("gc removes stale state only", func(t *testing.T) {
+		now := time.Now()
+		store.Put(&models.LoginThrottle{Key: "stale", LastFailureAt: now.Add(-2 * time.Hour), LockedUntil: now.Add(-2 * time.Hour)})
+		store.Put(&models.LoginThrottle{Key: "locked", LastFailureAt: now.Add(-2 * time.Hour), LockedUntil: now.Add(time.Hour)})
+		store.Put(&models.LoginThrottle{Key: "recent", LastFailureAt: now, LockedUntil: now})
+
+		if err := store.GC(now.Add(-time.Hour)); err != nil {
+			t.Fatalf("GC() returned error: %v", err)
+		}
+		if _, ok := store.throttles["stale"]; ok {
+			t.Error("GC() did not remove stale state")
+		}
+		if _, ok := store.throttles["locked"]; !ok {
+			t.Error("GC() removed an active lockout")
+		}
+		if _, ok := store.throttles["recent"]; !ok {
+			t.Error("GC() removed recent state")
+		}
+	})
+}
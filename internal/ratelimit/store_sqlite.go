@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+)
+
+// SQLiteStore keeps throttle state in SQLite so limits survive restarts
+type SQLiteStore struct {
+	db *dbx.DB
+}
+
+// NewSQLiteStore creates a new SQLite throttle store
+func NewSQLiteStore(db *dbx.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+func (s *SQLiteStore) Get(key string) (*models.LoginThrottle, error) {
+	return s.db.GetLoginThrottle(context.Background(), key)
+}
+
+func (s *SQLiteStore) Put(t *models.LoginThrottle) error {
+	return s.db.UpsertLoginThrottle(context.Background(), t)
+}
+
+func (s *SQLiteStore) Delete(key string) error {
+	return s.db.DeleteLoginThrottle(context.Background(), key)
+}
+
+func (s *SQLiteStore) GC(threshold time.Time) error {
+	return s.db.CleanLoginThrottles(context.Background(), threshold)
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	db := dbx.SetupTestDB(t)
+	defer db.Close()
+
+	store := NewSQLiteStore(db)
+
+	t.Run("returns nil for unknown key", func(t *testing.T) {
+		throttle, err := store.Get("ip:unknown")
+		if err != nil {
+			t.Fatalf("Get() returned error: %v", err)
+		}
+		if throttle != nil {
+			t.Error("Get() should return nil for unknown key")
+		}
+	})
+
+	t.Run("put upserts state", func(t *testing.T) {
+		lockedUntil := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+		if err := store.Put(&models.LoginThrottle{Key: "account:a@b.c", Failures: 1, LastFailureAt: time.Now()}); err != nil {
+			t.Fatalf("Put() returned error: %v", err)
+		}
+		if err := store.Put(&models.LoginThrottle{Key: "account:a@b.c", Failures: 5, LastFailureAt: time.Now(), LockedUntil: lockedUntil}); err != nil {
+			t.Fatalf("Put() second call returned error: %v", err)
+		}
+
+		throttle, err := store.Get("account:a@b.c")
+		if err != nil {
+			t.Fatalf("Get() returned error: %v", err)
+		}
+		if throttle == nil || throttle.Failures != 5 {
+			t.Fatalf("Get() = %+v, want 5 failures", throttle)
+		}
+		if !throttle.LockedUntil.Equal(lockedUntil) {
+			t.Errorf("Get() LockedUntil = %v, want %v", throttle.LockedUntil, lockedUntil)
+		}
+	})
+
+	t.Run("limits survive a new limiter", func(t *testing.T) {
+		policy := Policy{LockoutThreshold: 1, LockoutDuration: time.Hour, ResetAfter: time.Hour}
+		NewLimiter(store, policy, 0).Fail("ip:10.0.0.9")
+
+		wait, err := NewLimiter(NewSQLiteStore(db), policy, 0).Check("ip:10.0.0.9")
+		if err != nil {
+			t.Fatalf("Check() returned error: %v", err)
+		}
+		if wait <= 0 {
+			t.Error("Check() should still report the lockout from the earlier limiter")
+		}
+	})
+
+	t.Run("delete and gc", func(t *testing.T) {
+		if err := store.Delete("account:a@b.c"); err != nil {
+			t.Fatalf("Delete() returned error: %v", err)
+		}
+		if throttle, _ := store.Get("account:a@b.c"); throttle != nil {
+			t.Error("Delete() did not remove key")
+		}
+
+		old := time.Now().Add(-2 * time.Hour)
+		store.Put(&models.LoginThrottle{Key: "stale", LastFailureAt: old, LockedUntil: old})
+		if err := store.GC(time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("GC() returned error: %v", err)
+		}
+		if throttle, _ := store.Get("stale"); throttle != nil {
+			t.Error("GC() did not remove stale state")
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+)
+
+// maxAuditPage caps how many audit events one request returns
+const maxAuditPage = 500
+
+// maxTablePage caps how many table rows one request returns
+const maxTablePage = 500
+
+// requireAdmin returns the signed-in site administrator, writing a 401 or
+// 403 if the request doesn't come from one
+func requireAdmin(w http.ResponseWriter, r *http.Request, db *dbx.DB) (*models.User, bool) {
+	user, ok := authenticatedUser(w, r, db)
+	if !ok {
+		return nil, false
+	}
+	if !user.IsAdmin {
+		http.Error(w, "admin only", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
+
+// AuditPage is one page of audit events; pass NextBefore as ?before= for the next
+type AuditPage struct {
+	Events     []models.AuditEvent `json:"events"`
+	NextBefore int64               `json:"next_before,omitempty"`
+}
+
+// RegisterAdmin registers the admin-only endpoints
+func RegisterAdmin(mux *http.ServeMux, db *dbx.DB) {
+	mux.HandleFunc("GET /api/admin/audit", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		filter, err := parseAuditFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		events, err := db.GetAuditEvents(r.Context(), filter)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		page := AuditPage{Events: events}
+		if len(events) == filter.Limit {
+			page.NextBefore = events[len(events)-1].ID
+		}
+		writeJSON(w, page)
+	})
+
+	// Streams every matching event as JSON Lines, newest first
+	mux.HandleFunc("GET /api/admin/audit/export", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		filter, err := parseAuditFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		filter.Limit = maxAuditPage
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		enc := json.NewEncoder(w)
+		for {
+			events, err := db.GetAuditEvents(r.Context(), filter)
+			if err != nil {
+				// Headers are already out; all we can do is stop the stream
+				return
+			}
+			for _, e := range events {
+				if err := enc.Encode(e); err != nil {
+					return
+				}
+			}
+			if len(events) < filter.Limit {
+				return
+			}
+			filter.BeforeID = events[len(events)-1].ID
+		}
+	})
+
+	mux.HandleFunc("GET /api/admin/tables", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		tables, err := db.GetAllTables(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, tables)
+	})
+
+	// Pages through rows with ?limit, ?offset, ?sort=<column> and ?order=desc
+	mux.HandleFunc("GET /api/admin/tables/{table}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		q := r.URL.Query()
+		tq := dbx.TableQuery{
+			Table: r.PathValue("table"),
+			Sort:  q.Get("sort"),
+			Desc:  q.Get("order") == "desc",
+			Limit: 100,
+		}
+		var err error
+		if v := q.Get("limit"); v != "" {
+			if tq.Limit, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "invalid limit", 400)
+				return
+			}
+		}
+		if v := q.Get("offset"); v != "" {
+			if tq.Offset, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "invalid offset", 400)
+				return
+			}
+		}
+		tq.Limit = max(1, min(tq.Limit, maxTablePage))
+
+		page, err := db.GetTableRows(r.Context(), tq)
+		if err != nil {
+			tableError(w, err)
+			return
+		}
+		auditTableRead(r, db, user, tq.Table, fmt.Sprintf("offset=%d limit=%d", tq.Offset, tq.Limit))
+		writeJSON(w, page)
+	})
+
+	mux.HandleFunc("GET /api/admin/tables/{table}/schema", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		schema, err := db.GetTableSchema(r.Context(), r.PathValue("table"))
+		if err != nil {
+			tableError(w, err)
+			return
+		}
+		writeJSON(w, schema)
+	})
+
+	mux.HandleFunc("GET /api/admin/tables/{table}/export", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		table := r.PathValue("table")
+		if _, err := db.GetTableSchema(r.Context(), table); err != nil {
+			tableError(w, err)
+			return
+		}
+		auditTableRead(r, db, user, table, "csv export")
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, table))
+		// Headers are out once rows start streaming, so a failure just ends the file
+		_ = db.ExportTableCSV(r.Context(), table, w)
+	})
+}
+
+// SQLQuery is the body of a console request
+type SQLQuery struct {
+	Query string `json:"query"`
+}
+
+// RegisterSQLConsole registers the admin-only read-only SQL console
+func RegisterSQLConsole(mux *http.ServeMux, db *dbx.DB, timeout time.Duration, maxRows int) {
+	mux.HandleFunc("POST /api/admin/sql", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		var body SQLQuery
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditAdminSQLQuery, Details: body.Query})
+		result, err := db.ReadOnlyQuery(r.Context(), body.Query, timeout, maxRows)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		writeJSON(w, result)
+	})
+}
+
+// tableError maps admin table lookup errors to a status code
+func tableError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, dbx.ErrTableNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, dbx.ErrUnknownColumn):
+		http.Error(w, err.Error(), 400)
+	default:
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+func auditTableRead(r *http.Request, db *dbx.DB, user *models.User, table, details string) {
+	auditLog(r, db, user, models.AuditEvent{Action: models.AuditAdminTableRead, Target: table, Details: details})
+}
+
+// parseAuditFilter reads actor, action, target, vault, since, until
+// (RFC 3339), before and limit from the query string
+func parseAuditFilter(r *http.Request) (models.AuditFilter, error) {
+	q := r.URL.Query()
+	f := models.AuditFilter{
+		Actor:  q.Get("actor"),
+		Action: q.Get("action"),
+		Target: q.Get("target"),
+		Vault:  q.Get("vault"),
+		Limit:  100,
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, err
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, err
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return f, err
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			return f, err
+		}
+	}
+	f.Limit = max(1, min(f.Limit, maxAuditPage))
+	return f, nil
+}
@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func TestAdminAudit(t *testing.T) {
+	ts := newTestServer(t, "admin", "user")
+	db, admin := ts.db, ts.users["admin"]
+	db.SetUserAdmin(t.Context(), admin.ID, true)
+
+	vs := NewVaults(db, vault.NewManager(t.TempDir()), nil)
+	RegisterApi(ts.mux, vs)
+	RegisterAdmin(ts.mux, db)
+	ts.login()
+	do := ts.do
+	do("", "POST", "/api/auth/login", `{"email":"user@example.com","password":"wrong"}`)
+	do("user", "POST", "/api/file", `{"path":"a.md","content":"one"}`)
+	do("user", "PUT", "/api/file?path=a.md", `{"content":"two"}`)
+	do("user", "DELETE", "/api/file?path=a.md", "")
+
+	t.Run("requires an admin", func(t *testing.T) {
+		if rec := do("user", "GET", "/api/admin/audit", ""); rec.Code != http.StatusForbidden {
+			t.Errorf("GET /api/admin/audit as user status = %v, want 403", rec.Code)
+		}
+	})
+
+	t.Run("records logins and failures", func(t *testing.T) {
+		var page AuditPage
+		json.NewDecoder(do("admin", "GET", "/api/admin/audit?action=auth", "").Body).Decode(&page)
+		var logins, failures int
+		for _, e := range page.Events {
+			switch e.Action {
+			case models.AuditLogin:
+				logins++
+			case models.AuditLoginFailed:
+				failures++
+			}
+		}
+		if logins != 2 || failures != 1 {
+			t.Errorf("logins, failures = %d, %d, want 2, 1", logins, failures)
+		}
+	})
+
+	t.Run("records file changes with hashes", func(t *testing.T) {
+		var page AuditPage
+		json.NewDecoder(do("admin", "GET", "/api/admin/audit?action=file&actor=user@example.com", "").Body).Decode(&page)
+		if len(page.Events) != 3 {
+			t.Fatalf("file events = %d, want 3", len(page.Events))
+		}
+		del, write, create := page.Events[0], page.Events[1], page.Events[2]
+		if create.Action != models.AuditFileCreate || create.HashBefore != "" || create.HashAfter == "" {
+			t.Errorf("create event = %+v", create)
+		}
+		if write.Action != models.AuditFileWrite || write.HashBefore != create.HashAfter {
+			t.Errorf("write event = %+v, want hash_before %s", write, create.HashAfter)
+		}
+		if del.Action != models.AuditFileDelete || del.HashBefore != write.HashAfter || del.Vault == "" {
+			t.Errorf("delete event = %+v", del)
+		}
+	})
+
+	t.Run("pages and exports", func(t *testing.T) {
+		var page AuditPage
+		json.NewDecoder(do("admin", "GET", "/api/admin/audit?limit=2", "").Body).Decode(&page)
+		if len(page.Events) != 2 || page.NextBefore == 0 {
+			t.Errorf("first page = %d events, next %d", len(page.Events), page.NextBefore)
+		}
+
+		rec := do("admin", "GET", "/api/admin/audit/export", "")
+		lines := 0
+		for sc := bufio.NewScanner(rec.Body); sc.Scan(); lines++ {
+			var e models.AuditEvent
+			if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+				t.Fatalf("export line %d is not JSON: %v", lines, err)
+			}
+		}
+		if lines < 6 {
+			t.Errorf("export has %d lines, want at least 6", lines)
+		}
+	})
+}
+
+func TestAdminTables(t *testing.T) {
+	ts := newTestServer(t, "admin", "user")
+	db, admin := ts.db, ts.users["admin"]
+	db.SetUserAdmin(t.Context(), admin.ID, true)
+
+	RegisterAdmin(ts.mux, db)
+	RegisterSQLConsole(ts.mux, db, time.Second, 100)
+	ts.login()
+	do := ts.do
+
+	t.Run("requires an admin", func(t *testing.T) {
+		for _, path := range []string{"/api/admin/tables", "/api/admin/tables/users", "/api/admin/tables/users/export"} {
+			if rec := do("user", "GET", path, ""); rec.Code != http.StatusForbidden {
+				t.Errorf("GET %s as user status = %v, want 403", path, rec.Code)
+			}
+		}
+		if rec := do("user", "POST", "/api/admin/sql", `{"query":"SELECT 1"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("POST /api/admin/sql as user status = %v, want 403", rec.Code)
+		}
+	})
+
+	t.Run("pages rows with redaction", func(t *testing.T) {
+		rec := do("admin", "GET", "/api/admin/tables/users?sort=email&order=desc&limit=1", "")
+		if rec.Code != 200 {
+			t.Fatalf("status = %v: %s", rec.Code, rec.Body)
+		}
+		var page dbx.TablePage
+		json.NewDecoder(rec.Body).Decode(&page)
+		if page.Total != 2 || len(page.Rows) != 1 || page.Rows[0]["email"] != "user@example.com" {
+			t.Errorf("page = %+v", page)
+		}
+		if page.Rows[0]["password_hash"] != dbx.Redacted {
+			t.Errorf("password_hash = %v, want redacted", page.Rows[0]["password_hash"])
+		}
+	})
+
+	t.Run("reports bad tables and columns", func(t *testing.T) {
+		if rec := do("admin", "GET", "/api/admin/tables/nope", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("unknown table status = %v, want 404", rec.Code)
+		}
+		if rec := do("admin", "GET", "/api/admin/tables/users?sort=nope", ""); rec.Code != 400 {
+			t.Errorf("unknown sort column status = %v, want 400", rec.Code)
+		}
+	})
+
+	t.Run("returns the schema", func(t *testing.T) {
+		var schema []dbx.TableColumn
+		json.NewDecoder(do("admin", "GET", "/api/admin/tables/sessions/schema", "").Body).Decode(&schema)
+		if len(schema) == 0 || schema[0].Name != "id" {
+			t.Errorf("schema = %+v", schema)
+		}
+	})
+
+	t.Run("exports csv", func(t *testing.T) {
+		rec := do("admin", "GET", "/api/admin/tables/sessions/export", "")
+		if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+			t.Errorf("Content-Type = %q, want text/csv", ct)
+		}
+		for _, c := range ts.cookies["admin"] {
+			if strings.Contains(rec.Body.String(), c.Value) {
+				t.Errorf("export leaks cookie %s", c.Name)
+			}
+		}
+	})
+
+	t.Run("runs console queries", func(t *testing.T) {
+		rec := do("admin", "POST", "/api/admin/sql", `{"query":"SELECT count(*) AS n FROM users"}`)
+		var res dbx.QueryResult
+		json.NewDecoder(rec.Body).Decode(&res)
+		if len(res.Rows) != 1 || res.Rows[0][0] != float64(2) {
+			t.Errorf("result = %+v", res)
+		}
+		if rec := do("admin", "POST", "/api/admin/sql", `{"query":"DELETE FROM users"}`); rec.Code != 400 {
+			t.Errorf("DELETE status = %v, want 400", rec.Code)
+		}
+	})
+
+	t.Run("audits reads", func(t *testing.T) {
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: "admin"})
+		if len(events) < 4 {
+			t.Errorf("admin audit events = %d, want at least 4", len(events))
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"dragonbytelabs/dz/internal/audit"
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/ratelimit"
+	"dragonbytelabs/dz/internal/session"
+)
+
+// RegisterAuth registers the login, logout and current-user endpoints
+func RegisterAuth(mux *http.ServeMux, db *dbx.DB, sm *session.SessionManager, limiter *ratelimit.Limiter) {
+	mux.HandleFunc("POST /api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if req.Email == "" || req.Password == "" {
+			http.Error(w, "email and password required", 400)
+			return
+		}
+
+		attempt := &models.LoginAttempt{
+			Email:     req.Email,
+			IP:        clientIP(r),
+			UserAgent: r.UserAgent(),
+		}
+		keys := []string{ratelimit.IPKey(attempt.IP), ratelimit.AccountKey(req.Email)}
+
+		// Refuse outright while either the client or the account is throttled
+		for _, key := range keys {
+			wait, err := limiter.Check(key)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			if wait > 0 {
+				attempt.Reason = "rate_limited"
+				recordLoginAttempt(r, db, attempt)
+				tooManyAttempts(w, wait, "login attempts")
+				return
+			}
+		}
+
+		user, err := db.GetUserByEmail(r.Context(), req.Email)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if user == nil || user.CheckPassword(req.Password) != nil {
+			attempt.Reason = "invalid_credentials"
+			recordLoginAttempt(r, db, attempt)
+			for _, key := range keys {
+				if _, err := limiter.Fail(key); err != nil {
+					log.Printf("login: failed to record failure for %s: %v", key, err)
+				}
+			}
+			http.Error(w, "invalid email or password", http.StatusUnauthorized)
+			return
+		}
+
+		// Only the account is cleared; a shared IP keeps its history
+		if err := limiter.Reset(ratelimit.AccountKey(req.Email)); err != nil {
+			log.Printf("login: failed to reset limiter: %v", err)
+		}
+		attempt.Success = true
+		recordLoginAttempt(r, db, attempt)
+
+		sess := session.GetSession(r)
+		if err := sm.Migrate(sess); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		sess.Put("user_id", user.UserHash)
+
+		writeJSON(w, user)
+	})
+
+	mux.HandleFunc("POST /api/auth/logout", func(w http.ResponseWriter, r *http.Request) {
+		if user, err := currentUser(r, db); err == nil && user != nil {
+			auditLog(r, db, user, models.AuditEvent{Action: models.AuditLogout})
+		}
+
+		sess := session.GetSession(r)
+		sess.Delete("user_id")
+		if err := sm.Migrate(sess); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+
+	mux.HandleFunc("GET /api/auth/me", func(w http.ResponseWriter, r *http.Request) {
+		user, err := currentUser(r, db)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if user == nil {
+			http.Error(w, "not authenticated", http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, user)
+	})
+}
+
+// currentUser returns the user bound to the request's session, or nil
+func currentUser(r *http.Request, db *dbx.DB) (*models.User, error) {
+	sess := session.GetSessionSafe(r)
+	if sess == nil {
+		return nil, nil
+	}
+	userHash, ok := sess.Get("user_id").(string)
+	if !ok || userHash == "" {
+		return nil, nil
+	}
+	return db.GetUserByHash(r.Context(), userHash)
+}
+
+// clientIP returns the remote address of the request without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func recordLoginAttempt(r *http.Request, db *dbx.DB, attempt *models.LoginAttempt) {
+	if err := db.RecordLoginAttempt(r.Context(), attempt); err != nil {
+		log.Printf("login: failed to record attempt: %v", err)
+	}
+
+	event := models.AuditEvent{
+		Action:     models.AuditLogin,
+		ActorEmail: attempt.Email,
+		IP:         attempt.IP,
+	}
+	if !attempt.Success {
+		event.Action = models.AuditLoginFailed
+		event.Details = attempt.Reason
+	}
+	audit.Log(r.Context(), db, event)
+}
+
+// auditLog records an event performed by user from the request's address
+func auditLog(r *http.Request, db *dbx.DB, user *models.User, e models.AuditEvent) {
+	ctx := r.Context()
+	if user != nil {
+		ctx = audit.WithActor(ctx, audit.ForUser(user, clientIP(r)))
+	}
+	audit.Log(ctx, db, e)
+}
+
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration, what string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+	http.Error(w, fmt.Sprintf("too many %s, retry in %ds", what, seconds), http.StatusTooManyRequests)
+}
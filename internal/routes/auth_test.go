@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/ratelimit"
+	"dragonbytelabs/dz/internal/session"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setupAuthTest(t *testing.T, policy ratelimit.Policy) (*dbx.DB, http.Handler) {
+	t.Helper()
+
+	db := dbx.SetupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct-horse"), bcrypt.MinCost)
+	if _, err := db.CreateUser(context.Background(), "user@example.com", string(hash), "User"); err != nil {
+		t.Fatalf("CreateUser() returned error: %v", err)
+	}
+
+	sm := session.NewSessionManager(session.NewInMemoryStore(), 30*time.Minute, 1*time.Hour, 12*time.Hour, "session_id")
+	limiter := ratelimit.NewLimiter(ratelimit.NewInMemoryStore(), policy, 0)
+
+	mux := http.NewServeMux()
+	RegisterAuth(mux, db, sm, limiter)
+	return db, sm.Handle(mux)
+}
+
+func login(handler http.Handler, email, password string) *httptest.ResponseRecorder {
+	body := `{"email":"` + email + `","password":"` + password + `"}`
+	req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(body))
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLogin(t *testing.T) {
+	t.Run("accepts valid credentials", func(t *testing.T) {
+		db, handler := setupAuthTest(t, ratelimit.DefaultPolicy())
+
+		rec := login(handler, "user@example.com", "correct-horse")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("login status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		if strings.Contains(rec.Body.String(), "password_hash") {
+			t.Error("login response leaked the password hash")
+		}
+
+		attempts, _ := db.GetLoginAttempts(context.Background(), "user@example.com", 10)
+		if len(attempts) != 1 || !attempts[0].Success {
+			t.Errorf("recorded attempts = %+v, want one success", attempts)
+		}
+	})
+
+	t.Run("rejects invalid credentials", func(t *testing.T) {
+		db, handler := setupAuthTest(t, ratelimit.DefaultPolicy())
+
+		rec := login(handler, "user@example.com", "wrong")
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("login status = %v, want %v", rec.Code, http.StatusUnauthorized)
+		}
+
+		attempts, _ := db.GetLoginAttempts(context.Background(), "user@example.com", 10)
+		if len(attempts) != 1 || attempts[0].Success || attempts[0].Reason != "invalid_credentials" {
+			t.Errorf("recorded attempts = %+v, want one invalid_credentials failure", attempts)
+		}
+	})
+
+	t.Run("locks out after repeated failures", func(t *testing.T) {
+		_, handler := setupAuthTest(t, ratelimit.Policy{
+			FreeAttempts:     1,
+			BaseDelay:        time.Minute,
+			MaxDelay:         time.Hour,
+			LockoutThreshold: 3,
+			LockoutDuration:  time.Hour,
+			ResetAfter:       time.Hour,
+		})
+
+		login(handler, "user@example.com", "wrong")
+		login(handler, "user@example.com", "wrong")
+
+		// Even the right password is refused while throttled
+		rec := login(handler, "user@example.com", "correct-horse")
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("login status = %v, want %v", rec.Code, http.StatusTooManyRequests)
+		}
+		if rec.Header().Get("Retry-After") == "" {
+			t.Error("throttled response is missing Retry-After")
+		}
+	})
+}
+
+func TestMe(t *testing.T) {
+	_, handler := setupAuthTest(t, ratelimit.DefaultPolicy())
+
+	req := httptest.NewRequest("GET", "/api/auth/me", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/auth/me status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+
+	loginRec := login(handler, "user@example.com", "correct-horse")
+	req = httptest.NewRequest("GET", "/api/auth/me", nil)
+	for _, c := range loginRec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /api/auth/me after login status = %v, want %v", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,347 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"dragonbytelabs/dz/internal/markdown"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/search"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+const (
+	// maxCollectionItems caps the paths of a collection
+	maxCollectionItems = 1000
+	// maxSmartResults caps the notes a smart collection returns
+	maxSmartResults = 500
+)
+
+// collectionRequest carries the fields of a collection to set; nil fields
+// are left alone. Setting a query makes the collection smart and drops its
+// paths; shared makes a team vault collection visible to the team.
+type collectionRequest struct {
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Query       *string  `json:"query"`
+	Paths       []string `json:"paths"`
+	Shared      *bool    `json:"shared"`
+}
+
+// CollectionInfo is a collection with the notes the caller can read in it
+type CollectionInfo struct {
+	models.Collection
+	Items    []search.Result `json:"items"`
+	Editable bool            `json:"editable"`
+}
+
+// collectionTeam returns the team owning the vault of c, or 0 for a
+// personal vault
+func collectionTeam(c *models.Collection) int64 {
+	id, ok := strings.CutPrefix(c.Vault, "teams/")
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(id, 10, 64)
+	return n
+}
+
+// openCollection returns the vault of c with a context carrying user's role
+// in it. It returns a nil vault when user may not read the collection:
+// only the owner reads a private one, and team members a shared one.
+func (vs *Vaults) openCollection(ctx context.Context, c *models.Collection, user *models.User) (*vault.Vault, context.Context, error) {
+	role := ""
+	if teamID := collectionTeam(c); teamID != 0 {
+		member, err := vs.db.GetTeamMember(ctx, teamID, user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if member != nil && (c.UserID == user.ID || c.TeamID != nil) {
+			role = member.Role
+		}
+	} else if c.Vault == fmt.Sprintf("users/%d", user.ID) {
+		role = models.RoleOwner
+	}
+	if role == "" {
+		return nil, nil, nil
+	}
+	v, err := vs.byName(c.Vault)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, vault.WithRole(ctx, role), nil
+}
+
+// readableCollection loads the collection named by the {id} path value with
+// its vault, writing a 404 unless user may read it
+func (vs *Vaults) readableCollection(w http.ResponseWriter, r *http.Request, user *models.User) (*models.Collection, *vault.Vault, context.Context, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", 400)
+		return nil, nil, nil, false
+	}
+	c, err := vs.db.GetCollection(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return nil, nil, nil, false
+	}
+	if c == nil {
+		http.Error(w, "collection not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+	v, ctx, err := vs.openCollection(r.Context(), c, user)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return nil, nil, nil, false
+	}
+	if v == nil {
+		http.Error(w, "collection not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+	return c, v, ctx, true
+}
+
+// apply sets the requested fields on c and returns the paths to store: nil
+// to keep the current ones. It writes a 400 for invalid fields.
+func (req *collectionRequest) apply(ctx context.Context, w http.ResponseWriter, v *vault.Vault, c *models.Collection) ([]string, bool) {
+	if req.Name != nil {
+		c.Name = strings.TrimSpace(*req.Name)
+	}
+	if c.Name == "" {
+		http.Error(w, "name is required", 400)
+		return nil, false
+	}
+	if req.Description != nil {
+		c.Description = req.Description
+	}
+	if req.Query != nil {
+		c.Query = strings.TrimSpace(*req.Query)
+		if c.Query != "" {
+			if _, err := search.Parse(c.Query); err != nil {
+				http.Error(w, err.Error(), 400)
+				return nil, false
+			}
+		}
+	}
+	if req.Shared != nil {
+		c.TeamID = nil
+		if *req.Shared {
+			teamID := collectionTeam(c)
+			if teamID == 0 {
+				http.Error(w, "only team vault collections can be shared", 400)
+				return nil, false
+			}
+			c.TeamID = &teamID
+		}
+	}
+
+	if c.Smart() {
+		if len(req.Paths) > 0 {
+			http.Error(w, "smart collections have no paths", 400)
+			return nil, false
+		}
+		return []string{}, true
+	}
+	if req.Paths == nil {
+		return nil, true
+	}
+	if len(req.Paths) > maxCollectionItems {
+		http.Error(w, fmt.Sprintf("collections hold at most %d notes", maxCollectionItems), 400)
+		return nil, false
+	}
+	paths := make([]string, 0, len(req.Paths))
+	seen := map[string]bool{}
+	for _, p := range req.Paths {
+		if !strings.EqualFold(path.Ext(p), ".md") {
+			http.Error(w, "collections hold notes only: "+p, 400)
+			return nil, false
+		}
+		res, err := v.ReadFile(ctx, p)
+		if err != nil {
+			vaultError(w, fmt.Errorf("%s: %w", p, err), 400)
+			return nil, false
+		}
+		if !seen[res.Path] {
+			seen[res.Path] = true
+			paths = append(paths, res.Path)
+		}
+	}
+	return paths, true
+}
+
+// collectionItems returns the notes of c that ctx may read: the matches of
+// its query, or its paths in order, skipping notes that are gone
+func collectionItems(ctx context.Context, v *vault.Vault, c *models.Collection, paths []string) ([]search.Result, error) {
+	if c.Smart() {
+		q, err := search.Parse(c.Query)
+		if err != nil {
+			return nil, err
+		}
+		return search.Run(ctx, v, q, maxSmartResults)
+	}
+	items := make([]search.Result, 0, len(paths))
+	for _, p := range paths {
+		res, err := v.ReadFile(ctx, p)
+		if err != nil {
+			continue
+		}
+		title := strings.TrimSuffix(path.Base(p), path.Ext(p))
+		if note, err := markdown.Parse([]byte(res.Content)); err == nil {
+			title = search.Title(p, note)
+		}
+		items = append(items, search.Result{Path: res.Path, Title: title, MTime: res.MTime})
+	}
+	return items, nil
+}
+
+// RegisterCollections registers the collections API. Owners manage their
+// collections; team members read the ones shared with their team. Stored
+// paths follow renames and drop notes that are deleted.
+func RegisterCollections(mux *http.ServeMux, vs *Vaults) {
+	db := vs.db
+	vs.Observe(func(ctx context.Context, e vault.Event) {
+		var err error
+		switch e.Op {
+		case vault.OpRename:
+			err = db.RenameCollectionItems(ctx, e.Vault, e.OldPath, e.Path)
+		case vault.OpDelete, vault.OpDeleteFolder:
+			err = db.RemoveCollectionItems(ctx, e.Vault, e.Path)
+		default:
+			return
+		}
+		if err != nil {
+			log.Printf("collections: following %s of %s in %s: %v", e.Op, e.Path, e.Vault, err)
+		}
+	})
+
+	mux.HandleFunc("GET /api/collections", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		collections, err := db.GetCollectionsByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, collections)
+	})
+
+	mux.HandleFunc("POST /api/collections", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		var req collectionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		c := &models.Collection{UserID: user.ID, Vault: v.Name()}
+		paths, ok := req.apply(r.Context(), w, v, c)
+		if !ok {
+			return
+		}
+		if paths == nil {
+			paths = []string{}
+		}
+		saved, err := db.CreateCollection(r.Context(), c, paths)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, saved)
+	})
+
+	mux.HandleFunc("GET /api/collections/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		c, v, ctx, ok := vs.readableCollection(w, r, user)
+		if !ok {
+			return
+		}
+		var paths []string
+		if !c.Smart() {
+			var err error
+			if paths, err = db.GetCollectionItems(ctx, c.ID); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+		}
+		items, err := collectionItems(ctx, v, c, paths)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, CollectionInfo{Collection: *c, Items: items, Editable: c.UserID == user.ID})
+	})
+
+	mux.HandleFunc("PUT /api/collections/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		c, v, ctx, ok := vs.readableCollection(w, r, user)
+		if !ok {
+			return
+		}
+		if c.UserID != user.ID {
+			http.Error(w, "only the owner can change a collection", http.StatusForbidden)
+			return
+		}
+		var req collectionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		paths, ok := req.apply(ctx, w, v, c)
+		if !ok {
+			return
+		}
+		saved, err := db.UpdateCollection(ctx, c, paths)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, saved)
+	})
+
+	mux.HandleFunc("DELETE /api/collections/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", 400)
+			return
+		}
+		c, err := db.GetCollection(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		// Owners can drop collections even after losing access to the vault
+		if c == nil || c.UserID != user.ID {
+			http.Error(w, "collection not found", http.StatusNotFound)
+			return
+		}
+		if err := db.DeleteCollection(r.Context(), id, user.ID); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+}
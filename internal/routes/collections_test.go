@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func TestCollections(t *testing.T) {
+	ts := newTestServer(t, "owner", "member", "other")
+	db, owner := ts.db, ts.users["owner"]
+	team, _ := db.CreateTeam(t.Context(), "Docs", nil, owner.ID)
+	db.AddTeamMember(t.Context(), team.ID, ts.users["member"].ID, models.RoleViewer)
+
+	vs := NewVaults(db, vault.NewManager(t.TempDir()), nil)
+	RegisterApi(ts.mux, vs)
+	RegisterCollections(ts.mux, vs)
+	ts.login()
+	do := ts.do
+	collection := func(who, method, path, body string) (CollectionInfo, *httptest.ResponseRecorder) {
+		rec := do(who, method, path, body)
+		var c CollectionInfo
+		json.NewDecoder(strings.NewReader(rec.Body.String())).Decode(&c)
+		return c, rec
+	}
+	paths := func(c CollectionInfo) string {
+		var out []string
+		for _, item := range c.Items {
+			out = append(out, item.Path)
+		}
+		return strings.Join(out, ",")
+	}
+
+	do("owner", "POST", "/api/file", `{"path":"ideas/plan.md","content":"---\ntitle: The Plan\ntags: [work]\n---\nStep one"}`)
+	do("owner", "POST", "/api/file", `{"path":"ideas/later.md","content":"Someday #work"}`)
+	do("owner", "POST", "/api/file", `{"path":"journal.md","content":"Dear diary"}`)
+
+	var reading CollectionInfo
+	t.Run("keeps notes in order", func(t *testing.T) {
+		var rec *httptest.ResponseRecorder
+		reading, rec = collection("owner", "POST", "/api/collections", `{"name":"Reading","paths":["journal.md","ideas/plan.md","journal.md"]}`)
+		if rec.Code != http.StatusOK || reading.Vault != fmt.Sprintf("users/%d", owner.ID) {
+			t.Fatalf("POST /api/collections = %v %s", rec.Code, rec.Body)
+		}
+		got, rec := collection("owner", "GET", fmt.Sprintf("/api/collections/%d", reading.ID), "")
+		if rec.Code != http.StatusOK || paths(got) != "journal.md,ideas/plan.md" || got.Items[1].Title != "The Plan" || !got.Editable {
+			t.Errorf("GET collection = %v %+v", rec.Code, got)
+		}
+		for body, want := range map[string]int{
+			`{"name":" "}`:                        400,
+			`{"name":"x","paths":["missing.md"]}`: 400,
+			`{"name":"x","paths":["a.png"]}`:      400,
+			`{"name":"x","query":"\"open"}`:       400,
+			`{"name":"x","shared":true}`:          400,
+		} {
+			if _, rec := collection("owner", "POST", "/api/collections", body); rec.Code != want {
+				t.Errorf("POST %s status = %v, want %v", body, rec.Code, want)
+			}
+		}
+	})
+
+	t.Run("follows renames and deletes", func(t *testing.T) {
+		do("owner", "PATCH", "/api/file", `{"oldPath":"ideas","newPath":"archive/ideas"}`)
+		do("owner", "DELETE", "/api/file?path=journal.md", "")
+		got, _ := collection("owner", "GET", fmt.Sprintf("/api/collections/%d", reading.ID), "")
+		if paths(got) != "archive/ideas/plan.md" {
+			t.Errorf("items after rename and delete = %q", paths(got))
+		}
+	})
+
+	t.Run("evaluates smart collections on read", func(t *testing.T) {
+		smart, rec := collection("owner", "POST", "/api/collections", `{"name":"Work","query":"tag:work"}`)
+		if rec.Code != http.StatusOK || smart.Query != "tag:work" {
+			t.Fatalf("POST smart collection = %v %s", rec.Code, rec.Body)
+		}
+		path := fmt.Sprintf("/api/collections/%d", smart.ID)
+		if got, _ := collection("owner", "GET", path, ""); len(got.Items) != 2 {
+			t.Errorf("smart items = %+v, want both work notes", got.Items)
+		}
+		do("owner", "POST", "/api/file", `{"path":"new.md","content":"#work too"}`)
+		if got, _ := collection("owner", "GET", path, ""); len(got.Items) != 3 || got.Items[0].Path != "new.md" {
+			t.Errorf("smart items after a new note = %+v", got.Items)
+		}
+		if _, rec := collection("owner", "PUT", path, `{"paths":["new.md"]}`); rec.Code != 400 {
+			t.Errorf("PUT paths on smart collection status = %v, want 400", rec.Code)
+		}
+	})
+
+	t.Run("shares with the team", func(t *testing.T) {
+		teamVault := fmt.Sprintf("?vault=team:%d", team.ID)
+		do("owner", "POST", "/api/file"+teamVault, `{"path":"team.md","content":"shared"}`)
+		shared, rec := collection("owner", "POST", "/api/collections"+teamVault, `{"name":"Team","paths":["team.md"]}`)
+		if rec.Code != http.StatusOK || shared.TeamID != nil {
+			t.Fatalf("POST team collection = %v %s", rec.Code, rec.Body)
+		}
+		path := fmt.Sprintf("/api/collections/%d", shared.ID)
+		if rec := do("member", "GET", path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("member GET before sharing status = %v, want 404", rec.Code)
+		}
+		collection("owner", "PUT", path, `{"shared":true}`)
+		got, rec := collection("member", "GET", path, "")
+		if rec.Code != http.StatusOK || paths(got) != "team.md" || got.Editable {
+			t.Errorf("member GET shared = %v %+v", rec.Code, got)
+		}
+		if _, rec := collection("member", "PUT", path, `{"name":"Mine"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("member PUT status = %v, want 403", rec.Code)
+		}
+		if rec := do("member", "DELETE", path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("member DELETE status = %v, want 404", rec.Code)
+		}
+		if rec := do("other", "GET", path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("outsider GET status = %v, want 404", rec.Code)
+		}
+		var list []models.Collection
+		json.NewDecoder(do("member", "GET", "/api/collections", "").Body).Decode(&list)
+		if len(list) != 1 || list[0].ID != shared.ID {
+			t.Errorf("member lists %+v, want the shared collection", list)
+		}
+	})
+
+	t.Run("deletes", func(t *testing.T) {
+		path := fmt.Sprintf("/api/collections/%d", reading.ID)
+		if rec := do("owner", "DELETE", path, ""); rec.Code != http.StatusOK {
+			t.Fatalf("DELETE status = %v", rec.Code)
+		}
+		if rec := do("owner", "GET", path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET deleted status = %v, want 404", rec.Code)
+		}
+		if rec := do("", "GET", "/api/collections", ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous list status = %v, want 401", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,393 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/forms"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/ratelimit"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// FormPrefix is where forms are served and submitted; these pages need no session
+const FormPrefix = "/f/"
+
+// maxSubmissionSize caps the body of one submission
+const maxSubmissionSize = 64 << 10
+
+// formRequest is a form as admins create and edit it
+type formRequest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Fields      models.FormFields `json:"fields"`
+	CapturePath string            `json:"capture_path"` // note submissions are appended to, in ?vault=
+}
+
+// formPage renders a form for visitors, and thanks them once it is sent
+var formPage = template.Must(template.New("form").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Form.Name}}</title>
+<style>
+body { font-family: system-ui, sans-serif; line-height: 1.6; max-width: 36rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+label { display: block; margin-top: 1rem; font-weight: 600; }
+input:not([type=checkbox]), select { display: block; width: 100%; padding: .4rem; box-sizing: border-box; }
+button { margin-top: 1.5rem; }
+.error { color: #b00020; font-weight: normal; }
+.trap { position: absolute; left: -10000px; }
+</style>
+</head>
+<body>
+<h1>{{.Form.Name}}</h1>
+{{if .Done}}<p>Thanks, your submission was received.</p>{{else}}
+{{with .Form.Description}}<p>{{.}}</p>{{end}}
+<form method="post">
+{{range .Form.Fields}}{{$value := index $.Values .Name}}{{$error := index $.Errors .Name}}
+<label>{{if eq .Type "checkbox"}}<input type="checkbox" name="{{.Name}}" value="true"{{if eq $value "true"}} checked{{end}}{{if .Required}} required{{end}}> {{.Label}}{{else}}{{.Label}}{{end}}
+{{with $error}}<span class="error">{{.}}</span>{{end}}
+{{if eq .Type "select"}}<select name="{{.Name}}"{{if .Required}} required{{end}}>
+<option value=""></option>
+{{range .Options}}<option{{if eq . $value}} selected{{end}}>{{.}}</option>
+{{end}}</select>
+{{else if eq .Type "number"}}<input type="number" step="any" name="{{.Name}}" value="{{$value}}"{{with .Min}} min="{{.}}"{{end}}{{with .Max}} max="{{.}}"{{end}}{{if .Required}} required{{end}}>
+{{else if ne .Type "checkbox"}}<input type="{{.Type}}" name="{{.Name}}" value="{{$value}}"{{with .MaxLen}} maxlength="{{.}}"{{end}}{{if .Required}} required{{end}}>
+{{end}}</label>
+{{end}}
+<div class="trap" aria-hidden="true"><label>Leave this empty <input type="text" name="_website" tabindex="-1" autocomplete="off"></label></div>
+<button type="submit">Send</button>
+</form>{{end}}
+</body>
+</html>
+`))
+
+type formPageData struct {
+	Form   *models.Form
+	Values map[string]string
+	Errors forms.FieldErrors
+	Done   bool
+}
+
+func renderFormPage(w http.ResponseWriter, status int, data formPageData) {
+	var buf bytes.Buffer
+	if err := formPage.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
+
+// formByID loads the form named by the {id} path value, writing a 404 if
+// there is none
+func formByID(w http.ResponseWriter, r *http.Request, db *dbx.DB) (*models.Form, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "form not found", http.StatusNotFound)
+		return nil, false
+	}
+	f, err := db.GetForm(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return nil, false
+	}
+	if f == nil {
+		http.Error(w, "form not found", http.StatusNotFound)
+		return nil, false
+	}
+	return f, true
+}
+
+// submittedValues reads a submission sent as JSON or as a posted HTML form
+func submittedValues(r *http.Request) (url.Values, bool, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := r.ParseForm(); err != nil {
+			return nil, false, err
+		}
+		return r.PostForm, false, nil
+	}
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, true, errors.New("invalid json body")
+	}
+	values := url.Values{}
+	for k, v := range body {
+		switch v := v.(type) {
+		case string:
+			values.Set(k, v)
+		case bool, float64:
+			values.Set(k, fmt.Sprint(v))
+		}
+	}
+	return values, true, nil
+}
+
+// RegisterForms registers form management for admins and the public /f/
+// pages visitors submit forms through. Submissions are rate limited per
+// client and may be appended to a vault note.
+func RegisterForms(mux *http.ServeMux, vs *Vaults, limiter *ratelimit.Limiter) {
+	db := vs.db
+
+	// save validates a form and where it captures to, then stores it
+	save := func(w http.ResponseWriter, r *http.Request, user *models.User, f *models.Form) {
+		var req formRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		f.Name, f.Description, f.Fields = req.Name, strings.TrimSpace(req.Description), req.Fields
+		if err := forms.Validate(f); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		f.CaptureVault, f.CapturePath, f.CaptureRole = "", "", ""
+		if req.CapturePath != "" {
+			if !strings.EqualFold(path.Ext(req.CapturePath), ".md") {
+				http.Error(w, "submissions can only be captured to a note", 400)
+				return
+			}
+			v, r, ok := vs.resolve(w, r)
+			if !ok {
+				return
+			}
+			role, _ := vault.RoleFrom(r.Context())
+			if !models.RoleAtLeast(role, models.RoleEditor) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			capturePath := path.Clean(strings.TrimPrefix(req.CapturePath, "/"))
+			if err := v.CheckWrite(r.Context(), capturePath); err != nil {
+				vaultError(w, err, 400)
+				return
+			}
+			f.CaptureVault, f.CapturePath, f.CaptureRole = v.Name(), capturePath, role
+		}
+
+		var saved *models.Form
+		var err error
+		action := models.AuditFormUpdated
+		if f.ID == 0 {
+			action = models.AuditFormCreated
+			saved, err = db.CreateForm(r.Context(), f)
+		} else {
+			saved, err = db.UpdateForm(r.Context(), f)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: action, Target: saved.Name, Details: fmt.Sprintf("form %d", saved.ID)})
+		writeJSON(w, saved)
+	}
+
+	mux.HandleFunc("GET /api/forms", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		all, err := db.GetForms(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, all)
+	})
+
+	mux.HandleFunc("POST /api/forms", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		save(w, r, user, &models.Form{CreatedBy: &user.ID})
+	})
+
+	mux.HandleFunc("GET /api/forms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		if f, ok := formByID(w, r, db); ok {
+			writeJSON(w, f)
+		}
+	})
+
+	mux.HandleFunc("PUT /api/forms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		if f, ok := formByID(w, r, db); ok {
+			save(w, r, user, f)
+		}
+	})
+
+	mux.HandleFunc("DELETE /api/forms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		f, ok := formByID(w, r, db)
+		if !ok {
+			return
+		}
+		if err := db.DeleteForm(r.Context(), f.ID); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditFormDeleted, Target: f.Name, Details: fmt.Sprintf("form %d", f.ID)})
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+
+	mux.HandleFunc("GET /api/forms/{id}/submissions", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		f, ok := formByID(w, r, db)
+		if !ok {
+			return
+		}
+		subs, err := db.GetFormSubmissions(r.Context(), f.ID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, subs)
+	})
+
+	mux.HandleFunc("GET /api/forms/{id}/submissions.csv", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		f, ok := formByID(w, r, db)
+		if !ok {
+			return
+		}
+		subs, err := db.GetFormSubmissions(r.Context(), f.ID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditFormExported, Target: f.Name, Details: fmt.Sprintf("form %d", f.ID)})
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="form-%d-submissions.csv"`, f.ID))
+		_ = forms.WriteCSV(w, f, subs)
+	})
+
+	// Captures append to the end of a note; one at a time, so none is lost.
+	// They are written with the role of the form's creator, so the vault's
+	// folder rules still apply.
+	var captureMu sync.Mutex
+	capture := func(ctx context.Context, f *models.Form, s *models.FormSubmission) error {
+		v, err := vs.byName(f.CaptureVault)
+		if err != nil {
+			return err
+		}
+		ctx = vault.WithRole(ctx, f.CaptureRole)
+		captureMu.Lock()
+		defer captureMu.Unlock()
+
+		content := "# " + f.Name + "\n"
+		var ifMatch string
+		if res, err := v.ReadFile(ctx, f.CapturePath); err == nil {
+			content, ifMatch = strings.TrimRight(res.Content, "\n")+"\n", res.Hash
+		}
+		_, err = v.WriteFile(ctx, f.CapturePath, vault.WriteRequest{Content: content + forms.Capture(f, s), IfMatch: ifMatch})
+		return err
+	}
+
+	mux.HandleFunc("GET "+FormPrefix+"{id}", func(w http.ResponseWriter, r *http.Request) {
+		if f, ok := formByID(w, r, db); ok {
+			renderFormPage(w, http.StatusOK, formPageData{Form: f})
+		}
+	})
+
+	// Accepts a posted HTML form, answering with a page, or a JSON object,
+	// answering with JSON
+	mux.HandleFunc("POST "+FormPrefix+"{id}", func(w http.ResponseWriter, r *http.Request) {
+		f, ok := formByID(w, r, db)
+		if !ok {
+			return
+		}
+		ip := clientIP(r)
+		key := "form:" + ip
+		if wait, err := limiter.Check(key); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		} else if wait > 0 {
+			tooManyAttempts(w, wait, "submissions")
+			return
+		}
+		// Every submission counts, so rejected spam slows its sender too
+		if _, err := limiter.Fail(key); err != nil {
+			log.Printf("forms: rate limiting %s: %v", ip, err)
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSubmissionSize)
+		values, isJSON, err := submittedValues(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		respond := func(status int, errs forms.FieldErrors) {
+			if isJSON {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				if errs != nil {
+					_ = json.NewEncoder(w).Encode(map[string]any{"errors": errs})
+				} else {
+					_ = json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+				}
+				return
+			}
+			data := formPageData{Form: f, Errors: errs, Done: errs == nil, Values: map[string]string{}}
+			for k := range values {
+				data.Values[k] = values.Get(k)
+			}
+			renderFormPage(w, status, data)
+		}
+
+		// Bots that fill in the hidden field are told it worked
+		if values.Get(forms.HoneypotField) != "" {
+			log.Printf("forms: dropped spam for form %d from %s", f.ID, ip)
+			respond(http.StatusOK, nil)
+			return
+		}
+		data, err := forms.Clean(f.Fields, values)
+		var errs forms.FieldErrors
+		if errors.As(err, &errs) {
+			respond(400, errs)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		sub, err := db.CreateFormSubmission(r.Context(), f.ID, data, ip, r.UserAgent())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if f.CapturePath != "" {
+			if err := capture(r.Context(), f, sub); err != nil {
+				log.Printf("forms: capturing submission %d to %s in %s: %v", sub.ID, f.CapturePath, f.CaptureVault, err)
+			}
+		}
+		respond(http.StatusOK, nil)
+	})
+}
@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"dragonbytelabs/dz/internal/forms"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/ratelimit"
+	"dragonbytelabs/dz/internal/session"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func TestForms(t *testing.T) {
+	ts := newTestServer(t, "admin", "user")
+	db, admin := ts.db, ts.users["admin"]
+	db.SetUserAdmin(t.Context(), admin.ID, true)
+
+	vs := NewVaults(db, vault.NewManager(t.TempDir()), nil)
+	RegisterApi(ts.mux, vs)
+	RegisterForms(ts.mux, vs, ratelimit.NewLimiter(ratelimit.NewInMemoryStore(), forms.SubmitPolicy(), 0))
+	ts.handler = ts.sm.Handle(session.CSRF(ts.mux, FormPrefix))
+	ts.login()
+	do := ts.do
+	request := func(who, method, path, contentType, body, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if ip != "" {
+			req.RemoteAddr = ip + ":1234"
+		}
+		return ts.serve(who, req)
+	}
+	submit := func(id int64, values url.Values, ip string) *httptest.ResponseRecorder {
+		return request("", "POST", fmt.Sprintf("/f/%d", id), "application/x-www-form-urlencoded", values.Encode(), ip)
+	}
+
+	var form models.Form
+	t.Run("admins create forms", func(t *testing.T) {
+		body := `{"name":"Contact","fields":[{"name":"email","label":"Email","type":"email","required":true},` +
+			`{"name":"topic","type":"select","options":["sales","support"]},{"name":"note","type":"text"}],"capture_path":"inbox/contact.md"}`
+		if rec := do("user", "POST", "/api/forms", body); rec.Code != http.StatusForbidden {
+			t.Errorf("POST /api/forms as user status = %v, want 403", rec.Code)
+		}
+		if rec := do("admin", "POST", "/api/forms", `{"name":"Bad","fields":[{"name":"x","type":"color"}]}`); rec.Code != 400 {
+			t.Errorf("POST invalid schema status = %v, want 400", rec.Code)
+		}
+		rec := do("admin", "POST", "/api/forms", body)
+		json.NewDecoder(rec.Body).Decode(&form)
+		if rec.Code != http.StatusOK || len(form.Fields) != 3 || form.CaptureVault != fmt.Sprintf("users/%d", admin.ID) {
+			t.Fatalf("POST /api/forms = %v %+v", rec.Code, form)
+		}
+	})
+
+	t.Run("renders the public form", func(t *testing.T) {
+		rec := do("", "GET", fmt.Sprintf("/f/%d", form.ID), "")
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || !strings.Contains(body, `<input type="email" name="email" value="" required>`) ||
+			!strings.Contains(body, "<option>support</option>") || !strings.Contains(body, `name="_website"`) {
+			t.Errorf("GET form = %v %q", rec.Code, body)
+		}
+	})
+
+	t.Run("validates submissions", func(t *testing.T) {
+		rec := submit(form.ID, url.Values{"email": {"not an email"}, "topic": {"sales"}}, "10.0.0.1")
+		if rec.Code != 400 || !strings.Contains(rec.Body.String(), "not an email address") || !strings.Contains(rec.Body.String(), `value="not an email"`) {
+			t.Errorf("invalid submission = %v %q", rec.Code, rec.Body.String())
+		}
+		rec = request("", "POST", fmt.Sprintf("/f/%d", form.ID), "application/json", `{"topic":"other"}`, "10.0.0.1")
+		var out struct{ Errors map[string]string }
+		json.NewDecoder(rec.Body).Decode(&out)
+		if rec.Code != 400 || out.Errors["email"] != "required" || out.Errors["topic"] == "" {
+			t.Errorf("invalid json submission = %v %+v", rec.Code, out)
+		}
+	})
+
+	t.Run("stores and captures submissions", func(t *testing.T) {
+		rec := submit(form.ID, url.Values{"email": {"ada@example.com"}, "note": {"Hello"}}, "10.0.0.2")
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "submission was received") {
+			t.Fatalf("submission = %v %q", rec.Code, rec.Body.String())
+		}
+		rec = request("", "POST", fmt.Sprintf("/f/%d", form.ID), "application/json", `{"email":"bob@example.com","topic":"sales"}`, "10.0.0.2")
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"ok":true}` {
+			t.Fatalf("json submission = %v %q", rec.Code, rec.Body.String())
+		}
+
+		var subs []models.FormSubmission
+		json.NewDecoder(do("admin", "GET", fmt.Sprintf("/api/forms/%d/submissions", form.ID), "").Body).Decode(&subs)
+		if len(subs) != 2 || subs[0].Data["email"] != "ada@example.com" || subs[0].IP != "10.0.0.2" {
+			t.Errorf("submissions = %+v", subs)
+		}
+
+		note := do("admin", "GET", "/api/file?path=inbox/contact.md", "").Body.String()
+		if !strings.Contains(note, "# Contact") || !strings.Contains(note, `- **Email**: ada@example.com`) || !strings.Contains(note, `bob@example.com`) {
+			t.Errorf("capture note = %q", note)
+		}
+	})
+
+	t.Run("drops honeypot spam", func(t *testing.T) {
+		rec := submit(form.ID, url.Values{"email": {"bot@example.com"}, "_website": {"http://spam"}}, "10.0.0.3")
+		if rec.Code != http.StatusOK {
+			t.Errorf("honeypot submission status = %v, want 200", rec.Code)
+		}
+		if subs, _ := db.GetFormSubmissions(t.Context(), form.ID); len(subs) != 2 {
+			t.Errorf("%d submissions stored, want the spam dropped", len(subs))
+		}
+	})
+
+	t.Run("rate limits per client", func(t *testing.T) {
+		codes := []int{}
+		for range forms.SubmitPolicy().FreeAttempts + 2 {
+			codes = append(codes, submit(form.ID, url.Values{"email": {"a@example.com"}}, "10.0.0.4").Code)
+		}
+		if codes[len(codes)-1] != http.StatusTooManyRequests {
+			t.Errorf("status codes = %v, want the last throttled", codes)
+		}
+		if rec := submit(form.ID, url.Values{"email": {"a@example.com"}}, "10.0.0.5"); rec.Code != http.StatusOK {
+			t.Errorf("other client status = %v, want 200", rec.Code)
+		}
+	})
+
+	t.Run("exports CSV", func(t *testing.T) {
+		rec := do("admin", "GET", fmt.Sprintf("/api/forms/%d/submissions.csv", form.ID), "")
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv" || lines[0] != "id,submitted_at,email,topic,note" {
+			t.Fatalf("CSV export = %v %q", rec.Code, rec.Body.String())
+		}
+		if rec := do("user", "GET", fmt.Sprintf("/api/forms/%d/submissions.csv", form.ID), ""); rec.Code != http.StatusForbidden {
+			t.Errorf("CSV export as user status = %v, want 403", rec.Code)
+		}
+	})
+
+	t.Run("deletes forms", func(t *testing.T) {
+		if rec := do("admin", "DELETE", fmt.Sprintf("/api/forms/%d", form.ID), ""); rec.Code != http.StatusOK {
+			t.Fatalf("DELETE status = %v", rec.Code)
+		}
+		if rec := do("", "GET", fmt.Sprintf("/f/%d", form.ID), ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET deleted form status = %v, want 404", rec.Code)
+		}
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: "form"})
+		if len(events) != 3 {
+			t.Errorf("form audit events = %+v", events)
+		}
+	})
+
+	t.Run("captures with the creator's role", func(t *testing.T) {
+		team, _ := db.CreateTeam(t.Context(), "Sales", nil, ts.users["user"].ID)
+		db.AddTeamMember(t.Context(), team.ID, admin.ID, models.RoleEditor)
+		tv, _ := vs.manager.Team(team.ID)
+		tv.SetACL(t.Context(), vault.ACL{Rules: []vault.Rule{{Prefix: "private/", Read: models.RoleViewer, Write: models.RoleAdmin}}})
+		query := fmt.Sprintf("?vault=team:%d", team.ID)
+
+		body := `{"name":"Leads","fields":[{"name":"email","type":"email"}],"capture_path":"Private/leads.md"}`
+		if rec := do("admin", "POST", "/api/forms"+query, body); rec.Code != http.StatusForbidden {
+			t.Errorf("POST form capturing to a protected folder as a team editor = %v, want 403", rec.Code)
+		}
+
+		// Rules added after the form was saved hold too
+		var leads models.Form
+		rec := do("admin", "POST", "/api/forms"+query, `{"name":"Leads","fields":[{"name":"email","type":"email"}],"capture_path":"inbox/leads.md"}`)
+		json.NewDecoder(rec.Body).Decode(&leads)
+		if rec.Code != http.StatusOK || leads.CaptureRole != models.RoleEditor {
+			t.Fatalf("POST form = %v %+v", rec.Code, leads)
+		}
+		tv.SetACL(t.Context(), vault.ACL{Rules: []vault.Rule{{Prefix: "inbox/", Read: models.RoleViewer, Write: models.RoleAdmin}}})
+		if rec := submit(leads.ID, url.Values{"email": {"lead@example.com"}}, "10.0.0.6"); rec.Code != http.StatusOK {
+			t.Fatalf("submission = %v %q", rec.Code, rec.Body.String())
+		}
+		if _, err := tv.ReadFile(t.Context(), "inbox/leads.md"); err == nil {
+			t.Error("captured to a folder the form's creator can't write")
+		}
+	})
+}
@@ -12,7 +12,7 @@ type Info struct {
 	Version string `json:"version"`
 }
 
-func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
+func RegisterApi(mux *http.ServeMux, vaults *Vaults) {
 	mux.HandleFunc("GET /api/info", func(w http.ResponseWriter, _ *http.Request) {
 		fmt.Printf("/api/info called\n")
 		w.Header().Set("Content-Type", "application/json")
@@ -25,25 +25,40 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 		w.Write([]byte(`{"ok":true}`))
 	})
 	mux.HandleFunc("GET /api/files", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vaults.resolve(w, r)
+		if !ok {
+			return
+		}
+
 		files, err := v.ListMarkdown(r.Context())
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			vaultError(w, err, 500)
 			return
 		}
 		writeJSON(w, files)
 	})
 
 	mux.HandleFunc("GET /api/file", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vaults.resolve(w, r)
+		if !ok {
+			return
+		}
+
 		p := r.URL.Query().Get("path")
 		res, err := v.ReadFile(r.Context(), p)
 		if err != nil {
-			http.Error(w, err.Error(), 400)
+			vaultError(w, err, 400)
 			return
 		}
 		writeJSON(w, res)
 	})
 
 	mux.HandleFunc("POST /api/file", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vaults.resolve(w, r)
+		if !ok {
+			return
+		}
+
 		var req struct {
 			Path    string `json:"path"`
 			Content string `json:"content"`
@@ -53,9 +68,13 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 			return
 		}
 
-		// Check if file already exists
-		_, err := v.ReadFile(r.Context(), req.Path)
-		if err == nil {
+		// Check if file already exists, even if the caller can't read it
+		exists, err := v.Exists(r.Context(), req.Path)
+		if err != nil {
+			vaultError(w, err, 400)
+			return
+		}
+		if exists {
 			http.Error(w, "file already exists", http.StatusConflict)
 			return
 		}
@@ -64,7 +83,7 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 		writeReq := vault.WriteRequest{Content: req.Content}
 		res, err := v.WriteFile(r.Context(), req.Path, writeReq)
 		if err != nil {
-			http.Error(w, err.Error(), 400)
+			vaultError(w, err, 400)
 			return
 		}
 
@@ -72,6 +91,11 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 	})
 
 	mux.HandleFunc("PUT /api/file", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vaults.resolve(w, r)
+		if !ok {
+			return
+		}
+
 		p := r.URL.Query().Get("path")
 
 		var req vault.WriteRequest
@@ -86,7 +110,7 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 				http.Error(w, err.Error(), http.StatusConflict)
 				return
 			}
-			http.Error(w, err.Error(), 400)
+			vaultError(w, err, 400)
 			return
 		}
 
@@ -94,15 +118,25 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 	})
 
 	mux.HandleFunc("GET /api/tree", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vaults.resolve(w, r)
+		if !ok {
+			return
+		}
+
 		entries, err := v.ListEntries(r.Context())
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			vaultError(w, err, 500)
 			return
 		}
 		writeJSON(w, entries)
 	})
 
 	mux.HandleFunc("DELETE /api/file", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vaults.resolve(w, r)
+		if !ok {
+			return
+		}
+
 		p := r.URL.Query().Get("path")
 		if p == "" {
 			http.Error(w, "path parameter required", 400)
@@ -110,7 +144,7 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 		}
 
 		if err := v.DeleteFile(r.Context(), p); err != nil {
-			http.Error(w, err.Error(), 500)
+			vaultError(w, err, 500)
 			return
 		}
 
@@ -118,6 +152,11 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 	})
 
 	mux.HandleFunc("POST /api/folder", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vaults.resolve(w, r)
+		if !ok {
+			return
+		}
+
 		var req struct {
 			Path string `json:"path"`
 		}
@@ -132,7 +171,7 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 		}
 
 		if err := v.CreateFolder(r.Context(), req.Path); err != nil {
-			http.Error(w, err.Error(), 500)
+			vaultError(w, err, 500)
 			return
 		}
 
@@ -140,6 +179,11 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 	})
 
 	mux.HandleFunc("DELETE /api/folder", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vaults.resolve(w, r)
+		if !ok {
+			return
+		}
+
 		p := r.URL.Query().Get("path")
 		if p == "" {
 			http.Error(w, "path parameter required", 400)
@@ -147,7 +191,7 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 		}
 
 		if err := v.DeleteFolder(r.Context(), p); err != nil {
-			http.Error(w, err.Error(), 500)
+			vaultError(w, err, 500)
 			return
 		}
 
@@ -155,6 +199,11 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 	})
 
 	mux.HandleFunc("PATCH /api/file", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vaults.resolve(w, r)
+		if !ok {
+			return
+		}
+
 		var req struct {
 			OldPath string `json:"oldPath"`
 			NewPath string `json:"newPath"`
@@ -170,7 +219,7 @@ func RegisterApi(mux *http.ServeMux, v *vault.Vault) {
 		}
 
 		if err := v.RenameFile(r.Context(), req.OldPath, req.NewPath); err != nil {
-			http.Error(w, err.Error(), 500)
+			vaultError(w, err, 500)
 			return
 		}
 
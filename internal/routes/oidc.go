@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/oidc"
+	"dragonbytelabs/dz/internal/session"
+)
+
+// RegisterOIDC registers the single sign-on login and callback endpoints
+func RegisterOIDC(mux *http.ServeMux, db *dbx.DB, sm *session.SessionManager, provider *oidc.Provider, opts oidc.ProvisionOptions) {
+	mux.HandleFunc("GET /api/auth/oidc/login", func(w http.ResponseWriter, r *http.Request) {
+		state := oidc.RandomString()
+		nonce := oidc.RandomString()
+		verifier := oidc.RandomString()
+
+		sess := session.GetSession(r)
+		sess.Put("oidc_state", state)
+		sess.Put("oidc_nonce", nonce)
+		sess.Put("oidc_verifier", verifier)
+
+		http.Redirect(w, r, provider.AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier)), http.StatusFound)
+	})
+
+	mux.HandleFunc("GET /api/auth/oidc/callback", func(w http.ResponseWriter, r *http.Request) {
+		sess := session.GetSession(r)
+		state, _ := sess.Get("oidc_state").(string)
+		nonce, _ := sess.Get("oidc_nonce").(string)
+		verifier, _ := sess.Get("oidc_verifier").(string)
+
+		// The flow values are single use
+		sess.Delete("oidc_state")
+		sess.Delete("oidc_nonce")
+		sess.Delete("oidc_verifier")
+
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			http.Error(w, "sign-in was not completed: "+e, http.StatusUnauthorized)
+			return
+		}
+		if state == "" || q.Get("state") != state {
+			http.Error(w, "invalid sign-in state", http.StatusBadRequest)
+			return
+		}
+
+		token, err := provider.Exchange(r.Context(), q.Get("code"), verifier)
+		if err != nil {
+			log.Printf("oidc: exchange failed: %v", err)
+			http.Error(w, "sign-in failed", http.StatusUnauthorized)
+			return
+		}
+		claims, err := provider.Verify(r.Context(), token.IDToken, nonce)
+		if err != nil {
+			log.Printf("oidc: id token rejected: %v", err)
+			http.Error(w, "sign-in failed", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := oidc.Provision(r.Context(), db, claims, opts)
+		if err != nil {
+			log.Printf("oidc: provisioning failed: %v", err)
+			http.Error(w, "sign-in failed", http.StatusForbidden)
+			return
+		}
+
+		if err := sm.Migrate(sess); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		sess.Put("user_id", user.UserHash)
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditLogin, Details: "oidc " + claims.Issuer})
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+}
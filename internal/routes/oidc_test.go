@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/oidc"
+	"dragonbytelabs/dz/internal/oidc/oidctest"
+	"dragonbytelabs/dz/internal/ratelimit"
+	"dragonbytelabs/dz/internal/session"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	fake := oidctest.NewProvider("deez")
+	defer fake.Close()
+	fake.Claims = map[string]any{
+		"sub":            "sso-user",
+		"email":          "sso@example.com",
+		"email_verified": true,
+		"name":           "SSO User",
+	}
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:      fake.Issuer(),
+		ClientID:    "deez",
+		RedirectURL: "http://app.test/api/auth/oidc/callback",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Discover() returned error: %v", err)
+	}
+
+	db := dbx.SetupTestDB(t)
+	defer db.Close()
+
+	sm := session.NewSessionManager(session.NewInMemoryStore(), 30*time.Minute, 1*time.Hour, 12*time.Hour, "session_id")
+	mux := http.NewServeMux()
+	RegisterAuth(mux, db, sm, ratelimit.NewLimiter(ratelimit.NewInMemoryStore(), ratelimit.DefaultPolicy(), 0))
+	RegisterOIDC(mux, db, sm, provider, oidc.ProvisionOptions{LinkByEmail: true})
+	handler := sm.Handle(mux)
+
+	// Start the flow
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %v, want %v", rec.Code, http.StatusFound)
+	}
+	cookies := rec.Result().Cookies()
+
+	// The user approves at the provider
+	callback, err := fake.Authorize(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Authorize() returned error: %v", err)
+	}
+	cbURL, _ := url.Parse(callback)
+
+	t.Run("rejects a callback without the login session", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", cbURL.RequestURI(), nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("callback status = %v, want %v", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	req := httptest.NewRequest("GET", cbURL.RequestURI(), nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("callback status = %v, want %v: %s", rec.Code, http.StatusSeeOther, rec.Body.String())
+	}
+
+	user, err := db.GetUserByIdentity(context.Background(), fake.Issuer(), "sso-user")
+	if err != nil || user == nil {
+		t.Fatalf("GetUserByIdentity() = %v, %v; want provisioned user", user, err)
+	}
+
+	// The migrated session is authenticated
+	req = httptest.NewRequest("GET", "/api/auth/me", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /api/auth/me status = %v, want %v", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+)
+
+// RegisterPlugins registers the plugin registry the editor loads its
+// plugins from. Everyone sees the enabled plugins; admins see every
+// installed plugin, enable or disable them and grant their hooks the
+// capabilities they ask for. Installing and removing plugins is done with
+// `dz plugin`.
+func RegisterPlugins(mux *http.ServeMux, db *dbx.DB) {
+	mux.HandleFunc("GET /api/plugins", func(w http.ResponseWriter, r *http.Request) {
+		user, err := currentUser(r, db)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		var plugins []models.Plugin
+		if user != nil && user.IsAdmin {
+			plugins, err = db.GetPlugins(r.Context())
+		} else {
+			plugins, err = db.GetActivePlugins(r.Context())
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, plugins)
+	})
+
+	mux.HandleFunc("PUT /api/plugins/{name}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		var req struct {
+			Active       *bool               `json:"active"`
+			Capabilities models.Capabilities `json:"capabilities"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Active == nil && req.Capabilities == nil) {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		p, err := db.GetPlugin(r.Context(), r.PathValue("name"))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if p == nil {
+			http.Error(w, "plugin not found", http.StatusNotFound)
+			return
+		}
+		if req.Capabilities != nil {
+			for _, c := range req.Capabilities {
+				if !p.Capabilities.Has(c) {
+					http.Error(w, fmt.Sprintf("plugin %s does not ask for %q", p.Name, c), 400)
+					return
+				}
+			}
+			if err := db.SetPluginGrants(r.Context(), p.Name, req.Capabilities); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			auditLog(r, db, user, models.AuditEvent{Action: models.AuditPluginGrants, Target: p.Name, Details: strings.Join(req.Capabilities, ",")})
+			p.Granted = req.Capabilities
+		}
+		if req.Active != nil {
+			if err := db.SetPluginActive(r.Context(), p.Name, *req.Active); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			action := models.AuditPluginDisabled
+			if *req.Active {
+				action = models.AuditPluginEnabled
+			}
+			auditLog(r, db, user, models.AuditEvent{Action: action, Target: p.Name, Details: p.Version})
+			p.IsActive = *req.Active
+		}
+		writeJSON(w, p)
+	})
+}
@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestPlugins(t *testing.T) {
+	ts := newTestServer(t, "admin", "user")
+	db, admin := ts.db, ts.users["admin"]
+	db.SetUserAdmin(t.Context(), admin.ID, true)
+	db.AddPlugin(t.Context(), &models.Plugin{Name: "core.kanban", DisplayName: "Kanban", Version: "1.0.0"})
+	db.AddPlugin(t.Context(), &models.Plugin{Name: "notes.stats", DisplayName: "Stats", Version: "0.1.0",
+		HookModule: "hooks.wasm", Capabilities: models.Capabilities{models.CapReadNotes}})
+
+	RegisterPlugins(ts.mux, db)
+	ts.login()
+	do := ts.do
+	list := func(who string) []models.Plugin {
+		var plugins []models.Plugin
+		json.NewDecoder(do(who, "GET", "/api/plugins", "").Body).Decode(&plugins)
+		return plugins
+	}
+
+	t.Run("only admins see inactive plugins", func(t *testing.T) {
+		if got := list("admin"); len(got) != 2 {
+			t.Errorf("admin sees %d plugins, want 2", len(got))
+		}
+		for _, who := range []string{"user", ""} {
+			if got := list(who); len(got) != 0 {
+				t.Errorf("%q sees %d plugins, want none active", who, len(got))
+			}
+		}
+	})
+
+	t.Run("admins enable and disable", func(t *testing.T) {
+		if rec := do("user", "PUT", "/api/plugins/core.kanban", `{"active":true}`); rec.Code != http.StatusForbidden {
+			t.Errorf("PUT as user status = %v, want 403", rec.Code)
+		}
+		if rec := do("admin", "PUT", "/api/plugins/missing", `{"active":true}`); rec.Code != http.StatusNotFound {
+			t.Errorf("PUT unknown plugin status = %v, want 404", rec.Code)
+		}
+		if rec := do("admin", "PUT", "/api/plugins/core.kanban", `{}`); rec.Code != 400 {
+			t.Errorf("PUT without active status = %v, want 400", rec.Code)
+		}
+		rec := do("admin", "PUT", "/api/plugins/core.kanban", `{"active":true}`)
+		var p models.Plugin
+		json.NewDecoder(rec.Body).Decode(&p)
+		if rec.Code != http.StatusOK || !p.IsActive {
+			t.Fatalf("enable = %v %+v", rec.Code, p)
+		}
+		if got := list("user"); len(got) != 1 || got[0].Name != "core.kanban" {
+			t.Errorf("user sees %+v, want the enabled plugin", got)
+		}
+		do("admin", "PUT", "/api/plugins/core.kanban", `{"active":false}`)
+		if got := list("user"); len(got) != 0 {
+			t.Errorf("user sees %d plugins after disabling, want 0", len(got))
+		}
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: "plugin"})
+		if len(events) != 2 {
+			t.Errorf("plugin audit events = %+v, want 2", events)
+		}
+	})
+
+	t.Run("admins grant requested capabilities", func(t *testing.T) {
+		if rec := do("user", "PUT", "/api/plugins/notes.stats", `{"capabilities":["read_notes"]}`); rec.Code != http.StatusForbidden {
+			t.Errorf("grant as user status = %v, want 403", rec.Code)
+		}
+		if rec := do("admin", "PUT", "/api/plugins/notes.stats", `{"capabilities":["write_notes"]}`); rec.Code != 400 {
+			t.Errorf("grant unrequested capability status = %v, want 400", rec.Code)
+		}
+		rec := do("admin", "PUT", "/api/plugins/notes.stats", `{"capabilities":["read_notes"]}`)
+		var p models.Plugin
+		json.NewDecoder(rec.Body).Decode(&p)
+		if rec.Code != http.StatusOK || !p.Granted.Has(models.CapReadNotes) || p.IsActive {
+			t.Fatalf("grant = %v %+v, want read_notes granted and the plugin left inactive", rec.Code, p)
+		}
+		if saved, _ := db.GetPlugin(t.Context(), "notes.stats"); len(saved.Active()) != 1 {
+			t.Errorf("saved grants = %v", saved.Granted)
+		}
+		do("admin", "PUT", "/api/plugins/notes.stats", `{"capabilities":[]}`)
+		if saved, _ := db.GetPlugin(t.Context(), "notes.stats"); len(saved.Granted) != 0 {
+			t.Errorf("grants after revoking = %v, want none", saved.Granted)
+		}
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: models.AuditPluginGrants})
+		if len(events) != 2 {
+			t.Errorf("grant audit events = %d, want 2", len(events))
+		}
+	})
+}
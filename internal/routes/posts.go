@@ -0,0 +1,351 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/posts"
+	"dragonbytelabs/dz/internal/vault"
+	"dragonbytelabs/dz/internal/webhooks"
+)
+
+// maxPublicPosts caps how many posts one public listing returns
+const maxPublicPosts = 100
+
+// postRequest carries the fields of a post to set; nil fields are left alone
+type postRequest struct {
+	Title      *string    `json:"title"`
+	Slug       *string    `json:"slug"`
+	Content    *string    `json:"content"`
+	Status     *string    `json:"status"`
+	Visibility *string    `json:"visibility"`
+	Format     *string    `json:"format"`
+	Excerpt    *string    `json:"excerpt"`
+	PublishAt  *time.Time `json:"publish_at"`
+}
+
+func (req *postRequest) apply(p *models.Post) {
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&p.Title, req.Title)
+	set(&p.Slug, req.Slug)
+	set(&p.Content, req.Content)
+	set(&p.Status, req.Status)
+	set(&p.Visibility, req.Visibility)
+	set(&p.Format, req.Format)
+	set(&p.Excerpt, req.Excerpt)
+	if req.PublishAt != nil {
+		p.PublishAt = req.PublishAt
+	}
+}
+
+// PublicPost is a live post as the public sees it
+type PublicPost struct {
+	Title     string     `json:"title"`
+	Slug      string     `json:"slug"`
+	Excerpt   string     `json:"excerpt,omitempty"`
+	HTML      string     `json:"html"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func publicPost(p models.Post) (PublicPost, error) {
+	html, err := posts.Render(&p)
+	if err != nil {
+		return PublicPost{}, err
+	}
+	return PublicPost{
+		Title:     p.Title,
+		Slug:      p.Slug,
+		Excerpt:   p.Excerpt,
+		HTML:      html,
+		PublishAt: p.PublishAt,
+		UpdatedAt: p.UpdatedAt,
+	}, nil
+}
+
+// canEditPost reports whether user may change a post: its author or an admin
+func canEditPost(user *models.User, p *models.Post) bool {
+	return user.IsAdmin || (p.AuthorID != nil && *p.AuthorID == user.ID)
+}
+
+// editablePost loads the post named by the {id} path value, writing a 404
+// unless user may edit it
+func editablePost(w http.ResponseWriter, r *http.Request, db *dbx.DB, user *models.User) (*models.Post, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", 400)
+		return nil, false
+	}
+	p, err := db.GetPost(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return nil, false
+	}
+	if p == nil || !canEditPost(user, p) {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return nil, false
+	}
+	return p, true
+}
+
+// savePost validates and stores p, auditing the change. wasPublished is
+// the status before the change, so publishing is recorded once.
+func savePost(w http.ResponseWriter, r *http.Request, db *dbx.DB, user *models.User, p *models.Post, wasPublished bool) {
+	if err := posts.Prepare(p, time.Now()); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	// Raw HTML reaches visitors unescaped, so only admins may author it
+	if p.Format == models.FormatHTML && !user.IsAdmin {
+		http.Error(w, "only admins can write html posts", http.StatusForbidden)
+		return
+	}
+
+	var saved *models.Post
+	var err error
+	action := models.AuditPostUpdated
+	if p.ID == 0 {
+		action = models.AuditPostCreated
+		saved, err = db.CreatePost(r.Context(), p)
+	} else {
+		saved, err = db.UpdatePost(r.Context(), p)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	auditLog(r, db, user, models.AuditEvent{Action: action, Target: saved.Slug})
+	if saved.Status == models.PostPublished && !wasPublished {
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditPostPublished, Target: saved.Slug})
+		notify(r, db, user, webhooks.PostPublished(saved))
+	}
+	writeJSON(w, saved)
+}
+
+// RegisterPosts registers the posts API: management for signed-in users,
+// promotion of vault notes, and read-only access to live posts for everyone
+func RegisterPosts(mux *http.ServeMux, vs *Vaults) {
+	db := vs.db
+	// Promoted posts stay attached to their note across renames
+	vs.Observe(func(ctx context.Context, e vault.Event) {
+		if e.Op != vault.OpRename {
+			return
+		}
+		if err := db.RenamePostSources(ctx, e.Vault, e.OldPath, e.Path); err != nil {
+			log.Printf("posts: following rename of %s in %s: %v", e.OldPath, e.Vault, err)
+		}
+	})
+
+	mux.HandleFunc("GET /api/posts", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		all, err := db.GetPosts(r.Context(), r.URL.Query().Get("status"))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		out := make([]models.Post, 0, len(all))
+		for _, p := range all {
+			if canEditPost(user, &p) {
+				out = append(out, p)
+			}
+		}
+		writeJSON(w, out)
+	})
+
+	mux.HandleFunc("POST /api/posts", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		var req postRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		p := &models.Post{AuthorID: &user.ID}
+		req.apply(p)
+		savePost(w, r, db, user, p, false)
+	})
+
+	mux.HandleFunc("GET /api/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		if p, ok := editablePost(w, r, db, user); ok {
+			writeJSON(w, p)
+		}
+	})
+
+	mux.HandleFunc("PUT /api/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		p, ok := editablePost(w, r, db, user)
+		if !ok {
+			return
+		}
+		var req postRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		wasPublished := p.Status == models.PostPublished
+		req.apply(p)
+		savePost(w, r, db, user, p, wasPublished)
+	})
+
+	mux.HandleFunc("DELETE /api/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		p, ok := editablePost(w, r, db, user)
+		if !ok {
+			return
+		}
+		if err := db.DeletePost(r.Context(), p.ID); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditPostDeleted, Target: p.Slug})
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+
+	// Creates a post from a note, or refreshes the post the note was
+	// promoted to before. The other fields of the body override the note.
+	mux.HandleFunc("POST /api/posts/promote", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			Path string `json:"path"`
+			postRequest
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if !strings.EqualFold(path.Ext(req.Path), ".md") {
+			http.Error(w, "only notes can be promoted", 400)
+			return
+		}
+		// Promoting publishes the note, so it takes the right to edit it
+		if role, _ := vault.RoleFrom(r.Context()); !models.RoleAtLeast(role, models.RoleEditor) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		res, err := v.ReadFile(r.Context(), req.Path)
+		if err != nil {
+			vaultError(w, err, 404)
+			return
+		}
+		fromNote, err := posts.FromNote(res.Path, res.Content)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		p, err := db.GetPostBySource(r.Context(), v.Name(), res.Path)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		wasPublished := false
+		if p == nil {
+			p = &models.Post{AuthorID: &user.ID, SourceVault: v.Name(), SourcePath: res.Path, Slug: fromNote.Slug}
+		} else {
+			if !canEditPost(user, p) {
+				http.Error(w, "note was promoted by another author", http.StatusForbidden)
+				return
+			}
+			wasPublished = p.Status == models.PostPublished
+			if fromNote.Slug != "" {
+				p.Slug = fromNote.Slug
+			}
+		}
+		p.Title, p.Content, p.Format = fromNote.Title, fromNote.Content, fromNote.Format
+		if fromNote.Excerpt != "" {
+			p.Excerpt = fromNote.Excerpt
+		}
+		req.apply(p)
+		savePost(w, r, db, user, p, wasPublished)
+	})
+
+	mux.HandleFunc("GET /api/public/posts", func(w http.ResponseWriter, r *http.Request) {
+		limit := maxPublicPosts
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", 400)
+				return
+			}
+			limit = min(n, maxPublicPosts)
+		}
+		live, err := db.GetPublishedPosts(r.Context(), limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		out := make([]PublicPost, 0, len(live))
+		for _, p := range live {
+			pp, err := publicPost(p)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			out = append(out, pp)
+		}
+		writeJSON(w, out)
+	})
+
+	mux.HandleFunc("GET /api/public/posts/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		p, err := db.GetPostBySlug(r.Context(), r.PathValue("slug"))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if p == nil || p.Status != models.PostPublished {
+			http.Error(w, "post not found", http.StatusNotFound)
+			return
+		}
+		if p.Visibility == models.PostPrivate {
+			user, err := currentUser(r, db)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			if user == nil {
+				http.Error(w, "post not found", http.StatusNotFound)
+				return
+			}
+		}
+		pp, err := publicPost(*p)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, pp)
+	})
+}
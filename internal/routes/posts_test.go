@@ -0,0 +1,156 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func TestPosts(t *testing.T) {
+	ts := newTestServer(t, "admin", "author", "other")
+	db, admin := ts.db, ts.users["admin"]
+	db.SetUserAdmin(t.Context(), admin.ID, true)
+
+	vs := NewVaults(db, vault.NewManager(t.TempDir()), nil)
+	RegisterApi(ts.mux, vs)
+	RegisterPosts(ts.mux, vs)
+	ts.login()
+	do := ts.do
+	post := func(who, method, path, body string) (models.Post, *httptest.ResponseRecorder) {
+		rec := do(who, method, path, body)
+		var p models.Post
+		json.NewDecoder(strings.NewReader(rec.Body.String())).Decode(&p)
+		return p, rec
+	}
+
+	var draft models.Post
+	t.Run("creates drafts with slugs", func(t *testing.T) {
+		var rec *httptest.ResponseRecorder
+		draft, rec = post("author", "POST", "/api/posts", `{"title":"Hello, World!","content":"Hi **all**"}`)
+		if rec.Code != http.StatusOK || draft.Slug != "hello-world" || draft.Status != models.PostDraft {
+			t.Fatalf("POST /api/posts = %v %+v", rec.Code, draft)
+		}
+		if _, rec := post("", "POST", "/api/posts", `{"title":"x"}`); rec.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous create status = %v, want 401", rec.Code)
+		}
+		if _, rec := post("author", "POST", "/api/posts", `{"title":"x","status":"scheduled"}`); rec.Code != 400 {
+			t.Errorf("scheduled without publish_at status = %v, want 400", rec.Code)
+		}
+		if _, rec := post("author", "POST", "/api/posts", `{"title":"x","format":"html"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("html post by non-admin status = %v, want 403", rec.Code)
+		}
+	})
+
+	t.Run("drafts are not public", func(t *testing.T) {
+		if rec := do("", "GET", "/api/public/posts/hello-world", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET draft publicly status = %v, want 404", rec.Code)
+		}
+	})
+
+	t.Run("only authors and admins edit", func(t *testing.T) {
+		path := fmt.Sprintf("/api/posts/%d", draft.ID)
+		if _, rec := post("other", "PUT", path, `{"title":"Mine now"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("PUT by other status = %v, want 404", rec.Code)
+		}
+		var list []models.Post
+		json.NewDecoder(do("other", "GET", "/api/posts", "").Body).Decode(&list)
+		if len(list) != 0 {
+			t.Errorf("other sees %d posts, want 0", len(list))
+		}
+		if p, rec := post("admin", "GET", path, ""); rec.Code != http.StatusOK || p.ID != draft.ID {
+			t.Errorf("GET by admin = %v %+v", rec.Code, p)
+		}
+	})
+
+	t.Run("publishes", func(t *testing.T) {
+		p, rec := post("author", "PUT", fmt.Sprintf("/api/posts/%d", draft.ID), `{"status":"published","excerpt":"Greetings"}`)
+		if rec.Code != http.StatusOK || p.Status != models.PostPublished || p.PublishAt == nil || p.Title != "Hello, World!" {
+			t.Fatalf("publish = %v %+v", rec.Code, p)
+		}
+		var pub PublicPost
+		json.NewDecoder(do("", "GET", "/api/public/posts/hello-world", "").Body).Decode(&pub)
+		if pub.Title != "Hello, World!" || pub.HTML != "<p>Hi <strong>all</strong></p>\n" || pub.Excerpt != "Greetings" {
+			t.Errorf("public post = %+v", pub)
+		}
+		var list []PublicPost
+		json.NewDecoder(do("", "GET", "/api/public/posts", "").Body).Decode(&list)
+		if len(list) != 1 {
+			t.Errorf("public posts = %d, want 1", len(list))
+		}
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: models.AuditPostPublished})
+		if len(events) != 1 || events[0].Target != "hello-world" {
+			t.Errorf("published audit events = %+v", events)
+		}
+	})
+
+	t.Run("schedules", func(t *testing.T) {
+		at := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		p, rec := post("author", "POST", "/api/posts", fmt.Sprintf(`{"title":"Soon","status":"scheduled","publish_at":%q}`, at))
+		if rec.Code != http.StatusOK || p.Status != models.PostScheduled {
+			t.Fatalf("schedule = %v %+v", rec.Code, p)
+		}
+		if rec := do("", "GET", "/api/public/posts/soon", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET scheduled post status = %v, want 404", rec.Code)
+		}
+	})
+
+	t.Run("hides private and unlisted posts", func(t *testing.T) {
+		post("author", "POST", "/api/posts", `{"title":"Members","status":"published","visibility":"private"}`)
+		post("author", "POST", "/api/posts", `{"title":"Hidden","status":"published","visibility":"unlisted"}`)
+		if rec := do("", "GET", "/api/public/posts/members", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("anonymous GET private post status = %v, want 404", rec.Code)
+		}
+		if rec := do("other", "GET", "/api/public/posts/members", ""); rec.Code != http.StatusOK {
+			t.Errorf("signed-in GET private post status = %v, want 200", rec.Code)
+		}
+		if rec := do("", "GET", "/api/public/posts/hidden", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET unlisted post status = %v, want 200", rec.Code)
+		}
+		var list []PublicPost
+		json.NewDecoder(do("", "GET", "/api/public/posts", "").Body).Decode(&list)
+		if len(list) != 1 {
+			t.Errorf("public listing has %d posts, want only the public one", len(list))
+		}
+	})
+
+	t.Run("promotes notes", func(t *testing.T) {
+		do("author", "POST", "/api/file", `{"path":"blog/launch.md","content":"---\ntitle: Launch Day\ndescription: It shipped\n---\nWe **shipped**.\n"}`)
+		p, rec := post("author", "POST", "/api/posts/promote", `{"path":"blog/launch.md","status":"published"}`)
+		if rec.Code != http.StatusOK || p.Title != "Launch Day" || p.Slug != "launch-day" || p.Excerpt != "It shipped" ||
+			p.Content != "We **shipped**.\n" || p.SourcePath != "blog/launch.md" || p.Status != models.PostPublished {
+			t.Fatalf("promote = %v %+v", rec.Code, p)
+		}
+
+		// Promoting again refreshes the same post, also after a rename
+		do("author", "PATCH", "/api/file", `{"oldPath":"blog/launch.md","newPath":"blog/launched.md"}`)
+		do("author", "PUT", "/api/file?path=blog/launched.md", `{"content":"---\ntitle: Launch Day\n---\nUpdated.\n"}`)
+		again, rec := post("author", "POST", "/api/posts/promote", `{"path":"blog/launched.md"}`)
+		if rec.Code != http.StatusOK || again.ID != p.ID || again.Content != "Updated.\n" || again.Status != models.PostPublished {
+			t.Errorf("promote again = %v %+v", rec.Code, again)
+		}
+
+		if _, rec := post("author", "POST", "/api/posts/promote", `{"path":"missing.md"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("promote missing note status = %v, want 404", rec.Code)
+		}
+	})
+
+	t.Run("deletes", func(t *testing.T) {
+		path := fmt.Sprintf("/api/posts/%d", draft.ID)
+		if rec := do("other", "DELETE", path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("DELETE by other status = %v, want 404", rec.Code)
+		}
+		if rec := do("author", "DELETE", path, ""); rec.Code != http.StatusOK {
+			t.Fatalf("DELETE status = %v", rec.Code)
+		}
+		if rec := do("", "GET", "/api/public/posts/hello-world", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET deleted post status = %v, want 404", rec.Code)
+		}
+	})
+}
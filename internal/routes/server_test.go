@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/ratelimit"
+	"dragonbytelabs/dz/internal/session"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testServer serves routes under test to signed-in users. Register the
+// routes on mux, wrap handler if needed, e.g. in session.CSRF, then login.
+type testServer struct {
+	t       *testing.T
+	db      *dbx.DB
+	sm      *session.SessionManager
+	mux     *http.ServeMux
+	handler http.Handler
+	users   map[string]*models.User
+	cookies map[string][]*http.Cookie
+}
+
+// newTestServer creates a test database with a user <name>@example.com,
+// password "pw", for each name, and a mux with the auth routes
+func newTestServer(t *testing.T, users ...string) *testServer {
+	t.Helper()
+	db := dbx.SetupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	ts := &testServer{
+		t:       t,
+		db:      db,
+		sm:      session.NewSessionManager(session.NewInMemoryStore(), 30*time.Minute, 1*time.Hour, 12*time.Hour, "session_id"),
+		mux:     http.NewServeMux(),
+		users:   map[string]*models.User{},
+		cookies: map[string][]*http.Cookie{},
+	}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	for _, name := range users {
+		u, err := db.CreateUser(t.Context(), name+"@example.com", string(hash), strings.ToUpper(name[:1])+name[1:])
+		if err != nil {
+			t.Fatalf("CreateUser(%s) returned error: %v", name, err)
+		}
+		ts.users[name] = u
+	}
+	RegisterAuth(ts.mux, db, ts.sm, ratelimit.NewLimiter(ratelimit.NewInMemoryStore(), ratelimit.DefaultPolicy(), 0))
+	ts.handler = ts.sm.Handle(ts.mux)
+	return ts
+}
+
+// login signs every user in
+func (ts *testServer) login() {
+	ts.t.Helper()
+	for name := range ts.users {
+		rec := ts.do("", "POST", "/api/auth/login", fmt.Sprintf(`{"email":"%s@example.com","password":"pw"}`, name))
+		if rec.Code != http.StatusOK {
+			ts.t.Fatalf("login %s status = %v", name, rec.Code)
+		}
+		ts.cookies[name] = rec.Result().Cookies()
+	}
+}
+
+// serve serves req as the user who, or anonymously if who is empty, with
+// the CSRF token a browser would send
+func (ts *testServer) serve(who string, req *http.Request) *httptest.ResponseRecorder {
+	for _, c := range ts.cookies[who] {
+		req.AddCookie(c)
+		if c.Name == session.CSRFCookie {
+			req.Header.Set(session.CSRFHeader, c.Value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	ts.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// do serves a request with body, if any, as the user who
+func (ts *testServer) do(who, method, path, body string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	return ts.serve(who, httptest.NewRequest(method, path, r))
+}
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"dragonbytelabs/dz/internal/session"
+)
+
+// SessionInfo describes one of the current user's sessions
+type SessionInfo struct {
+	ID             string    `json:"id"` // public handle, not the cookie value
+	UserAgent      string    `json:"user_agent"`
+	IP             string    `json:"ip"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+	Current        bool      `json:"current"`
+}
+
+// RegisterSessions registers endpoints for listing and revoking the current user's sessions
+func RegisterSessions(mux *http.ServeMux, sm *session.SessionManager) {
+	mux.HandleFunc("GET /api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		sess := session.GetSession(r)
+		userID := sess.UserID()
+		if userID == "" {
+			http.Error(w, "not authenticated", http.StatusUnauthorized)
+			return
+		}
+
+		sessions, err := sm.ListUserSessions(userID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		out := make([]SessionInfo, 0, len(sessions))
+		for _, s := range sessions {
+			out = append(out, SessionInfo{
+				ID:             s.Handle(),
+				UserAgent:      s.UserAgent(),
+				IP:             s.IP(),
+				CreatedAt:      s.CreatedAt(),
+				LastActivityAt: s.LastActivityAt(),
+				Current:        s.ID() == sess.ID(),
+			})
+		}
+		writeJSON(w, out)
+	})
+
+	mux.HandleFunc("DELETE /api/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		sess := session.GetSession(r)
+		userID := sess.UserID()
+		if userID == "" {
+			http.Error(w, "not authenticated", http.StatusUnauthorized)
+			return
+		}
+
+		// Revoking the current session is a logout; otherwise the middleware
+		// would write it straight back at the end of the request
+		if r.PathValue("id") == sess.Handle() {
+			sess.Delete("user_id")
+			if err := sm.Migrate(sess); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			writeJSON(w, map[string]bool{"ok": true})
+			return
+		}
+
+		found, err := sm.RevokeSession(userID, r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if !found {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+
+	// Sign out everywhere else
+	mux.HandleFunc("DELETE /api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		sess := session.GetSession(r)
+		userID := sess.UserID()
+		if userID == "" {
+			http.Error(w, "not authenticated", http.StatusUnauthorized)
+			return
+		}
+
+		revoked, err := sm.RevokeOtherSessions(userID, sess.ID())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, map[string]int{"revoked": revoked})
+	})
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/ratelimit"
+	"dragonbytelabs/dz/internal/session"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSessions(t *testing.T) {
+	db := dbx.SetupTestDB(t)
+	defer db.Close()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	db.CreateUser(t.Context(), "user@example.com", string(hash), "User")
+
+	sm := session.NewSessionManager(session.NewSQLiteStore(db), 30*time.Minute, 1*time.Hour, 12*time.Hour, "session_id")
+	mux := http.NewServeMux()
+	RegisterAuth(mux, db, sm, ratelimit.NewLimiter(ratelimit.NewInMemoryStore(), ratelimit.DefaultPolicy(), 0))
+	RegisterSessions(mux, sm)
+	handler := sm.Handle(mux)
+
+	loginAs := func(userAgent string) []*http.Cookie {
+		req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"email":"user@example.com","password":"pw"}`))
+		req.Header.Set("User-Agent", userAgent)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("login status = %v", rec.Code)
+		}
+		return rec.Result().Cookies()
+	}
+	do := func(method, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("User-Agent", "laptop")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	laptop := loginAs("laptop")
+	phone := loginAs("phone")
+
+	t.Run("requires authentication", func(t *testing.T) {
+		if rec := do("GET", "/api/sessions", nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("GET /api/sessions status = %v, want %v", rec.Code, http.StatusUnauthorized)
+		}
+	})
+
+	var sessions []SessionInfo
+	t.Run("lists sessions with metadata", func(t *testing.T) {
+		rec := do("GET", "/api/sessions", laptop)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/sessions status = %v", rec.Code)
+		}
+		json.NewDecoder(rec.Body).Decode(&sessions)
+		if len(sessions) != 2 {
+			t.Fatalf("GET /api/sessions returned %d sessions, want 2", len(sessions))
+		}
+		for _, s := range sessions {
+			if s.ID == laptop[0].Value || s.ID == phone[0].Value {
+				t.Error("session listing exposed a cookie value")
+			}
+		}
+	})
+
+	t.Run("revokes another session", func(t *testing.T) {
+		var phoneHandle string
+		for _, s := range sessions {
+			if !s.Current {
+				phoneHandle = s.ID
+			}
+		}
+		if rec := do("DELETE", "/api/sessions/"+phoneHandle, laptop); rec.Code != http.StatusOK {
+			t.Fatalf("DELETE /api/sessions/{id} status = %v", rec.Code)
+		}
+		if rec := do("GET", "/api/sessions", phone); rec.Code != http.StatusUnauthorized {
+			t.Errorf("revoked session is still authenticated: status = %v", rec.Code)
+		}
+	})
+
+	t.Run("signs out everywhere else", func(t *testing.T) {
+		tablet := loginAs("tablet")
+		rec := do("DELETE", "/api/sessions", laptop)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("DELETE /api/sessions status = %v", rec.Code)
+		}
+		if rec := do("GET", "/api/sessions", tablet); rec.Code != http.StatusUnauthorized {
+			t.Errorf("tablet session survived sign-out-everywhere: status = %v", rec.Code)
+		}
+		if rec := do("GET", "/api/sessions", laptop); rec.Code != http.StatusOK {
+			t.Errorf("current session was signed out: status = %v", rec.Code)
+		}
+	})
+}
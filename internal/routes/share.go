@@ -0,0 +1,314 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/audit"
+	"dragonbytelabs/dz/internal/markdown"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/ratelimit"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// Share links last this long unless the request picks an expiry
+const defaultShareExpiry = 30 * 24 * time.Hour
+
+// SharePrefix is where share links are served; these pages need no session
+const SharePrefix = "/s/"
+
+// ShareLinkInfo is a share link as returned to its creator
+type ShareLinkInfo struct {
+	models.ShareLink
+	URL       string `json:"url"`
+	Protected bool   `json:"protected"`
+}
+
+func shareLinkInfo(l models.ShareLink) ShareLinkInfo {
+	return ShareLinkInfo{ShareLink: l, URL: SharePrefix + l.Token, Protected: l.HasPassword()}
+}
+
+// sharePage renders a shared note, or the password prompt in front of it
+var sharePage = template.Must(template.New("share").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; line-height: 1.6; max-width: 46rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+pre { background: #f4f4f4; padding: .75rem; overflow-x: auto; }
+mark { background: #fff3a3; }
+.callout { border-left: 4px solid #4a1e79; padding: .5rem 1rem; margin: 1rem 0; background: #f7f4fb; }
+.callout-title { font-weight: 600; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+{{if .Locked}}<form method="post">
+<h1>This note is password protected</h1>
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+<input type="password" name="password" autofocus required aria-label="Password">
+<button type="submit">View note</button>
+</form>{{else}}<article>
+<h1>{{.Title}}</h1>
+{{.Content}}
+</article>{{end}}
+</body>
+</html>
+`))
+
+type sharePageData struct {
+	Title   string
+	Content template.HTML
+	Locked  bool
+	Error   string
+}
+
+// RegisterShares registers share link management and the public /s/ pages.
+// Links follow their note when it, or a folder holding it, is renamed.
+// Password guesses are throttled per link and client by limiter.
+func RegisterShares(mux *http.ServeMux, vs *Vaults, limiter *ratelimit.Limiter) {
+	db := vs.db
+	vs.Observe(func(ctx context.Context, e vault.Event) {
+		if e.Op != vault.OpRename {
+			return
+		}
+		if err := db.RenameShareLinks(ctx, e.Vault, e.OldPath, e.Path); err != nil {
+			log.Printf("share: following rename of %s in %s: %v", e.OldPath, e.Vault, err)
+		}
+	})
+
+	mux.HandleFunc("GET /api/shares", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		links, err := db.GetShareLinksByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		out := make([]ShareLinkInfo, 0, len(links))
+		for _, l := range links {
+			out = append(out, shareLinkInfo(l))
+		}
+		writeJSON(w, out)
+	})
+
+	mux.HandleFunc("POST /api/shares", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			Path      string     `json:"path"`
+			ExpiresAt *time.Time `json:"expires_at"`
+			Password  string     `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if !strings.EqualFold(path.Ext(req.Path), ".md") {
+			http.Error(w, "only notes can be shared", 400)
+			return
+		}
+		// Sharing publishes the note, so it takes the right to edit it
+		if role, _ := vault.RoleFrom(r.Context()); !models.RoleAtLeast(role, models.RoleEditor) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		res, err := v.ReadFile(r.Context(), req.Path)
+		if err != nil {
+			vaultError(w, err, 404)
+			return
+		}
+
+		expiresAt := time.Now().Add(defaultShareExpiry)
+		if req.ExpiresAt != nil {
+			if !req.ExpiresAt.After(time.Now()) {
+				http.Error(w, "expires_at must be in the future", 400)
+				return
+			}
+			expiresAt = *req.ExpiresAt
+		}
+		var hash string
+		if req.Password != "" {
+			if hash, err = models.HashPassword(req.Password); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+		}
+
+		link, err := db.CreateShareLink(r.Context(), v.Name(), res.Path, user.ID, hash, expiresAt)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		audit.Log(r.Context(), db, models.AuditEvent{
+			Action:  models.AuditTokenCreated,
+			Target:  link.Path,
+			Vault:   link.Vault,
+			Details: fmt.Sprintf("share link %d", link.ID),
+		})
+		writeJSON(w, shareLinkInfo(*link))
+	})
+
+	mux.HandleFunc("DELETE /api/shares/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", 400)
+			return
+		}
+		link, err := db.GetShareLink(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if link == nil || (!user.IsAdmin && (link.CreatedBy == nil || *link.CreatedBy != user.ID)) {
+			http.Error(w, "share link not found", http.StatusNotFound)
+			return
+		}
+		if err := db.RevokeShareLink(r.Context(), id); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{
+			Action:  models.AuditTokenRevoked,
+			Target:  link.Path,
+			Vault:   link.Vault,
+			Details: fmt.Sprintf("share link %d", link.ID),
+		})
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+
+	// Public, read-only view of a shared note. Protected links ask for the
+	// password and show the note in the response to the form.
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("X-Robots-Tag", "noindex")
+
+		link, err := db.GetShareLinkByToken(r.Context(), r.PathValue("token"))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if link == nil || link.RevokedAt != nil {
+			http.Error(w, "share link not found", http.StatusNotFound)
+			return
+		}
+		if !link.Active(time.Now()) {
+			http.Error(w, "share link expired", http.StatusGone)
+			return
+		}
+
+		if link.HasPassword() {
+			data := sharePageData{Title: "Protected note", Locked: true}
+			if r.Method != http.MethodPost {
+				renderSharePage(w, http.StatusOK, data)
+				return
+			}
+			ip := clientIP(r)
+			key := "share:" + link.Token + ":" + ip
+			if wait, err := limiter.Check(key); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			} else if wait > 0 {
+				seconds := int(math.Ceil(wait.Seconds()))
+				w.Header().Set("Retry-After", fmt.Sprint(seconds))
+				data.Error = fmt.Sprintf("Too many attempts, retry in %ds", seconds)
+				renderSharePage(w, http.StatusTooManyRequests, data)
+				return
+			}
+			if link.CheckPassword(r.PostFormValue("password")) != nil {
+				if _, err := limiter.Fail(key); err != nil {
+					log.Printf("share: rate limiting %s: %v", ip, err)
+				}
+				data.Error = "Wrong password"
+				renderSharePage(w, http.StatusUnauthorized, data)
+				return
+			}
+			if err := limiter.Reset(key); err != nil {
+				log.Printf("share: resetting limiter for %s: %v", ip, err)
+			}
+		}
+
+		v, err := vs.byName(link.Vault)
+		if err != nil {
+			http.Error(w, "share link not found", http.StatusNotFound)
+			return
+		}
+		res, err := v.ReadFile(r.Context(), link.Path)
+		if err != nil {
+			http.Error(w, "share link not found", http.StatusNotFound)
+			return
+		}
+		data, err := renderSharedNote(link.Path, res.Content)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if err := db.RecordShareView(r.Context(), link.ID); err != nil {
+			log.Printf("share: counting view of link %d: %v", link.ID, err)
+		}
+		renderSharePage(w, http.StatusOK, data)
+	}
+	mux.HandleFunc("GET "+SharePrefix+"{token}", serve)
+	mux.HandleFunc("POST "+SharePrefix+"{token}", serve)
+}
+
+// renderSharedNote renders a note on its own: links to other notes and
+// vault files become plain text, since the link only grants this note
+func renderSharedNote(notePath, content string) (sharePageData, error) {
+	note, err := markdown.Parse([]byte(content))
+	if err != nil {
+		return sharePageData{}, err
+	}
+	title := note.Frontmatter.Title
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(notePath), path.Ext(notePath))
+	}
+
+	var buf bytes.Buffer
+	err = markdown.Render(&buf, note.Body, markdown.Options{
+		URL: func(dest string, image bool) (string, bool) {
+			return dest, markdown.IsExternal(dest)
+		},
+	})
+	if err != nil {
+		return sharePageData{}, err
+	}
+	return sharePageData{Title: title, Content: template.HTML(buf.String())}, nil
+}
+
+func renderSharePage(w http.ResponseWriter, status int, data sharePageData) {
+	var buf bytes.Buffer
+	if err := sharePage.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
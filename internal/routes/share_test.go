@@ -0,0 +1,203 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/ratelimit"
+	"dragonbytelabs/dz/internal/session"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func TestShares(t *testing.T) {
+	ts := newTestServer(t, "owner", "viewer")
+	db, owner, viewer := ts.db, ts.users["owner"], ts.users["viewer"]
+	team, _ := db.CreateTeam(t.Context(), "Docs", nil, owner.ID)
+	db.AddTeamMember(t.Context(), team.ID, viewer.ID, models.RoleViewer)
+
+	vs := NewVaults(db, vault.NewManager(t.TempDir()), nil)
+	RegisterApi(ts.mux, vs)
+	RegisterShares(ts.mux, vs, ratelimit.NewLimiter(ratelimit.NewInMemoryStore(), ratelimit.DefaultPolicy(), 0))
+	ts.handler = ts.sm.Handle(session.CSRF(ts.mux, SharePrefix))
+	ts.login()
+	do := ts.do
+	share := func(who, query, body string) (ShareLinkInfo, *httptest.ResponseRecorder) {
+		rec := do(who, "POST", "/api/shares"+query, body)
+		var info ShareLinkInfo
+		json.NewDecoder(rec.Body).Decode(&info)
+		return info, rec
+	}
+	// posts the password form the way a browser does: with the cookies,
+	// but no CSRF header
+	postPassword := func(linkURL, who, password, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", linkURL, strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if ip != "" {
+			req.RemoteAddr = ip + ":1234"
+		}
+		for _, c := range ts.cookies[who] {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		ts.handler.ServeHTTP(rec, req)
+		return rec
+	}
+	do("owner", "POST", "/api/file", `{"path":"ideas/plan.md","content":"---\ntitle: The Plan\n---\nStep **one**, see [[other]] and [site](https://example.com).\n"}`)
+
+	t.Run("renders a shared note without a session", func(t *testing.T) {
+		link, rec := share("owner", "", `{"path":"ideas/plan.md"}`)
+		if rec.Code != http.StatusOK || link.URL != "/s/"+link.Token || link.Protected {
+			t.Fatalf("POST /api/shares = %v %+v", rec.Code, link)
+		}
+		if until := time.Until(link.ExpiresAt); until < 29*24*time.Hour || until > 31*24*time.Hour {
+			t.Errorf("default expiry in %v, want 30 days", until)
+		}
+
+		page := do("", "GET", link.URL, "")
+		body := page.Body.String()
+		if page.Code != http.StatusOK || !strings.Contains(body, "<h1>The Plan</h1>") || !strings.Contains(body, "<strong>one</strong>") {
+			t.Fatalf("GET %s = %v %q", link.URL, page.Code, body)
+		}
+		if !strings.Contains(body, `<a href="https://example.com">site</a>`) || strings.Contains(body, "internal-link") {
+			t.Errorf("links rendered wrong: %q", body)
+		}
+		if page.Header().Get("Referrer-Policy") != "no-referrer" {
+			t.Error("share page leaks its token in the referrer")
+		}
+		do("", "GET", link.URL, "")
+		got, _ := db.GetShareLink(t.Context(), link.ID)
+		if got.ViewCount != 2 {
+			t.Errorf("view count = %d, want 2", got.ViewCount)
+		}
+	})
+
+	t.Run("asks for the password", func(t *testing.T) {
+		link, _ := share("owner", "", `{"path":"ideas/plan.md","password":"s3cret"}`)
+		if !link.Protected {
+			t.Fatalf("link = %+v, want protected", link)
+		}
+		stored, _ := db.GetShareLink(t.Context(), link.ID)
+		if stored.PasswordHash == "s3cret" || stored.CheckPassword("s3cret") != nil {
+			t.Error("password is not stored as a bcrypt hash")
+		}
+
+		page := do("", "GET", link.URL, "")
+		if !strings.Contains(page.Body.String(), `type="password"`) || strings.Contains(page.Body.String(), "Step") {
+			t.Errorf("GET protected link = %q", page.Body.String())
+		}
+		post := func(who, password string) *httptest.ResponseRecorder {
+			return postPassword(link.URL, who, password, "")
+		}
+		if rec := post("", "wrong"); rec.Code != http.StatusUnauthorized || strings.Contains(rec.Body.String(), "Step") {
+			t.Errorf("wrong password = %v %q", rec.Code, rec.Body.String())
+		}
+		// Signed-in visitors post the form without a CSRF header too
+		for _, who := range []string{"", "viewer"} {
+			if rec := post(who, "s3cret"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<strong>one</strong>") {
+				t.Errorf("right password as %q = %v %q", who, rec.Code, rec.Body.String())
+			}
+		}
+	})
+
+	t.Run("follows renames", func(t *testing.T) {
+		link, _ := share("owner", "", `{"path":"ideas/plan.md"}`)
+		do("owner", "PATCH", "/api/file", `{"oldPath":"ideas/plan.md","newPath":"ideas/roadmap.md"}`)
+		do("owner", "PATCH", "/api/file", `{"oldPath":"ideas","newPath":"archive/ideas"}`)
+		got, _ := db.GetShareLink(t.Context(), link.ID)
+		if got.Path != "archive/ideas/roadmap.md" {
+			t.Errorf("path after renames = %q", got.Path)
+		}
+		if rec := do("", "GET", link.URL, ""); rec.Code != http.StatusOK {
+			t.Errorf("GET after rename status = %v, want 200", rec.Code)
+		}
+	})
+
+	t.Run("revokes", func(t *testing.T) {
+		link, _ := share("owner", "", `{"path":"archive/ideas/roadmap.md"}`)
+		if rec := do("viewer", "DELETE", fmt.Sprintf("/api/shares/%d", link.ID), ""); rec.Code != http.StatusNotFound {
+			t.Errorf("DELETE by another user status = %v, want 404", rec.Code)
+		}
+		if rec := do("owner", "DELETE", fmt.Sprintf("/api/shares/%d", link.ID), ""); rec.Code != http.StatusOK {
+			t.Fatalf("DELETE status = %v", rec.Code)
+		}
+		if rec := do("", "GET", link.URL, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET revoked link status = %v, want 404", rec.Code)
+		}
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: models.AuditTokenRevoked})
+		if len(events) != 1 || events[0].Target != "archive/ideas/roadmap.md" {
+			t.Errorf("revocation audit events = %+v", events)
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		link, _ := db.CreateShareLink(t.Context(), fmt.Sprintf("users/%d", owner.ID), "archive/ideas/roadmap.md", owner.ID, "", time.Now().Add(-time.Minute))
+		if rec := do("", "GET", "/s/"+link.Token, ""); rec.Code != http.StatusGone {
+			t.Errorf("GET expired link status = %v, want 410", rec.Code)
+		}
+		if _, rec := share("owner", "", `{"path":"archive/ideas/roadmap.md","expires_at":"2001-01-01T00:00:00Z"}`); rec.Code != 400 {
+			t.Errorf("past expires_at status = %v, want 400", rec.Code)
+		}
+	})
+
+	t.Run("rejects what can't be shared", func(t *testing.T) {
+		if rec := do("", "GET", "/s/unknown", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET unknown token status = %v, want 404", rec.Code)
+		}
+		if _, rec := share("owner", "", `{"path":"missing.md"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("share missing note status = %v, want 404", rec.Code)
+		}
+		if _, rec := share("", "", `{"path":"archive/ideas/roadmap.md"}`); rec.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous share status = %v, want 401", rec.Code)
+		}
+		teamVault := fmt.Sprintf("?vault=team:%d", team.ID)
+		do("owner", "POST", "/api/file"+teamVault, `{"path":"team.md","content":"x"}`)
+		if _, rec := share("viewer", teamVault, `{"path":"team.md"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("viewer share status = %v, want 403", rec.Code)
+		}
+		if link, rec := share("owner", teamVault, `{"path":"team.md"}`); rec.Code != http.StatusOK || link.Vault != fmt.Sprintf("teams/%d", team.ID) {
+			t.Errorf("owner share in team vault = %v %+v", rec.Code, link)
+		}
+	})
+
+	t.Run("lists own links", func(t *testing.T) {
+		var links []ShareLinkInfo
+		json.NewDecoder(do("viewer", "GET", "/api/shares", "").Body).Decode(&links)
+		if len(links) != 0 {
+			t.Errorf("viewer has %d links, want 0", len(links))
+		}
+		json.NewDecoder(do("owner", "GET", "/api/shares", "").Body).Decode(&links)
+		if len(links) != 6 {
+			t.Errorf("owner has %d links, want 6", len(links))
+		}
+	})
+
+	t.Run("locks out password guessing", func(t *testing.T) {
+		do("owner", "POST", "/api/file", `{"path":"locked.md","content":"Step two"}`)
+		link, _ := share("owner", "", `{"path":"locked.md","password":"s3cret"}`)
+		other, _ := share("owner", "", `{"path":"locked.md","password":"s3cret"}`)
+		free := ratelimit.DefaultPolicy().FreeAttempts
+		for i := 0; i <= free; i++ {
+			if rec := postPassword(link.URL, "", "guess", "203.0.113.7"); rec.Code != http.StatusUnauthorized {
+				t.Fatalf("guess %d = %v, want 401", i+1, rec.Code)
+			}
+		}
+		rec := postPassword(link.URL, "", "s3cret", "203.0.113.7")
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" || strings.Contains(rec.Body.String(), "Step") {
+			t.Errorf("right password after %d guesses = %v %q, want 429", free+1, rec.Code, rec.Body.String())
+		}
+		// Keyed by link and client
+		if rec := postPassword(other.URL, "", "s3cret", "203.0.113.7"); rec.Code != http.StatusOK {
+			t.Errorf("another link from the same client = %v, want 200", rec.Code)
+		}
+		if rec := postPassword(link.URL, "", "s3cret", "198.51.100.2"); rec.Code != http.StatusOK {
+			t.Errorf("the link from another client = %v, want 200", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/feed"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/publish"
+	"dragonbytelabs/dz/internal/theme"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// maxSitePosts caps how many public posts the site shows
+const maxSitePosts = 1000
+
+// PublicSite serves the published notes of a vault and the public posts,
+// rendered with the active theme. The site is built on first use and
+// rebuilt after the vault or the posts change or another theme is picked.
+type PublicSite struct {
+	db         *dbx.DB
+	vault      *vault.Vault
+	themesPath string
+	opts       publish.Options
+
+	mu        sync.Mutex
+	site      *publish.Site
+	themeName string   // theme site was built with
+	postsSum  [32]byte // fingerprint of the posts site was built with
+	stale     bool
+}
+
+// ThemeInfo lists the installed themes and which one the site uses
+type ThemeInfo struct {
+	Themes []string `json:"themes"`
+	Active string   `json:"active"`
+}
+
+// NewPublicSite serves the notes of v with the themes installed in
+// themesPath. opts sets the title and feeds; its theme and posts are ignored.
+func NewPublicSite(db *dbx.DB, v *vault.Vault, themesPath string, opts publish.Options) *PublicSite {
+	ps := &PublicSite{db: db, vault: v, themesPath: themesPath, opts: opts}
+	v.Observe(func(ctx context.Context, e vault.Event) {
+		ps.mu.Lock()
+		ps.stale = true
+		ps.mu.Unlock()
+	})
+	return ps
+}
+
+// build returns the current site, rebuilding it if needed
+func (ps *PublicSite) build(ctx context.Context) (*publish.Site, error) {
+	name, err := ps.db.GetSiteSetting(ctx, models.SettingActiveTheme)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = theme.DefaultName
+	}
+	live, err := ps.db.GetPublishedPosts(ctx, maxSitePosts)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(live)
+	if err != nil {
+		return nil, err
+	}
+	postsSum := sha256.Sum256(b)
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.site != nil && !ps.stale && ps.themeName == name && ps.postsSum == postsSum {
+		return ps.site, nil
+	}
+
+	th, err := theme.Open(ps.themesPath, name)
+	if err != nil {
+		// Keep the site up when the active theme was removed or broken
+		log.Printf("site: %v; using the %s theme", err, theme.DefaultName)
+		th = theme.Default()
+	}
+	ps.stale = false
+	opts := ps.opts
+	opts.Theme, opts.Posts = th, live
+	site, err := publish.Build(ctx, ps.vault, opts)
+	if err != nil {
+		return nil, err
+	}
+	ps.site, ps.themeName, ps.postsSum = site, name, postsSum
+	return site, nil
+}
+
+// RegisterSite registers the public site under /site/ and the admin
+// endpoints that pick its theme. Site files carry an ETag and, for pages
+// and feeds, a Last-Modified time, so readers can poll with conditional GETs.
+func RegisterSite(mux *http.ServeMux, db *dbx.DB, ps *PublicSite) {
+	mux.HandleFunc("GET /site", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/site/", http.StatusMovedPermanently)
+	})
+
+	mux.HandleFunc("GET /site/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		rel := r.PathValue("path")
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			rel += "index.html"
+		}
+
+		site, err := ps.build(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		var buf bytes.Buffer
+		if err := site.RenderFile(r.Context(), rel, &buf); err != nil {
+			if errors.Is(err, publish.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		feed.Serve(w, r, buf.Bytes(), site.ContentType(rel), site.ModTime(rel))
+	})
+
+	mux.HandleFunc("GET /api/admin/themes", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		themes, err := theme.List(ps.themesPath)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		active, err := db.GetSiteSetting(r.Context(), models.SettingActiveTheme)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if active == "" {
+			active = theme.DefaultName
+		}
+		writeJSON(w, ThemeInfo{Themes: themes, Active: active})
+	})
+
+	mux.HandleFunc("PUT /api/admin/theme", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if req.Name == "" {
+			req.Name = theme.DefaultName
+		}
+		// Refuse themes that don't load rather than breaking the site
+		if _, err := theme.Open(ps.themesPath, req.Name); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err := db.SetSiteSetting(r.Context(), models.SettingActiveTheme, req.Name); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditSiteTheme, Target: req.Name})
+		writeJSON(w, map[string]string{"active": req.Name})
+	})
+}
@@ -0,0 +1,164 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/publish"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func TestPublicSite(t *testing.T) {
+	ts := newTestServer(t, "admin", "user")
+	db, admin := ts.db, ts.users["admin"]
+	db.SetUserAdmin(t.Context(), admin.ID, true)
+
+	v, err := vault.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.WriteFile(t.Context(), "hello.md", vault.WriteRequest{Content: "---\npublish: true\ntags: [intro]\n---\nHello **world**\n"})
+	v.WriteFile(t.Context(), "private.md", vault.WriteRequest{Content: "secret\n"})
+
+	themesPath := t.TempDir()
+	os.MkdirAll(filepath.Join(themesPath, "plain", "static"), 0o755)
+	os.WriteFile(filepath.Join(themesPath, "plain", "index.html"), []byte(`<main class="plain">{{range .Site.Pages}}{{.Title}}{{end}}</main>`), 0o644)
+	os.WriteFile(filepath.Join(themesPath, "plain", "static", "plain.css"), []byte("main {}"), 0o644)
+	os.MkdirAll(filepath.Join(themesPath, "broken"), 0o755)
+	os.WriteFile(filepath.Join(themesPath, "broken", "index.html"), []byte(`{{if .Site}}`), 0o644)
+
+	RegisterSite(ts.mux, db, NewPublicSite(db, v, themesPath, publish.Options{BaseURL: "https://example.com/site"}))
+	ts.login()
+	do := ts.do
+
+	t.Run("serves published notes with the default theme", func(t *testing.T) {
+		rec := do("", "GET", "/site/", "")
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `href="notes/hello.html"`) {
+			t.Fatalf("GET /site/ = %v %q", rec.Code, rec.Body.String())
+		}
+		rec = do("", "GET", "/site/notes/hello.html", "")
+		if !strings.Contains(rec.Body.String(), "<strong>world</strong>") {
+			t.Errorf("note page = %q", rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), `href="../tags/intro.html"`) {
+			t.Errorf("note page lacks its tag link: %q", rec.Body.String())
+		}
+		if ct := do("", "GET", "/site/static/style.css", "").Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+			t.Errorf("style.css Content-Type = %q", ct)
+		}
+	})
+
+	t.Run("hides unpublished notes", func(t *testing.T) {
+		if rec := do("", "GET", "/site/notes/private.html", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET private note status = %v, want 404", rec.Code)
+		}
+	})
+
+	t.Run("rebuilds after vault changes", func(t *testing.T) {
+		v.WriteFile(t.Context(), "later.md", vault.WriteRequest{Content: "---\npublish: true\n---\nLater\n"})
+		if rec := do("", "GET", "/site/notes/later.html", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET new note status = %v, want 200", rec.Code)
+		}
+	})
+
+	t.Run("serves feeds with conditional GET", func(t *testing.T) {
+		rec := do("", "GET", "/site/feed.xml", "")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/rss+xml; charset=utf-8" {
+			t.Fatalf("GET /site/feed.xml = %v %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rec.Body.String(), "<link>https://example.com/site/notes/hello.html</link>") {
+			t.Errorf("feed lacks the absolute link of the note: %q", rec.Body.String())
+		}
+		etag, modified := rec.Header().Get("ETag"), rec.Header().Get("Last-Modified")
+		if etag == "" || modified == "" {
+			t.Fatalf("feed headers = %v", rec.Header())
+		}
+
+		for header, value := range map[string]string{"If-None-Match": etag, "If-Modified-Since": modified} {
+			req := httptest.NewRequest("GET", "/site/feed.xml", nil)
+			req.Header.Set(header, value)
+			rec := ts.serve("", req)
+			if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+				t.Errorf("GET with %s = %v, want 304", header, rec.Code)
+			}
+		}
+		if rec := do("", "GET", "/site/tags/intro/atom.xml", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<feed") {
+			t.Errorf("GET tag atom feed = %v %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("shows public posts", func(t *testing.T) {
+		now := time.Now()
+		db.CreatePost(t.Context(), &models.Post{Title: "News", Slug: "news", Content: "Big *news*", Status: models.PostPublished,
+			Visibility: models.PostPublic, Format: models.FormatMarkdown, PublishAt: &now})
+		if rec := do("", "GET", "/site/posts/news.html", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<em>news</em>") {
+			t.Errorf("GET post page = %v %q", rec.Code, rec.Body.String())
+		}
+		var jf struct {
+			Items []struct {
+				URL string `json:"url"`
+			} `json:"items"`
+		}
+		json.NewDecoder(do("", "GET", "/site/feed.json", "").Body).Decode(&jf)
+		if len(jf.Items) != 3 {
+			t.Errorf("site feed has %d items, want the 2 notes and the post", len(jf.Items))
+		}
+	})
+
+	t.Run("theme admin requires an admin", func(t *testing.T) {
+		if rec := do("user", "GET", "/api/admin/themes", ""); rec.Code != http.StatusForbidden {
+			t.Errorf("GET /api/admin/themes as user status = %v, want 403", rec.Code)
+		}
+		if rec := do("user", "PUT", "/api/admin/theme", `{"name":"plain"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("PUT /api/admin/theme as user status = %v, want 403", rec.Code)
+		}
+	})
+
+	t.Run("lists themes", func(t *testing.T) {
+		var info ThemeInfo
+		json.NewDecoder(do("admin", "GET", "/api/admin/themes", "").Body).Decode(&info)
+		if info.Active != "default" || strings.Join(info.Themes, ",") != "default,broken,plain" {
+			t.Errorf("themes = %+v", info)
+		}
+	})
+
+	t.Run("rejects themes that don't load", func(t *testing.T) {
+		for _, name := range []string{"broken", "missing", "../plain"} {
+			if rec := do("admin", "PUT", "/api/admin/theme", fmt.Sprintf(`{"name":%q}`, name)); rec.Code != 400 {
+				t.Errorf("PUT theme %q status = %v, want 400", name, rec.Code)
+			}
+		}
+	})
+
+	t.Run("switches the active theme", func(t *testing.T) {
+		if rec := do("admin", "PUT", "/api/admin/theme", `{"name":"plain"}`); rec.Code != http.StatusOK {
+			t.Fatalf("PUT theme status = %v: %s", rec.Code, rec.Body.String())
+		}
+		if active, _ := db.GetSiteSetting(t.Context(), models.SettingActiveTheme); active != "plain" {
+			t.Errorf("active theme = %q, want plain", active)
+		}
+		if body := do("", "GET", "/site/", "").Body.String(); !strings.HasPrefix(body, `<main class="plain">`) {
+			t.Errorf("index with plain theme = %q", body)
+		}
+		if rec := do("", "GET", "/site/static/plain.css", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET theme static file status = %v, want 200", rec.Code)
+		}
+		// Pages the theme lacks fall back to the built-in theme
+		if rec := do("", "GET", "/site/notes/hello.html", ""); !strings.Contains(rec.Body.String(), "<strong>world</strong>") {
+			t.Errorf("note page = %q", rec.Body.String())
+		}
+
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: models.AuditSiteTheme})
+		if len(events) != 1 || events[0].Target != "plain" || events[0].ActorEmail != "admin@example.com" {
+			t.Errorf("theme audit events = %+v", events)
+		}
+	})
+}
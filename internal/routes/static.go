@@ -15,7 +15,7 @@ func RegisterStatic(mux *http.ServeMux) {
 		log.Fatal(err)
 	}
 
-	assets := http.StripPrefix("/assets/", http.FileServer(http.FS(dist)))
+	assets := http.FileServer(http.FS(dist))
 	mux.Handle("GET /assets/{file...}", assets)
 
 	mux.HandleFunc("GET /favicon.ico", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"dragonbytelabs/dz/internal/blobstore"
+	"dragonbytelabs/dz/internal/dbx"
+)
+
+// maxBlobSize caps the blobs PUT /api/sync/{path} accepts
+const maxBlobSize = 64 << 20
+
+// SyncPrefix is where the sync server is served; point the editor's
+// HttpRemoteStore at it
+const SyncPrefix = "/api/sync"
+
+// syncError writes err with the status the editor's remote stores expect
+func syncError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, blobstore.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, blobstore.ErrConflict):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, blobstore.ErrNotSealed):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+// etag quotes a blob hash for the ETag header
+func etag(hash string) string {
+	return `"` + hash + `"`
+}
+
+// ifMatch returns the hash of the If-Match header, quoted or not
+func ifMatch(r *http.Request) string {
+	return strings.Trim(strings.TrimPrefix(r.Header.Get("If-Match"), "W/"), `"`)
+}
+
+// RegisterSync registers the zero-knowledge sync server in the protocol of
+// the editor's HttpRemoteStore. Each signed-in user gets a store of blobs
+// that their client encrypted, paths included, with VaultEncryption: the
+// server keeps them with their ciphertext hash, size and time, and never
+// sees a key or a plaintext. Writes and deletes with If-Match fail with 412
+// when the blob changed.
+func RegisterSync(mux *http.ServeMux, db *dbx.DB, stores *blobstore.Manager) {
+	store := func(w http.ResponseWriter, r *http.Request) (*blobstore.Store, bool) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return nil, false
+		}
+		s, err := stores.Personal(user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return nil, false
+		}
+		return s, true
+	}
+
+	mux.HandleFunc("GET "+SyncPrefix+"/.deez/index.json", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := store(w, r)
+		if !ok {
+			return
+		}
+		writeJSON(w, s.Index())
+	})
+
+	// The index is kept from the blobs the server holds. Clients push theirs
+	// after syncing; it is acknowledged and dropped, since it may list their
+	// plaintext paths.
+	mux.HandleFunc("PUT "+SyncPrefix+"/.deez/index.json", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := store(w, r)
+		if !ok {
+			return
+		}
+		writeJSON(w, s.Index())
+	})
+
+	// Also serves HEAD, for HttpRemoteStore.fileExists
+	mux.HandleFunc("GET "+SyncPrefix+"/{path}", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := store(w, r)
+		if !ok {
+			return
+		}
+		b, blob, err := s.Get(r.Context(), r.PathValue("path"))
+		if err != nil {
+			syncError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("ETag", etag(blob.Hash))
+		w.Header().Set("Last-Modified", blob.Modified.Format(http.TimeFormat))
+		w.Write(b)
+	})
+
+	mux.HandleFunc("PUT "+SyncPrefix+"/{path}", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := store(w, r)
+		if !ok {
+			return
+		}
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBlobSize))
+		if err != nil {
+			http.Error(w, "blob too large or unreadable", http.StatusRequestEntityTooLarge)
+			return
+		}
+		blob, err := s.Put(r.Context(), r.PathValue("path"), b, ifMatch(r))
+		if err != nil {
+			syncError(w, err)
+			return
+		}
+		w.Header().Set("ETag", etag(blob.Hash))
+		writeJSON(w, blob)
+	})
+
+	mux.HandleFunc("DELETE "+SyncPrefix+"/{path}", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := store(w, r)
+		if !ok {
+			return
+		}
+		if err := s.Delete(r.Context(), r.PathValue("path"), ifMatch(r)); err != nil {
+			syncError(w, err)
+			return
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+}
@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dragonbytelabs/dz/internal/blobstore"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// Sealed by the editor's VaultEncryption with the key for "correct horse"
+// and the salt 0..15
+const (
+	// journal/today.md
+	editorPath = "EhvweixjOvf9TCvFEwZ0oxyrWh4icM/aXi6cpRLaqf2J+/7q5a93ambXU/Y="
+	// # Today\nmet Alice at the lighthouse
+	editorContent = "KHQ8GEXvUQh7VQodd7j9MTz1oZtWFaNnmioJJc3EcA9Tn32sBb4HJBFGc54nBkYriNz6kxEa/VYy4JSnKdjr"
+)
+
+// TestSync_ZeroKnowledge syncs a vault through the sync server the way the
+// editor does, recording everything the server receives, sends and stores,
+// and checks that none of it is plaintext
+func TestSync_ZeroKnowledge(t *testing.T) {
+	ts := newTestServer(t, "alice", "bob")
+	base := t.TempDir()
+	RegisterSync(ts.mux, ts.db, blobstore.NewManager(base))
+	ts.login()
+
+	// Everything that crosses the wire to or from the sync server
+	var wire bytes.Buffer
+	do := func(who, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := ts.serve(who, req)
+		if strings.HasPrefix(path, SyncPrefix) {
+			unescaped, _ := url.PathUnescape(path)
+			wire.WriteString(method + " " + unescaped + "\n")
+			for k, v := range header {
+				wire.WriteString(k + ": " + strings.Join(v, ",") + "\n")
+			}
+			wire.WriteString(body + "\n")
+			for k, v := range rec.Header() {
+				wire.WriteString(k + ": " + strings.Join(v, ",") + "\n")
+			}
+			wire.Write(rec.Body.Bytes())
+		}
+		return rec
+	}
+	blobURL := func(sealedPath string) string {
+		return SyncPrefix + "/" + url.PathEscape(sealedPath)
+	}
+
+	// The client: VaultEncryption's key, which never leaves it
+	salt, _ := base64.StdEncoding.DecodeString("AAECAwQFBgcICQoLDA0ODw==")
+	key, err := vault.DeriveKey("correct horse", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func(sealed string) string {
+		t.Helper()
+		b, err := key.Decrypt(sealed)
+		if err != nil {
+			t.Fatalf("Decrypt(%q) returned error: %v", sealed, err)
+		}
+		return string(b)
+	}
+	plaintexts := map[string]string{
+		"clients/acme/contract.md": "# Contract\nThe acme password is hunter2",
+		"diary.md":                 "# Dear diary\nI told nobody about the merger",
+	}
+	sealedPaths := map[string]string{}
+	for p, content := range plaintexts {
+		sealedPaths[p] = key.Encrypt([]byte(p))
+		if rec := do("alice", "PUT", blobURL(sealedPaths[p]), key.Encrypt([]byte(content)), nil); rec.Code != http.StatusOK {
+			t.Fatalf("PUT %s status = %v: %s", p, rec.Code, rec.Body)
+		}
+	}
+	// and one the editor uploaded
+	if rec := do("alice", "PUT", blobURL(editorPath), editorContent, nil); rec.Code != http.StatusOK {
+		t.Fatalf("PUT of the editor's blob status = %v: %s", rec.Code, rec.Body)
+	}
+	plaintexts["journal/today.md"] = "# Today\nmet Alice at the lighthouse"
+
+	t.Run("the client reads back what it synced", func(t *testing.T) {
+		var index blobstore.Index
+		json.NewDecoder(do("alice", "GET", SyncPrefix+"/.deez/index.json", "", nil).Body).Decode(&index)
+		if len(index.Files) != len(plaintexts) {
+			t.Fatalf("index has %d files, want %d", len(index.Files), len(plaintexts))
+		}
+		for _, f := range index.Files {
+			p := open(f.Path)
+			rec := do("alice", "GET", blobURL(f.Path), "", nil)
+			if got := open(rec.Body.String()); got != plaintexts[p] {
+				t.Errorf("%s = %q, want %q", p, got, plaintexts[p])
+			}
+			if rec.Header().Get("ETag") != `"`+f.Hash+`"` || f.Size != int64(rec.Body.Len()) {
+				t.Errorf("%s: ETag %s, size %d for %+v", p, rec.Header().Get("ETag"), rec.Body.Len(), f)
+			}
+		}
+	})
+
+	t.Run("if-match works on ciphertext hashes", func(t *testing.T) {
+		url := blobURL(sealedPaths["diary.md"])
+		current := do("alice", "GET", url, "", nil).Header().Get("ETag")
+		changed := key.Encrypt([]byte("# Dear diary\nstill nobody knows"))
+		rec := do("alice", "PUT", url, changed, http.Header{"If-Match": {current}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PUT with the current hash status = %v: %s", rec.Code, rec.Body)
+		}
+		if rec := do("alice", "PUT", url, key.Encrypt([]byte("# Lost update")), http.Header{"If-Match": {current}}); rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("PUT with a stale hash status = %v, want 412", rec.Code)
+		}
+		if rec := do("alice", "DELETE", url, "", http.Header{"If-Match": {current}}); rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("DELETE with a stale hash status = %v, want 412", rec.Code)
+		}
+		if got := open(do("alice", "GET", url, "", nil).Body.String()); got != "# Dear diary\nstill nobody knows" {
+			t.Errorf("diary.md = %q", got)
+		}
+		plaintexts["diary.md"] = "# Dear diary\nstill nobody knows"
+	})
+
+	t.Run("plaintext is refused", func(t *testing.T) {
+		if rec := do("alice", "PUT", blobURL("notes/plain.md"), key.Encrypt([]byte("x")), nil); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("PUT with a plaintext path status = %v, want 422", rec.Code)
+		}
+		if rec := do("alice", "PUT", blobURL(key.Encrypt([]byte("plain.md"))), "# Plain note", nil); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("PUT of plaintext status = %v, want 422", rec.Code)
+		}
+	})
+
+	t.Run("stores are per user", func(t *testing.T) {
+		if rec := do("bob", "GET", blobURL(editorPath), "", nil); rec.Code != http.StatusNotFound {
+			t.Errorf("bob's GET of alice's blob status = %v, want 404", rec.Code)
+		}
+		if rec := do("", "GET", SyncPrefix+"/.deez/index.json", "", nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous index status = %v, want 401", rec.Code)
+		}
+	})
+
+	// The server never saw, sent or stored a plaintext path or note
+	if !strings.Contains(wire.String(), editorContent) {
+		t.Fatal("the wire wasn't recorded")
+	}
+	var stored bytes.Buffer
+	filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			stored.WriteString(p + "\n")
+			f, _ := os.Open(p)
+			io.Copy(&stored, f)
+			f.Close()
+		}
+		return err
+	})
+	for p, content := range plaintexts {
+		for _, secret := range append([]string{p, filepath.Base(p)}, strings.Split(content, "\n")...) {
+			if bytes.Contains(wire.Bytes(), []byte(secret)) {
+				t.Errorf("%q crossed the wire", secret)
+			}
+			if bytes.Contains(stored.Bytes(), []byte(secret)) {
+				t.Errorf("%q is stored on the server", secret)
+			}
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"dragonbytelabs/dz/internal/audit"
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/webhooks"
+)
+
+type teamContextKey struct{}
+
+// teamAccess is what RequireTeamRole attaches to the request
+type teamAccess struct {
+	user   *models.User
+	member *models.TeamMember
+}
+
+// RequireTeamRole only lets through signed-in members of the team named by
+// the {teamID} path value who hold at least minRole. Non-members get a 404
+// so team IDs can't be probed.
+func RequireTeamRole(db *dbx.DB, minRole string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := authenticatedUser(w, r, db)
+			if !ok {
+				return
+			}
+
+			teamID, err := strconv.ParseInt(r.PathValue("teamID"), 10, 64)
+			if err != nil {
+				http.Error(w, "invalid team id", 400)
+				return
+			}
+
+			member, err := db.GetTeamMember(r.Context(), teamID, user.ID)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			if member == nil {
+				http.Error(w, "team not found", http.StatusNotFound)
+				return
+			}
+			if !member.HasRole(minRole) {
+				http.Error(w, "requires team role "+minRole, http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), teamContextKey{}, &teamAccess{user: user, member: member})
+			ctx = audit.WithActor(ctx, audit.ForUser(user, clientIP(r)))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// teamMember returns the user and membership attached by RequireTeamRole
+func teamMember(r *http.Request) (*models.User, *models.TeamMember) {
+	access := r.Context().Value(teamContextKey{}).(*teamAccess)
+	return access.user, access.member
+}
+
+// authenticatedUser returns the signed-in user, writing a 401 if there is none
+func authenticatedUser(w http.ResponseWriter, r *http.Request, db *dbx.DB) (*models.User, bool) {
+	user, err := currentUser(r, db)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return nil, false
+	}
+	if user == nil {
+		http.Error(w, "not authenticated", http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+// TeamDetail is a team together with its members
+type TeamDetail struct {
+	models.Team
+	Role    string                    `json:"role"`
+	Members []models.TeamMemberDetail `json:"members"`
+}
+
+// RegisterTeams registers team management, membership and invitation endpoints
+func RegisterTeams(mux *http.ServeMux, db *dbx.DB) {
+	withRole := func(role string, h http.HandlerFunc) http.Handler {
+		return RequireTeamRole(db, role)(h)
+	}
+
+	mux.HandleFunc("GET /api/teams", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		teams, err := db.GetTeamsByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, teams)
+	})
+
+	mux.HandleFunc("POST /api/teams", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		var req struct {
+			Name        string  `json:"name"`
+			Description *string `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			http.Error(w, "name required", 400)
+			return
+		}
+
+		team, err := db.CreateTeam(r.Context(), req.Name, req.Description, user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditTeamCreated, Target: teamTarget(team.ID), Details: team.Name})
+		writeJSON(w, team)
+	})
+
+	mux.Handle("GET /api/teams/{teamID}", withRole(models.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		_, member := teamMember(r)
+		team, err := db.GetTeamByID(r.Context(), member.TeamID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		members, err := db.GetTeamMembers(r.Context(), member.TeamID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, TeamDetail{Team: *team, Role: member.Role, Members: members})
+	}))
+
+	mux.Handle("PATCH /api/teams/{teamID}", withRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		_, member := teamMember(r)
+		team, err := db.GetTeamByID(r.Context(), member.TeamID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		var req struct {
+			Name        *string `json:"name"`
+			Description *string `json:"description"`
+			AvatarURL   *string `json:"avatar_url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if req.Name != nil {
+			team.Name = strings.TrimSpace(*req.Name)
+			if team.Name == "" {
+				http.Error(w, "name required", 400)
+				return
+			}
+		}
+		if req.Description != nil {
+			team.Description = req.Description
+		}
+		if req.AvatarURL != nil {
+			team.AvatarURL = req.AvatarURL
+		}
+
+		updated, err := db.UpdateTeam(r.Context(), team)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, updated)
+	}))
+
+	mux.Handle("DELETE /api/teams/{teamID}", withRole(models.RoleOwner, func(w http.ResponseWriter, r *http.Request) {
+		_, member := teamMember(r)
+		if err := db.DeleteTeam(r.Context(), member.TeamID); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		audit.Log(r.Context(), db, models.AuditEvent{Action: models.AuditTeamDeleted, Target: teamTarget(member.TeamID)})
+		writeJSON(w, map[string]bool{"ok": true})
+	}))
+
+	mux.Handle("PATCH /api/teams/{teamID}/members/{userID}", withRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		_, actor := teamMember(r)
+		target, ok := targetMember(w, r, db, actor.TeamID)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Role string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if !models.ValidRole(req.Role) {
+			http.Error(w, "invalid role", 400)
+			return
+		}
+		if req.Role == models.RoleOwner {
+			http.Error(w, "use the transfer endpoint to change ownership", 400)
+			return
+		}
+		if !canManage(actor, target) || !actor.HasRole(req.Role) {
+			http.Error(w, "insufficient role", http.StatusForbidden)
+			return
+		}
+		if target.Role == models.RoleOwner {
+			if lastOwner(w, r, db, target) {
+				return
+			}
+		}
+
+		if err := db.UpdateTeamMemberRole(r.Context(), actor.TeamID, target.UserID, req.Role); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		audit.Log(r.Context(), db, models.AuditEvent{
+			Action:  models.AuditMemberRole,
+			Target:  memberTarget(actor.TeamID, target.UserID),
+			Details: target.Role + " -> " + req.Role,
+		})
+		target.Role = req.Role
+		writeJSON(w, target)
+	}))
+
+	// Admins remove others; any member may remove themselves to leave the team
+	mux.Handle("DELETE /api/teams/{teamID}/members/{userID}", withRole(models.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		_, actor := teamMember(r)
+		target, ok := targetMember(w, r, db, actor.TeamID)
+		if !ok {
+			return
+		}
+
+		leaving := target.UserID == actor.UserID
+		if !leaving && (!actor.HasRole(models.RoleAdmin) || !canManage(actor, target)) {
+			http.Error(w, "insufficient role", http.StatusForbidden)
+			return
+		}
+		if target.Role == models.RoleOwner {
+			if lastOwner(w, r, db, target) {
+				return
+			}
+		}
+
+		if err := db.RemoveTeamMember(r.Context(), actor.TeamID, target.UserID); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		details := "removed"
+		if leaving {
+			details = "left"
+		}
+		audit.Log(r.Context(), db, models.AuditEvent{
+			Action:  models.AuditMemberRemoved,
+			Target:  memberTarget(actor.TeamID, target.UserID),
+			Details: details,
+		})
+		writeJSON(w, map[string]bool{"ok": true})
+	}))
+
+	mux.Handle("POST /api/teams/{teamID}/transfer", withRole(models.RoleOwner, func(w http.ResponseWriter, r *http.Request) {
+		_, actor := teamMember(r)
+		var req struct {
+			UserID int64 `json:"user_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if req.UserID == actor.UserID {
+			http.Error(w, "you already own this team", 400)
+			return
+		}
+
+		target, err := db.GetTeamMember(r.Context(), actor.TeamID, req.UserID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if target == nil {
+			http.Error(w, "new owner must be a team member", 400)
+			return
+		}
+
+		if err := db.TransferTeamOwnership(r.Context(), actor.TeamID, actor.UserID, target.UserID); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		audit.Log(r.Context(), db, models.AuditEvent{Action: models.AuditOwnerChanged, Target: memberTarget(actor.TeamID, target.UserID)})
+		writeJSON(w, map[string]bool{"ok": true})
+	}))
+
+	mux.Handle("GET /api/teams/{teamID}/invitations", withRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		_, member := teamMember(r)
+		invitations, err := db.GetTeamInvitations(r.Context(), member.TeamID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, invitations)
+	}))
+
+	mux.Handle("POST /api/teams/{teamID}/invitations", withRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		user, actor := teamMember(r)
+		var req struct {
+			Email string `json:"email"`
+			Role  string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if req.Role == "" {
+			req.Role = models.RoleViewer
+		}
+		if strings.TrimSpace(req.Email) == "" || !strings.Contains(req.Email, "@") {
+			http.Error(w, "valid email required", 400)
+			return
+		}
+		if !models.ValidRole(req.Role) || req.Role == models.RoleOwner {
+			http.Error(w, "invalid role", 400)
+			return
+		}
+		if !actor.HasRole(req.Role) {
+			http.Error(w, "insufficient role", http.StatusForbidden)
+			return
+		}
+
+		invitee, err := db.GetUserByEmail(r.Context(), strings.TrimSpace(req.Email))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if invitee != nil {
+			existing, err := db.GetTeamMember(r.Context(), actor.TeamID, invitee.ID)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			if existing != nil {
+				http.Error(w, "user is already a member", http.StatusConflict)
+				return
+			}
+		}
+
+		inv, err := db.CreateTeamInvitation(r.Context(), actor.TeamID, req.Email, req.Role, user.ID)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE") {
+				http.Error(w, "an invitation is already pending for this email", http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		audit.Log(r.Context(), db, models.AuditEvent{
+			Action:  models.AuditMemberInvited,
+			Target:  teamTarget(actor.TeamID),
+			Details: inv.Email + " as " + inv.Role,
+		})
+		writeJSON(w, inv)
+	}))
+
+	mux.Handle("DELETE /api/teams/{teamID}/invitations/{id}", withRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		_, member := teamMember(r)
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid invitation id", 400)
+			return
+		}
+		inv, err := db.GetTeamInvitation(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if inv == nil || inv.TeamID != member.TeamID {
+			http.Error(w, "invitation not found", http.StatusNotFound)
+			return
+		}
+		if err := db.DeleteTeamInvitation(r.Context(), id); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+	}))
+
+	// Invitations addressed to the signed-in user
+	mux.HandleFunc("GET /api/invitations", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		invitations, err := db.GetInvitationsByEmail(r.Context(), user.Email)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, invitations)
+	})
+
+	mux.HandleFunc("POST /api/invitations/{id}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid invitation id", 400)
+			return
+		}
+		inv, err := db.GetTeamInvitation(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if inv == nil || !strings.EqualFold(inv.Email, user.Email) {
+			http.Error(w, "invitation not found", http.StatusNotFound)
+			return
+		}
+
+		var action string
+		switch r.PathValue("action") {
+		case "accept":
+			action = models.AuditMemberJoined
+			err = db.AcceptTeamInvitation(r.Context(), inv, user.ID)
+		case "decline":
+			action = models.AuditMemberDeclined
+			err = db.DeclineTeamInvitation(r.Context(), inv.ID)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if errors.Is(err, dbx.ErrInvitationNotPending) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: action, Target: memberTarget(inv.TeamID, user.ID), Details: inv.Role})
+		if action == models.AuditMemberJoined {
+			notify(r, db, user, webhooks.MemberJoined(inv.TeamID, user.ID, inv.Role))
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+}
+
+func teamTarget(teamID int64) string {
+	return fmt.Sprintf("teams/%d", teamID)
+}
+
+func memberTarget(teamID, userID int64) string {
+	return fmt.Sprintf("teams/%d/members/%d", teamID, userID)
+}
+
+// targetMember loads the membership named by the {userID} path value
+func targetMember(w http.ResponseWriter, r *http.Request, db *dbx.DB, teamID int64) (*models.TeamMember, bool) {
+	userID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid user id", 400)
+		return nil, false
+	}
+	target, err := db.GetTeamMember(r.Context(), teamID, userID)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return nil, false
+	}
+	if target == nil {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return nil, false
+	}
+	return target, true
+}
+
+// canManage reports whether actor may change or remove target.
+// Owners manage everyone; others only manage members below them.
+func canManage(actor, target *models.TeamMember) bool {
+	return actor.Role == models.RoleOwner || actor.Outranks(target.Role)
+}
+
+// lastOwner writes a 409 and reports true if owner is the team's only owner
+func lastOwner(w http.ResponseWriter, r *http.Request, db *dbx.DB, owner *models.TeamMember) bool {
+	owners, err := db.CountTeamOwners(r.Context(), owner.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return true
+	}
+	if owners <= 1 {
+		http.Error(w, "a team needs an owner; transfer ownership first", http.StatusConflict)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestTeams(t *testing.T) {
+	ts := newTestServer(t, "owner", "admin", "viewer", "outsider")
+	db, users := ts.db, ts.users
+
+	RegisterTeams(ts.mux, db)
+	ts.login()
+	do := ts.do
+
+	var team models.Team
+	rec := do("owner", "POST", "/api/teams", `{"name":"Docs"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /api/teams status = %v: %s", rec.Code, rec.Body)
+	}
+	json.NewDecoder(rec.Body).Decode(&team)
+	base := fmt.Sprintf("/api/teams/%d", team.ID)
+
+	// invite accepts the invitation on behalf of the invitee
+	invite := func(who, role string) {
+		t.Helper()
+		rec := do("owner", "POST", base+"/invitations", fmt.Sprintf(`{"email":"%s@example.com","role":"%s"}`, who, role))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("invite %s status = %v: %s", who, rec.Code, rec.Body)
+		}
+		var inv models.TeamInvitation
+		json.NewDecoder(rec.Body).Decode(&inv)
+		if rec := do(who, "POST", fmt.Sprintf("/api/invitations/%d/accept", inv.ID), ""); rec.Code != http.StatusOK {
+			t.Fatalf("accept %s status = %v: %s", who, rec.Code, rec.Body)
+		}
+	}
+
+	t.Run("creator is owner", func(t *testing.T) {
+		m, _ := db.GetTeamMember(t.Context(), team.ID, users["owner"].ID)
+		if m == nil || m.Role != models.RoleOwner {
+			t.Errorf("creator membership = %+v, want owner", m)
+		}
+	})
+
+	t.Run("invitations can be accepted", func(t *testing.T) {
+		invite("admin", models.RoleAdmin)
+		invite("viewer", models.RoleViewer)
+
+		rec := do("viewer", "GET", base, "")
+		var detail TeamDetail
+		json.NewDecoder(rec.Body).Decode(&detail)
+		if rec.Code != http.StatusOK || len(detail.Members) != 3 {
+			t.Errorf("GET team status = %v, members = %d, want 200 and 3", rec.Code, len(detail.Members))
+		}
+	})
+
+	t.Run("invitations can be declined", func(t *testing.T) {
+		rec := do("admin", "POST", base+"/invitations", `{"email":"outsider@example.com"}`)
+		var inv models.TeamInvitation
+		json.NewDecoder(rec.Body).Decode(&inv)
+
+		if rec := do("viewer", "POST", fmt.Sprintf("/api/invitations/%d/decline", inv.ID), ""); rec.Code != http.StatusNotFound {
+			t.Errorf("decline by someone else status = %v, want 404", rec.Code)
+		}
+		if rec := do("outsider", "POST", fmt.Sprintf("/api/invitations/%d/decline", inv.ID), ""); rec.Code != http.StatusOK {
+			t.Errorf("decline status = %v, want 200", rec.Code)
+		}
+		if rec := do("outsider", "POST", fmt.Sprintf("/api/invitations/%d/accept", inv.ID), ""); rec.Code != http.StatusConflict {
+			t.Errorf("accept after decline status = %v, want 409", rec.Code)
+		}
+	})
+
+	t.Run("non-members can't see the team", func(t *testing.T) {
+		if rec := do("outsider", "GET", base, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET team as outsider status = %v, want 404", rec.Code)
+		}
+	})
+
+	t.Run("roles are enforced", func(t *testing.T) {
+		if rec := do("viewer", "PATCH", base, `{"name":"Hacked"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("PATCH team as viewer status = %v, want 403", rec.Code)
+		}
+		if rec := do("admin", "PATCH", base, `{"name":"Handbook"}`); rec.Code != http.StatusOK {
+			t.Errorf("PATCH team as admin status = %v, want 200", rec.Code)
+		}
+		path := fmt.Sprintf("%s/members/%d", base, users["owner"].ID)
+		if rec := do("admin", "DELETE", path, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("admin removing owner status = %v, want 403", rec.Code)
+		}
+		if rec := do("admin", "DELETE", base, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("admin deleting team status = %v, want 403", rec.Code)
+		}
+	})
+
+	t.Run("last owner can't leave", func(t *testing.T) {
+		path := fmt.Sprintf("%s/members/%d", base, users["owner"].ID)
+		if rec := do("owner", "DELETE", path, ""); rec.Code != http.StatusConflict {
+			t.Errorf("last owner leaving status = %v, want 409", rec.Code)
+		}
+	})
+
+	t.Run("ownership can be transferred", func(t *testing.T) {
+		rec := do("owner", "POST", base+"/transfer", fmt.Sprintf(`{"user_id":%d}`, users["admin"].ID))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("transfer status = %v: %s", rec.Code, rec.Body)
+		}
+		newOwner, _ := db.GetTeamMember(t.Context(), team.ID, users["admin"].ID)
+		oldOwner, _ := db.GetTeamMember(t.Context(), team.ID, users["owner"].ID)
+		if newOwner.Role != models.RoleOwner || oldOwner.Role != models.RoleAdmin {
+			t.Errorf("after transfer roles = %s/%s, want owner/admin", newOwner.Role, oldOwner.Role)
+		}
+
+		path := fmt.Sprintf("%s/members/%d", base, users["owner"].ID)
+		if rec := do("owner", "DELETE", path, ""); rec.Code != http.StatusOK {
+			t.Errorf("former owner leaving status = %v, want 200", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,448 @@
+package routes
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"dragonbytelabs/dz/internal/audit"
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/lint"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/session"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// Vaults resolves which vault a request addresses and with what role.
+// Signed-in users own their personal vault and act in a team vault with
+// their team role. Anonymous requests use the fallback vault when one is
+// configured (single-user desktop mode) and are rejected otherwise.
+type Vaults struct {
+	db       *dbx.DB
+	manager  *vault.Manager
+	fallback *vault.Vault
+	keys     *keyring
+}
+
+// keyIdleTimeout is how long an unlocked vault stays unlocked for a session
+// that doesn't use it
+const keyIdleTimeout = 30 * time.Minute
+
+// keyring holds the keys of the encrypted vaults each session unlocked.
+// Keys are kept in memory only, so a restart locks every vault.
+type keyring struct {
+	mu   sync.Mutex
+	keys map[string]*unlocked // by session id and vault name
+}
+
+type unlocked struct {
+	key  *vault.Key
+	used time.Time
+}
+
+func keyringID(sess *session.Session, v *vault.Vault) string {
+	return sess.ID() + "\x00" + v.Name()
+}
+
+// get returns the key sess unlocked v with, nil if it didn't or has been
+// idle for too long
+func (kr *keyring) get(sess *session.Session, v *vault.Vault) *vault.Key {
+	if sess == nil {
+		return nil
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	u, ok := kr.keys[keyringID(sess, v)]
+	if !ok {
+		return nil
+	}
+	if time.Since(u.used) > keyIdleTimeout {
+		delete(kr.keys, keyringID(sess, v))
+		return nil
+	}
+	u.used = time.Now()
+	return u.key
+}
+
+// put keeps k for sess, dropping the keys idle for too long
+func (kr *keyring) put(sess *session.Session, v *vault.Vault, k *vault.Key) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for id, u := range kr.keys {
+		if time.Since(u.used) > keyIdleTimeout {
+			delete(kr.keys, id)
+		}
+	}
+	kr.keys[keyringID(sess, v)] = &unlocked{key: k, used: time.Now()}
+}
+
+func (kr *keyring) remove(sess *session.Session, v *vault.Vault) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	delete(kr.keys, keyringID(sess, v))
+}
+
+// NewVaults creates a resolver; fallback may be nil. Changes to any of the
+// vaults are recorded in the audit log.
+func NewVaults(db *dbx.DB, manager *vault.Manager, fallback *vault.Vault) *Vaults {
+	observer := func(ctx context.Context, e vault.Event) {
+		event := models.AuditEvent{
+			Action:     "file." + e.Op,
+			Target:     e.Path,
+			Vault:      e.Vault,
+			HashBefore: e.HashBefore,
+			HashAfter:  e.HashAfter,
+		}
+		if e.OldPath != "" {
+			event.Details = "renamed from " + e.OldPath
+		}
+		audit.Log(ctx, db, event)
+	}
+	vs := &Vaults{db: db, manager: manager, fallback: fallback, keys: &keyring{keys: map[string]*unlocked{}}}
+	vs.Observe(observer)
+	return vs
+}
+
+// Observe registers fn on every vault the resolver serves
+func (vs *Vaults) Observe(fn vault.Observer) {
+	vs.manager.Observe(fn)
+	if vs.fallback != nil {
+		vs.fallback.Observe(fn)
+	}
+}
+
+// Hook registers fn on every vault the resolver serves
+func (vs *Vaults) Hook(fn vault.Hook) {
+	vs.manager.Hook(fn)
+	if vs.fallback != nil {
+		vs.fallback.Hook(fn)
+	}
+}
+
+// byName returns the vault with the given Name, without access checks
+func (vs *Vaults) byName(name string) (*vault.Vault, error) {
+	if vs.fallback != nil && name == vs.fallback.Name() {
+		return vs.fallback, nil
+	}
+	return vs.manager.Open(name)
+}
+
+// VaultInfo describes a vault the current user can open
+type VaultInfo struct {
+	ID   string `json:"id"` // value for the ?vault= parameter
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// resolve returns the vault named by the ?vault= parameter ("personal",
+// the default, or "team:<id>") and a request whose context carries the
+// caller's role, and the vault's key if the session unlocked it. It writes
+// an error response and returns false on failure.
+func (vs *Vaults) resolve(w http.ResponseWriter, r *http.Request) (*vault.Vault, *http.Request, bool) {
+	user, err := currentUser(r, vs.db)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return nil, nil, false
+	}
+	if user == nil {
+		if vs.fallback == nil {
+			http.Error(w, "not authenticated", http.StatusUnauthorized)
+			return nil, nil, false
+		}
+		ctx := audit.WithActor(r.Context(), audit.Actor{IP: clientIP(r)})
+		return vs.fallback, r.WithContext(vs.withKey(ctx, r, vs.fallback)), true
+	}
+
+	var v *vault.Vault
+	role := models.RoleOwner
+	switch id := r.URL.Query().Get("vault"); {
+	case id == "" || id == "personal":
+		v, err = vs.manager.Personal(user.ID)
+	case strings.HasPrefix(id, "team:"):
+		teamID, perr := strconv.ParseInt(strings.TrimPrefix(id, "team:"), 10, 64)
+		if perr != nil {
+			http.Error(w, "invalid vault", 400)
+			return nil, nil, false
+		}
+		member, merr := vs.db.GetTeamMember(r.Context(), teamID, user.ID)
+		if merr != nil {
+			http.Error(w, merr.Error(), 500)
+			return nil, nil, false
+		}
+		if member == nil {
+			http.Error(w, "vault not found", http.StatusNotFound)
+			return nil, nil, false
+		}
+		role = member.Role
+		v, err = vs.manager.Team(teamID)
+	default:
+		http.Error(w, "invalid vault", 400)
+		return nil, nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return nil, nil, false
+	}
+
+	ctx := vault.WithRole(r.Context(), role)
+	ctx = audit.WithActor(ctx, audit.ForUser(user, clientIP(r)))
+	return v, r.WithContext(vs.withKey(ctx, r, v)), true
+}
+
+// withKey adds the key the request's session unlocked v with to ctx
+func (vs *Vaults) withKey(ctx context.Context, r *http.Request, v *vault.Vault) context.Context {
+	if k := vs.keys.get(session.GetSessionSafe(r), v); k != nil {
+		return vault.WithKey(ctx, k)
+	}
+	return ctx
+}
+
+// vaultError writes err, using 403 for ACL denials, 422 for changes refused
+// by plugin hooks, 423 for encrypted vaults the session hasn't unlocked and
+// code otherwise
+func vaultError(w http.ResponseWriter, err error, code int) {
+	if errors.Is(err, vault.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, vault.ErrLocked) {
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	}
+	if errors.Is(err, vault.ErrRejected) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	http.Error(w, err.Error(), code)
+}
+
+// maxImportSize caps the archives POST /api/vault/import accepts
+const maxImportSize = 1 << 30
+
+// exportFileName is the download name of a vault export, e.g.
+// "teams-3-2024-05-01.zip"
+func exportFileName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '-'
+	}, name)
+	return fmt.Sprintf("%s-%s.zip", strings.Trim(safe, "-."), time.Now().Format("2006-01-02"))
+}
+
+// RegisterVaults registers vault discovery, manifest, unlocking, check,
+// export and import, and ACL management endpoints
+func RegisterVaults(mux *http.ServeMux, vs *Vaults) {
+	mux.HandleFunc("GET /api/vaults", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authenticatedUser(w, r, vs.db)
+		if !ok {
+			return
+		}
+		teams, err := vs.db.GetTeamsByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		out := []VaultInfo{{ID: "personal", Name: "Personal", Role: models.RoleOwner}}
+		for _, t := range teams {
+			member, err := vs.db.GetTeamMember(r.Context(), t.ID, user.ID)
+			if err != nil || member == nil {
+				continue
+			}
+			out = append(out, VaultInfo{ID: fmt.Sprintf("team:%d", t.ID), Name: t.Name, Role: member.Role})
+		}
+		writeJSON(w, out)
+	})
+
+	mux.HandleFunc("GET /api/vault", func(w http.ResponseWriter, r *http.Request) {
+		v, _, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		writeJSON(w, v.Manifest())
+	})
+
+	// Unlocks an encrypted vault for the session until it locks it, signs
+	// out or leaves it idle for keyIdleTimeout
+	mux.HandleFunc("POST /api/vault/unlock", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		sess := session.GetSessionSafe(r)
+		if sess == nil {
+			http.Error(w, "unlocking a vault needs a session", 400)
+			return
+		}
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if !v.Encrypted() {
+			http.Error(w, "vault is not encrypted", 400)
+			return
+		}
+		k, err := v.Unlock(req.Password)
+		if errors.Is(err, vault.ErrWrongPassword) {
+			audit.Log(r.Context(), vs.db, models.AuditEvent{Action: models.AuditUnlockFailed, Vault: v.Name()})
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		vs.keys.put(sess, v, k)
+		audit.Log(r.Context(), vs.db, models.AuditEvent{Action: models.AuditVaultUnlocked, Vault: v.Name()})
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+
+	mux.HandleFunc("POST /api/vault/lock", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		if sess := session.GetSessionSafe(r); sess != nil {
+			vs.keys.remove(sess, v)
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+
+	// Checks the vault for problems; see package lint
+	mux.HandleFunc("GET /api/vault/check", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		report, err := lint.Check(r.Context(), v)
+		if err != nil {
+			vaultError(w, err, 500)
+			return
+		}
+		writeJSON(w, report)
+	})
+
+	// Checks the vault and repairs what is safely fixable. Fixes are made
+	// with the caller's role, so those it may not write are reported failed.
+	mux.HandleFunc("POST /api/vault/check/fix", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		report, err := lint.Check(r.Context(), v)
+		if err != nil {
+			vaultError(w, err, 500)
+			return
+		}
+		report.Fix(r.Context())
+		writeJSON(w, report)
+	})
+
+	// Streams the vault as a zip with its manifest and file hashes
+	mux.HandleFunc("GET /api/vault/export", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		m := v.Manifest()
+		audit.Log(r.Context(), vs.db, models.AuditEvent{Action: models.AuditVaultExport, Vault: v.Name()})
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exportFileName(m.Name)))
+		if err := v.Export(r.Context(), w); err != nil {
+			// The headers are gone; a truncated zip is all the client gets
+			log.Printf("exporting vault %s: %v", v.Name(), err)
+		}
+	})
+
+	// Imports a zip posted as the body. ?policy= says what happens to files
+	// that exist with other content (skip, overwrite or merge) and
+	// ?dry_run=true reports what would happen without writing.
+	mux.HandleFunc("POST /api/vault/import", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		opts := vault.ImportOptions{Policy: r.URL.Query().Get("policy"), DryRun: r.URL.Query().Get("dry_run") == "true"}
+		if opts.Policy != "" && !vault.ValidImportPolicy(opts.Policy) {
+			http.Error(w, "policy must be skip, overwrite or merge", 400)
+			return
+		}
+
+		// Zips are read from the end, so the body is spooled to disk first
+		tmp, err := os.CreateTemp("", "dz-import-*.zip")
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		size, err := io.Copy(tmp, http.MaxBytesReader(w, r.Body, maxImportSize))
+		if err != nil {
+			http.Error(w, "archive too large or unreadable", http.StatusRequestEntityTooLarge)
+			return
+		}
+		zr, err := zip.NewReader(tmp, size)
+		if err != nil {
+			http.Error(w, "body is not a zip archive", 400)
+			return
+		}
+
+		res, err := v.Import(r.Context(), zr, opts)
+		if err != nil {
+			if errors.Is(err, vault.ErrBadArchive) {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			vaultError(w, err, 500)
+			return
+		}
+		if !opts.DryRun {
+			details, _ := json.Marshal(res.Summary)
+			audit.Log(r.Context(), vs.db, models.AuditEvent{Action: models.AuditVaultImport, Vault: v.Name(), Target: res.Manifest.ID, Details: string(details)})
+		}
+		writeJSON(w, res)
+	})
+
+	mux.HandleFunc("GET /api/vault/acl", func(w http.ResponseWriter, r *http.Request) {
+		v, _, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		writeJSON(w, v.ACL())
+	})
+
+	mux.HandleFunc("PUT /api/vault/acl", func(w http.ResponseWriter, r *http.Request) {
+		v, r, ok := vs.resolve(w, r)
+		if !ok {
+			return
+		}
+		var acl vault.ACL
+		if err := json.NewDecoder(r.Body).Decode(&acl); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		if err := v.SetACL(r.Context(), acl); err != nil {
+			vaultError(w, err, 400)
+			return
+		}
+		details, _ := json.Marshal(acl.Rules)
+		audit.Log(r.Context(), vs.db, models.AuditEvent{Action: models.AuditVaultACL, Vault: v.Name(), Details: string(details)})
+		writeJSON(w, v.ACL())
+	})
+}
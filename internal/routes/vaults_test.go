@@ -0,0 +1,203 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"dragonbytelabs/dz/internal/lint"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func TestVaults(t *testing.T) {
+	ts := newTestServer(t, "owner", "editor", "viewer")
+	db, owner, viewer := ts.db, ts.users["owner"], ts.users["viewer"]
+	team, _ := db.CreateTeam(t.Context(), "Docs", nil, owner.ID)
+	db.AddTeamMember(t.Context(), team.ID, viewer.ID, models.RoleViewer)
+
+	vs := NewVaults(db, vault.NewManager(t.TempDir()), nil)
+	RegisterApi(ts.mux, vs)
+	RegisterVaults(ts.mux, vs)
+	ts.login()
+	do := ts.do
+	teamVault := fmt.Sprintf("vault=team:%d", team.ID)
+
+	t.Run("anonymous requests need a fallback vault", func(t *testing.T) {
+		if rec := do("", "GET", "/api/files", ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("GET /api/files status = %v, want 401", rec.Code)
+		}
+	})
+
+	t.Run("personal vaults are private", func(t *testing.T) {
+		do("owner", "POST", "/api/file", `{"path":"mine.md","content":"secret"}`)
+		var files []vault.FileInfo
+		json.NewDecoder(do("viewer", "GET", "/api/files", "").Body).Decode(&files)
+		if len(files) != 0 {
+			t.Errorf("viewer's personal vault has %d files, want 0", len(files))
+		}
+	})
+
+	t.Run("team vaults enforce roles and rules", func(t *testing.T) {
+		if rec := do("owner", "POST", "/api/file?"+teamVault, `{"path":"clients/acme.md","content":"x"}`); rec.Code != http.StatusOK {
+			t.Fatalf("owner write status = %v: %s", rec.Code, rec.Body)
+		}
+		if rec := do("owner", "PUT", "/api/vault/acl?"+teamVault, `{"rules":[{"prefix":"clients/","read":"editor","write":"editor"}]}`); rec.Code != http.StatusOK {
+			t.Fatalf("PUT acl status = %v: %s", rec.Code, rec.Body)
+		}
+
+		if rec := do("viewer", "GET", "/api/file?path=clients/acme.md&"+teamVault, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("viewer read status = %v, want 403", rec.Code)
+		}
+		if rec := do("viewer", "POST", "/api/file?"+teamVault, `{"path":"notes.md","content":"x"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("viewer write status = %v, want 403", rec.Code)
+		}
+		var files []vault.FileInfo
+		json.NewDecoder(do("viewer", "GET", "/api/files?"+teamVault, "").Body).Decode(&files)
+		if len(files) != 0 {
+			t.Errorf("viewer listing has %d files, want 0", len(files))
+		}
+	})
+
+	t.Run("creating doesn't overwrite files the writer can't read", func(t *testing.T) {
+		db.AddTeamMember(t.Context(), team.ID, ts.users["editor"].ID, models.RoleEditor)
+		do("owner", "PUT", "/api/vault/acl?"+teamVault, `{"rules":[{"prefix":"drop/","read":"admin","write":"editor"}]}`)
+		do("owner", "POST", "/api/file?"+teamVault, `{"path":"drop/report.md","content":"original"}`)
+		if rec := do("editor", "POST", "/api/file?"+teamVault, `{"path":"drop/report.md","content":"x"}`); rec.Code != http.StatusConflict {
+			t.Errorf("editor create over unreadable file status = %v, want 409", rec.Code)
+		}
+		if rec := do("editor", "POST", "/api/file?"+teamVault, `{"path":"drop/new.md","content":"x"}`); rec.Code != http.StatusOK {
+			t.Errorf("editor create status = %v: %s", rec.Code, rec.Body)
+		}
+		var res vault.ReadResult
+		json.NewDecoder(do("owner", "GET", "/api/file?path=drop/report.md&"+teamVault, "").Body).Decode(&res)
+		if res.Content != "original" {
+			t.Errorf("drop/report.md = %q, want it unchanged", res.Content)
+		}
+		do("owner", "PUT", "/api/vault/acl?"+teamVault, `{"rules":[{"prefix":"clients/","read":"editor","write":"editor"}]}`)
+	})
+
+	t.Run("lists accessible vaults", func(t *testing.T) {
+		var vaults []VaultInfo
+		json.NewDecoder(do("viewer", "GET", "/api/vaults", "").Body).Decode(&vaults)
+		if len(vaults) != 2 || vaults[1].Role != models.RoleViewer {
+			t.Errorf("GET /api/vaults = %+v, want personal and team as viewer", vaults)
+		}
+	})
+
+	t.Run("serves the manifest", func(t *testing.T) {
+		var personal, shared vault.Manifest
+		json.NewDecoder(do("owner", "GET", "/api/vault", "").Body).Decode(&personal)
+		json.NewDecoder(do("viewer", "GET", "/api/vault?"+teamVault, "").Body).Decode(&shared)
+		if personal.Version != vault.SpecVersion || personal.Name != fmt.Sprintf("users/%d", owner.ID) {
+			t.Errorf("personal manifest = %+v", personal)
+		}
+		if shared.ID == "" || shared.ID == personal.ID || shared.Name != fmt.Sprintf("teams/%d", team.ID) {
+			t.Errorf("team manifest = %+v, want its own id", shared)
+		}
+		var again vault.Manifest
+		json.NewDecoder(do("owner", "GET", "/api/vault?"+teamVault, "").Body).Decode(&again)
+		if again.ID != shared.ID {
+			t.Errorf("team vault id changed from %s to %s", shared.ID, again.ID)
+		}
+	})
+
+	t.Run("checks and fixes the vault", func(t *testing.T) {
+		var report lint.Report
+		json.NewDecoder(do("owner", "GET", "/api/vault/check", "").Body).Decode(&report)
+		if report.Notes != 1 || report.OK() || report.Issues[0].Kind != lint.MissingFrontmatter {
+			t.Fatalf("check = %+v, want mine.md without frontmatter", report)
+		}
+
+		// Viewers may check a team vault but not fix it
+		do("owner", "POST", "/api/file?"+teamVault, `{"path":"notes.md","content":"x"}`)
+		json.NewDecoder(do("viewer", "POST", "/api/vault/check/fix?"+teamVault, "").Body).Decode(&report)
+		if report.Fixed != 0 || report.Issues[0].FixError == "" {
+			t.Errorf("fix as viewer = %+v, want it refused", report)
+		}
+
+		json.NewDecoder(do("owner", "POST", "/api/vault/check/fix", "").Body).Decode(&report)
+		if report.Fixed != 1 {
+			t.Errorf("fix = %+v, want 1 fixed", report)
+		}
+		json.NewDecoder(do("owner", "GET", "/api/vault/check", "").Body).Decode(&report)
+		if !report.OK() {
+			t.Errorf("check after fixing = %+v", report)
+		}
+	})
+
+	t.Run("exports and imports", func(t *testing.T) {
+		rec := do("owner", "GET", "/api/vault/export", "")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+			t.Fatalf("export = %v %v", rec.Code, rec.Header())
+		}
+		if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, fmt.Sprintf(`filename="users-%d-`, owner.ID)) {
+			t.Errorf("Content-Disposition = %q", cd)
+		}
+		archive := rec.Body.String()
+
+		if rec := do("viewer", "POST", "/api/vault/import?policy=explode", archive); rec.Code != 400 {
+			t.Errorf("import with unknown policy status = %v, want 400", rec.Code)
+		}
+		if rec := do("viewer", "POST", "/api/vault/import", "not a zip"); rec.Code != 400 {
+			t.Errorf("import of garbage status = %v, want 400", rec.Code)
+		}
+
+		var res vault.ImportResult
+		json.NewDecoder(do("viewer", "POST", "/api/vault/import?dry_run=true", archive).Body).Decode(&res)
+		if !res.DryRun || !res.Verified || res.Summary[vault.ImportCreated] != 1 {
+			t.Errorf("dry run = %+v", res)
+		}
+		json.NewDecoder(do("viewer", "POST", "/api/vault/import", archive).Body).Decode(&res)
+		if res.Summary[vault.ImportCreated] != 1 {
+			t.Fatalf("import = %+v", res)
+		}
+		if rec := do("viewer", "GET", "/api/file?path=mine.md", ""); rec.Code != http.StatusOK {
+			t.Errorf("imported note status = %v", rec.Code)
+		}
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: "vault.imported"})
+		if len(events) != 1 || events[0].Vault != fmt.Sprintf("users/%d", viewer.ID) {
+			t.Errorf("import audit events = %+v", events)
+		}
+	})
+
+	t.Run("encrypted vaults unlock per session", func(t *testing.T) {
+		if rec := do("viewer", "POST", "/api/vault/unlock", `{"password":"x"}`); rec.Code != 400 {
+			t.Errorf("unlocking a plain vault status = %v, want 400", rec.Code)
+		}
+		v, _ := vs.manager.Personal(owner.ID)
+		if _, err := v.Rekey(t.Context(), "correct horse", true); err != nil {
+			t.Fatalf("Rekey() returned error: %v", err)
+		}
+		rec := do("", "POST", "/api/auth/login", `{"email":"owner@example.com","password":"pw"}`)
+		ts.cookies["owner2"] = rec.Result().Cookies()
+
+		if rec := do("owner", "GET", "/api/file?path=mine.md", ""); rec.Code != http.StatusLocked {
+			t.Errorf("locked read status = %v, want 423", rec.Code)
+		}
+		if rec := do("owner", "POST", "/api/vault/unlock", `{"password":"wrong"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("wrong password status = %v, want 403", rec.Code)
+		}
+		if rec := do("owner", "POST", "/api/vault/unlock", `{"password":"correct horse"}`); rec.Code != http.StatusOK {
+			t.Fatalf("unlock status = %v: %s", rec.Code, rec.Body)
+		}
+		var res vault.ReadResult
+		json.NewDecoder(do("owner", "GET", "/api/file?path=mine.md", "").Body).Decode(&res)
+		if !strings.HasSuffix(res.Content, "secret") {
+			t.Errorf("unlocked read = %+v", res)
+		}
+		if rec := do("owner2", "GET", "/api/file?path=mine.md", ""); rec.Code != http.StatusLocked {
+			t.Errorf("other session's read status = %v, want 423", rec.Code)
+		}
+		do("owner", "POST", "/api/vault/lock", "")
+		if rec := do("owner", "GET", "/api/file?path=mine.md", ""); rec.Code != http.StatusLocked {
+			t.Errorf("read after lock status = %v, want 423", rec.Code)
+		}
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: models.AuditUnlockFailed})
+		if len(events) != 1 {
+			t.Errorf("failed unlock audit events = %+v", events)
+		}
+	})
+}
@@ -0,0 +1,304 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"dragonbytelabs/dz/internal/audit"
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/vault"
+	"dragonbytelabs/dz/internal/webhooks"
+)
+
+const (
+	// defaultDeliveryPage and maxDeliveryPage size the delivery log
+	defaultDeliveryPage = 50
+	maxDeliveryPage     = 500
+)
+
+// webhookRequest carries the fields of a webhook to set; nil fields are
+// left alone. An empty secret on creation, or rotate_secret, generates one.
+type webhookRequest struct {
+	URL          *string  `json:"url"`
+	Secret       *string  `json:"secret"`
+	RotateSecret bool     `json:"rotate_secret"`
+	Events       []string `json:"events"`
+	Paths        []string `json:"paths"`
+	Vault        *string  `json:"vault"`
+	Active       *bool    `json:"active"`
+}
+
+// WebhookInfo is a webhook with its secret, returned when the secret is set
+type WebhookInfo struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+// notify queues e for the webhooks, attributed to user
+func notify(r *http.Request, db *dbx.DB, user *models.User, e webhooks.Event) {
+	ctx := r.Context()
+	if user != nil {
+		ctx = audit.WithActor(ctx, audit.ForUser(user, clientIP(r)))
+	}
+	webhooks.Notify(ctx, db, e)
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to generate webhook secret")
+	}
+	return hex.EncodeToString(b)
+}
+
+// apply sets the requested fields on h, writing a 400 for invalid ones. It
+// reports whether the secret changed.
+func (req *webhookRequest) apply(w http.ResponseWriter, vs *Vaults, h *models.Webhook) (secretChanged, ok bool) {
+	if req.URL != nil {
+		u, err := url.Parse(*req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "url must be an absolute http or https URL", 400)
+			return false, false
+		}
+		h.URL = u.String()
+	}
+	if h.URL == "" {
+		http.Error(w, "url is required", 400)
+		return false, false
+	}
+	if req.Events != nil {
+		h.Events = models.StringList{}
+		for _, e := range req.Events {
+			if !models.ValidWebhookEvent(e) {
+				http.Error(w, fmt.Sprintf("unknown event %q", e), 400)
+				return false, false
+			}
+			h.Events = append(h.Events, e)
+		}
+	}
+	if len(h.Events) == 0 {
+		http.Error(w, "events are required", 400)
+		return false, false
+	}
+	if req.Paths != nil {
+		h.Paths = models.StringList{}
+		for _, p := range req.Paths {
+			if !webhooks.ValidPattern(p) {
+				http.Error(w, fmt.Sprintf("invalid path pattern %q", p), 400)
+				return false, false
+			}
+			h.Paths = append(h.Paths, p)
+		}
+	}
+	if req.Vault != nil {
+		h.Vault = *req.Vault
+		if h.Vault != "" {
+			if _, err := vs.byName(h.Vault); err != nil {
+				http.Error(w, err.Error(), 400)
+				return false, false
+			}
+		}
+	}
+	if req.Active != nil {
+		h.IsActive = *req.Active
+	}
+	switch {
+	case req.Secret != nil && *req.Secret != "":
+		h.Secret = *req.Secret
+	case req.RotateSecret || h.Secret == "":
+		h.Secret = newWebhookSecret()
+	default:
+		return false, true
+	}
+	return true, true
+}
+
+// webhookByID loads the webhook named by the {id} path value, writing an
+// error when there is none
+func webhookByID(w http.ResponseWriter, r *http.Request, db *dbx.DB) (*models.Webhook, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", 400)
+		return nil, false
+	}
+	h, err := db.GetWebhook(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return nil, false
+	}
+	if h == nil {
+		http.Error(w, "webhook not found", http.StatusNotFound)
+		return nil, false
+	}
+	return h, true
+}
+
+// RegisterWebhooks registers the admin API for outgoing webhooks and
+// queues deliveries for changes to the vaults
+func RegisterWebhooks(mux *http.ServeMux, vs *Vaults) {
+	db := vs.db
+	vs.Observe(func(ctx context.Context, e vault.Event) {
+		switch e.Op {
+		case vault.OpCreate, vault.OpWrite, vault.OpRename, vault.OpDelete:
+			webhooks.Notify(ctx, db, webhooks.Event{Type: "file." + e.Op, Vault: e.Vault, Path: e.Path, OldPath: e.OldPath})
+		}
+	})
+
+	mux.HandleFunc("GET /api/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		hooks, err := db.GetWebhooks(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, hooks)
+	})
+
+	mux.HandleFunc("POST /api/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		var req webhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		h := &models.Webhook{IsActive: true, CreatedBy: &user.ID}
+		if _, ok := req.apply(w, vs, h); !ok {
+			return
+		}
+		saved, err := db.CreateWebhook(r.Context(), h)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditWebhookCreated, Target: fmt.Sprint(saved.ID), Details: saved.URL})
+		writeJSON(w, WebhookInfo{Webhook: *saved, Secret: saved.Secret})
+	})
+
+	mux.HandleFunc("GET /api/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		if h, ok := webhookByID(w, r, db); ok {
+			writeJSON(w, h)
+		}
+	})
+
+	mux.HandleFunc("PUT /api/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		h, ok := webhookByID(w, r, db)
+		if !ok {
+			return
+		}
+		var req webhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", 400)
+			return
+		}
+		secretChanged, ok := req.apply(w, vs, h)
+		if !ok {
+			return
+		}
+		saved, err := db.UpdateWebhook(r.Context(), h)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditWebhookUpdated, Target: fmt.Sprint(saved.ID), Details: saved.URL})
+		if secretChanged {
+			writeJSON(w, WebhookInfo{Webhook: *saved, Secret: saved.Secret})
+			return
+		}
+		writeJSON(w, saved)
+	})
+
+	mux.HandleFunc("DELETE /api/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		h, ok := webhookByID(w, r, db)
+		if !ok {
+			return
+		}
+		if err := db.DeleteWebhook(r.Context(), h.ID); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditWebhookDeleted, Target: fmt.Sprint(h.ID), Details: h.URL})
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+
+	// The delivery log, newest first; ?limit pages it
+	mux.HandleFunc("GET /api/webhooks/{id}/deliveries", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(w, r, db); !ok {
+			return
+		}
+		h, ok := webhookByID(w, r, db)
+		if !ok {
+			return
+		}
+		limit := defaultDeliveryPage
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, "invalid limit", 400)
+				return
+			}
+			limit = max(1, min(n, maxDeliveryPage))
+		}
+		deliveries, err := db.GetWebhookDeliveries(r.Context(), h.ID, limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, deliveries)
+	})
+
+	// Redelivering queues the payload again as a new delivery
+	mux.HandleFunc("POST /api/webhooks/{id}/deliveries/{deliveryID}/redeliver", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireAdmin(w, r, db)
+		if !ok {
+			return
+		}
+		h, ok := webhookByID(w, r, db)
+		if !ok {
+			return
+		}
+		deliveryID, err := strconv.ParseInt(r.PathValue("deliveryID"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid delivery id", 400)
+			return
+		}
+		dl, err := db.GetWebhookDelivery(r.Context(), deliveryID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if dl == nil || dl.WebhookID != h.ID {
+			http.Error(w, "delivery not found", http.StatusNotFound)
+			return
+		}
+		queued, err := db.QueueWebhookDelivery(r.Context(), h.ID, dl.Event, dl.Payload)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditLog(r, db, user, models.AuditEvent{Action: models.AuditWebhookResent, Target: fmt.Sprint(h.ID), Details: fmt.Sprintf("delivery %d as %d", dl.ID, queued.ID)})
+		writeJSON(w, queued)
+	})
+}
@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+	"dragonbytelabs/dz/internal/vault"
+	"dragonbytelabs/dz/internal/webhooks"
+)
+
+func TestWebhooks(t *testing.T) {
+	ts := newTestServer(t, "admin", "user")
+	db, admin, user := ts.db, ts.users["admin"], ts.users["user"]
+	db.SetUserAdmin(t.Context(), admin.ID, true)
+	team, _ := db.CreateTeam(t.Context(), "Docs", nil, admin.ID)
+
+	vs := NewVaults(db, vault.NewManager(t.TempDir()), nil)
+	RegisterApi(ts.mux, vs)
+	RegisterTeams(ts.mux, db)
+	RegisterWebhooks(ts.mux, vs)
+	ts.login()
+	do := ts.do
+	deliveries := func(id int64) []models.WebhookDelivery {
+		var out []models.WebhookDelivery
+		json.NewDecoder(do("admin", "GET", fmt.Sprintf("/api/webhooks/%d/deliveries", id), "").Body).Decode(&out)
+		return out
+	}
+
+	var hook WebhookInfo
+	t.Run("admins manage webhooks", func(t *testing.T) {
+		body := `{"url":"https://ci.example.com/hook","events":["file.write","file.create","team.member_joined"],"paths":["projects/**"]}`
+		if rec := do("user", "POST", "/api/webhooks", body); rec.Code != http.StatusForbidden {
+			t.Errorf("create as user status = %v, want 403", rec.Code)
+		}
+		for _, bad := range []string{
+			`{"url":"ftp://example.com","events":["file.write"]}`,
+			`{"url":"https://example.com"}`,
+			`{"url":"https://example.com","events":["file.explode"]}`,
+			`{"url":"https://example.com","events":["file.write"],"paths":["/abs"]}`,
+			`{"url":"https://example.com","events":["file.write"],"vault":"nowhere"}`,
+		} {
+			if rec := do("admin", "POST", "/api/webhooks", bad); rec.Code != 400 {
+				t.Errorf("create %s status = %v, want 400", bad, rec.Code)
+			}
+		}
+
+		rec := do("admin", "POST", "/api/webhooks", body)
+		json.NewDecoder(rec.Body).Decode(&hook)
+		if rec.Code != http.StatusOK || hook.ID == 0 || len(hook.Secret) != 64 || !hook.IsActive {
+			t.Fatalf("create = %v %+v", rec.Code, hook)
+		}
+		// The secret is only shown when it is set
+		if rec := do("admin", "GET", fmt.Sprintf("/api/webhooks/%d", hook.ID), ""); strings.Contains(rec.Body.String(), hook.Secret) {
+			t.Error("GET returned the secret")
+		}
+		var rotated WebhookInfo
+		json.NewDecoder(do("admin", "PUT", fmt.Sprintf("/api/webhooks/%d", hook.ID), `{"rotate_secret":true}`).Body).Decode(&rotated)
+		if rotated.Secret == "" || rotated.Secret == hook.Secret || len(rotated.Events) != 3 {
+			t.Errorf("rotate = %+v, want a new secret and the rest kept", rotated)
+		}
+	})
+
+	t.Run("vault changes queue deliveries", func(t *testing.T) {
+		do("user", "POST", "/api/file", `{"path":"projects/plan.md","content":"v1"}`)
+		do("user", "PUT", "/api/file?path=projects/plan.md", `{"content":"v2"}`)
+		do("user", "POST", "/api/file", `{"path":"notes/other.md","content":"x"}`)
+
+		got := deliveries(hook.ID)
+		if len(got) != 2 || got[0].Event != models.AuditFileWrite || got[1].Event != models.AuditFileCreate {
+			t.Fatalf("deliveries = %+v, want the write then the create of projects/plan.md", got)
+		}
+		var p webhooks.Payload
+		json.Unmarshal([]byte(got[0].Payload), &p)
+		if p.Path != "projects/plan.md" || p.Vault != fmt.Sprintf("users/%d", user.ID) || p.Actor == nil || p.Actor.ID != user.ID {
+			t.Errorf("payload = %s", got[0].Payload)
+		}
+	})
+
+	t.Run("joining a team queues a delivery", func(t *testing.T) {
+		do("admin", "POST", fmt.Sprintf("/api/teams/%d/invitations", team.ID), `{"email":"user@example.com","role":"viewer"}`)
+		inv, _ := db.GetInvitationsByEmail(t.Context(), "user@example.com")
+		if len(inv) != 1 {
+			t.Fatalf("invitations = %+v", inv)
+		}
+		do("user", "POST", fmt.Sprintf("/api/invitations/%d/accept", inv[0].ID), "")
+		if got := deliveries(hook.ID); len(got) != 3 || got[0].Event != models.AuditMemberJoined {
+			t.Errorf("deliveries = %+v, want the join first", got)
+		}
+	})
+
+	t.Run("redelivers", func(t *testing.T) {
+		first := deliveries(hook.ID)[2]
+		if rec := do("user", "POST", fmt.Sprintf("/api/webhooks/%d/deliveries/%d/redeliver", hook.ID, first.ID), ""); rec.Code != http.StatusForbidden {
+			t.Errorf("redeliver as user status = %v, want 403", rec.Code)
+		}
+		if rec := do("admin", "POST", fmt.Sprintf("/api/webhooks/%d/deliveries/999/redeliver", hook.ID), ""); rec.Code != http.StatusNotFound {
+			t.Errorf("redeliver unknown status = %v, want 404", rec.Code)
+		}
+		var queued models.WebhookDelivery
+		json.NewDecoder(do("admin", "POST", fmt.Sprintf("/api/webhooks/%d/deliveries/%d/redeliver", hook.ID, first.ID), "").Body).Decode(&queued)
+		if queued.ID == first.ID || queued.Payload != first.Payload || queued.Status != models.DeliveryPending {
+			t.Errorf("redeliver = %+v, want a new pending copy of %d", queued, first.ID)
+		}
+		if got := deliveries(hook.ID); len(got) != 4 {
+			t.Errorf("deliveries = %d, want 4", len(got))
+		}
+	})
+
+	t.Run("deleting drops the log", func(t *testing.T) {
+		if rec := do("admin", "DELETE", fmt.Sprintf("/api/webhooks/%d", hook.ID), ""); rec.Code != http.StatusOK {
+			t.Fatalf("delete status = %v", rec.Code)
+		}
+		if rec := do("admin", "GET", fmt.Sprintf("/api/webhooks/%d/deliveries", hook.ID), ""); rec.Code != http.StatusNotFound {
+			t.Errorf("deliveries of deleted webhook status = %v, want 404", rec.Code)
+		}
+		events, _ := db.GetAuditEvents(t.Context(), models.AuditFilter{Action: "webhook"})
+		if len(events) != 4 {
+			t.Errorf("webhook audit events = %d, want 4", len(events))
+		}
+	})
+}
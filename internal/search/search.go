@@ -0,0 +1,156 @@
+// Package search finds the notes of a vault matching a query. A query is a
+// list of terms that must all match:
+//
+//	word "a phrase"    text in the title or body, ignoring case
+//	tag:name           frontmatter or #inline tag; tag:web also matches web/dev
+//	path:folder        notes in folder (or the note at that path)
+//	title:word         text in the title
+//	-term              notes the term does not match
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/markdown"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+// Query is a parsed search query
+type Query struct {
+	raw   string
+	terms []term
+}
+
+type term struct {
+	field  string // "", "tag", "path" or "title"
+	value  string // lower case
+	negate bool
+}
+
+var fields = map[string]bool{"tag": true, "path": true, "title": true}
+
+// Parse parses a query, rejecting empty ones and unclosed quotes
+func Parse(s string) (*Query, error) {
+	q := &Query{raw: strings.TrimSpace(s)}
+	rest := q.raw
+	for {
+		rest = strings.TrimLeft(rest, " \t\n")
+		if rest == "" {
+			break
+		}
+		var t term
+		if rest[0] == '-' {
+			t.negate, rest = true, rest[1:]
+		}
+		if name, after, ok := strings.Cut(rest, ":"); ok && fields[strings.ToLower(name)] && !strings.ContainsAny(name, " \t\n\"") {
+			t.field, rest = strings.ToLower(name), after
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, errors.New("search: unclosed quote")
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, " \t\n")
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		if value = strings.ToLower(strings.TrimSpace(value)); value == "" {
+			return nil, fmt.Errorf("search: empty term in %q", q.raw)
+		}
+		t.value = value
+		q.terms = append(q.terms, t)
+	}
+	if len(q.terms) == 0 {
+		return nil, errors.New("search: empty query")
+	}
+	return q, nil
+}
+
+// String returns the query as written
+func (q *Query) String() string { return q.raw }
+
+// Match reports whether the note at notePath satisfies every term
+func (q *Query) Match(notePath string, note *markdown.Note) bool {
+	title := strings.ToLower(Title(notePath, note))
+	var body string // lowered on first use
+	for _, t := range q.terms {
+		var ok bool
+		switch t.field {
+		case "tag":
+			for _, tag := range note.Tags() {
+				tag = strings.ToLower(tag)
+				if tag == t.value || strings.HasPrefix(tag, t.value+"/") {
+					ok = true
+					break
+				}
+			}
+		case "path":
+			p, prefix := strings.ToLower(notePath), strings.Trim(t.value, "/")
+			ok = p == prefix || strings.HasPrefix(p, prefix+"/")
+		case "title":
+			ok = strings.Contains(title, t.value)
+		default:
+			if body == "" {
+				body = strings.ToLower(string(note.Body))
+			}
+			ok = strings.Contains(title, t.value) || strings.Contains(body, t.value)
+		}
+		if ok == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Title is the frontmatter title of a note, or its file name
+func Title(notePath string, note *markdown.Note) string {
+	if note.Frontmatter.Title != "" {
+		return note.Frontmatter.Title
+	}
+	return strings.TrimSuffix(path.Base(notePath), path.Ext(notePath))
+}
+
+// Result is a note matching a query
+type Result struct {
+	Path  string    `json:"path"`
+	Title string    `json:"title"`
+	MTime time.Time `json:"mtime"`
+}
+
+// Run returns up to limit notes of v matching q that ctx may read, most
+// recently modified first. Notes with broken frontmatter are skipped.
+func Run(ctx context.Context, v *vault.Vault, q *Query, limit int) ([]Result, error) {
+	files, err := v.ListMarkdown(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := []Result{}
+	for _, f := range files {
+		if len(results) >= limit {
+			break
+		}
+		res, err := v.ReadFile(ctx, f.Path)
+		if err != nil {
+			continue
+		}
+		note, err := markdown.Parse([]byte(res.Content))
+		if err != nil {
+			continue
+		}
+		if q.Match(f.Path, note) {
+			results = append(results, Result{Path: f.Path, Title: Title(f.Path, note), MTime: f.MTime})
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,108 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/markdown"
+	"dragonbytelabs/dz/internal/vault"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`  tag:Web -path:archive "Big Plan" title:x  `)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []term{
+		{field: "tag", value: "web"},
+		{field: "path", value: "archive", negate: true},
+		{value: "big plan"},
+		{field: "title", value: "x"},
+	}
+	if len(q.terms) != len(want) {
+		t.Fatalf("terms = %+v", q.terms)
+	}
+	for i, term := range want {
+		if q.terms[i] != term {
+			t.Errorf("term %d = %+v, want %+v", i, q.terms[i], term)
+		}
+	}
+	if q.String() != `tag:Web -path:archive "Big Plan" title:x` {
+		t.Errorf("String() = %q", q.String())
+	}
+	if q, _ := Parse("see:also http://x"); q.terms[0].field != "" || q.terms[1].value != "http://x" {
+		t.Errorf("unknown fields are not plain text: %+v", q.terms)
+	}
+
+	for _, bad := range []string{"", "   ", `"unclosed`, "tag:", "-", `""`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	note, _ := markdown.Parse([]byte("---\ntitle: Release Plan\ntags: [web/dev]\n---\nShip the **new** site. #launch\n"))
+	for query, want := range map[string]bool{
+		"ship site":             true,
+		`"ship the site"`:       false,
+		"release":               true,
+		"title:plan":            true,
+		"title:ship":            false,
+		"tag:web":               true,
+		"tag:web/dev":           true,
+		"tag:we":                false,
+		"tag:LAUNCH":            true,
+		"path:projects":         true,
+		"path:projects/":        true,
+		"path:proj":             false,
+		"path:projects/plan.md": true,
+		"-tag:launch":           false,
+		"ship -archived":        true,
+	} {
+		q, err := Parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.Match("projects/plan.md", note); got != want {
+			t.Errorf("Match(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"old.md":        "draft #todo",
+		"new.md":        "---\ntitle: Fresh\n---\nanother draft #todo",
+		"done.md":       "finished",
+		"broken.md":     "---\ntitle: [\n---\ndraft #todo",
+		"data/todo.txt": "draft #todo",
+	}
+	now := time.Now()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, []byte(content), 0o644)
+	}
+	os.Chtimes(filepath.Join(dir, "old.md"), now.Add(-time.Hour), now.Add(-time.Hour))
+	v, err := vault.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, _ := Parse("tag:todo")
+	results, err := Run(context.Background(), v, q, 10)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Path != "new.md" || results[0].Title != "Fresh" || results[1].Title != "old" {
+		t.Errorf("Run() = %+v, want new.md then old.md", results)
+	}
+	if results, _ := Run(context.Background(), v, q, 1); len(results) != 1 {
+		t.Errorf("Run(limit 1) = %d results", len(results))
+	}
+}
@@ -0,0 +1,105 @@
+package session
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SecureMode controls when the Secure attribute is set on cookies
+type SecureMode string
+
+const (
+	// SecureAuto marks cookies Secure when the request arrived over TLS,
+	// directly or through a proxy setting X-Forwarded-Proto
+	SecureAuto   SecureMode = "auto"
+	SecureAlways SecureMode = "always"
+	SecureNever  SecureMode = "never"
+)
+
+// hostPrefix is the cookie name prefix browsers only accept on Secure,
+// host-only cookies with Path=/
+const hostPrefix = "__Host-"
+
+// CookieOptions are the attributes of the cookies written by the manager
+type CookieOptions struct {
+	Secure   SecureMode
+	SameSite http.SameSite
+	Domain   string
+	// HostPrefix names cookies with the __Host- prefix on secure requests.
+	// The Domain is dropped for those cookies as the prefix requires.
+	HostPrefix bool
+}
+
+// DefaultCookieOptions returns Lax, host-only cookies that are Secure behind TLS
+func DefaultCookieOptions() CookieOptions {
+	return CookieOptions{
+		Secure:   SecureAuto,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// ParseSameSite converts "lax", "strict" or "none" to an http.SameSite,
+// defaulting to Lax
+func ParseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// SetCookieOptions changes the attributes of cookies written from now on
+func (m *SessionManager) SetCookieOptions(opts CookieOptions) {
+	if opts.Secure == "" {
+		opts.Secure = SecureAuto
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	m.cookieOptions = opts
+}
+
+// isSecure reports whether cookies for this request get the Secure attribute
+func (o CookieOptions) isSecure(r *http.Request) bool {
+	switch o.Secure {
+	case SecureAlways:
+		return true
+	case SecureNever:
+		return false
+	}
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// name returns the cookie name to use for this request
+func (o CookieOptions) name(base string, r *http.Request) string {
+	if o.HostPrefix && o.isSecure(r) {
+		return hostPrefix + base
+	}
+	return base
+}
+
+// cookie builds a cookie carrying the configured attributes
+func (o CookieOptions) cookie(r *http.Request, base, value string, maxAge int, httpOnly bool) *http.Cookie {
+	secure := o.isSecure(r)
+	c := &http.Cookie{
+		Name:     o.name(base, r),
+		Value:    value,
+		Path:     "/",
+		Domain:   o.Domain,
+		Secure:   secure,
+		HttpOnly: httpOnly,
+		SameSite: o.SameSite,
+		MaxAge:   maxAge,
+	}
+	if o.HostPrefix && secure {
+		c.Domain = ""
+	}
+	// Browsers reject SameSite=None cookies that are not Secure
+	if c.SameSite == http.SameSiteNoneMode && !secure {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	return c
+}
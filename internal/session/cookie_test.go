@@ -0,0 +1,98 @@
+package session
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieOptions(t *testing.T) {
+	plain := httptest.NewRequest("GET", "/", nil)
+	tlsReq := httptest.NewRequest("GET", "/", nil)
+	tlsReq.TLS = &tls.ConnectionState{}
+	proxied := httptest.NewRequest("GET", "/", nil)
+	proxied.Header.Set("X-Forwarded-Proto", "https")
+
+	t.Run("auto follows the request scheme", func(t *testing.T) {
+		o := DefaultCookieOptions()
+		if o.isSecure(plain) {
+			t.Error("isSecure(plain) = true, want false")
+		}
+		if !o.isSecure(tlsReq) {
+			t.Error("isSecure(tls) = false, want true")
+		}
+		if !o.isSecure(proxied) {
+			t.Error("isSecure(proxied) = false, want true")
+		}
+	})
+
+	t.Run("always and never override the scheme", func(t *testing.T) {
+		if !(CookieOptions{Secure: SecureAlways}).isSecure(plain) {
+			t.Error("SecureAlways isSecure(plain) = false, want true")
+		}
+		if (CookieOptions{Secure: SecureNever}).isSecure(tlsReq) {
+			t.Error("SecureNever isSecure(tls) = true, want false")
+		}
+	})
+
+	t.Run("host prefix applies only to secure requests", func(t *testing.T) {
+		o := CookieOptions{Secure: SecureAuto, SameSite: http.SameSiteStrictMode, Domain: "example.com", HostPrefix: true}
+
+		c := o.cookie(tlsReq, "sid", "v", 60, true)
+		if c.Name != "__Host-sid" || c.Domain != "" || !c.Secure || c.Path != "/" {
+			t.Errorf("cookie() = %+v, want secure host-only __Host-sid", c)
+		}
+
+		c = o.cookie(plain, "sid", "v", 60, true)
+		if c.Name != "sid" || c.Domain != "example.com" || c.Secure {
+			t.Errorf("cookie() = %+v, want plain sid on example.com", c)
+		}
+	})
+
+	t.Run("SameSite=None falls back to Lax without Secure", func(t *testing.T) {
+		o := CookieOptions{Secure: SecureAuto, SameSite: http.SameSiteNoneMode}
+		if c := o.cookie(plain, "sid", "v", 60, true); c.SameSite != http.SameSiteLaxMode {
+			t.Errorf("cookie().SameSite = %v, want Lax", c.SameSite)
+		}
+		if c := o.cookie(tlsReq, "sid", "v", 60, true); c.SameSite != http.SameSiteNoneMode {
+			t.Errorf("cookie().SameSite = %v, want None", c.SameSite)
+		}
+	})
+}
+
+func TestParseSameSite(t *testing.T) {
+	tests := map[string]http.SameSite{
+		"strict": http.SameSiteStrictMode,
+		"None":   http.SameSiteNoneMode,
+		"lax":    http.SameSiteLaxMode,
+		"":       http.SameSiteLaxMode,
+	}
+	for in, want := range tests {
+		if got := ParseSameSite(in); got != want {
+			t.Errorf("ParseSameSite(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSessionManager_CookieAttributes(t *testing.T) {
+	sm := NewSessionManager(NewInMemoryStore(), time.Hour, time.Hour, 12*time.Hour, "session_id")
+	sm.SetCookieOptions(CookieOptions{HostPrefix: true})
+	handler := sm.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	header := rec.Header().Get("Set-Cookie")
+	for _, want := range []string{"__Host-session_id=", "Secure", "HttpOnly", "SameSite=Lax", "Path=/"} {
+		if !strings.Contains(header, want) {
+			t.Errorf("Set-Cookie = %q, want it to contain %q", header, want)
+		}
+	}
+}
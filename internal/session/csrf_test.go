@@ -0,0 +1,97 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCSRF(t *testing.T) {
+	sm := NewSessionManager(NewInMemoryStore(), time.Hour, time.Hour, 12*time.Hour, "session_id")
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		sess := GetSession(r)
+		sm.Migrate(sess)
+		sess.Put("user_id", "u1")
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/change", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("changed"))
+	})
+	mux.HandleFunc("POST /public/form", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("submitted"))
+	})
+	handler := sm.Handle(CSRF(mux, "/public/"))
+
+	do := func(method, path string, cookies []*http.Cookie, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Anonymous requests are not checked, so the login itself goes through
+	login := do("POST", "/login", nil, nil)
+	if login.Code != http.StatusOK {
+		t.Fatalf("login status = %d, want 200", login.Code)
+	}
+	var cookies []*http.Cookie
+	var token string
+	for _, c := range login.Result().Cookies() {
+		if c.Name == CSRFCookie {
+			token = c.Value
+			if c.HttpOnly {
+				t.Error("csrf cookie is HttpOnly, want readable by scripts")
+			}
+		}
+		cookies = append(cookies, c)
+	}
+	if token == "" {
+		t.Fatal("login did not set a csrf cookie")
+	}
+
+	t.Run("safe methods pass", func(t *testing.T) {
+		if rec := do("GET", "/change", cookies, nil); rec.Code != http.StatusOK {
+			t.Errorf("GET status = %d, want 200", rec.Code)
+		}
+	})
+
+	t.Run("mutation without token is rejected", func(t *testing.T) {
+		if rec := do("POST", "/change", cookies, nil); rec.Code != http.StatusForbidden {
+			t.Errorf("POST status = %d, want 403", rec.Code)
+		}
+	})
+
+	t.Run("mutation with wrong token is rejected", func(t *testing.T) {
+		rec := do("DELETE", "/change", cookies, map[string]string{CSRFHeader: "nope"})
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("DELETE status = %d, want 403", rec.Code)
+		}
+	})
+
+	t.Run("mutation with token passes", func(t *testing.T) {
+		rec := do("PUT", "/change", cookies, map[string]string{CSRFHeader: token})
+		if rec.Code != http.StatusOK {
+			t.Errorf("PUT status = %d, want 200", rec.Code)
+		}
+	})
+
+	t.Run("an authorization header doesn't skip the check", func(t *testing.T) {
+		rec := do("POST", "/change", cookies, map[string]string{"Authorization": "Bearer abc"})
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("POST status = %d, want 403", rec.Code)
+		}
+	})
+	t.Run("public paths skip the check", func(t *testing.T) {
+		if rec := do("POST", "/public/form", cookies, nil); rec.Code != http.StatusOK {
+			t.Errorf("POST public status = %d, want 200", rec.Code)
+		}
+	})
+}
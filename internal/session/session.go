@@ -3,9 +3,11 @@ package session
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -19,8 +21,12 @@ type Session struct {
 	mu             sync.RWMutex
 	id             string
 	data           map[string]any
+	userAgent      string
+	ip             string
 	createdAt      time.Time
 	lastActivityAt time.Time
+	// dirty is set when the session has changes that are not yet stored
+	dirty bool
 }
 
 func newSession() *Session {
@@ -29,6 +35,7 @@ func newSession() *Session {
 		data:           make(map[string]any),
 		createdAt:      time.Now(),
 		lastActivityAt: time.Now(),
+		dirty:          true,
 	}
 }
 
@@ -53,13 +60,71 @@ func (s *Session) Put(key string, value any) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.data[key] = value
+	s.dirty = true
 }
 
 // Delete removes a value from the session
 func (s *Session) Delete(key string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	delete(s.data, key)
+	if _, ok := s.data[key]; ok {
+		delete(s.data, key)
+		s.dirty = true
+	}
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// UserID returns the hash of the signed-in user, or "" for anonymous sessions
+func (s *Session) UserID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	userID, _ := s.data["user_id"].(string)
+	return userID
+}
+
+// UserAgent returns the user agent that last used the session
+func (s *Session) UserAgent() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.userAgent
+}
+
+// IP returns the client IP that last used the session
+func (s *Session) IP() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ip
+}
+
+// CreatedAt returns when the session was created
+func (s *Session) CreatedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.createdAt
+}
+
+// LastActivityAt returns when the session was last used
+func (s *Session) LastActivityAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastActivityAt
+}
+
+// Handle returns a stable public identifier for the session. Unlike the ID
+// it is safe to show to clients, since it cannot be used as a cookie.
+func (s *Session) Handle() string {
+	return handleFor(s.ID())
+}
+
+func handleFor(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
 }
 
 // SessionManager manages all sessions
@@ -68,6 +133,7 @@ type SessionManager struct {
 	idleExpiration     time.Duration
 	absoluteExpiration time.Duration
 	cookieName         string
+	cookieOptions      CookieOptions
 }
 
 // NewSessionManager creates a new session manager
@@ -83,6 +149,7 @@ func NewSessionManager(
 		idleExpiration:     idleExpiration,
 		absoluteExpiration: absoluteExpiration,
 		cookieName:         cookieName,
+		cookieOptions:      DefaultCookieOptions(),
 	}
 
 	go m.gc(gcInterval)
@@ -113,7 +180,7 @@ func (m *SessionManager) start(r *http.Request) (*Session, *http.Request) {
 	var session *Session
 
 	// Try to read from cookie
-	cookie, err := r.Cookie(m.cookieName)
+	cookie, err := r.Cookie(m.cookieOptions.name(m.cookieName, r))
 	if err == nil {
 		session, err = m.store.Read(cookie.Value)
 		if err != nil {
@@ -126,6 +193,12 @@ func (m *SessionManager) start(r *http.Request) (*Session, *http.Request) {
 		session = newSession()
 	}
 
+	// Remember where the session was last used
+	session.mu.Lock()
+	session.userAgent = r.UserAgent()
+	session.ip = clientIP(r)
+	session.mu.Unlock()
+
 	// Attach to context
 	ctx := context.WithValue(r.Context(), sessionContextKey, session)
 	r = r.WithContext(ctx)
@@ -134,8 +207,28 @@ func (m *SessionManager) start(r *http.Request) (*Session, *http.Request) {
 }
 
 func (m *SessionManager) save(session *Session) error {
+	session.mu.Lock()
 	session.lastActivityAt = time.Now()
-	return m.store.Write(session)
+	session.mu.Unlock()
+
+	if err := m.store.Write(session); err != nil {
+		return err
+	}
+
+	session.mu.Lock()
+	session.dirty = false
+	session.mu.Unlock()
+	return nil
+}
+
+// needsSave reports whether the session must be written after a request.
+// Unchanged sessions are only written periodically to record activity.
+func (m *SessionManager) needsSave(session *Session) bool {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+
+	touchInterval := min(time.Minute, m.idleExpiration/2)
+	return session.dirty || time.Since(session.lastActivityAt) >= touchInterval
 }
 
 // Migrate generates a new session ID (call on login/logout)
@@ -148,9 +241,67 @@ func (m *SessionManager) Migrate(session *Session) error {
 	}
 
 	session.id = generateSessionID()
+	// A new identity gets a new anti-forgery token as well
+	session.data[csrfKey] = generateSessionID()
+	session.dirty = true
 	return nil
 }
 
+// ListUserSessions returns the live sessions belonging to a user
+func (m *SessionManager) ListUserSessions(userID string) ([]*Session, error) {
+	if userID == "" {
+		return nil, nil
+	}
+	sessions, err := m.store.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make([]*Session, 0, len(sessions))
+	for _, s := range sessions {
+		if time.Since(s.createdAt) > m.absoluteExpiration ||
+			time.Since(s.lastActivityAt) > m.idleExpiration {
+			continue
+		}
+		live = append(live, s)
+	}
+	return live, nil
+}
+
+// RevokeSession destroys the user's session with the given handle.
+// It reports false if the user has no such session.
+func (m *SessionManager) RevokeSession(userID, handle string) (bool, error) {
+	sessions, err := m.ListUserSessions(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range sessions {
+		if s.Handle() == handle {
+			return true, m.store.Destroy(s.ID())
+		}
+	}
+	return false, nil
+}
+
+// RevokeOtherSessions signs a user out everywhere except the session exceptID
+func (m *SessionManager) RevokeOtherSessions(userID, exceptID string) (int, error) {
+	sessions, err := m.store.ListByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, s := range sessions {
+		if s.ID() == exceptID {
+			continue
+		}
+		if err := m.store.Destroy(s.ID()); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
 // GetSession retrieves the session from request context
 func GetSession(r *http.Request) *Session {
 	session, ok := r.Context().Value(sessionContextKey).(*Session)
@@ -170,6 +321,15 @@ func GetSessionSafe(r *http.Request) *Session {
 	return session
 }
 
+// clientIP returns the remote address of the request without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // Custom response writer to ensure cookie is written
 type sessionResponseWriter struct {
 	http.ResponseWriter
@@ -193,19 +353,30 @@ func (w *sessionResponseWriter) writeCookieIfNecessary() {
 		return
 	}
 
+	m := w.sessionManager
 	session := GetSession(w.request)
-
-	cookie := &http.Cookie{
-		Name:     w.sessionManager.cookieName,
-		Value:    session.id,
-		HttpOnly: true,
-		Path:     "/",
-		Secure:   false, // Set to true in production with HTTPS
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(w.sessionManager.idleExpiration / time.Second),
+	maxAge := int(m.idleExpiration / time.Second)
+
+	value := session.ID()
+	if enc, ok := m.store.(CookieEncoder); ok {
+		session.mu.Lock()
+		session.lastActivityAt = time.Now()
+		session.mu.Unlock()
+
+		var err error
+		if value, err = enc.Encode(session); err != nil {
+			log.Printf("SessionManager: Failed to encode session cookie: %v", err)
+			value = ""
+		}
+	}
+	if value != "" {
+		http.SetCookie(w.ResponseWriter, m.cookieOptions.cookie(w.request, m.cookieName, value, maxAge, true))
 	}
 
-	http.SetCookie(w.ResponseWriter, cookie)
+	// The CSRF cookie is readable by scripts so the UI can echo it in a header
+	if token := session.csrfToken(); token != "" {
+		http.SetCookie(w.ResponseWriter, m.cookieOptions.cookie(w.request, CSRFCookie, token, maxAge, false))
+	}
 	w.done = true
 }
 
@@ -229,10 +400,9 @@ func (m *SessionManager) Handle(next http.Handler) http.Handler {
 		// Call next handler
 		next.ServeHTTP(sw, r)
 
-		// Make sure session is written after request
-		if session != nil {
-			log.Printf("SessionManager: Writing session after request, id=%s", session.id)
-			if err := m.store.Write(session); err != nil {
+		// Write the session after the request if it changed
+		if session != nil && m.needsSave(session) {
+			if err := m.save(session); err != nil {
 				log.Printf("SessionManager: Failed to write session: %v", err)
 			}
 		}
@@ -1,6 +1,8 @@
 package session
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -191,3 +193,114 @@ func TestSessionManager_Migrate(t *testing.T) {
 		t.Error("Migrate() did not destroy original session")
 	}
 }
+
+func TestSessionManager_Revoke(t *testing.T) {
+	store := NewInMemoryStore()
+	m := NewSessionManager(store, time.Hour, time.Hour, 12*time.Hour, "session_id")
+
+	sessions := make([]*Session, 3)
+	for i := range sessions {
+		sessions[i] = newSession()
+		sessions[i].data["user_id"] = "user-a"
+		store.Write(sessions[i])
+	}
+	other := newSession()
+	other.data["user_id"] = "user-b"
+	store.Write(other)
+
+	t.Run("lists the user's sessions", func(t *testing.T) {
+		list, err := m.ListUserSessions("user-a")
+		if err != nil {
+			t.Fatalf("ListUserSessions() returned error: %v", err)
+		}
+		if len(list) != 3 {
+			t.Errorf("ListUserSessions() returned %d sessions, want 3", len(list))
+		}
+	})
+
+	t.Run("cannot revoke another user's session", func(t *testing.T) {
+		found, err := m.RevokeSession("user-a", other.Handle())
+		if err != nil {
+			t.Fatalf("RevokeSession() returned error: %v", err)
+		}
+		if found {
+			t.Error("RevokeSession() revoked a session belonging to another user")
+		}
+	})
+
+	t.Run("revokes by handle", func(t *testing.T) {
+		found, err := m.RevokeSession("user-a", sessions[0].Handle())
+		if err != nil || !found {
+			t.Fatalf("RevokeSession() = %v, %v", found, err)
+		}
+		if s, _ := store.Read(sessions[0].id); s != nil {
+			t.Error("RevokeSession() did not destroy the session")
+		}
+	})
+
+	t.Run("revokes all other sessions", func(t *testing.T) {
+		revoked, err := m.RevokeOtherSessions("user-a", sessions[1].id)
+		if err != nil {
+			t.Fatalf("RevokeOtherSessions() returned error: %v", err)
+		}
+		if revoked != 1 {
+			t.Errorf("RevokeOtherSessions() revoked %d, want 1", revoked)
+		}
+		if s, _ := store.Read(sessions[1].id); s == nil {
+			t.Error("RevokeOtherSessions() destroyed the kept session")
+		}
+		if s, _ := store.Read(other.id); s == nil {
+			t.Error("RevokeOtherSessions() destroyed another user's session")
+		}
+	})
+}
+
+// countingStore records how often sessions are written
+type countingStore struct {
+	*InMemoryStore
+	writes int
+}
+
+func (s *countingStore) Write(session *Session) error {
+	s.writes++
+	return s.InMemoryStore.Write(session)
+}
+
+func TestSessionManager_SkipsUnchangedWrites(t *testing.T) {
+	store := &countingStore{InMemoryStore: NewInMemoryStore()}
+	sm := NewSessionManager(store, time.Hour, time.Hour, 12*time.Hour, "session_id")
+
+	var put bool
+	handler := sm.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if put {
+			GetSession(r).Put("key", "value")
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	do := func(cookie *http.Cookie) *http.Cookie {
+		req := httptest.NewRequest("GET", "/", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Result().Cookies()[0]
+	}
+
+	cookie := do(nil)
+	if store.writes != 1 {
+		t.Fatalf("writes after new session = %d, want 1", store.writes)
+	}
+
+	do(cookie)
+	if store.writes != 1 {
+		t.Errorf("writes after unchanged request = %d, want 1", store.writes)
+	}
+
+	put = true
+	do(cookie)
+	if store.writes != 2 {
+		t.Errorf("writes after change = %d, want 2", store.writes)
+	}
+}
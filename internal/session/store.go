@@ -10,4 +10,6 @@ type SessionStore interface {
 	Write(session *Session) error
 	Destroy(id string) error
 	GC(idleExpiration, absoluteExpiration time.Duration) error
+	// ListByUser returns every stored session belonging to a user
+	ListByUser(userID string) ([]*Session, error)
 }
@@ -0,0 +1,225 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	cookieVersion = 1
+	kidSize       = 4
+	nonceSize     = 12
+	macSize       = sha256.Size
+
+	// MaxCookieSize is the largest encoded session the store will emit;
+	// browsers drop cookies over roughly 4KB
+	MaxCookieSize = 4000
+
+	minSecretLength = 32
+)
+
+var (
+	ErrCookieTooLarge = errors.New("session: encoded session exceeds cookie size limit")
+	ErrInvalidCookie  = errors.New("session: invalid session cookie")
+	ErrCookieExpired  = errors.New("session: session cookie has expired")
+)
+
+// CookieEncoder is implemented by stores that keep the session in the
+// cookie itself; the manager writes the encoded value instead of the ID.
+type CookieEncoder interface {
+	Encode(session *Session) (string, error)
+}
+
+type cookieKey struct {
+	id     [kidSize]byte
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+// CookieStore keeps sessions in an AES-GCM encrypted, HMAC-authenticated
+// cookie so no server-side writes are needed. The first secret encrypts new
+// cookies; all of them are accepted, which allows rotating secrets.
+//
+// Because nothing is stored on the server, sessions cannot be listed or
+// revoked before they expire, and changes made after the response headers
+// are written are lost.
+type CookieStore struct {
+	keys   []cookieKey
+	maxAge time.Duration
+}
+
+type cookiePayload struct {
+	ID             string         `json:"id"`
+	Data           map[string]any `json:"d"`
+	CreatedAt      int64          `json:"c"`
+	LastActivityAt int64          `json:"l"`
+	ExpiresAt      int64          `json:"e"`
+}
+
+// NewCookieStore creates a cookie store from one or more secrets, newest
+// first. Cookies are rejected once they are older than maxAge.
+func NewCookieStore(secrets []string, maxAge time.Duration) (*CookieStore, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("session: cookie store needs at least one secret")
+	}
+
+	s := &CookieStore{maxAge: maxAge}
+	for _, secret := range secrets {
+		if len(secret) < minSecretLength {
+			return nil, errors.New("session: secrets must be at least 32 characters")
+		}
+		key, err := deriveCookieKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = append(s.keys, key)
+	}
+	return s, nil
+}
+
+// deriveCookieKey derives separate encryption and MAC keys from a secret
+func deriveCookieKey(secret string) (cookieKey, error) {
+	derive := func(label string) []byte {
+		h := hmac.New(sha256.New, []byte(secret))
+		h.Write([]byte(label))
+		return h.Sum(nil)
+	}
+
+	block, err := aes.NewCipher(derive("dz session encryption"))
+	if err != nil {
+		return cookieKey{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return cookieKey{}, err
+	}
+
+	key := cookieKey{aead: aead, macKey: derive("dz session authentication")}
+	copy(key.id[:], derive("dz session key id"))
+	return key, nil
+}
+
+// Encode seals the session into a cookie value with the newest key
+func (s *CookieStore) Encode(session *Session) (string, error) {
+	session.mu.RLock()
+	payload := cookiePayload{
+		ID:             session.id,
+		Data:           session.data,
+		CreatedAt:      session.createdAt.Unix(),
+		LastActivityAt: session.lastActivityAt.Unix(),
+		ExpiresAt:      time.Now().Add(s.maxAge).Unix(),
+	}
+	plaintext, err := json.Marshal(payload)
+	session.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	key := s.keys[0]
+	header := make([]byte, 0, 1+kidSize+nonceSize)
+	header = append(header, cookieVersion)
+	header = append(header, key.id[:]...)
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	header = append(header, nonce...)
+
+	sealed := key.aead.Seal(header, nonce, plaintext, header)
+	mac := hmac.New(sha256.New, key.macKey)
+	mac.Write(sealed)
+	sealed = mac.Sum(sealed)
+
+	value := base64.RawURLEncoding.EncodeToString(sealed)
+	if len(value) > MaxCookieSize {
+		return "", ErrCookieTooLarge
+	}
+	return value, nil
+}
+
+// Decode authenticates and decrypts a cookie value
+func (s *CookieStore) Decode(value string) (*Session, error) {
+	if len(value) > MaxCookieSize {
+		return nil, ErrCookieTooLarge
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) < 1+kidSize+nonceSize+macSize || raw[0] != cookieVersion {
+		return nil, ErrInvalidCookie
+	}
+
+	key, ok := s.key(raw[1 : 1+kidSize])
+	if !ok {
+		return nil, ErrInvalidCookie
+	}
+
+	body, sum := raw[:len(raw)-macSize], raw[len(raw)-macSize:]
+	mac := hmac.New(sha256.New, key.macKey)
+	mac.Write(body)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, ErrInvalidCookie
+	}
+
+	header := body[:1+kidSize+nonceSize]
+	nonce := header[1+kidSize:]
+	plaintext, err := key.aead.Open(nil, nonce, body[len(header):], header)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+
+	var payload cookiePayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, ErrInvalidCookie
+	}
+	if time.Now().Unix() > payload.ExpiresAt {
+		return nil, ErrCookieExpired
+	}
+	if payload.Data == nil {
+		payload.Data = make(map[string]any)
+	}
+
+	return &Session{
+		id:             payload.ID,
+		data:           payload.Data,
+		createdAt:      time.Unix(payload.CreatedAt, 0),
+		lastActivityAt: time.Unix(payload.LastActivityAt, 0),
+	}, nil
+}
+
+func (s *CookieStore) key(id []byte) (cookieKey, bool) {
+	for _, k := range s.keys {
+		if hmac.Equal(k.id[:], id) {
+			return k, true
+		}
+	}
+	return cookieKey{}, false
+}
+
+// Read decodes the cookie value; invalid or expired cookies start a new session
+func (s *CookieStore) Read(value string) (*Session, error) {
+	session, err := s.Decode(value)
+	if err != nil {
+		log.Printf("CookieStore: discarding session cookie: %v", err)
+		return nil, nil
+	}
+	return session, nil
+}
+
+// Write is a no-op; the session is written to the response cookie
+func (s *CookieStore) Write(session *Session) error { return nil }
+
+// Destroy is a no-op; a cookie stays valid until it expires or is replaced
+func (s *CookieStore) Destroy(id string) error { return nil }
+
+// GC is a no-op; expired cookies are rejected when they are read
+func (s *CookieStore) GC(idleExpiration, absoluteExpiration time.Duration) error { return nil }
+
+// ListByUser returns nothing, since sessions are not kept on the server
+func (s *CookieStore) ListByUser(userID string) ([]*Session, error) { return nil, nil }
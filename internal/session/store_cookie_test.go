@@ -0,0 +1,144 @@
+package session
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret    = "0123456789abcdef0123456789abcdef"
+	testSecretOld = "fedcba9876543210fedcba9876543210"
+)
+
+func TestNewCookieStore(t *testing.T) {
+	if _, err := NewCookieStore(nil, time.Hour); err == nil {
+		t.Error("NewCookieStore(nil) should fail")
+	}
+	if _, err := NewCookieStore([]string{"short"}, time.Hour); err == nil {
+		t.Error("NewCookieStore() should reject short secrets")
+	}
+	if _, err := NewCookieStore([]string{testSecret}, time.Hour); err != nil {
+		t.Errorf("NewCookieStore() returned error: %v", err)
+	}
+}
+
+func TestCookieStore_RoundTrip(t *testing.T) {
+	store, _ := NewCookieStore([]string{testSecret}, time.Hour)
+
+	session := newSession()
+	session.data["user_id"] = "abc"
+
+	value, err := store.Encode(session)
+	if err != nil {
+		t.Fatalf("Encode() returned error: %v", err)
+	}
+	if strings.Contains(value, "abc") {
+		t.Error("Encode() leaked session data in plaintext")
+	}
+
+	s, err := store.Decode(value)
+	if err != nil {
+		t.Fatalf("Decode() returned error: %v", err)
+	}
+	if s.id != session.id {
+		t.Errorf("Decode() id = %v, want %v", s.id, session.id)
+	}
+	if s.UserID() != "abc" {
+		t.Errorf("Decode() user_id = %v, want abc", s.UserID())
+	}
+	if s.createdAt.Unix() != session.createdAt.Unix() {
+		t.Errorf("Decode() createdAt = %v, want %v", s.createdAt, session.createdAt)
+	}
+}
+
+func TestCookieStore_RejectsTampering(t *testing.T) {
+	store, _ := NewCookieStore([]string{testSecret}, time.Hour)
+	value, _ := store.Encode(newSession())
+
+	raw, _ := base64.RawURLEncoding.DecodeString(value)
+	raw[len(raw)/2] ^= 0xff
+	tampered := base64.RawURLEncoding.EncodeToString(raw)
+
+	if _, err := store.Decode(tampered); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("Decode(tampered) error = %v, want %v", err, ErrInvalidCookie)
+	}
+	if _, err := store.Decode("not a cookie"); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("Decode(garbage) error = %v, want %v", err, ErrInvalidCookie)
+	}
+	if s, err := store.Read(tampered); s != nil || err != nil {
+		t.Errorf("Read(tampered) = %v, %v, want nil, nil", s, err)
+	}
+}
+
+func TestCookieStore_KeyRotation(t *testing.T) {
+	old, _ := NewCookieStore([]string{testSecretOld}, time.Hour)
+	value, _ := old.Encode(newSession())
+
+	rotated, _ := NewCookieStore([]string{testSecret, testSecretOld}, time.Hour)
+	if _, err := rotated.Decode(value); err != nil {
+		t.Errorf("Decode() with rotated keys returned error: %v", err)
+	}
+
+	retired, _ := NewCookieStore([]string{testSecret}, time.Hour)
+	if _, err := retired.Decode(value); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("Decode() with retired key error = %v, want %v", err, ErrInvalidCookie)
+	}
+}
+
+func TestCookieStore_Expiry(t *testing.T) {
+	store, _ := NewCookieStore([]string{testSecret}, -time.Minute)
+	value, _ := store.Encode(newSession())
+
+	if _, err := store.Decode(value); !errors.Is(err, ErrCookieExpired) {
+		t.Errorf("Decode() error = %v, want %v", err, ErrCookieExpired)
+	}
+}
+
+func TestCookieStore_SizeLimit(t *testing.T) {
+	store, _ := NewCookieStore([]string{testSecret}, time.Hour)
+
+	session := newSession()
+	session.data["blob"] = strings.Repeat("x", MaxCookieSize)
+	if _, err := store.Encode(session); !errors.Is(err, ErrCookieTooLarge) {
+		t.Errorf("Encode() error = %v, want %v", err, ErrCookieTooLarge)
+	}
+	if _, err := store.Decode(strings.Repeat("x", MaxCookieSize+1)); !errors.Is(err, ErrCookieTooLarge) {
+		t.Errorf("Decode() error = %v, want %v", err, ErrCookieTooLarge)
+	}
+}
+
+func TestCookieStore_WithManager(t *testing.T) {
+	store, _ := NewCookieStore([]string{testSecret}, time.Hour)
+	sm := NewSessionManager(store, time.Hour, time.Hour, 12*time.Hour, "session_id")
+
+	handler := sm.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess := GetSession(r)
+		n, _ := sess.Get("n").(float64)
+		sess.Put("n", n+1)
+		w.Write([]byte("ok"))
+	}))
+
+	var cookie *http.Cookie
+	for i := 1; i <= 2; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		cookie = rec.Result().Cookies()[0]
+		s, err := store.Decode(cookie.Value)
+		if err != nil {
+			t.Fatalf("request %d: Decode() returned error: %v", i, err)
+		}
+		if got := s.Get("n"); got != float64(i) {
+			t.Errorf("request %d: n = %v, want %d", i, got, i)
+		}
+	}
+}
@@ -59,3 +59,16 @@ func (s *InMemoryStore) GC(idleExpiration, absoluteExpiration time.Duration) err
 
 	return nil
 }
+
+func (s *InMemoryStore) ListByUser(userID string) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sessions []*Session
+	for _, session := range s.sessions {
+		if session.UserID() == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
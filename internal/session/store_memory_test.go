@@ -127,3 +127,25 @@ func TestNewInMemoryStore(t *testing.T) {
 		t.Error("NewInMemoryStore() created store with nil sessions map")
 	}
 }
+
+func TestInMemoryStore_ListByUser(t *testing.T) {
+	store := NewInMemoryStore()
+
+	mine := newSession()
+	mine.data["user_id"] = "user-a"
+	store.Write(mine)
+
+	theirs := newSession()
+	theirs.data["user_id"] = "user-b"
+	store.Write(theirs)
+
+	store.Write(newSession()) // anonymous
+
+	sessions, err := store.ListByUser("user-a")
+	if err != nil {
+		t.Fatalf("ListByUser() returned error: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].id != mine.id {
+		t.Errorf("ListByUser() = %v, want only %s", sessions, mine.id)
+	}
+}
@@ -3,10 +3,10 @@ package session
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"time"
 
 	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
 )
 
 // SQLiteStore stores sessions in SQLite database
@@ -30,6 +30,11 @@ func (s *SQLiteStore) Read(id string) (*Session, error) {
 		return nil, nil // Session not found
 	}
 
+	return fromModel(dbSession)
+}
+
+// fromModel converts a stored dbx session into a Session
+func fromModel(dbSession *models.Session) (*Session, error) {
 	// Deserialize session data
 	var data map[string]any
 	if err := json.Unmarshal([]byte(dbSession.Data), &data); err != nil {
@@ -40,6 +45,8 @@ func (s *SQLiteStore) Read(id string) (*Session, error) {
 	session := &Session{
 		id:             dbSession.ID,
 		data:           data,
+		userAgent:      dbSession.UserAgent,
+		ip:             dbSession.IP,
 		createdAt:      dbSession.CreatedAt,
 		lastActivityAt: dbSession.LastActivityAt,
 	}
@@ -55,11 +62,11 @@ func (s *SQLiteStore) Write(session *Session) error {
 	createdAt := session.createdAt
 	lastActivityAt := session.lastActivityAt
 	id := session.id
+	meta := models.SessionMeta{UserAgent: session.userAgent, IP: session.ip}
+	meta.UserID, _ = data["user_id"].(string)
 	session.mu.RUnlock()
 
-	log.Printf("SQLiteStore.Write: id=%s, data=%+v", id, data)
-
-	return s.db.CreateSession(ctx, id, data, createdAt, lastActivityAt)
+	return s.db.CreateSession(ctx, id, data, meta, createdAt, lastActivityAt)
 }
 
 func (s *SQLiteStore) Destroy(id string) error {
@@ -75,3 +82,22 @@ func (s *SQLiteStore) GC(idleExpiration, absoluteExpiration time.Duration) error
 
 	return s.db.CleanExpiredSessions(ctx, idleThreshold, absoluteThreshold)
 }
+
+func (s *SQLiteStore) ListByUser(userID string) ([]*Session, error) {
+	ctx := context.Background()
+
+	dbSessions, err := s.db.GetSessionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(dbSessions))
+	for i := range dbSessions {
+		session, err := fromModel(&dbSessions[i])
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
@@ -180,3 +180,30 @@ func TestSQLiteStore_GC(t *testing.T) {
 		t.Error("GC() did not remove absolute-expired session")
 	}
 }
+
+func TestSQLiteStore_ListByUser(t *testing.T) {
+	db := dbx.SetupTestDB(t)
+	defer db.Close()
+
+	store := NewSQLiteStore(db)
+
+	session := newSession()
+	session.data["user_id"] = "user-a"
+	session.userAgent = "Firefox"
+	session.ip = "192.0.2.10"
+	if err := store.Write(session); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	store.Write(newSession())
+
+	sessions, err := store.ListByUser("user-a")
+	if err != nil {
+		t.Fatalf("ListByUser() returned error: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("ListByUser() returned %d sessions, want 1", len(sessions))
+	}
+	if sessions[0].id != session.id || sessions[0].userAgent != "Firefox" || sessions[0].ip != "192.0.2.10" {
+		t.Errorf("ListByUser()[0] = %+v", sessions[0])
+	}
+}
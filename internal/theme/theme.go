@@ -0,0 +1,270 @@
+// Package theme loads the html/template themes published sites render with.
+//
+// A theme is a directory:
+//
+//	index.html       home page (required)
+//	note.html        one published note
+//	tags.html        the tag index
+//	tag.html         the notes carrying one tag
+//	layouts/*.html   shared layouts, e.g. {{define "base"}}...{{end}}
+//	partials/*.html  shared snippets, e.g. {{define "header"}}...{{end}}
+//	static/...       files copied to the site's static/ folder
+//
+// Each page is parsed together with every layout and partial, so a page
+// can fill in blocks of a layout:
+//
+//	{{template "base" .}}
+//	{{define "content"}}<h1>{{.Page.Title}}</h1>{{.Page.Content}}{{end}}
+//
+// Pages a theme leaves out are rendered by the built-in theme, and static
+// files it lacks are taken from the built-in theme too.
+//
+// Every page executes with the same data:
+//
+//	.Root            relative path from the page to the site root, e.g. "../../";
+//	                 prefix every site URL with it: {{.Root}}static/style.css
+//	.Site.Title      site title
+//	.Site.URL        public URL of the site root, if configured
+//	.Site.Pages      published notes, by title
+//	.Site.Posts      published posts, newest first; they render with note.html
+//	.Site.Tags       tags, by name
+//	.Page            the note or post (note.html only):
+//	  .Title         frontmatter title or file name
+//	  .URL           site-relative URL, e.g. "notes/ideas/plan.html"
+//	  .Path          vault path, e.g. "ideas/plan.md"; empty for posts
+//	  .Summary       frontmatter excerpt or description, else the first paragraph
+//	  .Content       rendered HTML
+//	  .Tags          tags, each with .Name, .URL and .Pages
+//	  .Backlinks     published notes linking here, by title
+//	  .Frontmatter   .ID, .Title, .Created, .Updated, .Tags, .Aliases and
+//	                 any other key under .Extra, e.g. {{.Page.Frontmatter.Extra.author}}
+//	  .Modified      last change of the file, a time.Time
+//	  .Published     frontmatter created, else .Updated
+//	  .Updated       frontmatter updated, else .Modified
+//	.Tag             the tag (tag.html only): .Name, .URL and .Pages
+//
+// Feeds live at feed.xml (RSS), atom.xml and feed.json in the site root,
+// in tags/<name>/ and in each folder under notes/.
+//
+// Besides the standard template functions, themes may use dict to pass
+// several values to a partial: {{template "list" (dict "Pages" .Site.Pages "Root" .Root)}}.
+package theme
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Pages a theme can provide
+const (
+	PageIndex = "index"
+	PageNote  = "note"
+	PageTags  = "tags"
+	PageTag   = "tag"
+)
+
+// DefaultName is the name of the built-in theme
+const DefaultName = "default"
+
+var pages = []string{PageIndex, PageNote, PageTags, PageTag}
+
+// StaticDir holds a theme's static files, in the theme and on the site
+const StaticDir = "static"
+
+//go:embed default
+var defaultFS embed.FS
+
+// Theme is a parsed theme
+type Theme struct {
+	Name     string
+	fsys     fs.FS
+	pages    map[string]*template.Template
+	fallback *Theme
+}
+
+// funcs are available to every theme template
+var funcs = template.FuncMap{
+	"dict": dict,
+}
+
+var builtin = func() *Theme {
+	fsys, err := fs.Sub(defaultFS, DefaultName)
+	if err != nil {
+		panic(err)
+	}
+	t, err := load(DefaultName, fsys, nil)
+	if err != nil {
+		panic(fmt.Sprintf("theme: built-in theme: %v", err))
+	}
+	return t
+}()
+
+// Default returns the built-in theme
+func Default() *Theme { return builtin }
+
+// Load parses the theme in fsys, reporting templates that fail to parse
+func Load(name string, fsys fs.FS) (*Theme, error) {
+	return load(name, fsys, builtin)
+}
+
+// Open loads the theme called name from the themes directory. An empty
+// name or DefaultName returns the built-in theme.
+func Open(themesPath, name string) (*Theme, error) {
+	if name == "" || name == DefaultName {
+		return builtin, nil
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("theme: invalid name %q", name)
+	}
+	dir := filepath.Join(themesPath, name)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("theme: %q is not installed", name)
+	}
+	return Load(name, os.DirFS(dir))
+}
+
+// List returns the names of the themes installed in themesPath, plus the
+// built-in theme
+func List(themesPath string) ([]string, error) {
+	names := []string{DefaultName}
+	entries, err := os.ReadDir(themesPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") && e.Name() != DefaultName {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names[1:])
+	return names, nil
+}
+
+func load(name string, fsys fs.FS, fallback *Theme) (*Theme, error) {
+	if _, err := fs.Stat(fsys, PageIndex+".html"); err != nil {
+		return nil, fmt.Errorf("theme %s: missing index.html", name)
+	}
+
+	var shared []string
+	for _, pattern := range []string{"layouts/*.html", "partials/*.html"} {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		shared = append(shared, matches...)
+	}
+
+	t := &Theme{Name: name, fsys: fsys, pages: map[string]*template.Template{}, fallback: fallback}
+	for _, page := range pages {
+		file := page + ".html"
+		if _, err := fs.Stat(fsys, file); err != nil {
+			if fallback == nil {
+				return nil, fmt.Errorf("theme %s: missing %s", name, file)
+			}
+			continue
+		}
+		tmpl := template.New(file).Funcs(funcs)
+		for _, f := range shared {
+			b, err := fs.ReadFile(fsys, f)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := tmpl.New(f).Parse(string(b)); err != nil {
+				return nil, fmt.Errorf("theme %s: %w", name, err)
+			}
+		}
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.Parse(string(b)); err != nil {
+			return nil, fmt.Errorf("theme %s: %w", name, err)
+		}
+		// html/template escapes on first use, so try it now to surface
+		// escaping errors; errors from the missing data are expected
+		var escErr *template.Error
+		if err := tmpl.Execute(io.Discard, nil); errors.As(err, &escErr) {
+			return nil, fmt.Errorf("theme %s: %w", name, err)
+		}
+		t.pages[page] = tmpl
+	}
+	return t, nil
+}
+
+// Render executes one of the theme's pages
+func (t *Theme) Render(w io.Writer, page string, data any) error {
+	tmpl, ok := t.pages[page]
+	if !ok {
+		if t.fallback == nil {
+			return fmt.Errorf("theme %s: no %s page", t.Name, page)
+		}
+		return t.fallback.Render(w, page, data)
+	}
+	return tmpl.Execute(w, data)
+}
+
+// StaticFiles lists the theme's static files, including those inherited
+// from the built-in theme, relative to the static folder
+func (t *Theme) StaticFiles() ([]string, error) {
+	seen := map[string]bool{}
+	for th := t; th != nil; th = th.fallback {
+		err := fs.WalkDir(th.fsys, StaticDir, func(p string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && p == StaticDir {
+				return fs.SkipDir
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+			seen[strings.TrimPrefix(p, StaticDir+"/")] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files := make([]string, 0, len(seen))
+	for f := range seen {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// StaticFile reads a file below the static folder, falling back to the
+// built-in theme
+func (t *Theme) StaticFile(name string) ([]byte, error) {
+	name = path.Clean("/" + name)[1:]
+	for th := t; th != nil; th = th.fallback {
+		b, err := fs.ReadFile(th.fsys, path.Join(StaticDir, name))
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return b, err
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// dict builds a map from alternating keys and values
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict: odd number of arguments")
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
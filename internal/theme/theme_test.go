@@ -0,0 +1,85 @@
+package theme
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("renders pages through layouts and partials", func(t *testing.T) {
+		th, err := Load("custom", fstest.MapFS{
+			"index.html":        {Data: []byte(`{{template "base" .}}{{define "body"}}{{template "hi" .}}{{end}}`)},
+			"layouts/base.html": {Data: []byte(`{{define "base"}}<main>{{block "body" .}}{{end}}</main>{{end}}`)},
+			"partials/hi.html":  {Data: []byte(`{{define "hi"}}Hi {{.}}{{end}}`)},
+			"static/custom.css": {Data: []byte("main {}")},
+			"static/style.css":  {Data: []byte("/* mine */")},
+		})
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		var buf bytes.Buffer
+		if err := th.Render(&buf, PageIndex, "<you>"); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != "<main>Hi &lt;you&gt;</main>" {
+			t.Errorf("Render() = %q", buf.String())
+		}
+
+		// Pages and static files the theme lacks come from the built-in theme
+		buf.Reset()
+		if err := th.Render(&buf, PageTags, nil); err != nil {
+			t.Errorf("Render(tags) error = %v", err)
+		}
+		files, _ := th.StaticFiles()
+		if strings.Join(files, ",") != "custom.css,style.css" {
+			t.Errorf("StaticFiles() = %v", files)
+		}
+		if b, _ := th.StaticFile("style.css"); string(b) != "/* mine */" {
+			t.Errorf("StaticFile(style.css) = %q, want the theme's own", b)
+		}
+		if _, err := th.StaticFile("../index.html"); err == nil {
+			t.Error("StaticFile() read outside the static folder")
+		}
+	})
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing index":  {"note.html": {Data: []byte("note")}},
+		"parse error":    {"index.html": {Data: []byte("{{.Site.Title")}},
+		"broken partial": {"index.html": {Data: []byte("ok")}, "partials/x.html": {Data: []byte("{{end}}")}},
+		"escape error":   {"index.html": {Data: []byte(`<a href="{{if .}}x">{{end}}`)}},
+		"unknown func":   {"index.html": {Data: []byte("{{nope}}")}},
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			if _, err := Load("bad", fsys); err == nil {
+				t.Error("Load() succeeded")
+			}
+		})
+	}
+}
+
+func TestOpenAndList(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "plain"), 0o755)
+	os.WriteFile(filepath.Join(dir, "plain", "index.html"), []byte("plain"), 0o644)
+	os.MkdirAll(filepath.Join(dir, ".hidden"), 0o755)
+
+	names, err := List(dir)
+	if err != nil || strings.Join(names, ",") != "default,plain" {
+		t.Errorf("List() = %v, %v", names, err)
+	}
+	if th, err := Open(dir, ""); err != nil || th != Default() {
+		t.Errorf("Open(\"\") = %v, %v; want the built-in theme", th, err)
+	}
+	if th, err := Open(dir, "plain"); err != nil || th.Name != "plain" {
+		t.Errorf("Open(plain) = %v, %v", th, err)
+	}
+	for _, name := range []string{"missing", "../plain", ".hidden"} {
+		if _, err := Open(dir, name); err == nil {
+			t.Errorf("Open(%q) succeeded", name)
+		}
+	}
+}
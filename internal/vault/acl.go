@@ -0,0 +1,242 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// ErrForbidden is returned when the caller's role doesn't allow an operation
+var ErrForbidden = errors.New("forbidden")
+
+// MetaDir holds vault metadata; it is hidden from listings and only
+// admins may touch it through the regular file operations
+const MetaDir = ".deez"
+
+const aclFile = MetaDir + "/acl.json"
+
+// Rule restricts a folder prefix, e.g. "clients/", to members holding at
+// least the given roles. The longest matching prefix wins.
+type Rule struct {
+	Prefix string `json:"prefix"`
+	Read   string `json:"read"`
+	Write  string `json:"write"`
+}
+
+// ACL is the set of path rules of a vault
+type ACL struct {
+	Rules []Rule `json:"rules"`
+}
+
+// defaultRule applies where no rule matches: anyone may read, editors write
+var defaultRule = Rule{Read: models.RoleViewer, Write: models.RoleEditor}
+
+// metaRule protects the vault metadata folder
+var metaRule = Rule{Prefix: MetaDir + "/", Read: models.RoleAdmin, Write: models.RoleAdmin}
+
+type accessKey struct{}
+
+// WithRole scopes vault operations made with ctx to a member holding role.
+// Operations on a context without a role are trusted and unrestricted.
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, accessKey{}, role)
+}
+
+// RoleFrom returns the role ctx is scoped to, if any
+func RoleFrom(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(accessKey{}).(string)
+	return role, ok
+}
+
+// Validate checks that every rule names a prefix and known roles
+func (a *ACL) Validate() error {
+	for _, r := range a.Rules {
+		if normalizePrefix(r.Prefix) == "" {
+			return errors.New("acl: rule prefix required")
+		}
+		if !models.ValidRole(r.Read) || !models.ValidRole(r.Write) {
+			return errors.New("acl: invalid role in rule for " + r.Prefix)
+		}
+	}
+	return nil
+}
+
+// match returns the rule governing a vault-relative path
+func (a *ACL) match(rel string) Rule {
+	rel = normalizePath(rel)
+	if within(rel, metaRule.Prefix) {
+		return metaRule
+	}
+
+	best := defaultRule
+	for _, r := range a.Rules {
+		prefix := normalizePrefix(r.Prefix)
+		if within(rel, prefix) && len(prefix) > len(best.Prefix) {
+			best = r
+			best.Prefix = prefix
+		}
+	}
+	return best
+}
+
+// nested returns the rules for folders strictly inside dir
+func (a *ACL) nested(dir string) []Rule {
+	dir = normalizePrefix(dir)
+	out := []Rule{}
+	for _, r := range append(a.Rules, metaRule) {
+		prefix := normalizePrefix(r.Prefix)
+		if len(prefix) > len(dir) && within(prefix, dir) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// within reports whether the normalized path rel is the folder prefix or
+// inside it. Whole segments are compared, ignoring case as the file systems
+// of Windows and macOS do, so "Private/a.md" is within "private/" but
+// "private2/a.md" isn't.
+func within(rel, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	segs := strings.Split(strings.TrimSuffix(rel, "/"), "/")
+	want := strings.Split(strings.TrimSuffix(prefix, "/"), "/")
+	if len(segs) < len(want) {
+		return false
+	}
+	for i, w := range want {
+		if !strings.EqualFold(segs[i], w) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizePath(rel string) string {
+	p := path.Clean("/" + filepath.ToSlash(rel))
+	return strings.TrimPrefix(p, "/")
+}
+
+// normalizePrefix turns "clients", "/clients/" etc. into "clients/"
+func normalizePrefix(prefix string) string {
+	p := normalizePath(prefix)
+	if p == "" {
+		return ""
+	}
+	return p + "/"
+}
+
+func (v *Vault) acl() *ACL {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.rules
+}
+
+// canRead reports whether ctx may read rel
+func (v *Vault) canRead(ctx context.Context, rel string) bool {
+	role, ok := RoleFrom(ctx)
+	return !ok || models.RoleAtLeast(role, v.acl().match(rel).Read)
+}
+
+// checkRead returns ErrForbidden unless ctx may read rel
+func (v *Vault) checkRead(ctx context.Context, rel string) error {
+	if !v.canRead(ctx, rel) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CheckWrite returns ErrForbidden unless ctx may modify the file rel, for
+// callers that act on it later, with another context
+func (v *Vault) CheckWrite(ctx context.Context, rel string) error {
+	return v.checkWrite(ctx, rel, false)
+}
+
+// checkWrite returns ErrForbidden unless ctx may modify rel. With tree set,
+// rel is a folder and every protected folder inside it must be writable too.
+func (v *Vault) checkWrite(ctx context.Context, rel string, tree bool) error {
+	role, ok := RoleFrom(ctx)
+	if !ok {
+		return nil
+	}
+	acl := v.acl()
+	if !models.RoleAtLeast(role, acl.match(rel).Write) {
+		return ErrForbidden
+	}
+	if tree {
+		for _, r := range acl.nested(rel) {
+			if !models.RoleAtLeast(role, r.Write) {
+				return ErrForbidden
+			}
+		}
+	}
+	return nil
+}
+
+// ACL returns a copy of the vault's rules
+func (v *Vault) ACL() ACL {
+	acl := v.acl()
+	return ACL{Rules: append([]Rule(nil), acl.Rules...)}
+}
+
+// SetACL validates and saves new rules. Only admins may change them.
+func (v *Vault) SetACL(ctx context.Context, acl ACL) error {
+	if role, ok := RoleFrom(ctx); ok && !models.RoleAtLeast(role, models.RoleAdmin) {
+		return ErrForbidden
+	}
+	if err := acl.Validate(); err != nil {
+		return err
+	}
+	for i := range acl.Rules {
+		acl.Rules[i].Prefix = normalizePrefix(acl.Rules[i].Prefix)
+	}
+	sort.Slice(acl.Rules, func(i, j int) bool { return acl.Rules[i].Prefix < acl.Rules[j].Prefix })
+
+	b, err := json.MarshalIndent(acl, "", "  ")
+	if err != nil {
+		return err
+	}
+	abs := filepath.Join(v.root, filepath.FromSlash(aclFile))
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(abs, b, 0o644); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.rules = &acl
+	v.mu.Unlock()
+	return nil
+}
+
+// loadACL reads the vault's rules, treating a missing file as no rules
+func (v *Vault) loadACL() error {
+	acl := &ACL{}
+	b, err := os.ReadFile(filepath.Join(v.root, filepath.FromSlash(aclFile)))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(b, acl); err != nil {
+			return err
+		}
+		if err := acl.Validate(); err != nil {
+			return err
+		}
+	}
+
+	v.mu.Lock()
+	v.rules = acl
+	v.mu.Unlock()
+	return nil
+}
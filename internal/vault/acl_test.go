@@ -0,0 +1,161 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+func newTestVault(t *testing.T) *Vault {
+	t.Helper()
+	v, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	ctx := context.Background()
+	for _, p := range []string{"readme.md", "clients/acme.md", "clients/archive/old.md"} {
+		if _, err := v.WriteFile(ctx, p, WriteRequest{Content: "# " + p}); err != nil {
+			t.Fatalf("WriteFile(%s) returned error: %v", p, err)
+		}
+	}
+	if err := v.SetACL(ctx, ACL{Rules: []Rule{
+		{Prefix: "clients", Read: models.RoleEditor, Write: models.RoleEditor},
+		{Prefix: "clients/archive/", Read: models.RoleEditor, Write: models.RoleAdmin},
+	}}); err != nil {
+		t.Fatalf("SetACL() returned error: %v", err)
+	}
+	return v
+}
+
+func TestACL_Match(t *testing.T) {
+	acl := &ACL{Rules: []Rule{
+		{Prefix: "clients/", Read: models.RoleEditor, Write: models.RoleEditor},
+		{Prefix: "clients/archive/", Read: models.RoleEditor, Write: models.RoleAdmin},
+		{Prefix: "private", Read: models.RoleAdmin, Write: models.RoleOwner},
+	}}
+	tests := []struct {
+		path, write string
+	}{
+		{"notes/a.md", models.RoleEditor},
+		{"clients", models.RoleEditor},
+		{"clients/a.md", models.RoleEditor},
+		{"clients/archive/a.md", models.RoleAdmin},
+		{"clientsx/a.md", models.RoleEditor},
+		{".deez/acl.json", models.RoleAdmin},
+		{"private/a.md", models.RoleOwner},
+		// whole segments only
+		{"private2/a.md", models.RoleEditor},
+		{"privatenotes.md", models.RoleEditor},
+		// case-insensitive file systems put these in the same folders
+		{"Private/a.md", models.RoleOwner},
+		{"PRIVATE", models.RoleOwner},
+		{"Clients/Archive/a.md", models.RoleAdmin},
+		{".DEEZ/acl.json", models.RoleAdmin},
+	}
+	for _, tt := range tests {
+		if got := acl.match(tt.path).Write; got != tt.write {
+			t.Errorf("match(%q).Write = %v, want %v", tt.path, got, tt.write)
+		}
+	}
+}
+
+func TestVault_ACLEnforcement(t *testing.T) {
+	v := newTestVault(t)
+	viewer := WithRole(context.Background(), models.RoleViewer)
+	editor := WithRole(context.Background(), models.RoleEditor)
+	admin := WithRole(context.Background(), models.RoleAdmin)
+
+	t.Run("listing hides unreadable folders", func(t *testing.T) {
+		files, _ := v.ListMarkdown(viewer)
+		if len(files) != 1 || files[0].Path != "readme.md" {
+			t.Errorf("ListMarkdown(viewer) = %v, want only readme.md", files)
+		}
+		entries, _ := v.ListEntries(viewer)
+		if len(entries) != 1 {
+			t.Errorf("ListEntries(viewer) returned %d entries, want 1", len(entries))
+		}
+		files, _ = v.ListMarkdown(editor)
+		if len(files) != 3 {
+			t.Errorf("ListMarkdown(editor) returned %d files, want 3", len(files))
+		}
+	})
+
+	t.Run("reads and writes are checked", func(t *testing.T) {
+		if _, err := v.ReadFile(viewer, "clients/acme.md"); !errors.Is(err, ErrForbidden) {
+			t.Errorf("ReadFile(viewer) error = %v, want %v", err, ErrForbidden)
+		}
+		if _, err := v.WriteFile(viewer, "readme.md", WriteRequest{Content: "x"}); !errors.Is(err, ErrForbidden) {
+			t.Errorf("WriteFile(viewer) error = %v, want %v", err, ErrForbidden)
+		}
+		if _, err := v.WriteFile(editor, "clients/acme.md", WriteRequest{Content: "x"}); err != nil {
+			t.Errorf("WriteFile(editor) returned error: %v", err)
+		}
+		if _, err := v.ReadFile(editor, ".deez/acl.json"); !errors.Is(err, ErrForbidden) {
+			t.Errorf("ReadFile(editor, .deez) error = %v, want %v", err, ErrForbidden)
+		}
+	})
+
+	t.Run("refusals don't reveal whether files exist", func(t *testing.T) {
+		for _, p := range []string{"clients/acme.md", "clients/missing.md"} {
+			if err := v.DeleteFile(viewer, p); !errors.Is(err, ErrForbidden) {
+				t.Errorf("DeleteFile(viewer, %s) error = %v, want %v", p, err, ErrForbidden)
+			}
+			if _, err := v.Exists(viewer, p); !errors.Is(err, ErrForbidden) {
+				t.Errorf("Exists(viewer, %s) error = %v, want %v", p, err, ErrForbidden)
+			}
+		}
+		for _, p := range []string{"clients", "customers"} {
+			if err := v.DeleteFolder(viewer, p); !errors.Is(err, ErrForbidden) {
+				t.Errorf("DeleteFolder(viewer, %s) error = %v, want %v", p, err, ErrForbidden)
+			}
+		}
+	})
+
+	t.Run("writers who can't read see that files exist", func(t *testing.T) {
+		drop := newTestVault(t)
+		drop.SetACL(admin, ACL{Rules: []Rule{{Prefix: "drop/", Read: models.RoleAdmin, Write: models.RoleEditor}}})
+		drop.WriteFile(admin, "drop/report.md", WriteRequest{Content: "x"})
+		if exists, err := drop.Exists(editor, "drop/report.md"); err != nil || !exists {
+			t.Errorf("Exists(editor) = %v, %v, want true", exists, err)
+		}
+		if exists, err := drop.Exists(editor, "drop/other.md"); err != nil || exists {
+			t.Errorf("Exists(editor) of a missing file = %v, %v, want false", exists, err)
+		}
+	})
+
+	t.Run("folder operations check protected subfolders", func(t *testing.T) {
+		if err := v.DeleteFolder(editor, "clients"); !errors.Is(err, ErrForbidden) {
+			t.Errorf("DeleteFolder(editor) error = %v, want %v", err, ErrForbidden)
+		}
+		if err := v.RenameFile(editor, "clients", "customers"); !errors.Is(err, ErrForbidden) {
+			t.Errorf("RenameFile(editor) error = %v, want %v", err, ErrForbidden)
+		}
+		if err := v.RenameFile(editor, "readme.md", "clients/archive/readme.md"); !errors.Is(err, ErrForbidden) {
+			t.Errorf("RenameFile(editor) into archive error = %v, want %v", err, ErrForbidden)
+		}
+		if err := v.DeleteFolder(admin, "clients/archive"); err != nil {
+			t.Errorf("DeleteFolder(admin) returned error: %v", err)
+		}
+	})
+
+	t.Run("only admins change rules", func(t *testing.T) {
+		if err := v.SetACL(editor, ACL{}); !errors.Is(err, ErrForbidden) {
+			t.Errorf("SetACL(editor) error = %v, want %v", err, ErrForbidden)
+		}
+		if err := v.SetACL(admin, ACL{Rules: []Rule{{Prefix: "x", Read: "root", Write: "root"}}}); err == nil {
+			t.Error("SetACL() accepted an invalid role")
+		}
+	})
+
+	t.Run("rules survive reopening", func(t *testing.T) {
+		reopened, err := New(v.Root())
+		if err != nil {
+			t.Fatalf("New() returned error: %v", err)
+		}
+		if got := len(reopened.ACL().Rules); got != 2 {
+			t.Errorf("reopened ACL has %d rules, want 2", got)
+		}
+	})
+}
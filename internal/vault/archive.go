@@ -0,0 +1,422 @@
+package vault
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveIndexFile is the entry of an exported vault listing its files
+const archiveIndexFile = MetaDir + "/files.json"
+
+// ErrBadArchive is returned by Import for archives it refuses as a whole:
+// those without a manifest, with entries escaping the vault or with files
+// that don't match their hashes
+var ErrBadArchive = errors.New("invalid vault archive")
+
+// ArchiveIndex lists the files of an exported vault with their hashes. It
+// is written as .deez/files.json after the files have streamed past, so it
+// is the last entry of the zip.
+type ArchiveIndex struct {
+	Vault    string        `json:"vault"` // manifest id of the exported vault
+	Exported time.Time     `json:"exported"`
+	Files    []ArchiveFile `json:"files"`
+}
+
+// ArchiveFile is a file of an exported vault
+type ArchiveFile struct {
+	Path  string    `json:"path"`
+	Size  int64     `json:"size"`
+	Hash  string    `json:"sha256"`
+	MTime time.Time `json:"mtime"`
+}
+
+// Export streams the vault as a zip to w: .deez/manifest.json, every file
+// ctx may read, and .deez/files.json with their hashes. Files are read one
+// at a time, so the vault's size doesn't matter. Encrypted vaults are
+// exported decrypted, with a manifest that says so.
+func (v *Vault) Export(ctx context.Context, w io.Writer) error {
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return err
+	}
+	files, err := v.ListFiles(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	m := v.Manifest()
+	if k != nil {
+		m.Encryption = &Encryption{Algorithm: None, KeyDerivation: None}
+	}
+	zw := zip.NewWriter(w)
+	if err := writeJSONEntry(zw, manifestFile, m); err != nil {
+		return err
+	}
+
+	index := ArchiveIndex{Vault: m.ID, Exported: time.Now().UTC(), Files: []ArchiveFile{}}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		af, err := v.exportFile(zw, k, f)
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", f.Path, err)
+		}
+		index.Files = append(index.Files, *af)
+	}
+	if err := writeJSONEntry(zw, archiveIndexFile, index); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (v *Vault) exportFile(zw *zip.Writer, k *Key, f FileInfo) (*ArchiveFile, error) {
+	abs, err := v.locate(k, f.Path)
+	if err != nil {
+		return nil, err
+	}
+	var src io.Reader
+	if k != nil {
+		b, err := readStored(k, abs)
+		if err != nil {
+			return nil, err
+		}
+		src = bytes.NewReader(b)
+	} else {
+		file, err := os.Open(abs)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		src = file
+	}
+
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: f.Path, Method: zip.Deflate, Modified: f.MTime})
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(dst, h), src)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveFile{Path: f.Path, Size: n, Hash: hex.EncodeToString(h.Sum(nil)), MTime: f.MTime}, nil
+}
+
+func writeJSONEntry(zw *zip.Writer, name string, value any) error {
+	b, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(b)
+	return err
+}
+
+// Import policies for files that exist with other content
+const (
+	ImportSkip      = "skip"      // keep the vault's file
+	ImportOverwrite = "overwrite" // replace it with the archive's
+	ImportMerge     = "merge"     // keep both, the archive's as "name (imported).ext"
+)
+
+// ValidImportPolicy reports whether p is an import policy
+func ValidImportPolicy(p string) bool {
+	return p == ImportSkip || p == ImportOverwrite || p == ImportMerge
+}
+
+// What Import did with a file
+const (
+	ImportCreated     = "created"
+	ImportUnchanged   = "unchanged" // the vault has the same content
+	ImportSkipped     = "skipped"
+	ImportOverwritten = "overwritten"
+	ImportMerged      = "merged"
+	ImportFailed      = "failed"
+)
+
+// ImportOptions configures Import
+type ImportOptions struct {
+	Policy string // defaults to ImportSkip
+	DryRun bool   // report what would happen without writing
+}
+
+// ImportedFile is what Import did, or would do, with a file of the archive
+type ImportedFile struct {
+	Path      string `json:"path"`
+	Action    string `json:"action"`
+	WrittenTo string `json:"written_to,omitempty"` // merges only
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportResult reports an import. The vault keeps its own manifest; the
+// archive's is returned for reference.
+type ImportResult struct {
+	Manifest *Manifest      `json:"manifest"`
+	Verified bool           `json:"verified"` // the archive listed hashes and every file matched
+	DryRun   bool           `json:"dry_run"`
+	Summary  map[string]int `json:"summary"` // files per action
+	Files    []ImportedFile `json:"files"`
+}
+
+// Limits on what Import unpacks, by the sizes the entries declare. Reading
+// an entry fails once it goes past its declared size.
+const (
+	maxImportFileSize  = 64 << 20
+	maxImportTotalSize = 2 << 30
+)
+
+// importEntry is a file of the archive to import
+type importEntry struct {
+	file *zip.File
+	path string
+	hash string
+}
+
+// Import writes the files of a zip made by Export, or by the editor, into
+// the vault with the role in ctx. The archive is checked as a whole before
+// anything is written: it must carry a supported manifest, every entry must
+// resolve inside the vault and, when it lists hashes, every file must match
+// them, and it must not unpack to more than maxImportFileSize per entry or
+// maxImportTotalSize in all. Entries in hidden folders, .deez included,
+// aren't imported.
+func (v *Vault) Import(ctx context.Context, zr *zip.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.Policy == "" {
+		opts.Policy = ImportSkip
+	}
+	if !ValidImportPolicy(opts.Policy) {
+		return nil, fmt.Errorf("unknown import policy %q", opts.Policy)
+	}
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest *Manifest
+	var index *ArchiveIndex
+	var entries []importEntry
+	var total uint64
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > maxImportFileSize {
+			return nil, fmt.Errorf("%w: entry %q is larger than %d MiB", ErrBadArchive, f.Name, maxImportFileSize>>20)
+		}
+		if total += f.UncompressedSize64; total > maxImportTotalSize {
+			return nil, fmt.Errorf("%w: archive unpacks to more than %d MiB", ErrBadArchive, maxImportTotalSize>>20)
+		}
+		switch name := strings.TrimPrefix(f.Name, "./"); {
+		case name == manifestFile:
+			if err := readJSONEntry(f, &manifest); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrBadArchive, manifestFile, err)
+			}
+		case name == archiveIndexFile:
+			if err := readJSONEntry(f, &index); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrBadArchive, archiveIndexFile, err)
+			}
+		case strings.HasSuffix(name, "/") || f.Mode().IsDir():
+			// folders are created with their files
+		default:
+			if strings.Contains(name, `\`) {
+				return nil, fmt.Errorf("%w: entry %q has a backslash in its path", ErrBadArchive, f.Name)
+			}
+			if _, err := v.resolve(name); err != nil {
+				return nil, fmt.Errorf("%w: entry %q: %v", ErrBadArchive, f.Name, err)
+			}
+			if !f.Mode().IsRegular() {
+				return nil, fmt.Errorf("%w: entry %q is not a regular file", ErrBadArchive, f.Name)
+			}
+			if p := path.Clean(name); !inHiddenFolder(p) {
+				entries = append(entries, importEntry{file: f, path: p})
+			}
+		}
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: missing %s", ErrBadArchive, manifestFile)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrBadArchive, manifestFile, err)
+	}
+
+	// Hash every file, checking them against the index if there is one
+	for i := range entries {
+		h, err := hashEntry(entries[i].file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrBadArchive, entries[i].path, err)
+		}
+		entries[i].hash = h
+	}
+	if index != nil {
+		if err := verifyIndex(index, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &ImportResult{Manifest: manifest, Verified: index != nil, DryRun: opts.DryRun, Summary: map[string]int{}, Files: []ImportedFile{}}
+	taken := map[string]bool{} // paths merges have claimed
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		out := v.importFile(ctx, k, e, opts, taken)
+		res.Summary[out.Action]++
+		res.Files = append(res.Files, out)
+	}
+	return res, nil
+}
+
+func (v *Vault) importFile(ctx context.Context, k *Key, e importEntry, opts ImportOptions, taken map[string]bool) ImportedFile {
+	out := ImportedFile{Path: e.path}
+	fail := func(err error) ImportedFile {
+		out.Action, out.Error = ImportFailed, err.Error()
+		return out
+	}
+
+	// Before anything is read, so that refusals don't reveal whether a
+	// protected file exists or what it holds
+	if !v.canRead(ctx, e.path) || v.checkWrite(ctx, e.path, false) != nil {
+		return fail(ErrForbidden)
+	}
+	abs, err := v.locate(k, e.path)
+	if err != nil {
+		return fail(err)
+	}
+	dest := e.path
+	switch cur, err := readStored(k, abs); {
+	case errors.Is(err, os.ErrNotExist):
+		out.Action = ImportCreated
+	case err != nil:
+		return fail(err)
+	case sha256Hex(cur) == e.hash:
+		out.Action = ImportUnchanged
+		return out
+	case opts.Policy == ImportSkip:
+		out.Action = ImportSkipped
+		return out
+	case opts.Policy == ImportOverwrite:
+		out.Action = ImportOverwritten
+	default:
+		out.Action = ImportMerged
+		dest = v.importedName(k, e.path, taken)
+		out.WrittenTo = dest
+	}
+
+	if err := v.checkWrite(ctx, dest, false); err != nil {
+		return fail(err)
+	}
+	if opts.DryRun {
+		return out
+	}
+	rc, err := e.file.Open()
+	if err != nil {
+		return fail(err)
+	}
+	content, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := v.WriteFile(ctx, dest, WriteRequest{Content: string(content)}); err != nil {
+		return fail(err)
+	}
+	return out
+}
+
+// importedName returns a free path next to p for the archive's copy of it,
+// e.g. "notes/plan (imported).md", then "notes/plan (imported 2).md"
+func (v *Vault) importedName(k *Key, p string, taken map[string]bool) string {
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for i := 1; ; i++ {
+		suffix := " (imported)"
+		if i > 1 {
+			suffix = fmt.Sprintf(" (imported %d)", i)
+		}
+		candidate := base + suffix + ext
+		abs, err := v.locate(k, candidate)
+		if err != nil {
+			// the write fails the same way and is reported
+			return candidate
+		}
+		if _, err := os.Stat(abs); errors.Is(err, os.ErrNotExist) && !taken[candidate] {
+			taken[candidate] = true
+			return candidate
+		}
+	}
+}
+
+// verifyIndex checks that the archive's files are exactly those its index
+// lists, with the listed hashes
+func verifyIndex(index *ArchiveIndex, entries []importEntry) error {
+	listed := make(map[string]string, len(index.Files))
+	for _, f := range index.Files {
+		listed[path.Clean(f.Path)] = f.Hash
+	}
+	for _, e := range entries {
+		want, ok := listed[e.path]
+		if !ok {
+			return fmt.Errorf("%w: %s is not listed in %s", ErrBadArchive, e.path, archiveIndexFile)
+		}
+		if want != e.hash {
+			return fmt.Errorf("%w: %s doesn't match its sha256", ErrBadArchive, e.path)
+		}
+		delete(listed, e.path)
+	}
+	for p := range listed {
+		if !inHiddenFolder(p) {
+			return fmt.Errorf("%w: %s is listed but missing", ErrBadArchive, p)
+		}
+	}
+	return nil
+}
+
+func readJSONEntry(f *zip.File, value any) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(value)
+}
+
+func hashEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// inHiddenFolder reports whether the vault path p is inside a folder
+// listings skip, such as .deez or .git
+func inHiddenFolder(p string) bool {
+	dir := path.Dir(p)
+	if dir == "." {
+		return false
+	}
+	for _, seg := range strings.Split(dir, "/") {
+		if strings.HasPrefix(seg, ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,287 @@
+package vault
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// buildZip returns a zip of files, in order of names
+func buildZip(t *testing.T, names []string, files map[string]string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	zw.Close()
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func exportZip(t *testing.T, v *Vault) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := v.Export(context.Background(), &buf); err != nil {
+		t.Fatalf("Export() returned error: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("export is not a zip: %v", err)
+	}
+	return zr
+}
+
+const editorManifest = `{"version":"1.0.0","id":"vault-1","name":"Notes","created":"2024-05-01T10:00:00.000Z","updated":"2024-05-01T10:00:00.000Z"}`
+
+func TestExport(t *testing.T) {
+	v := newTestVault(t)
+	zr := exportZip(t, v)
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	want := ".deez/manifest.json,clients/acme.md,clients/archive/old.md,readme.md,.deez/files.json"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("entries = %s, want %s", got, want)
+	}
+
+	var index ArchiveIndex
+	readJSONEntry(zr.File[len(zr.File)-1], &index)
+	if index.Vault != v.Manifest().ID || len(index.Files) != 3 || index.Files[2].Hash != sha256Hex([]byte("# readme.md")) {
+		t.Errorf("index = %+v", index)
+	}
+
+	t.Run("leaves out what ctx can't read", func(t *testing.T) {
+		var buf bytes.Buffer
+		v.Export(WithRole(context.Background(), models.RoleViewer), &buf)
+		if strings.Contains(buf.String(), "clients/") {
+			t.Error("viewer export contains protected files")
+		}
+	})
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	src := newTestVault(t)
+	archive := exportZip(t, src)
+
+	t.Run("round trips", func(t *testing.T) {
+		dst, _ := New(t.TempDir())
+		res, err := dst.Import(ctx, archive, ImportOptions{})
+		if err != nil {
+			t.Fatalf("Import() returned error: %v", err)
+		}
+		if !res.Verified || res.Summary[ImportCreated] != 3 || res.Manifest.ID != src.Manifest().ID {
+			t.Errorf("result = %+v", res)
+		}
+		if got, _ := dst.ReadFile(ctx, "clients/archive/old.md"); got == nil || got.Content != "# clients/archive/old.md" {
+			t.Errorf("imported file = %+v", got)
+		}
+		if dst.Manifest().ID == src.Manifest().ID {
+			t.Error("import replaced the vault's own manifest")
+		}
+	})
+
+	policies := []struct {
+		policy  string
+		action  string
+		content string
+	}{
+		{ImportSkip, ImportSkipped, "mine"},
+		{ImportOverwrite, ImportOverwritten, "# readme.md"},
+		{ImportMerge, ImportMerged, "mine"},
+	}
+	for _, tt := range policies {
+		t.Run("collisions "+tt.policy, func(t *testing.T) {
+			dst, _ := New(t.TempDir())
+			dst.WriteFile(ctx, "readme.md", WriteRequest{Content: "mine"})
+			dst.WriteFile(ctx, "clients/acme.md", WriteRequest{Content: "# clients/acme.md"})
+
+			res, err := dst.Import(ctx, archive, ImportOptions{Policy: tt.policy})
+			if err != nil {
+				t.Fatalf("Import() returned error: %v", err)
+			}
+			if res.Summary[tt.action] != 1 || res.Summary[ImportUnchanged] != 1 || res.Summary[ImportCreated] != 1 {
+				t.Errorf("summary = %v", res.Summary)
+			}
+			if got, _ := dst.ReadFile(ctx, "readme.md"); got.Content != tt.content {
+				t.Errorf("readme.md = %q, want %q", got.Content, tt.content)
+			}
+			if tt.policy == ImportMerge {
+				got, err := dst.ReadFile(ctx, "readme (imported).md")
+				if err != nil || got.Content != "# readme.md" {
+					t.Errorf("merged copy = %+v, %v", got, err)
+				}
+			}
+		})
+	}
+
+	t.Run("dry run writes nothing", func(t *testing.T) {
+		dst, _ := New(t.TempDir())
+		res, err := dst.Import(ctx, archive, ImportOptions{DryRun: true})
+		if err != nil || !res.DryRun || res.Summary[ImportCreated] != 3 {
+			t.Fatalf("Import() = %+v, %v", res, err)
+		}
+		if files, _ := dst.ListFiles(ctx); len(files) != 0 {
+			t.Errorf("dry run wrote %d files", len(files))
+		}
+	})
+
+	t.Run("writes with the role in ctx", func(t *testing.T) {
+		dst, _ := New(t.TempDir())
+		dst.SetACL(ctx, ACL{Rules: []Rule{{Prefix: "clients/", Read: models.RoleEditor, Write: models.RoleAdmin}}})
+		res, err := dst.Import(WithRole(ctx, models.RoleEditor), archive, ImportOptions{})
+		if err != nil {
+			t.Fatalf("Import() returned error: %v", err)
+		}
+		if res.Summary[ImportFailed] != 2 || res.Summary[ImportCreated] != 1 {
+			t.Errorf("summary = %v, want the clients files refused", res.Summary)
+		}
+	})
+
+	t.Run("refusals don't reveal protected files", func(t *testing.T) {
+		dst, _ := New(t.TempDir())
+		dst.WriteFile(ctx, "private/plan.md", WriteRequest{Content: "the plan"})
+		dst.SetACL(ctx, ACL{Rules: []Rule{{Prefix: "private/", Read: models.RoleAdmin, Write: models.RoleAdmin}}})
+		zr := buildZip(t, []string{".deez/manifest.json", "private/plan.md", "private/other.md"}, map[string]string{
+			".deez/manifest.json": editorManifest,
+			"private/plan.md":     "the plan",
+			"private/other.md":    "x",
+		})
+		res, err := dst.Import(WithRole(ctx, models.RoleEditor), zr, ImportOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("Import() returned error: %v", err)
+		}
+		if len(res.Files) != 2 || res.Files[0].Action != ImportFailed || res.Files[0].Error != ErrForbidden.Error() ||
+			res.Files[1].Action != res.Files[0].Action || res.Files[1].Error != res.Files[0].Error {
+			t.Errorf("files = %+v, want the same refusal for both", res.Files)
+		}
+	})
+
+	t.Run("imports editor exports", func(t *testing.T) {
+		dst, _ := New(t.TempDir())
+		zr := buildZip(t, []string{".deez/manifest.json", ".deez/index.json", "a.md", "img/x.png"}, map[string]string{
+			".deez/manifest.json": editorManifest,
+			".deez/index.json":    "{}",
+			"a.md":                "a",
+			"img/x.png":           "png",
+		})
+		res, err := dst.Import(ctx, zr, ImportOptions{})
+		if err != nil {
+			t.Fatalf("Import() returned error: %v", err)
+		}
+		if res.Verified || res.Summary[ImportCreated] != 2 {
+			t.Errorf("result = %+v", res)
+		}
+		if _, err := os.Stat(filepath.Join(dst.Root(), MetaDir, "index.json")); err == nil {
+			t.Error("imported .deez/index.json")
+		}
+	})
+}
+
+func TestImport_Refused(t *testing.T) {
+	ctx := context.Background()
+	index := func(files ...ArchiveFile) string {
+		b, _ := json.Marshal(ArchiveIndex{Files: files})
+		return string(b)
+	}
+	tests := []struct {
+		name  string
+		names []string
+		files map[string]string
+	}{
+		{"no manifest", []string{"a.md"}, map[string]string{"a.md": "a"}},
+		{"unsupported manifest", []string{".deez/manifest.json"}, map[string]string{".deez/manifest.json": `{"version":"9.0.0","id":"x","name":"n","created":"2024-01-01T00:00:00Z"}`}},
+		{"zip slip", []string{".deez/manifest.json", "ok.md", "../../evil.md"}, map[string]string{".deez/manifest.json": editorManifest}},
+		{"absolute path", []string{".deez/manifest.json", "/etc/evil"}, map[string]string{".deez/manifest.json": editorManifest}},
+		{"backslashes", []string{".deez/manifest.json", `..\evil.md`}, map[string]string{".deez/manifest.json": editorManifest}},
+		{"hash mismatch", []string{".deez/manifest.json", "a.md", ".deez/files.json"}, map[string]string{
+			".deez/manifest.json": editorManifest,
+			"a.md":                "tampered",
+			".deez/files.json":    index(ArchiveFile{Path: "a.md", Hash: sha256Hex([]byte("original"))}),
+		}},
+		{"unlisted file", []string{".deez/manifest.json", "a.md", ".deez/files.json"}, map[string]string{
+			".deez/manifest.json": editorManifest,
+			"a.md":                "a",
+			".deez/files.json":    index(),
+		}},
+		{"missing file", []string{".deez/manifest.json", ".deez/files.json"}, map[string]string{
+			".deez/manifest.json": editorManifest,
+			".deez/files.json":    index(ArchiveFile{Path: "a.md", Hash: sha256Hex([]byte("a"))}),
+		}},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		dst, _ := New(filepath.Join(dir, "vault"))
+		_, err := dst.Import(ctx, buildZip(t, tt.names, tt.files), ImportOptions{})
+		if !errors.Is(err, ErrBadArchive) {
+			t.Errorf("%s: Import() error = %v, want ErrBadArchive", tt.name, err)
+		}
+		if files, _ := dst.ListFiles(ctx); len(files) != 0 {
+			t.Errorf("%s: wrote %d files", tt.name, len(files))
+		}
+		if _, err := os.Stat(filepath.Join(dir, "evil.md")); err == nil {
+			t.Errorf("%s: wrote outside the vault", tt.name)
+		}
+	}
+}
+
+func TestImport_DeclaredSizes(t *testing.T) {
+	// rawZip declares sizes for its entries without storing that much
+	rawZip := func(sizes ...uint64) *zip.Reader {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create(".deez/manifest.json")
+		w.Write([]byte(editorManifest))
+		for i, size := range sizes {
+			w, err := zw.CreateRaw(&zip.FileHeader{Name: fmt.Sprintf("bomb-%d.md", i), Method: zip.Deflate, UncompressedSize64: size, CompressedSize64: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte{0x03, 0x00})
+		}
+		zw.Close()
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return zr
+	}
+	// Checked before anything is unpacked
+	tests := []struct {
+		name  string
+		sizes []uint64
+		want  string
+	}{
+		{"one large entry", []uint64{maxImportFileSize + 1}, "is larger than"},
+		{"many entries", nil, "unpacks to more than"},
+	}
+	for total := uint64(0); total <= maxImportTotalSize; total += maxImportFileSize {
+		tests[1].sizes = append(tests[1].sizes, maxImportFileSize)
+	}
+	for _, tt := range tests {
+		v, _ := New(t.TempDir())
+		_, err := v.Import(context.Background(), rawZip(tt.sizes...), ImportOptions{})
+		if !errors.Is(err, ErrBadArchive) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Import() error = %v, want ErrBadArchive %s", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// Parameters of the editor's VaultEncryption, which files must stay
+// readable by
+const (
+	PBKDF2Iterations = 100000
+	saltLength       = 16
+	keyLength        = 32 // AES-256
+	ivLength         = 12
+)
+
+var (
+	// ErrLocked is returned for operations on an encrypted vault made
+	// without its key, see WithKey, and for changes while Rekey runs
+	ErrLocked = errors.New("vault is locked")
+	// ErrWrongPassword is returned by Unlock when the password doesn't
+	// match the vault's key check
+	ErrWrongPassword = errors.New("wrong vault password")
+)
+
+// Key is the AES-256-GCM key of an encrypted vault, derived from its
+// password with PBKDF2-SHA256
+type Key struct {
+	salt    []byte
+	aead    cipher.AEAD
+	nameKey []byte // derives the IVs of encrypted file names
+}
+
+// DeriveKey derives the key for password and salt the way the editor's
+// VaultEncryption.deriveKey does
+func DeriveKey(password string, salt []byte) (*Key, error) {
+	if len(salt) != saltLength {
+		return nil, fmt.Errorf("salt must be %d bytes", saltLength)
+	}
+	raw, err := pbkdf2.Key(sha256.New, password, salt, PBKDF2Iterations, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, raw)
+	mac.Write([]byte("deez file names"))
+	return &Key{salt: bytes.Clone(salt), aead: aead, nameKey: mac.Sum(nil)}, nil
+}
+
+// Encrypt seals plaintext in the editor's format: base64 of a random
+// 12-byte IV followed by the ciphertext and its tag
+func (k *Key) Encrypt(plaintext []byte) string {
+	iv := make([]byte, ivLength)
+	if _, err := rand.Read(iv); err != nil {
+		panic("failed to generate iv")
+	}
+	return base64.StdEncoding.EncodeToString(k.aead.Seal(iv, iv, plaintext, nil))
+}
+
+// Decrypt opens what Encrypt, or the editor's VaultEncryption.encrypt,
+// sealed
+func (k *Key) Decrypt(sealed string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sealed))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+	return k.open(b)
+}
+
+func (k *Key) open(b []byte) ([]byte, error) {
+	if len(b) < ivLength+k.aead.Overhead() {
+		return nil, errors.New("decrypting: ciphertext too short")
+	}
+	plain, err := k.aead.Open(nil, b[:ivLength], b[ivLength:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+	return plain, nil
+}
+
+// maxNameLength is the longest file name most file systems accept
+const maxNameLength = 255
+
+// sealName encrypts a file or folder name. The IV is derived from the name
+// so that a path always encrypts to the same names and can be looked up;
+// the output is base64url so it is a valid file name, and decodes to the
+// editor's format.
+func (k *Key) sealName(name string) (string, error) {
+	mac := hmac.New(sha256.New, k.nameKey)
+	mac.Write([]byte(name))
+	iv := mac.Sum(nil)[:ivLength]
+	sealed := base64.RawURLEncoding.EncodeToString(k.aead.Seal(bytes.Clone(iv), iv, []byte(name), nil))
+	if len(sealed) > maxNameLength {
+		return "", fmt.Errorf("name %q is too long to encrypt", name)
+	}
+	return sealed, nil
+}
+
+func (k *Key) openName(sealed string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	plain, err := k.open(b)
+	return string(plain), err
+}
+
+// keyCheck is the plaintext of a manifest's key check: a key that opens it
+// is the vault's current key
+func keyCheck(vaultID string) string {
+	return "deez key check " + vaultID
+}
+
+// newEncryption returns the manifest settings of a vault encrypted with a
+// new key for password, and that key
+func newEncryption(vaultID, password string, filenames bool) (*Encryption, *Key, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	k, err := DeriveKey(password, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Encryption{
+		Enabled:       true,
+		Algorithm:     AlgorithmAESGCM,
+		KeyDerivation: KeyPBKDF2,
+		Salt:          base64.StdEncoding.EncodeToString(salt),
+		KeyCheck:      k.Encrypt([]byte(keyCheck(vaultID))),
+		Filenames:     filenames,
+	}, k, nil
+}
+
+type keyCtx struct{}
+
+// WithKey lets operations made with ctx read and write an encrypted vault
+func WithKey(ctx context.Context, k *Key) context.Context {
+	return context.WithValue(ctx, keyCtx{}, k)
+}
+
+// Encrypted reports whether the vault's files are encrypted at rest
+func (v *Vault) Encrypted() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.manifest.Encryption != nil && v.manifest.Encryption.Enabled
+}
+
+// Unlock derives the vault's key from password, checking it against the
+// manifest's key check
+func (v *Vault) Unlock(password string) (*Key, error) {
+	m := v.Manifest()
+	if m.Encryption == nil || !m.Encryption.Enabled {
+		return nil, errors.New("vault is not encrypted")
+	}
+	salt, err := base64.StdEncoding.DecodeString(m.Encryption.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: invalid salt: %w", err)
+	}
+	k, err := DeriveKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	if check, err := k.Decrypt(m.Encryption.KeyCheck); err != nil || string(check) != keyCheck(m.ID) {
+		return nil, ErrWrongPassword
+	}
+	return k, nil
+}
+
+// keyFor returns the key of ctx for an encrypted vault, nil for a plain
+// one, and ErrLocked when ctx has no key or an outdated one
+func (v *Vault) keyFor(ctx context.Context) (*Key, error) {
+	v.mu.RLock()
+	e := v.manifest.Encryption
+	v.mu.RUnlock()
+	if e == nil || !e.Enabled {
+		return nil, nil
+	}
+	k, _ := ctx.Value(keyCtx{}).(*Key)
+	if k == nil || base64.StdEncoding.EncodeToString(k.salt) != e.Salt {
+		return nil, ErrLocked
+	}
+	return k, nil
+}
+
+// encryptsNames reports whether file and folder names are encrypted too
+func (v *Vault) encryptsNames() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.manifest.Encryption != nil && v.manifest.Encryption.Enabled && v.manifest.Encryption.Filenames
+}
+
+// locate returns where the file at the vault path rel is stored, encrypting
+// its names when the vault does. Hidden names, such as .deez, are kept.
+func (v *Vault) locate(k *Key, rel string) (string, error) {
+	abs, err := v.resolve(rel)
+	if err != nil || k == nil || !v.encryptsNames() {
+		return abs, err
+	}
+	clean, _ := filepath.Rel(v.root, abs)
+	segs := strings.Split(clean, string(filepath.Separator))
+	for i, seg := range segs {
+		if seg == "." || strings.HasPrefix(seg, ".") {
+			continue
+		}
+		if segs[i], err = k.sealName(seg); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(append([]string{v.root}, segs...)...), nil
+}
+
+// vaultPath returns the vault path of a file stored at the root-relative
+// path stored, false when its names don't decrypt with k
+func (v *Vault) vaultPath(k *Key, stored string) (string, bool) {
+	stored = filepath.ToSlash(stored)
+	if k == nil || !v.encryptsNames() {
+		return stored, true
+	}
+	segs := strings.Split(stored, "/")
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ".") {
+			continue
+		}
+		name, err := k.openName(seg)
+		if err != nil {
+			return "", false
+		}
+		segs[i] = name
+	}
+	return strings.Join(segs, "/"), true
+}
+
+// readStored reads the file at abs, decrypting it with k
+func readStored(k *Key, abs string) ([]byte, error) {
+	b, err := os.ReadFile(abs)
+	if err != nil || k == nil {
+		return b, err
+	}
+	return k.Decrypt(string(b))
+}
+
+// seal returns what is stored for content
+func seal(k *Key, content []byte) []byte {
+	if k == nil {
+		return content
+	}
+	return []byte(k.Encrypt(content))
+}
+
+// Rekey re-encrypts every file of the vault with a new key for password,
+// encrypting file names too when filenames is set, and returns that key.
+// An empty password decrypts the vault. ctx needs the current key of an
+// encrypted vault and, when scoped to a role, admin rights.
+//
+// The vault is rebuilt in .deez/rekey and swapped in. Changes in progress
+// are finished first and new ones fail with ErrLocked until it's done; if
+// the swap is interrupted the previous files are in .deez/rekey-old.
+// Hidden files and folders are left as they are.
+func (v *Vault) Rekey(ctx context.Context, password string, filenames bool) (*Key, error) {
+	if role, ok := RoleFrom(ctx); ok && !models.RoleAtLeast(role, models.RoleAdmin) {
+		return nil, ErrForbidden
+	}
+	v.mu.Lock()
+	if v.rekeying {
+		v.mu.Unlock()
+		return nil, ErrLocked
+	}
+	v.rekeying = true
+	v.mu.Unlock()
+	defer func() {
+		v.mu.Lock()
+		v.rekeying = false
+		v.mu.Unlock()
+	}()
+	v.writers.Wait()
+
+	old, err := v.keyFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := v.Manifest()
+	enc, k := &Encryption{Algorithm: None, KeyDerivation: None}, (*Key)(nil)
+	if password != "" {
+		if enc, k, err = newEncryption(m.ID, password, filenames); err != nil {
+			return nil, err
+		}
+	}
+
+	staging := filepath.Join(v.root, MetaDir, "rekey")
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, err
+	}
+	next := &Vault{root: staging, manifest: &Manifest{Encryption: enc}}
+	err = filepath.WalkDir(v.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p == v.root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		stored, _ := filepath.Rel(v.root, p)
+		rel, ok := v.vaultPath(old, stored)
+		if !ok && !d.IsDir() && strings.HasSuffix(stored, tempSuffix) {
+			// what an interrupted write left behind
+			return nil
+		}
+		if !ok {
+			return fmt.Errorf("%s: name doesn't decrypt with the vault's key", stored)
+		}
+		dest, err := next.locate(k, rel)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(dest, 0o755)
+		}
+		b, err := readStored(old, p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(dest, seal(k, b), 0o644)
+	})
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+	return k, v.swapIn(staging, enc)
+}
+
+// beginWrite registers a change to the files of the vault, to be ended by
+// calling done. It returns ErrLocked while Rekey rebuilds them, rather than
+// waiting, as hooks may make changes of their own while one is in progress.
+func (v *Vault) beginWrite() (done func(), err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.rekeying {
+		return nil, ErrLocked
+	}
+	v.writers.Add(1)
+	return v.writers.Done, nil
+}
+
+// tempSuffix is the extension of the files WriteFile renames into place
+const tempSuffix = ".tmp"
+
+// swapIn replaces the vault's files with those rebuilt in staging and saves
+// the manifest with enc
+func (v *Vault) swapIn(staging string, enc *Encryption) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	previous := filepath.Join(v.root, MetaDir, "rekey-old")
+	if err := os.RemoveAll(previous); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(previous, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(v.root)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if err := os.Rename(filepath.Join(v.root, e.Name()), filepath.Join(previous, e.Name())); err != nil {
+			return err
+		}
+	}
+	staged, err := os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	for _, e := range staged {
+		if err := os.Rename(filepath.Join(staging, e.Name()), filepath.Join(v.root, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	m := *v.manifest
+	m.Encryption = enc
+	m.Updated = time.Now().UTC().Truncate(time.Millisecond)
+	if err := v.saveManifest(&m); err != nil {
+		return err
+	}
+	v.manifest = &m
+	os.RemoveAll(staging)
+	return os.RemoveAll(previous)
+}
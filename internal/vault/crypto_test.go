@@ -0,0 +1,289 @@
+package vault
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"dragonbytelabs/dz/internal/models"
+)
+
+// Made with the editor's VaultEncryption for the password "correct horse",
+// the salt 0..15 and the IV 100..111
+const (
+	editorSalt   = "AAECAwQFBgcICQoLDA0ODw=="
+	editorSealed = "ZGVmZ2hpamtsbW5vd37bZFpsiReRle9CAcI1u0Nb9kSR41EquqBBMgowzCRuWNdtMm/a"
+)
+
+// encryptedVault returns newTestVault encrypted with password, and ctx
+// holding its key
+func encryptedVault(t *testing.T, password string, filenames bool) (*Vault, context.Context) {
+	t.Helper()
+	v := newTestVault(t)
+	k, err := v.Rekey(context.Background(), password, filenames)
+	if err != nil {
+		t.Fatalf("Rekey() returned error: %v", err)
+	}
+	return v, WithKey(context.Background(), k)
+}
+
+func TestKey_EditorFormat(t *testing.T) {
+	salt, _ := base64.StdEncoding.DecodeString(editorSalt)
+	k, err := DeriveKey("correct horse", salt)
+	if err != nil {
+		t.Fatalf("DeriveKey() returned error: %v", err)
+	}
+	plain, err := k.Decrypt(editorSealed)
+	if err != nil || string(plain) != "# Hello from the editor" {
+		t.Errorf("Decrypt(editor) = %q, %v", plain, err)
+	}
+
+	sealed := k.Encrypt([]byte("# Hello"))
+	if sealed == k.Encrypt([]byte("# Hello")) {
+		t.Error("Encrypt() reused its IV")
+	}
+	if plain, err := k.Decrypt(sealed); err != nil || string(plain) != "# Hello" {
+		t.Errorf("Decrypt(Encrypt()) = %q, %v", plain, err)
+	}
+
+	other, _ := DeriveKey("wrong horse", salt)
+	if _, err := other.Decrypt(editorSealed); err == nil {
+		t.Error("Decrypt() with another key succeeded")
+	}
+}
+
+func TestVault_Encrypted(t *testing.T) {
+	v, ctx := encryptedVault(t, "correct horse", false)
+
+	b, err := os.ReadFile(filepath.Join(v.Root(), "readme.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("readme")) {
+		t.Errorf("readme.md is stored as %q", b)
+	}
+	r, err := v.ReadFile(ctx, "readme.md")
+	if err != nil || r.Content != "# readme.md" || r.Hash != sha256Hex([]byte("# readme.md")) {
+		t.Fatalf("ReadFile() = %+v, %v", r, err)
+	}
+	if _, err := v.WriteFile(ctx, "readme.md", WriteRequest{Content: "# changed", IfMatch: r.Hash}); err != nil {
+		t.Errorf("WriteFile(If-Match) returned error: %v", err)
+	}
+
+	t.Run("needs the key", func(t *testing.T) {
+		if _, err := v.ReadFile(context.Background(), "readme.md"); !errors.Is(err, ErrLocked) {
+			t.Errorf("ReadFile() error = %v, want ErrLocked", err)
+		}
+		if _, err := v.ListFiles(context.Background()); !errors.Is(err, ErrLocked) {
+			t.Errorf("ListFiles() error = %v, want ErrLocked", err)
+		}
+		if _, err := v.WriteFile(context.Background(), "new.md", WriteRequest{Content: "x"}); !errors.Is(err, ErrLocked) {
+			t.Errorf("WriteFile() error = %v, want ErrLocked", err)
+		}
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		if _, err := v.Unlock("wrong horse"); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("Unlock(wrong) error = %v, want ErrWrongPassword", err)
+		}
+		k, err := v.Unlock("correct horse")
+		if err != nil {
+			t.Fatalf("Unlock() returned error: %v", err)
+		}
+		if r, err := v.ReadFile(WithKey(context.Background(), k), "readme.md"); err != nil || r.Content != "# changed" {
+			t.Errorf("ReadFile() = %+v, %v", r, err)
+		}
+	})
+
+	t.Run("the editor reads the files", func(t *testing.T) {
+		m := v.Manifest()
+		if m.Encryption.Salt == "" || m.Encryption.Algorithm != AlgorithmAESGCM || m.Encryption.KeyDerivation != KeyPBKDF2 {
+			t.Errorf("encryption = %+v", m.Encryption)
+		}
+		stored, _ := os.ReadFile(filepath.Join(v.Root(), "clients", "acme.md"))
+		k, _ := v.Unlock("correct horse")
+		if plain, err := k.Decrypt(string(stored)); err != nil || string(plain) != "# clients/acme.md" {
+			t.Errorf("Decrypt(clients/acme.md) = %q, %v", plain, err)
+		}
+	})
+
+	t.Run("exports plaintext", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := v.Export(ctx, &buf); err != nil {
+			t.Fatalf("Export() returned error: %v", err)
+		}
+		zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		for _, f := range zr.File {
+			if f.Name != "clients/acme.md" {
+				continue
+			}
+			rc, _ := f.Open()
+			b, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(b) != "# clients/acme.md" {
+				t.Errorf("exported clients/acme.md = %q", b)
+			}
+		}
+	})
+}
+
+func TestVault_EncryptedNames(t *testing.T) {
+	v, ctx := encryptedVault(t, "correct horse", true)
+
+	entries, _ := os.ReadDir(v.Root())
+	for _, e := range entries {
+		if e.Name() == "readme.md" || e.Name() == "clients" {
+			t.Errorf("%s is stored under its name", e.Name())
+		}
+	}
+	files, err := v.ListFiles(ctx)
+	if err != nil {
+		t.Fatalf("ListFiles() returned error: %v", err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if got := strings.Join(paths, ","); !strings.Contains(got, "clients/archive/old.md") || len(paths) != 3 {
+		t.Errorf("ListFiles() = %s", got)
+	}
+	if err := v.RenameFile(ctx, "readme.md", "docs/readme.md"); err != nil {
+		t.Fatalf("RenameFile() returned error: %v", err)
+	}
+	if r, err := v.ReadFile(ctx, "docs/readme.md"); err != nil || r.Content != "# readme.md" {
+		t.Errorf("ReadFile(docs/readme.md) = %+v, %v", r, err)
+	}
+	if err := v.CreateFolder(ctx, "drafts"); err != nil {
+		t.Errorf("CreateFolder() returned error: %v", err)
+	}
+	listed, err := v.ListEntries(ctx)
+	if err != nil {
+		t.Fatalf("ListEntries() returned error: %v", err)
+	}
+	var folders []string
+	for _, e := range listed {
+		if e.Kind == "folder" {
+			folders = append(folders, e.Path)
+		}
+	}
+	if got := strings.Join(folders, ","); !strings.Contains(got, "drafts") || !strings.Contains(got, "docs") {
+		t.Errorf("folders = %s", got)
+	}
+}
+
+func TestVault_Rekey(t *testing.T) {
+	v, ctx := encryptedVault(t, "correct horse", false)
+
+	if _, err := v.Rekey(context.Background(), "other", false); !errors.Is(err, ErrLocked) {
+		t.Errorf("Rekey() without the key error = %v, want ErrLocked", err)
+	}
+	if _, err := v.Rekey(WithRole(ctx, models.RoleEditor), "other", false); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Rekey() by an editor error = %v, want ErrForbidden", err)
+	}
+
+	k, err := v.Rekey(ctx, "battery staple", true)
+	if err != nil {
+		t.Fatalf("Rekey() returned error: %v", err)
+	}
+	if _, err := v.ReadFile(ctx, "readme.md"); !errors.Is(err, ErrLocked) {
+		t.Errorf("ReadFile() with the old key error = %v, want ErrLocked", err)
+	}
+	ctx = WithKey(context.Background(), k)
+	if r, err := v.ReadFile(ctx, "clients/archive/old.md"); err != nil || r.Content != "# clients/archive/old.md" {
+		t.Errorf("ReadFile() = %+v, %v", r, err)
+	}
+	if _, err := v.Unlock("correct horse"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Unlock(old password) error = %v, want ErrWrongPassword", err)
+	}
+
+	if _, err := v.Rekey(ctx, "", false); err != nil {
+		t.Fatalf("Rekey() to plaintext returned error: %v", err)
+	}
+	if v.Encrypted() {
+		t.Error("vault is still encrypted")
+	}
+	b, err := os.ReadFile(filepath.Join(v.Root(), "clients", "archive", "old.md"))
+	if err != nil || string(b) != "# clients/archive/old.md" {
+		t.Errorf("clients/archive/old.md = %q, %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(v.Root(), MetaDir, "acl.json")); err != nil {
+		t.Errorf("acl.json is gone: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(v.Root(), MetaDir, "rekey")); !os.IsNotExist(err) {
+		t.Errorf("staging was left behind: %v", err)
+	}
+}
+
+func TestVault_RekeyBlocksWrites(t *testing.T) {
+	v := newTestVault(t)
+	ctx := context.Background()
+
+	// Hold a write open in a hook until Rekey has started
+	entered, release := make(chan struct{}), make(chan struct{})
+	v.Hook(func(ctx context.Context, v *Vault, c *Change) error {
+		if c.Path == "slow.md" {
+			close(entered)
+			<-release
+		}
+		return nil
+	})
+	wrote := make(chan error)
+	go func() {
+		_, err := v.WriteFile(ctx, "slow.md", WriteRequest{Content: "kept"})
+		wrote <- err
+	}()
+	<-entered
+	rekeyed := make(chan error)
+	var k *Key
+	go func() {
+		var err error
+		k, err = v.Rekey(ctx, "correct horse", false)
+		rekeyed <- err
+	}()
+	for {
+		v.mu.RLock()
+		started := v.rekeying
+		v.mu.RUnlock()
+		if started {
+			break
+		}
+		runtime.Gosched()
+	}
+
+	if _, err := v.WriteFile(ctx, "late.md", WriteRequest{Content: "x"}); !errors.Is(err, ErrLocked) {
+		t.Errorf("WriteFile() during Rekey error = %v, want ErrLocked", err)
+	}
+	if err := v.DeleteFile(ctx, "readme.md"); !errors.Is(err, ErrLocked) {
+		t.Errorf("DeleteFile() during Rekey error = %v, want ErrLocked", err)
+	}
+	if err := v.RenameFile(ctx, "readme.md", "moved.md"); !errors.Is(err, ErrLocked) {
+		t.Errorf("RenameFile() during Rekey error = %v, want ErrLocked", err)
+	}
+	select {
+	case <-rekeyed:
+		t.Fatal("Rekey() finished before the write in progress")
+	default:
+	}
+
+	close(release)
+	if err := <-wrote; err != nil {
+		t.Fatalf("WriteFile() returned error: %v", err)
+	}
+	if err := <-rekeyed; err != nil {
+		t.Fatalf("Rekey() returned error: %v", err)
+	}
+	if r, err := v.ReadFile(WithKey(ctx, k), "slow.md"); err != nil || r.Content != "kept" {
+		t.Errorf("ReadFile(slow.md) after Rekey = %+v, %v", r, err)
+	}
+	if _, err := v.WriteFile(WithKey(ctx, k), "late.md", WriteRequest{Content: "x"}); err != nil {
+		t.Errorf("WriteFile() after Rekey returned error: %v", err)
+	}
+}
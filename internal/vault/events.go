@@ -0,0 +1,86 @@
+package vault
+
+import (
+	"context"
+	"errors"
+)
+
+// Change operations reported to observers
+const (
+	OpCreate       = "create"
+	OpWrite        = "write"
+	OpRename       = "rename"
+	OpDelete       = "delete"
+	OpCreateFolder = "folder_create"
+	OpDeleteFolder = "folder_delete"
+)
+
+// Event describes a completed change to a vault
+type Event struct {
+	Vault      string `json:"vault"`
+	Op         string `json:"op"`
+	Path       string `json:"path"`
+	OldPath    string `json:"old_path,omitempty"` // renames only
+	HashBefore string `json:"hash_before,omitempty"`
+	HashAfter  string `json:"hash_after,omitempty"`
+}
+
+// Observer is called after every successful change, with the context of
+// the operation
+type Observer func(ctx context.Context, e Event)
+
+// Observe registers fn to be called after every change to the vault
+func (v *Vault) Observe(fn Observer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.observers = append(v.observers, fn)
+}
+
+func (v *Vault) emit(ctx context.Context, e Event) {
+	v.mu.RLock()
+	observers := v.observers
+	v.mu.RUnlock()
+
+	e.Vault = v.name
+	for _, fn := range observers {
+		fn(ctx, e)
+	}
+}
+
+// Change is a change about to be made to a file of a vault
+type Change struct {
+	Op      string // OpCreate, OpWrite, OpRename or OpDelete
+	Path    string
+	OldPath string // renames only
+	Content string // creates and writes only; hooks may replace it
+}
+
+// ErrRejected wraps the errors of hooks that refuse a change
+var ErrRejected = errors.New("change rejected")
+
+// Hook is called before a file is created, written, renamed or deleted,
+// with the context of the operation. Returning an error cancels the change.
+type Hook func(ctx context.Context, v *Vault, c *Change) error
+
+// Hook registers fn to be called before every change to a file of the vault
+func (v *Vault) Hook(fn Hook) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.hooks = append(v.hooks, fn)
+}
+
+func (v *Vault) runHooks(ctx context.Context, c *Change) error {
+	v.mu.RLock()
+	hooks := v.hooks
+	v.mu.RUnlock()
+
+	for _, fn := range hooks {
+		if err := fn(ctx, v, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Name identifies the vault, e.g. "users/3", "teams/7" or "default"
+func (v *Vault) Name() string { return v.name }
@@ -0,0 +1,59 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVault_Hooks(t *testing.T) {
+	v, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	var seen []string
+	v.Hook(func(ctx context.Context, hv *Vault, c *Change) error {
+		if hv != v {
+			t.Error("hook got another vault")
+		}
+		seen = append(seen, c.Op+" "+c.OldPath+">"+c.Path)
+		if strings.Contains(c.Content, "secret") || strings.HasPrefix(c.Path, "locked/") {
+			return fmt.Errorf("%w: not here", ErrRejected)
+		}
+		if c.Op == OpCreate || c.Op == OpWrite {
+			c.Content += "!"
+		}
+		return nil
+	})
+
+	v.WriteFile(ctx, "a.md", WriteRequest{Content: "hi"})
+	v.WriteFile(ctx, "a.md", WriteRequest{Content: "again"})
+	if res, _ := v.ReadFile(ctx, "a.md"); res.Content != "again!" {
+		t.Errorf("content = %q, want the hook's rewrite", res.Content)
+	}
+	if res, err := v.WriteFile(ctx, "b.md", WriteRequest{Content: "x"}); err != nil || res.Hash != sha256Hex([]byte("x!")) {
+		t.Errorf("WriteFile() = %+v, %v; want the hash of what was written", res, err)
+	}
+
+	if _, err := v.WriteFile(ctx, "a.md", WriteRequest{Content: "secret"}); !errors.Is(err, ErrRejected) {
+		t.Errorf("rejected write error = %v", err)
+	}
+	if res, _ := v.ReadFile(ctx, "a.md"); res.Content != "again!" {
+		t.Errorf("rejected write changed the file to %q", res.Content)
+	}
+	if err := v.RenameFile(ctx, "a.md", "locked/a.md"); !errors.Is(err, ErrRejected) {
+		t.Errorf("rejected rename error = %v", err)
+	}
+	v.RenameFile(ctx, "a.md", "c.md")
+	v.DeleteFile(ctx, "c.md")
+	v.CreateFolder(ctx, "dir")
+	v.RenameFile(ctx, "dir", "folder")
+
+	want := "create >a.md,write >a.md,create >b.md,write >a.md,rename a.md>locked/a.md,rename a.md>c.md,delete >c.md"
+	if got := strings.Join(seen, ","); got != want {
+		t.Errorf("hooks saw %s\nwant %s", got, want)
+	}
+}
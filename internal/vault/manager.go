@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Manager opens and caches the per-user and per-team vaults under a base directory
+type Manager struct {
+	base string
+
+	mu        sync.Mutex
+	vaults    map[string]*Vault
+	observers []Observer
+	hooks     []Hook
+}
+
+// NewManager creates a manager rooted at base
+func NewManager(base string) *Manager {
+	return &Manager{
+		base:   base,
+		vaults: make(map[string]*Vault),
+	}
+}
+
+// Personal returns the private vault of a user
+func (m *Manager) Personal(userID int64) (*Vault, error) {
+	return m.open(filepath.Join("users", strconv.FormatInt(userID, 10)))
+}
+
+// Team returns the shared vault of a team
+func (m *Manager) Team(teamID int64) (*Vault, error) {
+	return m.open(filepath.Join("teams", strconv.FormatInt(teamID, 10)))
+}
+
+// Open returns the vault with the given Name, e.g. "users/3" or "teams/7"
+func (m *Manager) Open(name string) (*Vault, error) {
+	kind, id, _ := strings.Cut(name, "/")
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n <= 0 || strconv.FormatInt(n, 10) != id {
+		return nil, fmt.Errorf("invalid vault name %q", name)
+	}
+	switch kind {
+	case "users":
+		return m.Personal(n)
+	case "teams":
+		return m.Team(n)
+	}
+	return nil, fmt.Errorf("invalid vault name %q", name)
+}
+
+func (m *Manager) open(rel string) (*Vault, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if v, ok := m.vaults[rel]; ok {
+		return v, nil
+	}
+	v, err := newVault(filepath.Join(m.base, rel), filepath.ToSlash(rel))
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range m.observers {
+		v.Observe(fn)
+	}
+	for _, fn := range m.hooks {
+		v.Hook(fn)
+	}
+	m.vaults[rel] = v
+	return v, nil
+}
+
+// Observe registers fn on every vault the manager opens
+func (m *Manager) Observe(fn Observer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.observers = append(m.observers, fn)
+	for _, v := range m.vaults {
+		v.Observe(fn)
+	}
+}
+
+// Hook registers fn on every vault the manager opens
+func (m *Manager) Hook(fn Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks = append(m.hooks, fn)
+	for _, v := range m.vaults {
+		v.Hook(fn)
+	}
+}
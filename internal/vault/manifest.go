@@ -0,0 +1,209 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpecVersion is the version of the vault format written to new manifests.
+// Vaults with another major version are refused.
+const SpecVersion = "1.0.0"
+
+const manifestFile = MetaDir + "/manifest.json"
+
+// ErrUnsupportedVersion is returned when opening a vault whose manifest has
+// a spec major version this server doesn't understand
+var ErrUnsupportedVersion = errors.New("unsupported vault spec version")
+
+// Encryption algorithms and key derivations of a manifest
+const (
+	AlgorithmAESGCM = "AES-256-GCM"
+	KeyPBKDF2       = "PBKDF2"
+	None            = "none"
+)
+
+// Manifest identifies a vault and holds its vault-wide settings. It is
+// stored in .deez/manifest.json in the format of the editor's VaultManifest.
+type Manifest struct {
+	Version    string         `json:"version"` // spec version, e.g. 1.0.0
+	ID         string         `json:"id"`      // stable across renames, exports and syncs
+	Name       string         `json:"name"`
+	Created    time.Time      `json:"created"`
+	Updated    time.Time      `json:"updated"`
+	Encryption *Encryption    `json:"encryption,omitempty"`
+	Plugins    []string       `json:"plugins"`  // ids of the plugins the vault uses
+	Settings   map[string]any `json:"settings"` // vault-specific settings
+}
+
+// Encryption describes how the notes of a vault are encrypted at rest. The
+// key is derived from the vault's password and Salt; KeyCheck holds a known
+// text encrypted with it, so a wrong password is told apart from a
+// corrupted file.
+type Encryption struct {
+	Enabled       bool   `json:"enabled"`
+	Algorithm     string `json:"algorithm"`          // AlgorithmAESGCM or None
+	KeyDerivation string `json:"keyDerivation"`      // KeyPBKDF2 or None
+	Salt          string `json:"salt,omitempty"`     // base64, 16 bytes
+	KeyCheck      string `json:"keyCheck,omitempty"` // in the format of Key.Encrypt
+	Filenames     bool   `json:"filenames,omitempty"`
+}
+
+var validSpecVersion = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)$`)
+
+// NewManifest returns the manifest of a new vault named name
+func NewManifest(name string) *Manifest {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return &Manifest{
+		Version:    SpecVersion,
+		ID:         newVaultID(),
+		Name:       name,
+		Created:    now,
+		Updated:    now,
+		Encryption: &Encryption{Algorithm: None, KeyDerivation: None},
+		Plugins:    []string{"core.zettelkasten"},
+		Settings:   map[string]any{},
+	}
+}
+
+// newVaultID returns a random UUID
+func newVaultID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to generate vault id")
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Validate checks the manifest, wrapping ErrUnsupportedVersion when its
+// spec major version differs from SpecVersion's
+func (m *Manifest) Validate() error {
+	parts := validSpecVersion.FindStringSubmatch(m.Version)
+	if parts == nil {
+		return fmt.Errorf("manifest: invalid version %q", m.Version)
+	}
+	if major, _ := strconv.Atoi(parts[1]); major != specMajor() {
+		return fmt.Errorf("%w %s (this server reads %d.x)", ErrUnsupportedVersion, m.Version, specMajor())
+	}
+	if strings.TrimSpace(m.ID) == "" {
+		return errors.New("manifest: id is required")
+	}
+	if strings.TrimSpace(m.Name) == "" {
+		return errors.New("manifest: name is required")
+	}
+	if m.Created.IsZero() {
+		return errors.New("manifest: created is required")
+	}
+	if e := m.Encryption; e != nil {
+		if e.Algorithm != AlgorithmAESGCM && e.Algorithm != None {
+			return fmt.Errorf("manifest: unknown encryption algorithm %q", e.Algorithm)
+		}
+		if e.KeyDerivation != KeyPBKDF2 && e.KeyDerivation != None {
+			return fmt.Errorf("manifest: unknown key derivation %q", e.KeyDerivation)
+		}
+		if e.Enabled && (e.Algorithm == None || e.KeyDerivation == None) {
+			return errors.New("manifest: encryption is enabled without an algorithm and key derivation")
+		}
+		if e.Enabled {
+			if salt, err := base64.StdEncoding.DecodeString(e.Salt); err != nil || len(salt) != saltLength {
+				return fmt.Errorf("manifest: encryption needs a %d-byte base64 salt", saltLength)
+			}
+			if e.KeyCheck == "" {
+				return errors.New("manifest: encryption needs a key check")
+			}
+		}
+	}
+	for _, p := range m.Plugins {
+		if strings.TrimSpace(p) == "" {
+			return errors.New("manifest: empty plugin id")
+		}
+	}
+	return nil
+}
+
+func specMajor() int {
+	major, _ := strconv.Atoi(strings.SplitN(SpecVersion, ".", 2)[0])
+	return major
+}
+
+// Manifest returns a copy of the vault's manifest
+func (v *Vault) Manifest() Manifest {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	m := *v.manifest
+	if m.Encryption != nil {
+		e := *m.Encryption
+		m.Encryption = &e
+	}
+	m.Plugins = append([]string{}, m.Plugins...)
+	m.Settings = make(map[string]any, len(v.manifest.Settings))
+	for k, val := range v.manifest.Settings {
+		m.Settings[k] = val
+	}
+	return m
+}
+
+// loadManifest reads the vault's manifest, creating one named name when the
+// vault has none, and refuses invalid manifests
+func (v *Vault) loadManifest(name string) error {
+	abs := filepath.Join(v.root, filepath.FromSlash(manifestFile))
+	b, err := os.ReadFile(abs)
+	if errors.Is(err, os.ErrNotExist) {
+		m := NewManifest(name)
+		if err := v.saveManifest(m); err != nil {
+			return err
+		}
+		v.manifest = m
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	if err := m.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	if m.Plugins == nil {
+		m.Plugins = []string{}
+	}
+	if m.Settings == nil {
+		m.Settings = map[string]any{}
+	}
+	v.manifest = &m
+	return nil
+}
+
+// saveManifest writes m atomically
+func (v *Vault) saveManifest(m *Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	abs := filepath.Join(v.root, filepath.FromSlash(manifestFile))
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return err
+	}
+	tmp := abs + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, abs); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestManifest_Created(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "notes")
+	v, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	m := v.Manifest()
+	if m.Version != SpecVersion || m.Name != "notes" || m.Created.IsZero() {
+		t.Errorf("Manifest() = %+v", m)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(m.ID) {
+		t.Errorf("id = %q, want a UUID", m.ID)
+	}
+	if len(m.Plugins) != 1 || m.Encryption == nil || m.Encryption.Enabled {
+		t.Errorf("defaults = %+v %+v", m.Plugins, m.Encryption)
+	}
+
+	// Reopening keeps the identity
+	again, err := New(dir)
+	if err != nil {
+		t.Fatalf("reopening returned error: %v", err)
+	}
+	if got := again.Manifest(); got.ID != m.ID || !got.Created.Equal(m.Created) {
+		t.Errorf("reopened manifest = %+v, want %+v", got, m)
+	}
+
+	// The copy is the caller's
+	m.Plugins[0] = "changed"
+	m.Settings["x"] = 1
+	if got := v.Manifest(); got.Plugins[0] == "changed" || len(got.Settings) != 0 {
+		t.Errorf("Manifest() shares state with callers: %+v", got)
+	}
+}
+
+func TestManifest_Refused(t *testing.T) {
+	tests := []struct {
+		name, manifest string
+		unsupported    bool
+	}{
+		{"newer major", `{"version":"2.0.0","id":"x","name":"n","created":"2024-01-01T00:00:00Z"}`, true},
+		{"not json", `{`, false},
+		{"bad version", `{"version":"1","id":"x","name":"n","created":"2024-01-01T00:00:00Z"}`, false},
+		{"no id", `{"version":"1.0.0","name":"n","created":"2024-01-01T00:00:00Z"}`, false},
+		{"unknown algorithm", `{"version":"1.0.0","id":"x","name":"n","created":"2024-01-01T00:00:00Z","encryption":{"enabled":true,"algorithm":"ROT13","keyDerivation":"none"}}`, false},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		os.MkdirAll(filepath.Join(dir, MetaDir), 0o755)
+		os.WriteFile(filepath.Join(dir, MetaDir, "manifest.json"), []byte(tt.manifest), 0o644)
+		_, err := New(dir)
+		if err == nil {
+			t.Errorf("%s: New() succeeded, want an error", tt.name)
+			continue
+		}
+		if got := errors.Is(err, ErrUnsupportedVersion); got != tt.unsupported {
+			t.Errorf("%s: errors.Is(%v, ErrUnsupportedVersion) = %v", tt.name, err, got)
+		}
+	}
+
+	// A newer minor version of the same major is read
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, MetaDir), 0o755)
+	os.WriteFile(filepath.Join(dir, MetaDir, "manifest.json"), []byte(`{"version":"1.4.0","id":"abc","name":"n","created":"2024-01-01T00:00:00Z"}`), 0o644)
+	v, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() with 1.4.0 returned error: %v", err)
+	}
+	if m := v.Manifest(); m.ID != "abc" || m.Plugins == nil || m.Settings == nil || !strings.HasPrefix(m.Version, "1.") {
+		t.Errorf("Manifest() = %+v", m)
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"errors"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -51,9 +53,24 @@ type WriteResult struct {
 
 type Vault struct {
 	root string // absolute
+	name string
+
+	mu        sync.RWMutex
+	rules     *ACL
+	manifest  *Manifest
+	observers []Observer
+	hooks     []Hook
+	rekeying  bool           // Rekey is rebuilding the files
+	writers   sync.WaitGroup // changes to the files in progress
 }
 
+// New opens the vault at root, creating it and its manifest when missing.
+// It refuses vaults whose manifest is invalid or of an unsupported version.
 func New(root string) (*Vault, error) {
+	return newVault(root, "default")
+}
+
+func newVault(root, name string) (*Vault, error) {
 	abs, err := filepath.Abs(root)
 	if err != nil {
 		return nil, err
@@ -61,7 +78,18 @@ func New(root string) (*Vault, error) {
 	if err := os.MkdirAll(abs, 0o755); err != nil {
 		return nil, err
 	}
-	return &Vault{root: abs}, nil
+	v := &Vault{root: abs, name: name}
+	if err := v.loadACL(); err != nil {
+		return nil, err
+	}
+	manifestName := name
+	if name == "default" {
+		manifestName = filepath.Base(abs)
+	}
+	if err := v.loadManifest(manifestName); err != nil {
+		return nil, err
+	}
+	return v, nil
 }
 
 func (v *Vault) Root() string { return v.root }
@@ -99,9 +127,27 @@ func sha256Hex(b []byte) string {
 }
 
 func (v *Vault) ListMarkdown(ctx context.Context) ([]FileInfo, error) {
+	return v.listFiles(ctx, func(name string) bool {
+		return strings.HasSuffix(strings.ToLower(name), ".md")
+	})
+}
+
+// ListFiles lists every readable file outside hidden folders, e.g. notes
+// together with their attachments
+func (v *Vault) ListFiles(ctx context.Context) ([]FileInfo, error) {
+	return v.listFiles(ctx, func(string) bool { return true })
+}
+
+// listFiles walks the vault for readable files whose name satisfies match,
+// most recently modified first
+func (v *Vault) listFiles(ctx context.Context, match func(name string) bool) ([]FileInfo, error) {
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var out []FileInfo
 
-	err := filepath.WalkDir(v.root, func(p string, d fs.DirEntry, err error) error {
+	err = filepath.WalkDir(v.root, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -111,15 +157,22 @@ func (v *Vault) ListMarkdown(ctx context.Context) ([]FileInfo, error) {
 		default:
 		}
 
+		stored, _ := filepath.Rel(v.root, p)
+		rel, ok := v.vaultPath(k, stored)
+
 		if d.IsDir() {
 			// ignore hidden dirs like .git
 			if strings.HasPrefix(d.Name(), ".") && p != v.root {
 				return fs.SkipDir
 			}
+			if p != v.root && (!ok || !v.canRead(ctx, rel)) {
+				return fs.SkipDir
+			}
 			return nil
 		}
 
-		if !strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+		// files whose names don't decrypt aren't the vault's
+		if !ok || !match(path.Base(rel)) || !v.canRead(ctx, rel) {
 			return nil
 		}
 
@@ -128,10 +181,9 @@ func (v *Vault) ListMarkdown(ctx context.Context) ([]FileInfo, error) {
 			return err
 		}
 
-		rel, _ := filepath.Rel(v.root, p)
 		out = append(out, FileInfo{
-			Path:  filepath.ToSlash(rel),
-			Name:  d.Name(),
+			Path:  rel,
+			Name:  path.Base(rel),
 			Size:  info.Size(),
 			MTime: info.ModTime(),
 		})
@@ -149,13 +201,42 @@ func (v *Vault) ListMarkdown(ctx context.Context) ([]FileInfo, error) {
 	return out, nil
 }
 
+// Exists reports whether anything is stored at rel. Unlike ReadFile it
+// lets through writers who can't read rel, so they can avoid overwriting it.
+func (v *Vault) Exists(ctx context.Context, rel string) (bool, error) {
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return false, err
+	}
+	abs, err := v.locate(k, rel)
+	if err != nil {
+		return false, err
+	}
+	if !v.canRead(ctx, rel) && v.checkWrite(ctx, rel, false) != nil {
+		return false, ErrForbidden
+	}
+	if _, err := os.Stat(abs); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (v *Vault) ReadFile(ctx context.Context, rel string) (*ReadResult, error) {
-	abs, err := v.resolve(rel)
+	k, err := v.keyFor(ctx)
 	if err != nil {
 		return nil, err
 	}
+	abs, err := v.locate(k, rel)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.checkRead(ctx, rel); err != nil {
+		return nil, err
+	}
 
-	b, err := os.ReadFile(abs)
+	b, err := readStored(k, abs)
 	if err != nil {
 		return nil, err
 	}
@@ -168,41 +249,59 @@ func (v *Vault) ReadFile(ctx context.Context, rel string) (*ReadResult, error) {
 	return &ReadResult{
 		Path:    filepath.ToSlash(rel),
 		Content: string(b),
-		Size:    stat.Size(),
+		Size:    int64(len(b)),
 		MTime:   stat.ModTime(),
 		Hash:    sha256Hex(b),
 	}, nil
 }
 
 // WriteFile performs an atomic write (temp + rename).
-// If IfMatch is set, it will reject if current file hash differs.
+// If IfMatch is set, it will reject if current file hash differs. Hashes
+// are of the plaintext, also in encrypted vaults.
 func (v *Vault) WriteFile(ctx context.Context, rel string, req WriteRequest) (*WriteResult, error) {
-	abs, err := v.resolve(rel)
+	done, err := v.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := v.locate(k, rel)
 	if err != nil {
 		return nil, err
 	}
+	if err := v.checkWrite(ctx, rel, false); err != nil {
+		return nil, err
+	}
 
 	// ensure parent dir exists
 	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
 		return nil, err
 	}
 
-	newBytes := []byte(req.Content)
-	newHash := sha256Hex(newBytes)
+	op, curHash := OpCreate, ""
+	if cur, err := readStored(k, abs); err == nil {
+		op, curHash = OpWrite, sha256Hex(cur)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
 
 	// optimistic concurrency check
-	if req.IfMatch != "" {
-		cur, err := os.ReadFile(abs)
-		if err == nil {
-			curHash := sha256Hex(cur)
-			if curHash != req.IfMatch {
-				return nil, errors.New("conflict: file changed")
-			}
-		}
+	if req.IfMatch != "" && curHash != "" && curHash != req.IfMatch {
+		return nil, errors.New("conflict: file changed")
 	}
 
-	tmp := abs + ".tmp"
-	if err := os.WriteFile(tmp, newBytes, 0o644); err != nil {
+	change := &Change{Op: op, Path: filepath.ToSlash(rel), Content: req.Content}
+	if err := v.runHooks(ctx, change); err != nil {
+		return nil, err
+	}
+	newBytes := []byte(change.Content)
+	newHash := sha256Hex(newBytes)
+
+	tmp := abs + tempSuffix
+	if err := os.WriteFile(tmp, seal(k, newBytes), 0o644); err != nil {
 		return nil, err
 	}
 	if err := os.Rename(tmp, abs); err != nil {
@@ -215,9 +314,11 @@ func (v *Vault) WriteFile(ctx context.Context, rel string, req WriteRequest) (*W
 		return nil, err
 	}
 
+	v.emit(ctx, Event{Op: op, Path: filepath.ToSlash(rel), HashBefore: curHash, HashAfter: newHash})
+
 	return &WriteResult{
 		Path:  filepath.ToSlash(rel),
-		Size:  stat.Size(),
+		Size:  int64(len(newBytes)),
 		MTime: stat.ModTime(),
 		Hash:  newHash,
 	}, nil
@@ -225,10 +326,22 @@ func (v *Vault) WriteFile(ctx context.Context, rel string, req WriteRequest) (*W
 
 // CreateFolder creates a new directory in the vault
 func (v *Vault) CreateFolder(ctx context.Context, vaultPath string) error {
-	absPath, err := v.resolve(vaultPath)
+	done, err := v.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return err
+	}
+	absPath, err := v.locate(k, vaultPath)
 	if err != nil {
 		return err
 	}
+	if err := v.checkWrite(ctx, vaultPath, false); err != nil {
+		return err
+	}
 
 	// Check if it already exists
 	if _, err := os.Stat(absPath); err == nil {
@@ -236,13 +349,21 @@ func (v *Vault) CreateFolder(ctx context.Context, vaultPath string) error {
 	}
 
 	// Create the directory with parent directories
-	return os.MkdirAll(absPath, 0755)
+	if err := os.MkdirAll(absPath, 0755); err != nil {
+		return err
+	}
+	v.emit(ctx, Event{Op: OpCreateFolder, Path: filepath.ToSlash(vaultPath)})
+	return nil
 }
 
 func (v *Vault) ListEntries(ctx context.Context) ([]Entry, error) {
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var out []Entry
 
-	err := filepath.WalkDir(v.root, func(p string, d fs.DirEntry, err error) error {
+	err = filepath.WalkDir(v.root, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -262,12 +383,20 @@ func (v *Vault) ListEntries(ctx context.Context) ([]Entry, error) {
 			return fs.SkipDir
 		}
 
+		stored, _ := filepath.Rel(v.root, p)
+		rel, ok := v.vaultPath(k, stored)
+		if !ok || !v.canRead(ctx, rel) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
 		info, err := d.Info()
 		if err != nil {
 			return err
 		}
 
-		rel, _ := filepath.Rel(v.root, p)
 		kind := "file"
 		if d.IsDir() {
 			kind = "folder"
@@ -275,13 +404,13 @@ func (v *Vault) ListEntries(ctx context.Context) ([]Entry, error) {
 
 		// Optional: if you ONLY want markdown files, keep folders always
 		// and skip non-md files:
-		if kind == "file" && !strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+		if kind == "file" && !strings.HasSuffix(strings.ToLower(rel), ".md") {
 			return nil
 		}
 
 		out = append(out, Entry{
-			Path:  filepath.ToSlash(rel),
-			Name:  d.Name(),
+			Path:  rel,
+			Name:  path.Base(rel),
 			Kind:  kind,
 			Size:  info.Size(),
 			MTime: info.ModTime(),
@@ -306,10 +435,22 @@ func (v *Vault) ListEntries(ctx context.Context) ([]Entry, error) {
 
 // DeleteFile removes a file from the vault
 func (v *Vault) DeleteFile(ctx context.Context, vaultPath string) error {
-	absPath, err := v.resolve(vaultPath)
+	done, err := v.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return err
+	}
+	absPath, err := v.locate(k, vaultPath)
 	if err != nil {
 		return err
 	}
+	if err := v.checkWrite(ctx, vaultPath, false); err != nil {
+		return err
+	}
 
 	info, err := os.Stat(absPath)
 	if err != nil {
@@ -320,15 +461,40 @@ func (v *Vault) DeleteFile(ctx context.Context, vaultPath string) error {
 		return errors.New("path is a directory, use DeleteFolder instead")
 	}
 
-	return os.Remove(absPath)
+	if err := v.runHooks(ctx, &Change{Op: OpDelete, Path: filepath.ToSlash(vaultPath)}); err != nil {
+		return err
+	}
+
+	var hash string
+	if b, err := readStored(k, absPath); err == nil {
+		hash = sha256Hex(b)
+	}
+	if err := os.Remove(absPath); err != nil {
+		return err
+	}
+	v.emit(ctx, Event{Op: OpDelete, Path: filepath.ToSlash(vaultPath), HashBefore: hash})
+	return nil
 }
 
 // DeleteFolder removes a folder and all its contents from the vault
 func (v *Vault) DeleteFolder(ctx context.Context, vaultPath string) error {
-	absPath, err := v.resolve(vaultPath)
+	done, err := v.beginWrite()
 	if err != nil {
 		return err
 	}
+	defer done()
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return err
+	}
+	absPath, err := v.locate(k, vaultPath)
+	if err != nil {
+		return err
+	}
+	// The folder itself first, so that refusals don't reveal whether it exists
+	if err := v.checkWrite(ctx, vaultPath, false); err != nil {
+		return err
+	}
 
 	info, err := os.Stat(absPath)
 	if err != nil {
@@ -338,26 +504,65 @@ func (v *Vault) DeleteFolder(ctx context.Context, vaultPath string) error {
 	if !info.IsDir() {
 		return errors.New("path is not a directory, use DeleteFile instead")
 	}
+	if err := v.checkWrite(ctx, vaultPath, true); err != nil {
+		return err
+	}
 
-	return os.RemoveAll(absPath)
+	if err := os.RemoveAll(absPath); err != nil {
+		return err
+	}
+	v.emit(ctx, Event{Op: OpDeleteFolder, Path: filepath.ToSlash(vaultPath)})
+	return nil
 }
 
 // RenameFile renames or moves a file within the vault
 func (v *Vault) RenameFile(ctx context.Context, oldVaultPath, newVaultPath string) error {
-	oldAbs, err := v.resolve(oldVaultPath)
+	done, err := v.beginWrite()
 	if err != nil {
 		return err
 	}
-	newAbs, err := v.resolve(newVaultPath)
+	defer done()
+	k, err := v.keyFor(ctx)
+	if err != nil {
+		return err
+	}
+	oldAbs, err := v.locate(k, oldVaultPath)
+	if err != nil {
+		return err
+	}
+	newAbs, err := v.locate(k, newVaultPath)
 	if err != nil {
 		return err
 	}
 
+	// Moving a folder moves everything in it, protected subfolders included
+	isDir := false
+	if info, err := os.Stat(oldAbs); err == nil {
+		isDir = info.IsDir()
+	}
+	if err := v.checkWrite(ctx, oldVaultPath, isDir); err != nil {
+		return err
+	}
+	if err := v.checkWrite(ctx, newVaultPath, isDir); err != nil {
+		return err
+	}
+	// Hooks work on single files, so folder moves are not run past them
+	if !isDir {
+		c := &Change{Op: OpRename, Path: filepath.ToSlash(newVaultPath), OldPath: filepath.ToSlash(oldVaultPath)}
+		if err := v.runHooks(ctx, c); err != nil {
+			return err
+		}
+	}
+
 	// Create parent directory if it doesn't exist
 	newDir := filepath.Dir(newAbs)
 	if err := os.MkdirAll(newDir, 0755); err != nil {
 		return err
 	}
 
-	return os.Rename(oldAbs, newAbs)
+	if err := os.Rename(oldAbs, newAbs); err != nil {
+		return err
+	}
+	v.emit(ctx, Event{Op: OpRename, Path: filepath.ToSlash(newVaultPath), OldPath: filepath.ToSlash(oldVaultPath)})
+	return nil
 }
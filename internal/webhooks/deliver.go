@@ -0,0 +1,138 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+)
+
+const (
+	// MaxAttempts is how often a delivery is tried before it fails for good
+	MaxAttempts = 8
+	// batchSize caps the deliveries posted per round
+	batchSize = 50
+	// maxErrorLen caps the response body kept as a delivery's error
+	maxErrorLen = 512
+)
+
+// Backoff returns the wait after the given failed attempt: 30s, doubling
+// each time up to an hour
+func Backoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+// Deliverer posts queued deliveries to their webhooks
+type Deliverer struct {
+	db     *dbx.DB
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewDeliverer creates a deliverer and, if interval is positive, starts
+// posting due deliveries every interval
+func NewDeliverer(db *dbx.DB, interval time.Duration) *Deliverer {
+	d := &Deliverer{db: db, client: &http.Client{Timeout: 10 * time.Second}, now: time.Now}
+	if interval > 0 {
+		go d.run(interval)
+	}
+	return d
+}
+
+func (d *Deliverer) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for ; ; <-ticker.C {
+		if _, err := d.DeliverDue(context.Background()); err != nil {
+			log.Printf("webhooks: delivering failed: %v", err)
+		}
+	}
+}
+
+// DeliverDue posts the deliveries that are due and returns how many it
+// tried
+func (d *Deliverer) DeliverDue(ctx context.Context) (int, error) {
+	due, err := d.db.GetDueWebhookDeliveries(ctx, d.now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+	hooks := map[int64]*models.Webhook{}
+	for i := range due {
+		dl := &due[i]
+		h, ok := hooks[dl.WebhookID]
+		if !ok {
+			if h, err = d.db.GetWebhook(ctx, dl.WebhookID); err != nil {
+				return i, err
+			}
+			hooks[dl.WebhookID] = h
+		}
+		d.attempt(ctx, h, dl)
+		if err := d.db.UpdateWebhookDelivery(ctx, dl); err != nil {
+			return i, err
+		}
+	}
+	return len(due), nil
+}
+
+// attempt posts dl to h and records the outcome on dl
+func (d *Deliverer) attempt(ctx context.Context, h *models.Webhook, dl *models.WebhookDelivery) {
+	dl.Attempts++
+	dl.ResponseCode = nil
+	code, err := d.post(ctx, h, dl)
+	if code != 0 {
+		dl.ResponseCode = &code
+	}
+	if err == nil {
+		now := d.now()
+		dl.Status, dl.Error, dl.NextAttemptAt, dl.DeliveredAt = models.DeliveryDelivered, "", nil, &now
+		return
+	}
+	dl.Error = err.Error()
+	if dl.Attempts >= MaxAttempts || h == nil || !h.IsActive {
+		dl.Status, dl.NextAttemptAt = models.DeliveryFailed, nil
+		return
+	}
+	next := d.now().Add(Backoff(dl.Attempts))
+	dl.NextAttemptAt = &next
+}
+
+func (d *Deliverer) post(ctx context.Context, h *models.Webhook, dl *models.WebhookDelivery) (int, error) {
+	if h == nil {
+		return 0, fmt.Errorf("webhook deleted")
+	}
+	if !h.IsActive {
+		return 0, fmt.Errorf("webhook disabled")
+	}
+	body := []byte(dl.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "dz-webhooks")
+	req.Header.Set("X-Dz-Event", dl.Event)
+	req.Header.Set("X-Dz-Delivery", strconv.FormatInt(dl.ID, 10))
+	req.Header.Set("X-Dz-Signature", Sign(h.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLen))
+		return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	return resp.StatusCode, nil
+}
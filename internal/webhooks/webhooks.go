@@ -0,0 +1,198 @@
+// Package webhooks posts vault and account events to configured URLs.
+//
+// Notify queues a delivery for every active webhook subscribed to an event
+// in the webhook_deliveries table, and a Deliverer posts the queue,
+// retrying failures with exponential backoff. Payloads are JSON:
+//
+//	{
+//	  "event": "file.write",
+//	  "occurred_at": "2024-05-01T10:00:00Z",
+//	  "vault": "teams/3",
+//	  "path": "projects/plan.md",
+//	  "actor": {"id": 7, "email": "ada@example.com"},
+//	  "data": {...}
+//	}
+//
+// and carry the headers X-Dz-Event, X-Dz-Delivery and X-Dz-Signature, the
+// hex HMAC-SHA256 of the body keyed with the webhook's secret, as
+// "sha256=<hex>".
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"dragonbytelabs/dz/internal/audit"
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+)
+
+// Event is something webhooks can be told about
+type Event struct {
+	Type    string // one of models.WebhookEvents
+	Vault   string // file events only
+	Path    string
+	OldPath string // renames only
+	Data    any    // event details, e.g. the published post
+}
+
+// Actor is who caused an event
+type Actor struct {
+	ID    int64  `json:"id,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// Payload is the body posted to webhooks
+type Payload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Vault      string    `json:"vault,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	OldPath    string    `json:"old_path,omitempty"`
+	Actor      *Actor    `json:"actor,omitempty"`
+	Data       any       `json:"data,omitempty"`
+}
+
+// Notify queues e for every active webhook that subscribes to it. Failures
+// are logged rather than returned so webhooks never break a request.
+func Notify(ctx context.Context, db *dbx.DB, e Event) {
+	hooks, err := db.GetActiveWebhooks(ctx)
+	if err != nil {
+		log.Printf("webhooks: listing webhooks for %s: %v", e.Type, err)
+		return
+	}
+	var body []byte
+	for _, h := range hooks {
+		if !Matches(&h, e) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(payload(ctx, e)); err != nil {
+				log.Printf("webhooks: encoding %s: %v", e.Type, err)
+				return
+			}
+		}
+		if _, err := db.QueueWebhookDelivery(ctx, h.ID, e.Type, string(body)); err != nil {
+			log.Printf("webhooks: queueing %s for webhook %d: %v", e.Type, h.ID, err)
+		}
+	}
+}
+
+func payload(ctx context.Context, e Event) Payload {
+	p := Payload{
+		Event:      e.Type,
+		OccurredAt: time.Now().UTC(),
+		Vault:      e.Vault,
+		Path:       e.Path,
+		OldPath:    e.OldPath,
+		Data:       e.Data,
+	}
+	if a, ok := audit.ActorFrom(ctx); ok && (a.UserID != 0 || a.Email != "") {
+		p.Actor = &Actor{ID: a.UserID, Email: a.Email}
+	}
+	return p
+}
+
+// Matches reports whether h subscribes to e: the event is one of its
+// events, and for file events the vault is its vault, if any, and the path,
+// or the old path of a rename, matches one of its path patterns, if any
+func Matches(h *models.Webhook, e Event) bool {
+	subscribed := false
+	for _, name := range h.Events {
+		subscribed = subscribed || name == e.Type
+	}
+	if !subscribed {
+		return false
+	}
+	if h.Vault != "" && e.Vault != "" && h.Vault != e.Vault {
+		return false
+	}
+	if len(h.Paths) == 0 || e.Path == "" {
+		return true
+	}
+	for _, pattern := range h.Paths {
+		if Match(pattern, e.Path) || (e.OldPath != "" && Match(pattern, e.OldPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPattern reports whether pattern is a well-formed path pattern
+func ValidPattern(pattern string) bool {
+	if pattern == "" || strings.HasPrefix(pattern, "/") {
+		return false
+	}
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Match reports whether the vault path p matches pattern. Patterns are
+// slash-separated path.Match patterns where a "**" segment matches any
+// number of folders, so "projects/**" matches everything below projects and
+// "**/*.md" every note.
+func Match(pattern, p string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(strings.Trim(p, "/"), "/"))
+}
+
+func matchSegments(pattern, segs []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(rest, segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+			return false
+		}
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// Sign returns the X-Dz-Signature header of body for secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostPublished is the event of p going live
+func PostPublished(p *models.Post) Event {
+	return Event{Type: models.AuditPostPublished, Data: map[string]any{
+		"id":         p.ID,
+		"title":      p.Title,
+		"slug":       p.Slug,
+		"visibility": p.Visibility,
+	}}
+}
+
+// MemberJoined is the event of a user joining a team
+func MemberJoined(teamID, userID int64, role string) Event {
+	return Event{Type: models.AuditMemberJoined, Data: map[string]any{
+		"team_id": teamID,
+		"user_id": userID,
+		"role":    role,
+	}}
+}
@@ -0,0 +1,167 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"dragonbytelabs/dz/internal/audit"
+	"dragonbytelabs/dz/internal/dbx"
+	"dragonbytelabs/dz/internal/models"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"projects/**", "projects/plan.md", true},
+		{"projects/**", "projects/a/b/plan.md", true},
+		{"projects/**", "archive/projects/plan.md", false},
+		{"projects/*.md", "projects/plan.md", true},
+		{"projects/*.md", "projects/a/plan.md", false},
+		{"**/*.md", "plan.md", true},
+		{"**/*.md", "a/b/plan.md", true},
+		{"**/*.md", "a/b/data.txt", false},
+		{"a/**/z.md", "a/z.md", true},
+		{"a/**/z.md", "a/b/c/z.md", true},
+		{"daily/2024-??-*.md", "daily/2024-05-01.md", true},
+		{"inbox.md", "inbox.md", true},
+		{"inbox.md", "notes/inbox.md", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+
+	for _, p := range []string{"", "/abs/**", "bad/[", "**"} {
+		if want := p == "**"; ValidPattern(p) != want {
+			t.Errorf("ValidPattern(%q) = %v, want %v", p, !want, want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	h := &models.Webhook{
+		Events: models.StringList{models.AuditFileWrite, models.AuditFileRename, models.AuditMemberJoined},
+		Paths:  models.StringList{"projects/**"},
+		Vault:  "teams/1",
+	}
+	tests := []struct {
+		name string
+		e    Event
+		want bool
+	}{
+		{"matching write", Event{Type: models.AuditFileWrite, Vault: "teams/1", Path: "projects/a.md"}, true},
+		{"other event", Event{Type: models.AuditFileDelete, Vault: "teams/1", Path: "projects/a.md"}, false},
+		{"other vault", Event{Type: models.AuditFileWrite, Vault: "teams/2", Path: "projects/a.md"}, false},
+		{"other path", Event{Type: models.AuditFileWrite, Vault: "teams/1", Path: "notes/a.md"}, false},
+		{"renamed out", Event{Type: models.AuditFileRename, Vault: "teams/1", Path: "notes/a.md", OldPath: "projects/a.md"}, true},
+		{"no path", Event{Type: models.AuditMemberJoined}, true},
+	}
+	for _, tt := range tests {
+		if got := Matches(h, tt.e); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := Backoff(20); got != time.Hour {
+		t.Errorf("Backoff(20) = %v, want the one hour cap", got)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	db := dbx.SetupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var received []*http.Request
+	var bodies [][]byte
+	status := http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	h, _ := db.CreateWebhook(ctx, &models.Webhook{
+		URL:      srv.URL,
+		Secret:   "s3cret",
+		Events:   models.StringList{models.AuditFileWrite},
+		Paths:    models.StringList{"projects/**"},
+		IsActive: true,
+	})
+
+	actx := audit.WithActor(ctx, audit.Actor{UserID: 7, Email: "ada@example.com", IP: "10.0.0.1"})
+	Notify(actx, db, Event{Type: models.AuditFileWrite, Vault: "users/7", Path: "projects/plan.md"})
+	Notify(actx, db, Event{Type: models.AuditFileWrite, Vault: "users/7", Path: "notes/other.md"})
+	Notify(actx, db, Event{Type: models.AuditFileDelete, Vault: "users/7", Path: "projects/plan.md"})
+
+	now := time.Now()
+	d := NewDeliverer(db, 0)
+	d.now = func() time.Time { return now }
+
+	if n, err := d.DeliverDue(ctx); err != nil || n != 1 {
+		t.Fatalf("DeliverDue() = %d, %v; want the one matching delivery", n, err)
+	}
+	r, body := received[0], bodies[0]
+	if r.Header.Get("X-Dz-Event") != models.AuditFileWrite || r.Header.Get("X-Dz-Signature") != Sign("s3cret", body) {
+		t.Errorf("headers = %v", r.Header)
+	}
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil || p.Path != "projects/plan.md" || p.Vault != "users/7" || p.Actor == nil || p.Actor.ID != 7 {
+		t.Errorf("payload = %s", body)
+	}
+
+	deliveries, _ := db.GetWebhookDeliveries(ctx, h.ID, 10)
+	dl := deliveries[0]
+	if dl.Status != models.DeliveryPending || dl.Attempts != 1 || *dl.ResponseCode != 500 || dl.NextAttemptAt == nil {
+		t.Fatalf("after a failure delivery = %+v, want a retry", dl)
+	}
+	// Not retried before its backoff
+	if n, _ := d.DeliverDue(ctx); n != 0 {
+		t.Errorf("DeliverDue() before backoff tried %d", n)
+	}
+
+	status = http.StatusNoContent
+	now = now.Add(Backoff(1) + time.Second)
+	if n, _ := d.DeliverDue(ctx); n != 1 {
+		t.Errorf("DeliverDue() after backoff tried %d, want 1", n)
+	}
+	got, _ := db.GetWebhookDelivery(ctx, dl.ID)
+	if got.Status != models.DeliveryDelivered || got.Attempts != 2 || got.DeliveredAt == nil || got.Error != "" {
+		t.Errorf("after success delivery = %+v", got)
+	}
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		status = http.StatusBadGateway
+		Notify(ctx, db, Event{Type: models.AuditFileWrite, Path: "projects/x.md"})
+		for i := 0; i < MaxAttempts+2; i++ {
+			d.DeliverDue(ctx)
+			now = now.Add(time.Hour + time.Second)
+		}
+		deliveries, _ := db.GetWebhookDeliveries(ctx, h.ID, 1)
+		if dl := deliveries[0]; dl.Status != models.DeliveryFailed || dl.Attempts != MaxAttempts || dl.NextAttemptAt != nil {
+			t.Errorf("delivery = %+v, want failed after %d attempts", dl, MaxAttempts)
+		}
+	})
+}
@@ -5,7 +5,7 @@ import { marked } from "marked";
 import hljs from "highlight.js";
 import "highlight.js/styles/github-dark.css";
 import yaml from "js-yaml";
-import { api, type Entry } from "./server/api";
+import { api, csrfToken, type Entry, type ImportPolicy, type ImportResult } from "./server/api";
 import { AppProvider, useApp, type FileStoreEntry } from "./context/AppContext";
 import { TabBar } from "./components/TabBar";
 import { MarkdownToolbar } from "./components/MarkdownToolbar";
@@ -995,148 +995,33 @@ export function createVaultManifest(name: string): VaultManifest {
 ======================= */
 
 /**
- * Exports entire vault as a zip file
- * Preserves IDs, paths, hashes, and structure
+ * Downloads the vault as a zip with its manifest and file hashes. The
+ * server streams it, so large vaults don't have to fit in the tab.
  */
-export async function exportVault(
-	vaultName: string,
-	entries: Entry[],
-	api: { readFile: (path: string) => Promise<{ content: string; sha256: string }> },
-	notesIndex: Record<string, NoteMetadata>
-): Promise<Blob> {
-	const JSZip = (await import('jszip')).default;
-	const zip = new JSZip();
-	
-	// Create .deez directory
-	const deezDir = zip.folder('.deez')!;
-	
-	// Create and add manifest
-	const manifest = createVaultManifest(vaultName);
-	deezDir.file('manifest.json', JSON.stringify(manifest, null, 2));
-	
-	// Add cached index
-	deezDir.file('index.json', JSON.stringify(notesIndex, null, 2));
-	
-	// Export all files with their content
-	for (const entry of entries) {
-		if (entry.kind === 'file') {
-			try {
-				const { content } = await api.readFile(entry.path);
-				zip.file(entry.path, content);
-			} catch (e) {
-				console.error(`Failed to read ${entry.path}:`, e);
-			}
-		}
-	}
-	
-	// Generate zip blob
-	return await zip.generateAsync({ type: 'blob' });
+export function exportVault(): void {
+	const a = document.createElement('a');
+	a.href = api.vaultExportUrl();
+	a.click();
 }
 
 /**
- * Imports a vault from a zip file
- * Validates structure and frontmatter before importing
+ * Imports a vault zip on the server, which verifies its hashes and refuses
+ * archives with paths outside the vault. Files that exist with other
+ * content are handled by policy.
  */
 export async function importVault(
 	zipBlob: Blob,
-	api: {
-		createFile: (path: string, content: string) => Promise<any>;
-		createFolder: (path: string) => Promise<any>;
-	}
-): Promise<{ success: boolean; errors: string[]; manifest: VaultManifest | null }> {
-	const JSZip = (await import('jszip')).default;
-	const errors: string[] = [];
-	
+	policy: ImportPolicy,
+	dryRun = false
+): Promise<{ success: boolean; errors: string[]; result: ImportResult | null }> {
 	try {
-		const zip = await JSZip.loadAsync(zipBlob);
-		
-		// Read and validate manifest
-		const manifestFile = zip.file('.deez/manifest.json');
-		if (!manifestFile) {
-			errors.push('Missing .deez/manifest.json - not a valid DEEZ vault');
-			return { success: false, errors, manifest: null };
-		}
-		
-		const manifestContent = await manifestFile.async('text');
-		const manifest: VaultManifest = JSON.parse(manifestContent);
-		
-		// Validate manifest structure
-		if (!manifest.version || !manifest.id || !manifest.name) {
-			errors.push('Invalid manifest: missing required fields');
-			return { success: false, errors, manifest: null };
-		}
-		
-		// Collect all folders first
-		const folders = new Set<string>();
-		zip.forEach((relativePath, file) => {
-			if (file.dir) {
-				folders.add(relativePath.replace(/\/$/, ''));
-			} else {
-				// Extract parent folders from file paths
-				const parts = relativePath.split('/');
-				for (let i = 1; i < parts.length; i++) {
-					const folderPath = parts.slice(0, i).join('/');
-					if (folderPath && !folderPath.startsWith('.deez')) {
-						folders.add(folderPath);
-					}
-				}
-			}
-		});
-		
-		// Create folders
-		for (const folder of Array.from(folders).sort()) {
-			if (!folder.startsWith('.deez')) {
-				try {
-					await api.createFolder(folder);
-				} catch (e) {
-					// Folder might already exist, continue
-					console.warn(`Folder creation warning for ${folder}:`, e);
-				}
-			}
-		}
-		
-		// Import all markdown files
-		let fileCount = 0;
-		const filePromises: Promise<void>[] = [];
-		
-		zip.forEach((relativePath, file) => {
-			if (!file.dir && !relativePath.startsWith('.deez/') && relativePath.endsWith('.md')) {
-				filePromises.push(
-					(async () => {
-						try {
-							const content = await file.async('text');
-							
-							// Validate frontmatter
-							const parsed = parseFrontmatter(content);
-							const validation = validateNoteFrontmatter(parsed.frontmatter, relativePath);
-							
-							if (!validation.valid) {
-								errors.push(...validation.errors);
-								return;
-							}
-							
-							await api.createFile(relativePath, content);
-							fileCount++;
-						} catch (e) {
-							errors.push(`Failed to import ${relativePath}: ${e}`);
-						}
-					})()
-				);
-			}
-		});
-		
-		await Promise.all(filePromises);
-		
-		console.log(`Imported ${fileCount} files from vault: ${manifest.name}`);
-		
-		return {
-			success: errors.length === 0,
-			errors,
-			manifest
-		};
+		const result = await api.importVault(zipBlob, policy, dryRun);
+		const errors = result.files
+			.filter((f) => f.action === 'failed')
+			.map((f) => `${f.path}: ${f.error}`);
+		return { success: errors.length === 0, errors, result };
 	} catch (e) {
-		errors.push(`Failed to read zip file: ${e}`);
-		return { success: false, errors, manifest: null };
+		return { success: false, errors: [`Import failed: ${e}`], result: null };
 	}
 }
 
@@ -1210,6 +1095,11 @@ export class HttpRemoteStore implements RemoteStore {
 		if (this.authToken) {
 			headers['Authorization'] = `Bearer ${this.authToken}`;
 		}
+		if (options.method && options.method !== 'GET' && options.method !== 'HEAD') {
+			// The session cookie goes along to this server's /api/sync
+			const token = csrfToken();
+			if (token) headers['X-CSRF-Token'] = token;
+		}
 		
 		const response = await fetch(`${this.baseUrl}${path}`, {
 			...options,
@@ -1360,6 +1250,104 @@ export class MemoryRemoteStore implements RemoteStore {
 	}
 }
 
+/**
+ * Remote store for zero-knowledge sync servers: paths and contents are
+ * encrypted with VaultEncryption before they leave the client, so the
+ * server only ever holds ciphertext. Hashes are those of the ciphertext,
+ * which is what the server checks If-Match against.
+ */
+export class EncryptedRemoteStore implements RemoteStore {
+	name: string;
+	private inner: RemoteStore;
+	private key: VaultKey;
+	// Encryption is randomized, so a path keeps the encrypted name it was
+	// first stored under
+	private paths = new Map<string, string>();
+	private loaded?: Promise<RemoteIndex>;
+	
+	constructor(inner: RemoteStore, key: VaultKey) {
+		this.name = `${inner.name} (encrypted)`;
+		this.inner = inner;
+		this.key = key;
+	}
+	
+	async getIndex(): Promise<RemoteIndex> {
+		this.loaded = this.loadIndex();
+		return this.loaded;
+	}
+	
+	private async loadIndex(): Promise<RemoteIndex> {
+		const index = await this.inner.getIndex();
+		const files: RemoteFileInfo[] = [];
+		this.paths.clear();
+		for (const file of index.files) {
+			try {
+				const path = await VaultEncryption.decrypt(file.path, this.key);
+				this.paths.set(path, file.path);
+				files.push({ ...file, path });
+			} catch {
+				// Stored with another key
+			}
+		}
+		return { ...index, files };
+	}
+	
+	private async remotePath(path: string, create = false): Promise<string> {
+		if (!this.loaded) this.loaded = this.loadIndex();
+		await this.loaded;
+		let remote = this.paths.get(path);
+		if (!remote && create) {
+			remote = await VaultEncryption.encrypt(path, this.key);
+			this.paths.set(path, remote);
+		}
+		if (!remote) throw new Error(`File not found: ${path}`);
+		return remote;
+	}
+	
+	async readFile(path: string): Promise<{ content: string; hash: string }> {
+		const { content, hash } = await this.inner.readFile(await this.remotePath(path));
+		return { content: await VaultEncryption.decrypt(content, this.key), hash: unquoteETag(hash) };
+	}
+	
+	async fileExists(path: string): Promise<boolean> {
+		try {
+			await this.remotePath(path);
+			return true;
+		} catch {
+			return false;
+		}
+	}
+	
+	async writeFile(path: string, content: string, previousHash?: string): Promise<{ hash: string }> {
+		const remote = await this.remotePath(path, true);
+		const sealed = await VaultEncryption.encrypt(content, this.key);
+		const { hash } = await this.inner.writeFile(remote, sealed, previousHash);
+		return { hash: unquoteETag(hash) };
+	}
+	
+	async deleteFile(path: string): Promise<void> {
+		await this.inner.deleteFile(await this.remotePath(path));
+		this.paths.delete(path);
+	}
+	
+	async uploadFiles(files: Array<{ path: string; content: string }>): Promise<void> {
+		await Promise.all(files.map(f => this.writeFile(f.path, f.content)));
+	}
+	
+	async downloadFiles(paths: string[]): Promise<Array<{ path: string; content: string; hash: string }>> {
+		return Promise.all(paths.map(async (path) => ({ path, ...(await this.readFile(path)) })));
+	}
+	
+	async pushIndex(_index: RemoteIndex): Promise<void> {
+		// The server keeps the index of the blobs it holds; pushing the
+		// client's would reveal its paths
+	}
+}
+
+function unquoteETag(etag: string): string {
+	return etag.replace(/^(W\/)?"|"$/g, '');
+}
+
 /* =======================
    Sync Operations (Single-Writer)
 ======================= */
@@ -4364,24 +4352,8 @@ const HomeInner = (props?: HomeProps) => {
 
 	const collapseAll = () => setOpenFolders(new Set<string>());
 
-	const handleExportVault = async () => {
-		try {
-			const vaultName = prompt("Vault name:", "deez-vault") || "deez-vault";
-			const blob = await exportVault(vaultName, entries() ?? [], api, notesIndex);
-			
-			// Trigger download
-			const url = URL.createObjectURL(blob);
-			const a = document.createElement('a');
-			a.href = url;
-			a.download = `${vaultName}-${new Date().toISOString().split('T')[0]}.zip`;
-			a.click();
-			URL.revokeObjectURL(url);
-			
-			console.log(`Exported vault: ${vaultName}`);
-		} catch (e) {
-			console.error("Export failed:", e);
-			alert(`Export failed: ${e}`);
-		}
+	const handleExportVault = () => {
+		exportVault();
 	};
 
 	const handleImportVault = async () => {
@@ -4395,22 +4367,37 @@ const HomeInner = (props?: HomeProps) => {
 				const file = (e.target as HTMLInputElement).files?.[0];
 				if (!file) return;
 				
-				const confirmed = confirm(
-					`Import vault from "${file.name}"?\n\nThis will add all files from the archive to your current vault.`
-				);
-				if (!confirmed) return;
-				
-				const result = await importVault(file, api);
+				// Dry run first to show what would collide
+				const preview = await importVault(file, 'skip', true);
+				if (!preview.result) {
+					alert(preview.errors.join('\n'));
+					return;
+				}
+				const collisions = preview.result.files.filter((f) => f.action === 'skipped').length;
+				let policy: ImportPolicy = 'skip';
+				if (collisions > 0) {
+					const answer = prompt(
+						`${collisions} file(s) in "${file.name}" differ from your vault.\n\nType skip, overwrite or merge (keep both):`,
+						'skip'
+					);
+					if (answer === null) return;
+					if (answer !== 'skip' && answer !== 'overwrite' && answer !== 'merge') {
+						alert(`Unknown choice: ${answer}`);
+						return;
+					}
+					policy = answer;
+				} else if (!confirm(`Import vault "${preview.result.manifest.name}" from "${file.name}"?`)) {
+					return;
+				}
 				
+				const result = await importVault(file, policy);
 				if (result.success) {
-					alert(`Successfully imported vault: ${result.manifest?.name}\n\nReloading...`);
-					await refetchTree();
-					triggerIndexRebuild();
+					alert(`Successfully imported vault: ${result.result?.manifest.name}\n\nReloading...`);
 				} else {
 					alert(`Import completed with errors:\n\n${result.errors.join('\n')}`);
-					await refetchTree();
-					triggerIndexRebuild();
 				}
+				await refetchTree();
+				triggerIndexRebuild();
 			};
 			
 			input.click();
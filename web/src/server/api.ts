@@ -8,6 +8,8 @@ const routes = {
 	createFolder: "/api/folder",
 	createFile: "/api/file",
 	tree: "/api/tree",
+	vaultExport: "/api/vault/export",
+	vaultImport: "/api/vault/import",
 } as const;
 
 const methods = {
@@ -34,6 +36,18 @@ function withQuery(url: string, query?: RequestOptions["query"]) {
 	return u.pathname + u.search;
 }
 
+// The server mirrors the session's CSRF token into a readable cookie;
+// state-changing requests must echo it back in a header.
+export function csrfToken(): string | undefined {
+	for (const part of document.cookie.split("; ")) {
+		const [name, ...rest] = part.split("=");
+		if (name === "csrf_token" || name === "__Host-csrf_token") {
+			return decodeURIComponent(rest.join("="));
+		}
+	}
+	return undefined;
+}
+
 async function requestJSON<TResponse, TBody = unknown>(
 	url: string,
 	opts: RequestOptions<TBody> = {}
@@ -45,8 +59,16 @@ async function requestJSON<TResponse, TBody = unknown>(
 		...(opts.headers ?? {}),
 	};
 
+	if (method !== methods.GET) {
+		const token = csrfToken();
+		if (token) headers["X-CSRF-Token"] ??= token;
+	}
+
 	let body: BodyInit | undefined;
-	if (opts.body !== undefined) {
+	if (opts.body instanceof Blob) {
+		headers["Content-Type"] ??= opts.body.type || "application/octet-stream";
+		body = opts.body;
+	} else if (opts.body !== undefined) {
 		headers["Content-Type"] ??= "application/json";
 		body = JSON.stringify(opts.body);
 	}
@@ -86,6 +108,21 @@ export type Entry = {
   size?: number;
 };
 
+export type ImportPolicy = "skip" | "overwrite" | "merge";
+export type ImportedFile = {
+	path: string;
+	action: "created" | "unchanged" | "skipped" | "overwritten" | "merged" | "failed";
+	written_to?: string;
+	error?: string;
+};
+export type ImportResult = {
+	manifest: { version: string; id: string; name: string };
+	verified: boolean;
+	dry_run: boolean;
+	summary: Partial<Record<ImportedFile["action"], number>>;
+	files: ImportedFile[];
+};
+
 export const api = {
 	getInfo: () => requestJSON<Info>(routes.info),
 	getHealth: () => requestJSON<Health>(routes.health),
@@ -113,4 +150,12 @@ export const api = {
 			body: { oldPath, newPath },
 		}),
     listTree: () => requestJSON<Entry[]>(routes.tree),
-};
\ No newline at end of file
+	// The export streams from the server; link to it rather than fetching
+	vaultExportUrl: () => routes.vaultExport,
+	importVault: (archive: Blob, policy: ImportPolicy, dryRun = false) =>
+		requestJSON<ImportResult, Blob>(routes.vaultImport, {
+			method: "POST",
+			query: { policy, dry_run: dryRun || undefined },
+			body: archive,
+		}),
+};